@@ -0,0 +1,94 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of go-ruereum.
+//
+// go-ruereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ruereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ruereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/Rue-Foundation/go-rue/p2p/dnsdisc"
+	"github.com/Rue-Foundation/go-rue/p2p/enr"
+)
+
+// crawledNode is an entry of a crawl result. Only the node record is used to
+// build the DNS tree, other fields of the crawl result are ignored.
+type crawledNode struct {
+	Record string `json:"record"`
+}
+
+// loadCrawlResult reads the signed node records of a crawl result as written by
+// "devp2p crawl", which is a JSON object of crawled nodes keyed by node ID.
+func loadCrawlResult(file string) ([]*enr.Record, error) {
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var nodes map[string]crawledNode
+	if err := json.Unmarshal(blob, &nodes); err != nil {
+		return nil, fmt.Errorf("invalid crawl result: %v", err)
+	}
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	records := make([]*enr.Record, 0, len(nodes))
+	for _, id := range ids {
+		if nodes[id].Record == "" {
+			continue // node didn't provide a record, skip
+		}
+		record, err := dnsdisc.ParseRecord(nodes[id].Record)
+		if err != nil {
+			return nil, fmt.Errorf("invalid record of node %s: %v", id, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// signTree builds a DNS node tree from the given crawl result, signs it with
+// the key and writes the TXT records to publish at the domain as JSON.
+func signTree(key *ecdsa.PrivateKey, crawl, domain string, seq uint, links []string, out string) error {
+	records, err := loadCrawlResult(crawl)
+	if err != nil {
+		return err
+	}
+	tree, err := dnsdisc.MakeTree(seq, records, links)
+	if err != nil {
+		return err
+	}
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		return err
+	}
+	blob, err := json.MarshalIndent(tree.ToTXT(domain), "", "  ")
+	if err != nil {
+		return err
+	}
+	if out == "" || out == "-" {
+		os.Stdout.Write(append(blob, '\n'))
+	} else if err := ioutil.WriteFile(out, blob, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Signed tree with %d nodes, URL: %s\n", len(records), url)
+	return nil
+}
@@ -22,6 +22,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/Rue-Foundation/go-rue/cmd/utils"
 	"github.com/Rue-Foundation/go-rue/crypto"
@@ -42,6 +43,11 @@ func main() {
 		natdesc     = flag.String("nat", "none", "port mapping mechanism (any|none|upnp|pmp|extip:<IP>)")
 		netrestrict = flag.String("netrestrict", "", "restrict network communication to the given IP networks (CIDR masks)")
 		runv5       = flag.Bool("v5", false, "run a v5 topic discovery bootnode")
+		dnsSign     = flag.String("dnssign", "", "sign a DNS node tree built from the given crawl result and quit")
+		dnsDomain   = flag.String("dnsdomain", "", "domain the signed DNS node tree is published at")
+		dnsSeq      = flag.Uint("dnsseq", 1, "sequence number of the signed DNS node tree")
+		dnsLinks    = flag.String("dnslinks", "", "comma separated enrtree:// URLs of other trees to link")
+		dnsOut      = flag.String("dnsout", "", "output file for the TXT records of the signed tree (default stdout)")
 		verbosity   = flag.Int("verbosity", int(log.LvlInfo), "log verbosity (0-9)")
 		vmodule     = flag.String("vmodule", "", "log verbosity pattern")
 
@@ -87,6 +93,19 @@ func main() {
 		fmt.Printf("%v\n", discover.PubkeyID(&nodeKey.PublicKey))
 		os.Exit(0)
 	}
+	if *dnsSign != "" {
+		if *dnsDomain == "" {
+			utils.Fatalf("Use -dnsdomain to specify the domain of the DNS node tree")
+		}
+		var links []string
+		if *dnsLinks != "" {
+			links = strings.Split(*dnsLinks, ",")
+		}
+		if err := signTree(nodeKey, *dnsSign, *dnsDomain, *dnsSeq, links, *dnsOut); err != nil {
+			utils.Fatalf("-dnssign: %v", err)
+		}
+		os.Exit(0)
+	}
 
 	var restrictList *netutil.Netlist
 	if *netrestrict != "" {
@@ -0,0 +1,338 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of go-ruereum.
+//
+// go-ruereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ruereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ruereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/core/forkid"
+	"github.com/Rue-Foundation/go-rue/log"
+	"github.com/Rue-Foundation/go-rue/p2p"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/p2p/enr"
+	"github.com/Rue-Foundation/go-rue/rlp"
+)
+
+const (
+	dialTimeout   = 10 * time.Second // timeout of the TCP connection attempt
+	statusTimeout = 10 * time.Second // time to wait for the rue status after connecting
+
+	rueStatusMsg = 0x00 // message code of the rue status message
+)
+
+// rueVersions are the rue protocol versions the crawler negotiates, mirroring
+// the versions implemented by the rue package.
+var rueVersions = []uint{64, 63, 62}
+
+var (
+	errNoStatus    = errors.New("no rue status received")
+	errCheckFailed = errors.New("node check timed out")
+)
+
+// statusLegacy is the rue status message of protocol versions 62 and 63.
+type statusLegacy struct {
+	ProtocolVersion uint32
+	NetworkID       uint64
+	TD              *big.Int
+	Head            common.Hash
+	Genesis         common.Hash
+}
+
+// status64 is the rue status message of protocol version 64, which additionally
+// carries the fork identifier of the node.
+type status64 struct {
+	ProtocolVersion uint32
+	NetworkID       uint64
+	TD              *big.Int
+	Head            common.Hash
+	Genesis         common.Hash
+	ForkID          forkid.ID
+}
+
+// checkResult is the outcome of connecting to a single node.
+type checkResult struct {
+	name   string
+	caps   []string
+	status *rueStatus
+	err    error
+}
+
+// crawler walks the discovery DHT and checks every found node by connecting to
+// it and reading its rue status.
+type crawler struct {
+	input    nodeSet
+	output   nodeSet
+	tab      *discover.Table
+	srv      *p2p.Server
+	recheck  time.Duration // minimum time between two checks of the same node
+	workers  int
+	lock     sync.Mutex
+	inflight map[discover.NodeID]chan *checkResult
+}
+
+// newCrawler creates a crawler continuing from the given previous census.
+func newCrawler(input nodeSet, key *ecdsa.PrivateKey, tab *discover.Table, recheck time.Duration, workers int) (*crawler, error) {
+	c := &crawler{
+		input:    input,
+		output:   make(nodeSet, len(input)),
+		tab:      tab,
+		recheck:  recheck,
+		workers:  workers,
+		inflight: make(map[discover.NodeID]chan *checkResult),
+	}
+	c.srv = &p2p.Server{Config: p2p.Config{
+		PrivateKey:  key,
+		MaxPeers:    workers * 2,
+		NoDiscovery: true,
+		NoDial:      true,
+		Name:        "devp2p-crawler",
+		Logger:      log.Root(),
+	}}
+	for _, version := range rueVersions {
+		c.srv.Protocols = append(c.srv.Protocols, p2p.Protocol{
+			Name:    "eth",
+			Version: version,
+			Length:  17,
+			Run:     c.runProtocol(version),
+		})
+	}
+	if err := c.srv.Start(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// run crawls the network until the timeout expires and returns the census.
+func (c *crawler) run(timeout time.Duration) nodeSet {
+	var (
+		deadline = time.After(timeout)
+		quit     = make(chan struct{})
+		nodes    = make(chan *discover.Node)
+		wg       sync.WaitGroup
+	)
+	defer c.srv.Stop()
+
+	// Feed the nodes of the previous census first, then keep running lookups.
+	go func() {
+		for _, n := range c.input {
+			select {
+			case nodes <- n.N:
+			case <-quit:
+				return
+			}
+		}
+		for {
+			var target discover.NodeID
+			rand.Read(target[:])
+			results := c.tab.Lookup(target)
+			for _, n := range results {
+				select {
+				case nodes <- n:
+				case <-quit:
+					return
+				}
+			}
+			// Avoid spinning while the table is still empty.
+			if len(results) == 0 {
+				select {
+				case <-time.After(time.Second):
+				case <-quit:
+					return
+				}
+			}
+		}
+	}()
+	for i := 0; i < c.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case n := <-nodes:
+					c.updateNode(n)
+				case <-quit:
+					return
+				}
+			}
+		}()
+	}
+	status := time.NewTicker(10 * time.Second)
+	defer status.Stop()
+loop:
+	for {
+		select {
+		case <-status.C:
+			c.lock.Lock()
+			log.Info("Crawling in progress", "nodes", len(c.output))
+			c.lock.Unlock()
+		case <-deadline:
+			break loop
+		}
+	}
+	close(quit)
+	wg.Wait()
+
+	// Carry over the nodes of the previous census that weren't seen this time.
+	for id, n := range c.input {
+		if _, ok := c.output[id]; !ok {
+			c.output[id] = n
+		}
+	}
+	return c.output
+}
+
+// updateNode checks a single node unless it was already processed during this
+// crawl or checked recently, and stores the result in the output census.
+func (c *crawler) updateNode(n *discover.Node) {
+	c.lock.Lock()
+	if _, ok := c.output[n.ID]; ok {
+		c.lock.Unlock()
+		return
+	}
+	entry, known := c.input[n.ID]
+	if known && time.Since(entry.LastCheck) < c.recheck {
+		c.output[n.ID] = entry
+		c.lock.Unlock()
+		return
+	}
+	c.output[n.ID] = entry // reserve, so concurrent workers skip the node
+	c.lock.Unlock()
+
+	// Update the endpoint and the node record.
+	if entry.N == nil || !n.Incomplete() {
+		entry.N = n
+	}
+	if r := c.tab.NodeRecord(n.ID); r != nil && r.Seq() > entry.Seq {
+		entry.Seq, entry.Record = r.Seq(), encodeRecord(r)
+	}
+	// Connect and record the announced details.
+	res := c.check(entry.N)
+	entry.LastCheck = time.Now()
+	if res.name != "" {
+		entry.Name, entry.Caps = res.name, res.caps
+	}
+	if res.err != nil {
+		entry.LastError = res.err.Error()
+		log.Debug("Node check failed", "id", n.ID, "err", res.err)
+	} else {
+		if entry.FirstResponse.IsZero() {
+			entry.FirstResponse = entry.LastCheck
+		}
+		entry.LastResponse = entry.LastCheck
+		entry.LastError = ""
+		entry.Rue = res.status
+		log.Debug("Checked node", "id", n.ID, "name", res.name, "network", res.status.NetworkID)
+	}
+	c.lock.Lock()
+	c.output[n.ID] = entry
+	c.lock.Unlock()
+}
+
+// check connects to the node over RLPx and waits for its rue status.
+func (c *crawler) check(n *discover.Node) *checkResult {
+	if n.Incomplete() || n.TCP == 0 {
+		return &checkResult{err: errors.New("no TCP endpoint")}
+	}
+	resc := make(chan *checkResult, 1)
+	c.lock.Lock()
+	c.inflight[n.ID] = resc
+	c.lock.Unlock()
+	defer func() {
+		c.lock.Lock()
+		delete(c.inflight, n.ID)
+		c.lock.Unlock()
+	}()
+
+	addr := &net.TCPAddr{IP: n.IP, Port: int(n.TCP)}
+	fd, err := net.DialTimeout("tcp", addr.String(), dialTimeout)
+	if err != nil {
+		return &checkResult{err: err}
+	}
+	if err := c.srv.SetupConn(fd, 0, n); err != nil {
+		return &checkResult{err: err}
+	}
+	select {
+	case res := <-resc:
+		return res
+	case <-time.After(statusTimeout):
+		c.srv.RemovePeer(n)
+		return &checkResult{err: errCheckFailed}
+	}
+}
+
+// runProtocol returns the run function of the given rue protocol version, which
+// reads the status of the remote node and disconnects.
+func (c *crawler) runProtocol(version uint) func(*p2p.Peer, p2p.MsgReadWriter) error {
+	return func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+		res := &checkResult{name: p.Name()}
+		for _, cap := range p.Caps() {
+			res.caps = append(res.caps, cap.String())
+		}
+		res.status, res.err = readStatus(rw, version)
+
+		c.lock.Lock()
+		resc := c.inflight[p.ID()]
+		c.lock.Unlock()
+		if resc != nil {
+			resc <- res
+		}
+		return p2p.DiscRequested
+	}
+}
+
+// readStatus reads the rue status message of the given protocol version.
+func readStatus(rw p2p.MsgReadWriter, version uint) (*rueStatus, error) {
+	msg, err := rw.ReadMsg()
+	if err != nil {
+		return nil, err
+	}
+	defer msg.Discard()
+
+	if msg.Code != rueStatusMsg {
+		return nil, errNoStatus
+	}
+	if version >= 64 {
+		var status status64
+		if err := msg.Decode(&status); err != nil {
+			return nil, fmt.Errorf("invalid status: %v", err)
+		}
+		return &rueStatus{status.ProtocolVersion, status.NetworkID, status.TD, status.Head, status.Genesis}, nil
+	}
+	var status statusLegacy
+	if err := msg.Decode(&status); err != nil {
+		return nil, fmt.Errorf("invalid status: %v", err)
+	}
+	return &rueStatus{status.ProtocolVersion, status.NetworkID, status.TD, status.Head, status.Genesis}, nil
+}
+
+// encodeRecord returns the textual representation of a node record.
+func encodeRecord(r *enr.Record) string {
+	blob, err := rlp.EncodeToBytes(r)
+	if err != nil {
+		return ""
+	}
+	return "enr:" + base64.RawURLEncoding.EncodeToString(blob)
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of go-ruereum.
+//
+// go-ruereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ruereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ruereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"math/big"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/crypto"
+	"github.com/Rue-Foundation/go-rue/p2p"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/params"
+)
+
+// startTestNode starts a p2p server speaking the given rue protocol version,
+// announcing a fixed status on every connection.
+func startTestNode(t *testing.T, version uint, status interface{}) *p2p.Server {
+	key, _ := crypto.GenerateKey()
+	srv := &p2p.Server{Config: p2p.Config{
+		PrivateKey:  key,
+		MaxPeers:    10,
+		NoDiscovery: true,
+		ListenAddr:  "127.0.0.1:0",
+		Name:        "test/v1.0.0",
+		Protocols: []p2p.Protocol{{
+			Name:    "eth",
+			Version: version,
+			Length:  17,
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				if err := p2p.Send(rw, rueStatusMsg, status); err != nil {
+					return err
+				}
+				_, err := rw.ReadMsg()
+				return err
+			},
+		}},
+	}}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("can't start test node: %v", err)
+	}
+	return srv
+}
+
+func testNodeOf(srv *p2p.Server) *discover.Node {
+	addr := srv.ListenAddr
+	tcp, _ := net.ResolveTCPAddr("tcp", addr)
+	return discover.NewNode(srv.Self().ID, tcp.IP, uint16(tcp.Port), uint16(tcp.Port))
+}
+
+func TestCrawlerCheck(t *testing.T) {
+	var (
+		head    = common.Hash{1}
+		genesis = params.MainnetGenesisHash
+	)
+	tests := []struct {
+		version uint
+		status  interface{}
+	}{
+		{63, &statusLegacy{63, 1, big.NewInt(100), head, genesis}},
+		{64, &status64{ProtocolVersion: 64, NetworkID: 1, TD: big.NewInt(100), Head: head, Genesis: genesis}},
+	}
+	for _, test := range tests {
+		node := startTestNode(t, test.version, test.status)
+		defer node.Stop()
+
+		key, _ := crypto.GenerateKey()
+		c, err := newCrawler(make(nodeSet), key, nil, time.Hour, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.srv.Stop()
+
+		res := c.check(testNodeOf(node))
+		if res.err != nil {
+			t.Fatalf("eth/%d: check failed: %v", test.version, res.err)
+		}
+		want := &rueStatus{uint32(test.version), 1, big.NewInt(100), head, genesis}
+		if !reflect.DeepEqual(res.status, want) {
+			t.Errorf("eth/%d: status mismatch:\ngot:  %+v\nwant: %+v", test.version, res.status, want)
+		}
+		if res.name != "test/v1.0.0" {
+			t.Errorf("eth/%d: wrong client name %q", test.version, res.name)
+		}
+		if wantCaps := []string{p2p.Cap{Name: "eth", Version: test.version}.String()}; !reflect.DeepEqual(res.caps, wantCaps) {
+			t.Errorf("eth/%d: wrong caps %v, want %v", test.version, res.caps, wantCaps)
+		}
+	}
+}
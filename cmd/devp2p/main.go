@@ -0,0 +1,254 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of go-ruereum.
+//
+// go-ruereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ruereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ruereum. If not, see <http://www.gnu.org/licenses/>.
+
+// devp2p is a tool for inspecting the Ruereum peer-to-peer network.
+//
+// The crawl command walks the discovery DHT, connects to every found node and
+// records its client name, capabilities and rue status in a JSON node set:
+//
+//	$ devp2p crawl nodes.json
+//
+// Running the crawl again with the same file continues from the previous result,
+// only re-checking nodes that weren't checked recently. The filter command
+// selects nodes from a node set:
+//
+//	$ devp2p filter -mainnet -seen 24h nodes.json
+package main
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/crypto"
+	"github.com/Rue-Foundation/go-rue/log"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	bootnodesFlag = cli.StringFlag{
+		Name:  "bootnodes",
+		Usage: "Comma separated enode URLs for discovery bootstrap (defaults to the mainnet bootnodes)",
+	}
+	listenAddrFlag = cli.StringFlag{
+		Name:  "addr",
+		Usage: "UDP listen address of the discovery protocol",
+		Value: ":0",
+	}
+	nodeKeyFlag = cli.StringFlag{
+		Name:  "nodekey",
+		Usage: "Private key file of the crawler (a random key is used if not set)",
+	}
+	timeoutFlag = cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "Time limit of the crawl",
+		Value: 30 * time.Minute,
+	}
+	recheckFlag = cli.DurationFlag{
+		Name:  "recheck",
+		Usage: "Minimum time before a node of the previous crawl result is checked again",
+		Value: time.Hour,
+	}
+	workersFlag = cli.IntFlag{
+		Name:  "workers",
+		Usage: "Number of nodes checked concurrently",
+		Value: 16,
+	}
+	verbosityFlag = cli.IntFlag{
+		Name:  "verbosity",
+		Usage: "Logging verbosity (0-9)",
+		Value: int(log.LvlInfo),
+	}
+
+	networkFlag = cli.Uint64Flag{
+		Name:  "network",
+		Usage: "Only keep nodes on the given network ID",
+	}
+	genesisFlag = cli.StringFlag{
+		Name:  "genesis",
+		Usage: "Only keep nodes with the given genesis block hash",
+	}
+	mainnetFlag = cli.BoolFlag{
+		Name:  "mainnet",
+		Usage: "Only keep Rue mainnet nodes",
+	}
+	seenFlag = cli.DurationFlag{
+		Name:  "seen",
+		Usage: "Only keep nodes that responded within the given time",
+	}
+	clientFlag = cli.StringFlag{
+		Name:  "client",
+		Usage: "Only keep nodes whose client name contains the given string",
+	}
+	capFlag = cli.StringFlag{
+		Name:  "cap",
+		Usage: "Only keep nodes supporting the given capability (e.g. eth/63)",
+	}
+	outFlag = cli.StringFlag{
+		Name:  "out",
+		Usage: "Output file of the filtered node set",
+		Value: "-",
+	}
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Usage = "go-ruereum peer-to-peer network tool"
+	app.Commands = []cli.Command{
+		{
+			Name:      "crawl",
+			Usage:     "crawl the network and update a node set",
+			ArgsUsage: "<nodes.json>",
+			Action:    crawlNodes,
+			Flags: []cli.Flag{
+				bootnodesFlag,
+				listenAddrFlag,
+				nodeKeyFlag,
+				timeoutFlag,
+				recheckFlag,
+				workersFlag,
+				verbosityFlag,
+			},
+		},
+		{
+			Name:      "filter",
+			Usage:     "select nodes from a node set",
+			ArgsUsage: "<nodes.json>",
+			Action:    filterNodes,
+			Flags: []cli.Flag{
+				networkFlag,
+				genesisFlag,
+				mainnetFlag,
+				seenFlag,
+				clientFlag,
+				capFlag,
+				outFlag,
+			},
+		},
+	}
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// crawlNodes runs the crawl command, continuing from and updating the given
+// node set file.
+func crawlNodes(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("need node set file as argument")
+	}
+	file := ctx.Args().First()
+
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.Int(verbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+
+	input, err := loadNodesJSON(file)
+	if err != nil {
+		return err
+	}
+	key, err := crawlerKey(ctx)
+	if err != nil {
+		return err
+	}
+	bootnodes, err := parseBootnodes(ctx)
+	if err != nil {
+		return err
+	}
+	tab, err := discover.ListenUDP(key, ctx.String(listenAddrFlag.Name), nil, "", nil)
+	if err != nil {
+		return err
+	}
+	defer tab.Close()
+
+	// Bootstrap from the nodes of the previous crawl as well.
+	for _, n := range input {
+		bootnodes = append(bootnodes, n.N)
+	}
+	if err := tab.SetFallbackNodes(bootnodes); err != nil {
+		return err
+	}
+	c, err := newCrawler(input, key, tab, ctx.Duration(recheckFlag.Name), ctx.Int(workersFlag.Name))
+	if err != nil {
+		return err
+	}
+	output := c.run(ctx.Duration(timeoutFlag.Name))
+	log.Info("Crawl finished", "nodes", len(output))
+
+	return writeNodesJSON(file, output)
+}
+
+// filterNodes runs the filter command, writing the matching nodes.
+func filterNodes(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("need node set file as argument")
+	}
+	nodes, err := loadNodesJSON(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	var filters []nodeFilter
+	if ctx.Bool(mainnetFlag.Name) {
+		filters = append(filters, networkFilter(1), genesisFilter(params.MainnetGenesisHash))
+	}
+	if ctx.IsSet(networkFlag.Name) {
+		filters = append(filters, networkFilter(ctx.Uint64(networkFlag.Name)))
+	}
+	if genesis := ctx.String(genesisFlag.Name); genesis != "" {
+		filters = append(filters, genesisFilter(common.HexToHash(genesis)))
+	}
+	if seen := ctx.Duration(seenFlag.Name); seen > 0 {
+		filters = append(filters, seenFilter(time.Now().Add(-seen)))
+	}
+	if client := ctx.String(clientFlag.Name); client != "" {
+		filters = append(filters, clientFilter(client))
+	}
+	if cap := ctx.String(capFlag.Name); cap != "" {
+		filters = append(filters, capFilter(cap))
+	}
+	return writeNodesJSON(ctx.String(outFlag.Name), nodes.filter(filters...))
+}
+
+// crawlerKey loads the configured node key, or generates a random one.
+func crawlerKey(ctx *cli.Context) (*ecdsa.PrivateKey, error) {
+	if file := ctx.String(nodeKeyFlag.Name); file != "" {
+		return crypto.LoadECDSA(file)
+	}
+	return crypto.GenerateKey()
+}
+
+// parseBootnodes returns the configured bootstrap nodes.
+func parseBootnodes(ctx *cli.Context) ([]*discover.Node, error) {
+	urls := params.MainnetBootnodes
+	if ctx.IsSet(bootnodesFlag.Name) {
+		urls = strings.Split(ctx.String(bootnodesFlag.Name), ",")
+	}
+	nodes := make([]*discover.Node, len(urls))
+	for i, url := range urls {
+		n, err := discover.ParseNode(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bootnode %q: %v", url, err)
+		}
+		nodes[i] = n
+	}
+	return nodes, nil
+}
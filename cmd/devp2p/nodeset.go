@@ -0,0 +1,147 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of go-ruereum.
+//
+// go-ruereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ruereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ruereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+)
+
+// nodeSet is the node census written by the crawler, keyed by node ID.
+type nodeSet map[discover.NodeID]nodeJSON
+
+// nodeJSON is the census entry of a single node.
+type nodeJSON struct {
+	N      *discover.Node `json:"enode"`
+	Seq    uint64         `json:"seq,omitempty"`    // sequence number of the node record
+	Record string         `json:"record,omitempty"` // signed node record in text form
+	Name   string         `json:"name,omitempty"`   // client name announced in the devp2p handshake
+	Caps   []string       `json:"caps,omitempty"`   // devp2p capabilities
+	Rue    *rueStatus     `json:"rue,omitempty"`    // status announced in the rue handshake
+
+	FirstResponse time.Time `json:"firstResponse,omitempty"`
+	LastResponse  time.Time `json:"lastResponse,omitempty"`
+	LastCheck     time.Time `json:"lastCheck,omitempty"`
+	LastError     string    `json:"lastError,omitempty"`
+}
+
+// rueStatus is the chain status a node announced in the rue handshake.
+type rueStatus struct {
+	ProtocolVersion uint32      `json:"protocolVersion"`
+	NetworkID       uint64      `json:"networkId"`
+	TD              *big.Int    `json:"td"`
+	Head            common.Hash `json:"head"`
+	Genesis         common.Hash `json:"genesis"`
+}
+
+// loadNodesJSON reads a node set from the given file. A missing file yields an
+// empty set, so that a crawl can be started from scratch.
+func loadNodesJSON(file string) (nodeSet, error) {
+	blob, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return make(nodeSet), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(nodeSet)
+	if err := json.Unmarshal(blob, &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// writeNodesJSON writes a node set to the given file, or to stdout if the file
+// name is "-".
+func writeNodesJSON(file string, nodes nodeSet) error {
+	blob, err := json.MarshalIndent(nodes, "", "  ")
+	if err != nil {
+		return err
+	}
+	blob = append(blob, '\n')
+	if file == "-" {
+		_, err := os.Stdout.Write(blob)
+		return err
+	}
+	return ioutil.WriteFile(file, blob, 0644)
+}
+
+// nodeFilter reports whether a node set entry should be kept.
+type nodeFilter func(n nodeJSON) bool
+
+// filter returns the entries of the set matching all given filters.
+func (ns nodeSet) filter(filters ...nodeFilter) nodeSet {
+	result := make(nodeSet)
+outer:
+	for id, n := range ns {
+		for _, f := range filters {
+			if !f(n) {
+				continue outer
+			}
+		}
+		result[id] = n
+	}
+	return result
+}
+
+// networkFilter keeps nodes on the given network ID.
+func networkFilter(network uint64) nodeFilter {
+	return func(n nodeJSON) bool {
+		return n.Rue != nil && n.Rue.NetworkID == network
+	}
+}
+
+// genesisFilter keeps nodes with the given genesis block.
+func genesisFilter(genesis common.Hash) nodeFilter {
+	return func(n nodeJSON) bool {
+		return n.Rue != nil && n.Rue.Genesis == genesis
+	}
+}
+
+// seenFilter keeps nodes that responded after the given time.
+func seenFilter(since time.Time) nodeFilter {
+	return func(n nodeJSON) bool {
+		return n.LastResponse.After(since)
+	}
+}
+
+// clientFilter keeps nodes whose client name contains the given string.
+func clientFilter(name string) nodeFilter {
+	name = strings.ToLower(name)
+	return func(n nodeJSON) bool {
+		return strings.Contains(strings.ToLower(n.Name), name)
+	}
+}
+
+// capFilter keeps nodes supporting the given capability, e.g. "eth/63".
+func capFilter(cap string) nodeFilter {
+	return func(n nodeJSON) bool {
+		for _, c := range n.Caps {
+			if c == cap {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of go-ruereum.
+//
+// go-ruereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ruereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ruereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/params"
+)
+
+func testNodeSet() nodeSet {
+	var (
+		now   = time.Now().Round(time.Second)
+		ids   = []discover.NodeID{{1}, {2}, {3}, {4}}
+		nodes = make(nodeSet)
+	)
+	nodes[ids[0]] = nodeJSON{
+		N:            discover.NewNode(ids[0], net.IP{10, 0, 0, 1}, 30303, 30303),
+		Name:         "Grue/v1.7.3/linux-amd64/go1.9",
+		Caps:         []string{"eth/62", "eth/63"},
+		Rue:          &rueStatus{63, 1, big.NewInt(1), common.Hash{}, params.MainnetGenesisHash},
+		LastResponse: now.Add(-time.Hour),
+	}
+	nodes[ids[1]] = nodeJSON{
+		N:            discover.NewNode(ids[1], net.IP{10, 0, 0, 2}, 30303, 30303),
+		Name:         "Grue/v1.7.3/linux-amd64/go1.9",
+		Caps:         []string{"eth/63"},
+		Rue:          &rueStatus{63, 1, big.NewInt(1), common.Hash{}, params.MainnetGenesisHash},
+		LastResponse: now.Add(-48 * time.Hour),
+	}
+	nodes[ids[2]] = nodeJSON{
+		N:            discover.NewNode(ids[2], net.IP{10, 0, 0, 3}, 30303, 30303),
+		Name:         "Parity/v1.8.0",
+		Caps:         []string{"eth/63", "par/1"},
+		Rue:          &rueStatus{63, 3, big.NewInt(1), common.Hash{}, params.TestnetGenesisHash},
+		LastResponse: now.Add(-time.Hour),
+	}
+	nodes[ids[3]] = nodeJSON{
+		N:         discover.NewNode(ids[3], net.IP{10, 0, 0, 4}, 30303, 30303),
+		LastCheck: now,
+		LastError: "connection refused",
+	}
+	return nodes
+}
+
+func TestNodeSetFilter(t *testing.T) {
+	nodes := testNodeSet()
+	since := time.Now().Add(-24 * time.Hour)
+
+	tests := []struct {
+		filters []nodeFilter
+		want    []discover.NodeID
+	}{
+		{nil, []discover.NodeID{{1}, {2}, {3}, {4}}},
+		{[]nodeFilter{networkFilter(1)}, []discover.NodeID{{1}, {2}}},
+		{[]nodeFilter{genesisFilter(params.TestnetGenesisHash)}, []discover.NodeID{{3}}},
+		{[]nodeFilter{seenFilter(since)}, []discover.NodeID{{1}, {3}}},
+		{[]nodeFilter{networkFilter(1), seenFilter(since)}, []discover.NodeID{{1}}},
+		{[]nodeFilter{clientFilter("parity")}, []discover.NodeID{{3}}},
+		{[]nodeFilter{capFilter("eth/62")}, []discover.NodeID{{1}}},
+	}
+	for i, test := range tests {
+		result := nodes.filter(test.filters...)
+		want := make(nodeSet)
+		for _, id := range test.want {
+			want[id] = nodes[id]
+		}
+		if !reflect.DeepEqual(result, want) {
+			t.Errorf("test %d: wrong result: got %d nodes, want %d", i, len(result), len(want))
+		}
+	}
+}
+
+func TestNodeSetJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devp2p-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "nodes.json")
+
+	// A missing file is an empty node set.
+	nodes, err := loadNodesJSON(file)
+	if err != nil || len(nodes) != 0 {
+		t.Fatalf("unexpected result for missing file: %v, %v", nodes, err)
+	}
+	want := testNodeSet()
+	if err := writeNodesJSON(file, want); err != nil {
+		t.Fatal(err)
+	}
+	if nodes, err = loadNodesJSON(file); err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != len(want) {
+		t.Fatalf("wrong number of nodes loaded: got %d, want %d", len(nodes), len(want))
+	}
+	for id, n := range want {
+		got := nodes[id]
+		if got.N.String() != n.N.String() || got.Name != n.Name || !reflect.DeepEqual(got.Rue, n.Rue) || !got.LastResponse.Equal(n.LastResponse) {
+			t.Errorf("node %x mismatch after round trip:\ngot:  %+v\nwant: %+v", id[:4], got, n)
+		}
+	}
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/Rue-Foundation/go-rue/rue/downloader"
 	"github.com/Rue-Foundation/go-rue/ruedb"
 	"github.com/Rue-Foundation/go-rue/event"
+	"github.com/Rue-Foundation/go-rue/light"
 	"github.com/Rue-Foundation/go-rue/log"
 	"github.com/Rue-Foundation/go-rue/trie"
 	"github.com/syndtr/goleveldb/leveldb/util"
@@ -138,6 +139,33 @@ Remove blockchain and state databases`,
 The arguments are interpreted as block numbers or hashes.
 Use "ruereum dump 0" to dump the genesis block.`,
 	}
+	checkpointCommand = cli.Command{
+		Name:     "checkpoint",
+		Usage:    "Manage trusted light client checkpoints",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Trusted checkpoints allow light clients to skip downloading the header chain
+while still being able to securely access old headers and logs.`,
+		Subcommands: []cli.Command{
+			{
+				Action:    utils.MigrateFlags(exportCheckpoint),
+				Name:      "export",
+				Usage:     "Export the latest CHT checkpoint of a light serving node",
+				ArgsUsage: "[<name>]",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.TestnetFlag,
+					utils.RinkebyFlag,
+				},
+				Description: `
+Reads the latest section processed by both the CHT and the BloomTrie indexers
+of a synced full node running with --lightserv, and prints it as a JSON
+checkpoint usable by light clients through --les.checkpoint. The optional
+argument names the checkpoint.`,
+			},
+		},
+	}
 )
 
 // initGenesis will initialise the given JSON format genesis file and writes it as
@@ -297,7 +325,7 @@ func copyDb(ctx *cli.Context) error {
 	chain, chainDb := utils.MakeChain(ctx, stack)
 
 	syncmode := *utils.GlobalTextMarshaler(ctx, utils.SyncModeFlag.Name).(*downloader.SyncMode)
-	dl := downloader.New(syncmode, chainDb, new(event.TypeMux), chain, nil, nil)
+	dl := downloader.New(syncmode, nil, chainDb, new(event.TypeMux), chain, nil, nil)
 
 	// Create a source peer to satisfy downloader requests from
 	db, err := ruedb.NewLDBDatabase(ctx.Args().First(), ctx.GlobalInt(utils.CacheFlag.Name), 256)
@@ -390,6 +418,24 @@ func dump(ctx *cli.Context) error {
 	return nil
 }
 
+func exportCheckpoint(ctx *cli.Context) error {
+	name := ctx.Args().First()
+	if name == "" {
+		name = "grue"
+	}
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	checkpoint, err := light.ServerCheckpoint(chainDb, name)
+	if err != nil {
+		utils.Fatalf("Failed to export checkpoint: %v", err)
+	}
+	out, _ := json.MarshalIndent(checkpoint, "", "  ")
+	fmt.Println(string(out))
+	return nil
+}
+
 // hashish returns true for strings that look like hashes.
 func hashish(x string) bool {
 	_, err := strconv.Atoi(x)
@@ -77,22 +77,33 @@ var (
 		utils.TxPoolRejournalFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolPriceBumpFlag,
+		utils.TxPoolSlotSizeFlag,
+		utils.TxPoolMaxTxSizeFlag,
 		utils.TxPoolAccountSlotsFlag,
 		utils.TxPoolGlobalSlotsFlag,
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolPrivateBlocksFlag,
 		utils.FastSyncFlag,
 		utils.LightModeFlag,
 		utils.SyncModeFlag,
+		utils.SyncCheckpointFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
+		utils.LightCheckpointFlag,
+		utils.UltraLightServersFlag,
+		utils.UltraLightThresholdFlag,
 		utils.LightKDFFlag,
 		utils.CacheFlag,
 		utils.TrieCacheGenFlag,
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
+		utils.BandwidthInFlag,
+		utils.BandwidthOutFlag,
+		utils.BandwidthPeerInFlag,
+		utils.BandwidthPeerOutFlag,
 		utils.EtherbaseFlag,
 		utils.GasPriceFlag,
 		utils.MinerThreadsFlag,
@@ -101,6 +112,7 @@ var (
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
+		utils.DNSDiscoveryFlag,
 		utils.NetrestrictFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
@@ -118,6 +130,13 @@ var (
 		utils.GpoBlocksFlag,
 		utils.GpoPercentileFlag,
 		utils.ExtraDataFlag,
+		utils.MinerOrderingFlag,
+		utils.MinerBlacklistFlag,
+		utils.MinerLocalMinPriceFlag,
+		utils.MinerRemoteMinPriceFlag,
+		utils.MinerMaxSenderGasFlag,
+		utils.StratumAddrFlag,
+		utils.StratumDifficultyFlag,
 		configFileFlag,
 	}
 
@@ -155,6 +174,7 @@ func init() {
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		checkpointCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
@@ -74,10 +74,14 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TestnetFlag,
 			utils.RinkebyFlag,
 			utils.SyncModeFlag,
+			utils.SyncCheckpointFlag,
 			utils.EthStatsURLFlag,
 			utils.IdentityFlag,
 			utils.LightServFlag,
 			utils.LightPeersFlag,
+			utils.LightCheckpointFlag,
+			utils.UltraLightServersFlag,
+			utils.UltraLightThresholdFlag,
 			utils.LightKDFFlag,
 		},
 	},
@@ -116,11 +120,14 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TxPoolRejournalFlag,
 			utils.TxPoolPriceLimitFlag,
 			utils.TxPoolPriceBumpFlag,
+			utils.TxPoolSlotSizeFlag,
+			utils.TxPoolMaxTxSizeFlag,
 			utils.TxPoolAccountSlotsFlag,
 			utils.TxPoolGlobalSlotsFlag,
 			utils.TxPoolAccountQueueFlag,
 			utils.TxPoolGlobalQueueFlag,
 			utils.TxPoolLifetimeFlag,
+			utils.TxPoolPrivateBlocksFlag,
 		},
 	},
 	{
@@ -166,9 +173,14 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.ListenPortFlag,
 			utils.MaxPeersFlag,
 			utils.MaxPendingPeersFlag,
+			utils.BandwidthInFlag,
+			utils.BandwidthOutFlag,
+			utils.BandwidthPeerInFlag,
+			utils.BandwidthPeerOutFlag,
 			utils.NATFlag,
 			utils.NoDiscoverFlag,
 			utils.DiscoveryV5Flag,
+			utils.DNSDiscoveryFlag,
 			utils.NetrestrictFlag,
 			utils.NodeKeyFileFlag,
 			utils.NodeKeyHexFlag,
@@ -183,6 +195,13 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TargetGasLimitFlag,
 			utils.GasPriceFlag,
 			utils.ExtraDataFlag,
+			utils.MinerOrderingFlag,
+			utils.MinerBlacklistFlag,
+			utils.MinerLocalMinPriceFlag,
+			utils.MinerRemoteMinPriceFlag,
+			utils.MinerMaxSenderGasFlag,
+			utils.StratumAddrFlag,
+			utils.StratumDifficultyFlag,
 		},
 	},
 	{
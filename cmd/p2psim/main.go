@@ -18,6 +18,12 @@
 //     $ p2psim node connect node01 node02
 //     Connected node01 to node02
 //
+// The run command doesn't use the API, it runs a scenario file describing the
+// nodes, topology, events and assertions of a simulation in-process and
+// reports whether the assertions passed:
+//
+//     $ p2psim run scenario.json
+//
 package main
 
 import (
@@ -28,6 +34,7 @@ import (
 	"os"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/Rue-Foundation/go-rue/crypto"
 	"github.com/Rue-Foundation/go-rue/p2p"
@@ -77,6 +84,23 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:      "run",
+			ArgsUsage: "<scenario>",
+			Usage:     "run a simulation scenario with the in-process adapter",
+			Action:    runScenario,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print the report as JSON",
+				},
+				cli.DurationFlag{
+					Name:  "timeout",
+					Value: 10 * time.Minute,
+					Usage: "maximum duration of the scenario",
+				},
+			},
+		},
 		{
 			Name:   "snapshot",
 			Usage:  "create a network snapshot to stdout",
@@ -87,6 +111,37 @@ func main() {
 			Usage:  "load a network snapshot from stdin",
 			Action: loadSnapshot,
 		},
+		{
+			Name:      "link",
+			ArgsUsage: "[<node> <peer>]",
+			Usage:     "set the link between two nodes or the default link",
+			Action:    setLink,
+			Flags: []cli.Flag{
+				cli.DurationFlag{
+					Name:  "latency",
+					Usage: "one way latency of the link",
+				},
+				cli.IntFlag{
+					Name:  "bandwidth",
+					Usage: "bandwidth of the link in bytes per second (0 = unlimited)",
+				},
+				cli.Float64Flag{
+					Name:  "droprate",
+					Usage: "fraction of writes which are lost and retransmitted",
+				},
+			},
+		},
+		{
+			Name:      "partition",
+			ArgsUsage: "<group> <group> [<group>...]",
+			Usage:     "partition the network into groups of nodes (comma separated)",
+			Action:    partitionNetwork,
+		},
+		{
+			Name:   "heal",
+			Usage:  "heal a network partition",
+			Action: healNetwork,
+		},
 		{
 			Name:   "node",
 			Usage:  "manage simulation nodes",
@@ -164,7 +219,10 @@ func main() {
 			},
 		},
 	}
-	app.Run(os.Args)
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
 
 func showNetwork(ctx *cli.Context) error {
@@ -230,6 +288,59 @@ func loadSnapshot(ctx *cli.Context) error {
 	return client.LoadSnapshot(snap)
 }
 
+func setLink(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 0 && len(args) != 2 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	link := adapters.LinkConfig{
+		Latency:   ctx.Duration("latency"),
+		Bandwidth: ctx.Int("bandwidth"),
+		DropRate:  ctx.Float64("droprate"),
+	}
+	if len(args) == 0 {
+		if err := client.SetDefaultLink(link); err != nil {
+			return err
+		}
+		fmt.Fprintln(ctx.App.Writer, "Set default link")
+		return nil
+	}
+	nodeName := args[0]
+	peerName := args[1]
+	if err := client.SetLink(nodeName, peerName, link); err != nil {
+		return err
+	}
+	fmt.Fprintln(ctx.App.Writer, "Set link between", nodeName, "and", peerName)
+	return nil
+}
+
+func partitionNetwork(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) < 2 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	groups := make([][]string, len(args))
+	for i, arg := range args {
+		groups[i] = strings.Split(arg, ",")
+	}
+	if err := client.Partition(groups...); err != nil {
+		return err
+	}
+	fmt.Fprintln(ctx.App.Writer, "Partitioned network into", len(groups), "groups")
+	return nil
+}
+
+func healNetwork(ctx *cli.Context) error {
+	if len(ctx.Args()) != 0 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	if err := client.Heal(); err != nil {
+		return err
+	}
+	fmt.Fprintln(ctx.App.Writer, "Healed network")
+	return nil
+}
+
 func listNodes(ctx *cli.Context) error {
 	if len(ctx.Args()) != 0 {
 		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
@@ -0,0 +1,187 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of go-ruereum.
+//
+// go-ruereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ruereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ruereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/node"
+	"github.com/Rue-Foundation/go-rue/p2p"
+	"github.com/Rue-Foundation/go-rue/p2p/simulations"
+	"github.com/Rue-Foundation/go-rue/p2p/simulations/adapters"
+	"github.com/Rue-Foundation/go-rue/rpc"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// scenarioServices are the services available to the nodes of a scenario.
+var scenarioServices = adapters.Services{
+	"ping-pong": func(ctx *adapters.ServiceContext) (node.Service, error) {
+		return new(pingPongService), nil
+	},
+}
+
+func runScenario(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	scenario, err := simulations.LoadScenario(args[0])
+	if err != nil {
+		return err
+	}
+	network := simulations.NewNetwork(adapters.NewSimAdapter(scenarioServices), &simulations.NetworkConfig{})
+	defer network.Shutdown()
+
+	runCtx, cancel := context.WithTimeout(context.Background(), ctx.Duration("timeout"))
+	defer cancel()
+	report := simulations.RunScenario(runCtx, network, scenario)
+
+	if ctx.Bool("json") {
+		enc := json.NewEncoder(ctx.App.Writer)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printReport(ctx.App.Writer, report)
+	}
+	if !report.Pass {
+		return errors.New("scenario failed")
+	}
+	return nil
+}
+
+// printReport writes a human-readable scenario report followed by the event
+// log of the network.
+func printReport(out io.Writer, report *simulations.ScenarioReport) {
+	result := func(err string) string {
+		if err != "" {
+			return "FAIL: " + err
+		}
+		return "ok"
+	}
+	since := func(t time.Time) time.Duration {
+		return t.Sub(report.StartedAt).Round(time.Millisecond)
+	}
+
+	w := tabwriter.NewWriter(out, 1, 2, 2, ' ', 0)
+	fmt.Fprintf(w, "SCENARIO\t%s\n", report.Name)
+	if report.Error != "" {
+		fmt.Fprintf(w, "ERROR\t%s\n", report.Error)
+	}
+	fmt.Fprintf(w, "DURATION\t%v\n", since(report.FinishedAt))
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "NODE\tID\n")
+	for _, n := range report.Nodes {
+		fmt.Fprintf(w, "%s\t%s\n", n.Name, n.ID.TerminalString())
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "TIME\tEVENT\tRESULT\n")
+	for _, e := range report.Events {
+		fmt.Fprintf(w, "%v\t%s\t%s\n", since(e.Time), e.Event, result(e.Error))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "ASSERTION\tRESULT\n")
+	for _, a := range report.Assertions {
+		fmt.Fprintf(w, "%s\t%s\n", a.Assertion, result(a.Error))
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "--- EVENT LOG")
+	for _, e := range report.Log {
+		fmt.Fprintf(out, "%v %s\n", since(e.Time), e)
+	}
+	fmt.Fprintln(out, "---")
+	if report.Pass {
+		fmt.Fprintln(out, "PASS")
+	} else {
+		fmt.Fprintln(out, "FAIL")
+	}
+}
+
+// pingPongService runs a protocol which sends a ping to every peer once per
+// second and answers pings with pongs, generating message traffic between the
+// nodes of a scenario.
+type pingPongService struct{}
+
+const (
+	pingMsg = iota
+	pongMsg
+)
+
+func (s *pingPongService) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    "ping-pong",
+		Version: 1,
+		Length:  2,
+		Run:     s.run,
+	}}
+}
+
+func (s *pingPongService) APIs() []rpc.API                { return nil }
+func (s *pingPongService) Start(server *p2p.Server) error { return nil }
+func (s *pingPongService) Stop() error                    { return nil }
+
+func (s *pingPongService) run(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+	errc := make(chan error, 2)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := p2p.Send(rw, pingMsg, "PING"); err != nil {
+					errc <- err
+					return
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+	go func() {
+		for {
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				errc <- err
+				return
+			}
+			if _, err := io.Copy(ioutil.Discard, msg.Payload); err != nil {
+				errc <- err
+				return
+			}
+			if msg.Code == pingMsg {
+				if err := p2p.Send(rw, pongMsg, "PONG"); err != nil {
+					errc <- err
+					return
+				}
+			}
+		}
+	}()
+	return <-errc
+}
@@ -19,6 +19,7 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -31,6 +32,7 @@ import (
 	"github.com/Rue-Foundation/go-rue/accounts"
 	"github.com/Rue-Foundation/go-rue/accounts/keystore"
 	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/common/hexutil"
 	"github.com/Rue-Foundation/go-rue/consensus"
 	"github.com/Rue-Foundation/go-rue/consensus/clique"
 	"github.com/Rue-Foundation/go-rue/consensus/ruehash"
@@ -45,8 +47,10 @@ import (
 	"github.com/Rue-Foundation/go-rue/ruedb"
 	"github.com/Rue-Foundation/go-rue/ruestats"
 	"github.com/Rue-Foundation/go-rue/les"
+	"github.com/Rue-Foundation/go-rue/light"
 	"github.com/Rue-Foundation/go-rue/log"
 	"github.com/Rue-Foundation/go-rue/metrics"
+	"github.com/Rue-Foundation/go-rue/miner"
 	"github.com/Rue-Foundation/go-rue/node"
 	"github.com/Rue-Foundation/go-rue/p2p"
 	"github.com/Rue-Foundation/go-rue/p2p/discover"
@@ -166,10 +170,15 @@ var (
 	defaultSyncMode = eth.DefaultConfig.SyncMode
 	SyncModeFlag    = TextMarshalerFlag{
 		Name:  "syncmode",
-		Usage: `Blockchain sync mode ("fast", "full", or "light")`,
+		Usage: `Blockchain sync mode ("fast", "full", "light" or "beam")`,
 		Value: &defaultSyncMode,
 	}
 
+	SyncCheckpointFlag = cli.StringFlag{
+		Name:  "checkpoint",
+		Usage: "Trusted block the synced chain must contain (<number>:<hash>:<state root>)",
+	}
+
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -180,6 +189,18 @@ var (
 		Usage: "Maximum number of LES client peers",
 		Value: 20,
 	}
+	LightCheckpointFlag = cli.StringFlag{
+		Name:  "les.checkpoint",
+		Usage: "JSON file containing a trusted CHT checkpoint for light syncing",
+	}
+	UltraLightServersFlag = cli.StringFlag{
+		Name:  "ulc.servers",
+		Usage: "Comma separated enode URLs of trusted LES servers (enables ultra light client mode)",
+	}
+	UltraLightThresholdFlag = cli.IntFlag{
+		Name:  "ulc.threshold",
+		Usage: "Number of trusted servers that must announce a head before it's accepted (0 = all)",
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -264,6 +285,16 @@ var (
 		Usage: "Price bump percentage to replace an already existing transaction",
 		Value: eth.DefaultConfig.TxPool.PriceBump,
 	}
+	TxPoolSlotSizeFlag = cli.Uint64Flag{
+		Name:  "txpool.slotsize",
+		Usage: "Transaction size in bytes accounted as a single pool slot",
+		Value: eth.DefaultConfig.TxPool.SlotSize,
+	}
+	TxPoolMaxTxSizeFlag = cli.Uint64Flag{
+		Name:  "txpool.maxtxsize",
+		Usage: "Maximum size in bytes of a single transaction accepted into the pool",
+		Value: eth.DefaultConfig.TxPool.MaxTxSize,
+	}
 	TxPoolAccountSlotsFlag = cli.Uint64Flag{
 		Name:  "txpool.accountslots",
 		Usage: "Minimum number of executable transaction slots guaranteed per account",
@@ -289,6 +320,11 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: eth.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolPrivateBlocksFlag = cli.Uint64Flag{
+		Name:  "txpool.privateblocks",
+		Usage: "Number of blocks private transactions are withheld from broadcast",
+		Value: eth.DefaultConfig.TxPool.PrivateBlocks,
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -329,6 +365,36 @@ var (
 		Name:  "extradata",
 		Usage: "Block extra data set by the miner (default = client version)",
 	}
+	MinerOrderingFlag = cli.StringFlag{
+		Name:  "miner.ordering",
+		Usage: `Transaction ordering policy for mined blocks ("price", "localfirst" or "fifo")`,
+		Value: miner.OrderPriceAndNonce,
+	}
+	MinerBlacklistFlag = cli.StringFlag{
+		Name:  "miner.blacklist",
+		Usage: "Comma separated addresses whose transactions are never mined",
+	}
+	MinerLocalMinPriceFlag = BigFlag{
+		Name:  "miner.localminprice",
+		Usage: "Minimum gas price for mining transactions of local accounts",
+	}
+	MinerRemoteMinPriceFlag = BigFlag{
+		Name:  "miner.remoteminprice",
+		Usage: "Minimum gas price for mining transactions of remote accounts",
+	}
+	MinerMaxSenderGasFlag = cli.Uint64Flag{
+		Name:  "miner.maxsendergas",
+		Usage: "Maximum gas a single sender may use per mined block (0 = unlimited)",
+	}
+	StratumAddrFlag = cli.StringFlag{
+		Name:  "stratum.addr",
+		Usage: "Stratum server listening interface for remote miners (e.g. \":8008\", disabled if empty)",
+	}
+	StratumDifficultyFlag = cli.Float64Flag{
+		Name:  "stratum.difficulty",
+		Usage: "Default share difficulty of stratum workers (1 = 2^32 hashes)",
+		Value: 1,
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -439,6 +505,22 @@ var (
 		Usage: "Maximum number of pending connection attempts (defaults used if set to 0)",
 		Value: 0,
 	}
+	BandwidthInFlag = cli.IntFlag{
+		Name:  "bandwidth.in",
+		Usage: "Maximum total download rate of peer connections in KB/s (unlimited if set to 0)",
+	}
+	BandwidthOutFlag = cli.IntFlag{
+		Name:  "bandwidth.out",
+		Usage: "Maximum total upload rate of peer connections in KB/s (unlimited if set to 0)",
+	}
+	BandwidthPeerInFlag = cli.IntFlag{
+		Name:  "bandwidth.peerin",
+		Usage: "Maximum download rate of each peer connection in KB/s (unlimited if set to 0)",
+	}
+	BandwidthPeerOutFlag = cli.IntFlag{
+		Name:  "bandwidth.peerout",
+		Usage: "Maximum upload rate of each peer connection in KB/s (unlimited if set to 0)",
+	}
 	ListenPortFlag = cli.IntFlag{
 		Name:  "port",
 		Usage: "Network listening port",
@@ -480,6 +562,11 @@ var (
 		Name:  "v5disc",
 		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
 	}
+	DNSDiscoveryFlag = cli.StringFlag{
+		Name:  "discovery.dns",
+		Usage: "Comma separated enrtree:// URLs of DNS node lists to discover peers from",
+		Value: "",
+	}
 	NetrestrictFlag = cli.StringFlag{
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
@@ -803,6 +890,18 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	if ctx.GlobalIsSet(MaxPendingPeersFlag.Name) {
 		cfg.MaxPendingPeers = ctx.GlobalInt(MaxPendingPeersFlag.Name)
 	}
+	if ctx.GlobalIsSet(BandwidthInFlag.Name) {
+		cfg.MaxIngressRate = ctx.GlobalInt(BandwidthInFlag.Name) * 1024
+	}
+	if ctx.GlobalIsSet(BandwidthOutFlag.Name) {
+		cfg.MaxEgressRate = ctx.GlobalInt(BandwidthOutFlag.Name) * 1024
+	}
+	if ctx.GlobalIsSet(BandwidthPeerInFlag.Name) {
+		cfg.PeerIngressRate = ctx.GlobalInt(BandwidthPeerInFlag.Name) * 1024
+	}
+	if ctx.GlobalIsSet(BandwidthPeerOutFlag.Name) {
+		cfg.PeerEgressRate = ctx.GlobalInt(BandwidthPeerOutFlag.Name) * 1024
+	}
 	if ctx.GlobalIsSet(NoDiscoverFlag.Name) || ctx.GlobalBool(LightModeFlag.Name) {
 		cfg.NoDiscovery = true
 	}
@@ -816,6 +915,9 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	} else if forceV5Discovery {
 		cfg.DiscoveryV5 = true
 	}
+	if urls := ctx.GlobalString(DNSDiscoveryFlag.Name); urls != "" {
+		cfg.DNSDiscovery = strings.Split(urls, ",")
+	}
 
 	if netrestrict := ctx.GlobalString(NetrestrictFlag.Name); netrestrict != "" {
 		list, err := netutil.ParseNetlist(netrestrict)
@@ -832,6 +934,7 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		cfg.DiscoveryV5Addr = ":0"
 		cfg.NoDiscovery = true
 		cfg.DiscoveryV5 = false
+		cfg.DNSDiscovery = nil
 	}
 }
 
@@ -890,6 +993,12 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolPriceBumpFlag.Name) {
 		cfg.PriceBump = ctx.GlobalUint64(TxPoolPriceBumpFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolSlotSizeFlag.Name) {
+		cfg.SlotSize = ctx.GlobalUint64(TxPoolSlotSizeFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolMaxTxSizeFlag.Name) {
+		cfg.MaxTxSize = ctx.GlobalUint64(TxPoolMaxTxSizeFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolAccountSlotsFlag.Name) {
 		cfg.AccountSlots = ctx.GlobalUint64(TxPoolAccountSlotsFlag.Name)
 	}
@@ -905,6 +1014,80 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolPrivateBlocksFlag.Name) {
+		cfg.PrivateBlocks = ctx.GlobalUint64(TxPoolPrivateBlocksFlag.Name)
+	}
+}
+
+// loadCheckpoint reads a trusted CHT checkpoint from a JSON file.
+func loadCheckpoint(path string) *light.TrustedCheckpoint {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		Fatalf("Failed to read checkpoint file: %v", err)
+	}
+	checkpoint := new(light.TrustedCheckpoint)
+	if err := json.Unmarshal(blob, checkpoint); err != nil {
+		Fatalf("Invalid checkpoint file: %v", err)
+	}
+	if checkpoint.SectionHead == (common.Hash{}) || checkpoint.CHTRoot == (common.Hash{}) || checkpoint.BloomTrieRoot == (common.Hash{}) {
+		Fatalf("Incomplete checkpoint file: section head and trie roots are required")
+	}
+	return checkpoint
+}
+
+// parseSyncCheckpoint parses a trusted sync checkpoint given in the form of
+// <number>:<hash>:<state root>.
+func parseSyncCheckpoint(spec string) *params.SyncCheckpoint {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 3 {
+		Fatalf("Option %q: want <number>:<hash>:<state root>, have %q", SyncCheckpointFlag.Name, spec)
+	}
+	number, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		Fatalf("Option %q: invalid block number %q: %v", SyncCheckpointFlag.Name, parts[0], err)
+	}
+	checkpoint := &params.SyncCheckpoint{Number: number}
+	for i, field := range []*common.Hash{&checkpoint.Hash, &checkpoint.Root} {
+		blob, err := hexutil.Decode(parts[i+1])
+		if err != nil || len(blob) != common.HashLength {
+			Fatalf("Option %q: invalid hash %q", SyncCheckpointFlag.Name, parts[i+1])
+		}
+		*field = common.BytesToHash(blob)
+	}
+	return checkpoint
+}
+
+func setMiner(ctx *cli.Context, cfg *eth.Config) {
+	if ctx.GlobalIsSet(MinerOrderingFlag.Name) {
+		cfg.MinerOrdering = ctx.GlobalString(MinerOrderingFlag.Name)
+		if _, err := miner.OrderingByName(cfg.MinerOrdering); err != nil {
+			Fatalf("Option %q: %v", MinerOrderingFlag.Name, err)
+		}
+	}
+	if ctx.GlobalIsSet(MinerBlacklistFlag.Name) {
+		cfg.MinerInclusion.Blacklist = nil
+		for _, entry := range strings.Split(ctx.GlobalString(MinerBlacklistFlag.Name), ",") {
+			if entry = strings.TrimSpace(entry); !common.IsHexAddress(entry) {
+				Fatalf("Option %q: invalid address %q", MinerBlacklistFlag.Name, entry)
+			}
+			cfg.MinerInclusion.Blacklist = append(cfg.MinerInclusion.Blacklist, common.HexToAddress(entry))
+		}
+	}
+	if ctx.GlobalIsSet(MinerLocalMinPriceFlag.Name) {
+		cfg.MinerInclusion.LocalMinPrice = GlobalBig(ctx, MinerLocalMinPriceFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerRemoteMinPriceFlag.Name) {
+		cfg.MinerInclusion.RemoteMinPrice = GlobalBig(ctx, MinerRemoteMinPriceFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerMaxSenderGasFlag.Name) {
+		cfg.MinerInclusion.MaxSenderGas = ctx.GlobalUint64(MinerMaxSenderGasFlag.Name)
+	}
+	if ctx.GlobalIsSet(StratumAddrFlag.Name) {
+		cfg.Stratum.Addr = ctx.GlobalString(StratumAddrFlag.Name)
+	}
+	if ctx.GlobalIsSet(StratumDifficultyFlag.Name) {
+		cfg.Stratum.Difficulty = ctx.GlobalFloat64(StratumDifficultyFlag.Name)
+	}
 }
 
 func setRuehash(ctx *cli.Context, cfg *eth.Config) {
@@ -998,12 +1181,24 @@ func SetRueConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	case ctx.GlobalBool(LightModeFlag.Name):
 		cfg.SyncMode = downloader.LightSync
 	}
+	if ctx.GlobalIsSet(SyncCheckpointFlag.Name) {
+		cfg.SyncCheckpoint = parseSyncCheckpoint(ctx.GlobalString(SyncCheckpointFlag.Name))
+	}
 	if ctx.GlobalIsSet(LightServFlag.Name) {
 		cfg.LightServ = ctx.GlobalInt(LightServFlag.Name)
 	}
 	if ctx.GlobalIsSet(LightPeersFlag.Name) {
 		cfg.LightPeers = ctx.GlobalInt(LightPeersFlag.Name)
 	}
+	if ctx.GlobalIsSet(LightCheckpointFlag.Name) {
+		cfg.LightCheckpoint = loadCheckpoint(ctx.GlobalString(LightCheckpointFlag.Name))
+	}
+	if ctx.GlobalIsSet(UltraLightServersFlag.Name) {
+		cfg.UltraLightServers = strings.Split(ctx.GlobalString(UltraLightServersFlag.Name), ",")
+	}
+	if ctx.GlobalIsSet(UltraLightThresholdFlag.Name) {
+		cfg.UltraLightThreshold = ctx.GlobalInt(UltraLightThresholdFlag.Name)
+	}
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
 		cfg.NetworkId = ctx.GlobalUint64(NetworkIdFlag.Name)
 	}
@@ -1025,6 +1220,7 @@ func SetRueConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(GasPriceFlag.Name) {
 		cfg.GasPrice = GlobalBig(ctx, GasPriceFlag.Name)
 	}
+	setMiner(ctx, cfg)
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
@@ -554,6 +554,31 @@ func (ruehash *Ruehash) VerifySeal(chain consensus.ChainReader, header *types.He
 	return nil
 }
 
+// Hashimoto recomputes the mix digest and proof-of-work value of a sealing hash
+// and nonce using the verification cache of the given block number. It allows
+// remote miners submitting only nonces to be verified against custom targets.
+func (ruehash *Ruehash) Hashimoto(number uint64, hash common.Hash, nonce types.BlockNonce) (common.Hash, common.Hash, error) {
+	// If we're running a fake PoW, every nonce is a perfect solution
+	if ruehash.config.PowMode == ModeFake || ruehash.config.PowMode == ModeFullFake {
+		return common.Hash{}, common.Hash{}, nil
+	}
+	// If we're running a shared PoW, delegate hashing to it
+	if ruehash.shared != nil {
+		return ruehash.shared.Hashimoto(number, hash, nonce)
+	}
+	if number/epochLength >= uint64(len(cacheSizes)) {
+		return common.Hash{}, common.Hash{}, errNonceOutOfRange
+	}
+	cache := ruehash.cache(number)
+
+	size := datasetSize(number)
+	if ruehash.config.PowMode == ModeTest {
+		size = 32 * 1024
+	}
+	digest, result := hashimotoLight(size, cache, hash.Bytes(), nonce.Uint64())
+	return common.BytesToHash(digest), common.BytesToHash(result), nil
+}
+
 // Prepare implements consensus.Engine, initializing the difficulty field of a
 // header to conform to the ruehash protocol. The changes are done inline.
 func (ruehash *Ruehash) Prepare(chain consensus.ChainReader, header *types.Header) error {
@@ -96,6 +96,8 @@ type BlockChain struct {
 	currentBlock     *types.Block // Current head of the block chain
 	currentFastBlock *types.Block // Current head of the fast-sync chain (may be above the block chain!)
 
+	stateFetch *state.FetchingDatabase // Chain database retrieving missing state on demand (beam sync)
+
 	stateCache   state.Database // State database to reuse between imports (contains state cache)
 	bodyCache    *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
@@ -125,11 +127,13 @@ func NewBlockChain(chainDb ruedb.Database, config *params.ChainConfig, engine co
 	blockCache, _ := lru.New(blockCacheLimit)
 	futureBlocks, _ := lru.New(maxFutureBlocks)
 	badBlocks, _ := lru.New(badBlockLimit)
+	stateFetch := state.NewFetchingDatabase(chainDb)
 
 	bc := &BlockChain{
 		config:       config,
 		chainDb:      chainDb,
-		stateCache:   state.NewDatabase(chainDb),
+		stateCache:   state.NewDatabase(stateFetch),
+		stateFetch:   stateFetch,
 		quit:         make(chan struct{}),
 		bodyCache:    bodyCache,
 		bodyRLPCache: bodyRLPCache,
@@ -292,7 +296,7 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	if block == nil {
 		return fmt.Errorf("non existent block [%x…]", hash[:4])
 	}
-	if _, err := trie.NewSecure(block.Root(), bc.chainDb, 0); err != nil {
+	if _, err := trie.NewSecure(block.Root(), bc.stateFetch, 0); err != nil {
 		return err
 	}
 	// If all checks out, manually set the head block
@@ -304,6 +308,13 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	return nil
 }
 
+// SetStateFetcher sets a fetcher to retrieve state trie nodes and contract code
+// missing from the database on demand, allowing blocks to be processed on top of
+// a partially downloaded state. A nil fetcher disables on-demand retrieval.
+func (bc *BlockChain) SetStateFetcher(fetcher state.NodeFetcher) {
+	bc.stateFetch.SetFetcher(fetcher)
+}
+
 // GasLimit returns the gas limit of the current HEAD block.
 func (bc *BlockChain) GasLimit() *big.Int {
 	bc.mu.RLock()
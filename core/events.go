@@ -24,6 +24,10 @@ import (
 // TxPreEvent is posted when a transaction enters the transaction pool.
 type TxPreEvent struct{ Tx *types.Transaction }
 
+// PrivateTxReleaseEvent is posted when a private transaction was not included
+// within its allotted number of blocks and may now be broadcast to the network.
+type PrivateTxReleaseEvent struct{ Tx *types.Transaction }
+
 // PendingLogsEvent is posted pre mining and notifies of pending logs.
 type PendingLogsEvent struct {
 	Logs []*types.Log
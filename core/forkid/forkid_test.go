@@ -0,0 +1,232 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package forkid
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/params"
+	"github.com/Rue-Foundation/go-rue/rlp"
+)
+
+// rinkebyGenesisHash is the hash of the default Rinkeby genesis block.
+var rinkebyGenesisHash = common.HexToHash("0x6341fd3daf94b748c72ced5a5b26028f2474f5f00d824504e4fa37a75767e177")
+
+// Tests that fork ID calculation at various chain heads produces the expected
+// checksums and next fork blocks for every network config.
+func TestCreation(t *testing.T) {
+	type testcase struct {
+		head uint64
+		want ID
+	}
+	tests := []struct {
+		name    string
+		config  *params.ChainConfig
+		genesis common.Hash
+		cases   []testcase
+	}{
+		{
+			"mainnet",
+			params.MainnetChainConfig,
+			params.MainnetGenesisHash,
+			[]testcase{
+				{0, ID{Hash: checksumToBytes(0xc7aacd49), Next: 1}},               // Unsynced
+				{1, ID{Hash: checksumToBytes(0x6a8fce31), Next: 876600}},          // First Frontier block
+				{876599, ID{Hash: checksumToBytes(0x6a8fce31), Next: 876600}},     // Last Frontier block
+				{876600, ID{Hash: checksumToBytes(0xc924e1d7), Next: 1534050}},    // First Horizon block
+				{1534049, ID{Hash: checksumToBytes(0xc924e1d7), Next: 1534050}},   // Last Horizon block
+				{1534050, ID{Hash: checksumToBytes(0xa3e4946b), Next: 1972350}},   // First Hope block
+				{14463900, ID{Hash: checksumToBytes(0x767fa3ed), Next: 15350500}}, // First SaoHao block
+				{26517149, ID{Hash: checksumToBytes(0x5a9cff8c), Next: 26517150}}, // Last Mayalls block
+				{26517150, ID{Hash: checksumToBytes(0x51546a00), Next: 26955450}}, // First Thales block
+				{51061950, ID{Hash: checksumToBytes(0x3f5851ee), Next: 51938550}}, // First Kant block
+				{51938549, ID{Hash: checksumToBytes(0x3f5851ee), Next: 51938550}}, // Last Kant block
+				{51938550, ID{Hash: checksumToBytes(0xbec7f8b4), Next: 0}},        // First Buterin block
+				{60000000, ID{Hash: checksumToBytes(0xbec7f8b4), Next: 0}},        // Future Buterin block
+			},
+		},
+		{
+			"testnet",
+			params.TestnetChainConfig,
+			params.TestnetGenesisHash,
+			[]testcase{
+				{0, ID{Hash: checksumToBytes(0x30c7ddbc), Next: 1}},               // Unsynced
+				{1, ID{Hash: checksumToBytes(0xf4a4d818), Next: 876600}},          // First Frontier block
+				{876599, ID{Hash: checksumToBytes(0xf4a4d818), Next: 876600}},     // Last Frontier block
+				{876600, ID{Hash: checksumToBytes(0x284e5dda), Next: 1534050}},    // First Horizon block
+				{1534049, ID{Hash: checksumToBytes(0x284e5dda), Next: 1534050}},   // Last Horizon block
+				{1534050, ID{Hash: checksumToBytes(0x63ef663d), Next: 1972350}},   // First Hope block
+				{14463900, ID{Hash: checksumToBytes(0x570a924d), Next: 15350500}}, // First SaoHao block
+				{26517149, ID{Hash: checksumToBytes(0x5ea7ec55), Next: 26517150}}, // Last Mayalls block
+				{26517150, ID{Hash: checksumToBytes(0x850dd0ca), Next: 26955450}}, // First Thales block
+				{51061950, ID{Hash: checksumToBytes(0x59ea29d8), Next: 51938550}}, // First Kant block
+				{51938549, ID{Hash: checksumToBytes(0x59ea29d8), Next: 51938550}}, // Last Kant block
+				{51938550, ID{Hash: checksumToBytes(0x63920c91), Next: 0}},        // First Buterin block
+				{60000000, ID{Hash: checksumToBytes(0x63920c91), Next: 0}},        // Future Buterin block
+			},
+		},
+		{
+			"rinkeby",
+			params.RinkebyChainConfig,
+			rinkebyGenesisHash,
+			[]testcase{
+				{0, ID{Hash: checksumToBytes(0x3b8e0691), Next: 1}},               // Unsynced
+				{1, ID{Hash: checksumToBytes(0x60949295), Next: 876600}},          // First Frontier block
+				{876599, ID{Hash: checksumToBytes(0x60949295), Next: 876600}},     // Last Frontier block
+				{876600, ID{Hash: checksumToBytes(0x20f7619b), Next: 1534050}},    // First Horizon block
+				{1534049, ID{Hash: checksumToBytes(0x20f7619b), Next: 1534050}},   // Last Horizon block
+				{1534050, ID{Hash: checksumToBytes(0xe4ae6214), Next: 1972350}},   // First Hope block
+				{14463900, ID{Hash: checksumToBytes(0x4ad8f94c), Next: 15350500}}, // First SaoHao block
+				{26517149, ID{Hash: checksumToBytes(0xa4d7b0ae), Next: 26517150}}, // Last Mayalls block
+				{26517150, ID{Hash: checksumToBytes(0xcadf1e28), Next: 26955450}}, // First Thales block
+				{51061950, ID{Hash: checksumToBytes(0xf9759abe), Next: 51938550}}, // First Kant block
+				{51938549, ID{Hash: checksumToBytes(0xf9759abe), Next: 51938550}}, // Last Kant block
+				{51938550, ID{Hash: checksumToBytes(0xf89ccb90), Next: 0}},        // First Buterin block
+				{60000000, ID{Hash: checksumToBytes(0xf89ccb90), Next: 0}},        // Future Buterin block
+			},
+		},
+	}
+	for _, tt := range tests {
+		for j, ttt := range tt.cases {
+			if have := NewID(tt.config, tt.genesis, ttt.head); have != ttt.want {
+				t.Errorf("%s test %d: fork ID mismatch: have %x, want %x", tt.name, j, have, ttt.want)
+			}
+		}
+	}
+}
+
+// Tests that every fork block field of the chain config is part of the fork
+// checksum, so newly added forks can't be silently left out.
+func TestGatherForks(t *testing.T) {
+	kind := reflect.TypeOf(params.ChainConfig{})
+	for i := 0; i < kind.NumField(); i++ {
+		field := kind.Field(i)
+		if !strings.HasSuffix(field.Name, "Block") || field.Type != reflect.TypeOf(new(big.Int)) {
+			continue
+		}
+		config := new(params.ChainConfig)
+		reflect.ValueOf(config).Elem().Field(i).Set(reflect.ValueOf(big.NewInt(1000)))
+
+		if forks := gatherForks(config); !reflect.DeepEqual(forks, []uint64{1000}) {
+			t.Errorf("%s: forks mismatch: have %v, want [1000]", field.Name, forks)
+		}
+		if NewID(config, params.MainnetGenesisHash, 1000) == NewID(new(params.ChainConfig), params.MainnetGenesisHash, 1000) {
+			t.Errorf("%s: fork not included in the checksum", field.Name)
+		}
+	}
+	// Ensure all the mainnet forks are gathered, sorted and deduplicated
+	forks := gatherForks(params.MainnetChainConfig)
+	if len(forks) != 46 {
+		t.Errorf("mainnet fork count mismatch: have %d, want %d", len(forks), 46)
+	}
+	for i := 1; i < len(forks); i++ {
+		if forks[i-1] >= forks[i] {
+			t.Errorf("mainnet forks not sorted and unique at %d: %d >= %d", i, forks[i-1], forks[i])
+		}
+	}
+}
+
+// Tests that validation of remote fork IDs against the local chain correctly
+// accepts peers on the same or a compatible fork and rejects the rest.
+func TestValidation(t *testing.T) {
+	mainnet := func(head uint64) ID {
+		return NewID(params.MainnetChainConfig, params.MainnetGenesisHash, head)
+	}
+	tests := []struct {
+		head uint64
+		id   ID
+		err  error
+	}{
+		// Local is mainnet Frontier, remote announces the same. No future fork is announced.
+		{7000, mainnet(7000), nil},
+
+		// Local is mainnet Frontier, remote announces the same, also announcing a
+		// next fork at a block we don't know about yet. Local is not past it, accept.
+		{7000, ID{Hash: mainnet(7000).Hash, Next: math.MaxUint64}, nil},
+
+		// Local is mainnet Frontier, remote announces the same, and the next fork
+		// at a block already passed locally. Remote is incompatible, reject.
+		{7000, ID{Hash: mainnet(7000).Hash, Next: 6000}, ErrLocalIncompatibleOrStale},
+
+		// Local is mainnet Hope, remote announces Frontier with Horizon as the next
+		// fork. Remote is simply syncing, accept.
+		{1534050, mainnet(7000), nil},
+
+		// Local is mainnet Hope, remote announces Frontier with an unknown next fork
+		// in place of Horizon. Remote needs a software update, reject.
+		{1534050, ID{Hash: mainnet(7000).Hash, Next: 876601}, ErrRemoteStale},
+
+		// Local is mainnet Frontier, remote announces Horizon. Local is out of sync,
+		// accept.
+		{7000, mainnet(876600), nil},
+
+		// Local is mainnet Frontier, remote announces Thales. Local is out of sync,
+		// accept.
+		{7000, mainnet(26517150), nil},
+
+		// Local is mainnet Buterin, remote announces the same. Accept.
+		{51938550, mainnet(51938550), nil},
+
+		// Local is mainnet Buterin, remote announces Kant with Buterin as the next
+		// fork. Remote is simply syncing, accept.
+		{51938550, mainnet(51938549), nil},
+
+		// Remote is on the testnet, reject.
+		{7000, NewID(params.TestnetChainConfig, params.TestnetGenesisHash, 7000), ErrLocalIncompatibleOrStale},
+
+		// Remote announces a completely unknown fork, reject.
+		{7000, ID{Hash: checksumToBytes(0xafec6b27), Next: 0}, ErrLocalIncompatibleOrStale},
+	}
+	for i, tt := range tests {
+		filter := NewFilter(params.MainnetChainConfig, params.MainnetGenesisHash, func() uint64 { return tt.head })
+		if err := filter(tt.id); err != tt.err {
+			t.Errorf("test %d: validation error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+// Tests that fork IDs are RLP encoded and decoded correctly.
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		id   ID
+		want []byte
+	}{
+		{ID{Hash: checksumToBytes(0), Next: 0}, common.Hex2Bytes("c6840000000080")},
+		{ID{Hash: checksumToBytes(0xdeadbeef), Next: 0xBADDCAFE}, common.Hex2Bytes("ca84deadbeef84baddcafe")},
+		{ID{Hash: checksumToBytes(math.MaxUint32), Next: math.MaxUint64}, common.Hex2Bytes("ce84ffffffff88ffffffffffffffff")},
+	}
+	for i, tt := range tests {
+		have, err := rlp.EncodeToBytes(tt.id)
+		if err != nil {
+			t.Errorf("test %d: failed to encode forkid: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(have, tt.want) {
+			t.Errorf("test %d: RLP mismatch: have %x, want %x", i, have, tt.want)
+		}
+		var dec ID
+		if err := rlp.DecodeBytes(have, &dec); err != nil || dec != tt.id {
+			t.Errorf("test %d: decoded forkid mismatch: have %v, want %v, err %v", i, dec, tt.id, err)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"sync"
 
 	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/crypto"
 	"github.com/Rue-Foundation/go-rue/ruedb"
 	"github.com/Rue-Foundation/go-rue/trie"
 	lru "github.com/hashicorp/golang-lru"
@@ -152,3 +153,66 @@ func (m cachedTrie) CommitTo(dbw trie.DatabaseWriter) (common.Hash, error) {
 	}
 	return root, err
 }
+
+// NodeFetcher retrieves state trie nodes and contract code missing from the
+// local database by their hash, e.g. from remote peers.
+type NodeFetcher interface {
+	FetchNodeData(hash common.Hash) ([]byte, error)
+}
+
+// FetchingDatabase wraps a key-value store, retrieving any state trie node or
+// contract code missing from it through an optional NodeFetcher and storing it
+// locally.
+type FetchingDatabase struct {
+	ruedb.Database
+
+	fetcher NodeFetcher
+	lock    sync.RWMutex
+}
+
+// NewFetchingDatabase wraps a key-value store for on-demand state retrieval.
+// Until a fetcher is set, it behaves like the wrapped store.
+func NewFetchingDatabase(db ruedb.Database) *FetchingDatabase {
+	return &FetchingDatabase{Database: db}
+}
+
+// SetFetcher sets the fetcher used to retrieve missing state entries, nil
+// disabling on-demand retrieval.
+func (db *FetchingDatabase) SetFetcher(fetcher NodeFetcher) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.fetcher = fetcher
+}
+
+// Get retrieves the given key from the wrapped store, fetching it if it's a
+// missing state entry.
+func (db *FetchingDatabase) Get(key []byte) ([]byte, error) {
+	blob, err := db.Database.Get(key)
+	if err == nil || len(key) != common.HashLength {
+		return blob, err
+	}
+	db.lock.RLock()
+	fetcher := db.fetcher
+	db.lock.RUnlock()
+
+	if fetcher == nil {
+		return nil, err
+	}
+	hash := common.BytesToHash(key)
+	fetched, ferr := fetcher.FetchNodeData(hash)
+	if ferr != nil {
+		// The entry might have been stored meanwhile by a background sync
+		if blob, err := db.Database.Get(key); err == nil {
+			return blob, nil
+		}
+		return nil, ferr
+	}
+	if crypto.Keccak256Hash(fetched) != hash {
+		return nil, fmt.Errorf("fetched state entry %x has wrong hash", key)
+	}
+	if err := db.Database.Put(key, fetched); err != nil {
+		return nil, err
+	}
+	return fetched, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/ruedb"
+)
+
+// testNodeFetcher serves state entries from a remote database.
+type testNodeFetcher struct {
+	db      ruedb.Database
+	fetched int
+}
+
+func (f *testNodeFetcher) FetchNodeData(hash common.Hash) ([]byte, error) {
+	f.fetched++
+	blob, err := f.db.Get(hash[:])
+	if err != nil {
+		return nil, errors.New("not found")
+	}
+	return blob, nil
+}
+
+// Tests that a state built on a fetching database retrieves missing trie nodes
+// on demand, storing them locally.
+func TestFetchingDatabase(t *testing.T) {
+	// Create a remote state and an empty local database
+	_, remote, srcRoot, srcAccounts := makeTestState()
+
+	local, _ := ruedb.NewMemDatabase()
+	db := NewFetchingDatabase(local)
+
+	if _, err := New(srcRoot, NewDatabase(db)); err == nil {
+		t.Fatalf("state opened without fetcher")
+	}
+	fetcher := &testNodeFetcher{db: remote}
+	db.SetFetcher(fetcher)
+
+	state, err := New(srcRoot, NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open state: %v", err)
+	}
+	for i, acc := range srcAccounts {
+		if balance := state.GetBalance(acc.address); balance.Cmp(acc.balance) != 0 {
+			t.Errorf("account %d: balance mismatch: have %v, want %v", i, balance, acc.balance)
+		}
+		if code := state.GetCode(acc.address); !bytes.Equal(code, acc.code) {
+			t.Errorf("account %d: code mismatch: have %x, want %x", i, code, acc.code)
+		}
+	}
+	// Everything fetched is stored locally, so a second pass needs no fetching
+	fetched := fetcher.fetched
+	if fetched == 0 {
+		t.Fatalf("nothing fetched")
+	}
+	state, _ = New(srcRoot, NewDatabase(db))
+	for _, acc := range srcAccounts {
+		state.GetBalance(acc.address)
+		state.GetCode(acc.address)
+	}
+	if fetcher.fetched != fetched {
+		t.Errorf("refetched stored entries: have %d fetches, want %d", fetcher.fetched, fetched)
+	}
+}
@@ -223,18 +223,25 @@ type txList struct {
 	strict bool         // Whether nonces are strictly continuous or not
 	txs    *txSortedMap // Heap indexed sorted hash map of the transactions
 
+	slotSize uint64 // Transaction size in bytes accounted as a single slot
+	slots    int    // Number of slots occupied by all the contained transactions
+	total    *int   // Slot counter shared with the other lists of the pool (nil if none)
+
 	costcap *big.Int // Price of the highest costing transaction (reset only if exceeds balance)
 	gascap  *big.Int // Gas limit of the highest spending transaction (reset only if exceeds block limit)
 }
 
 // newTxList create a new transaction list for maintaining nonce-indexable fast,
-// gapped, sortable transaction lists.
-func newTxList(strict bool) *txList {
+// gapped, sortable transaction lists, accounting their memory use in slots of
+// the given size. The slots are also added to the optional total counter.
+func newTxList(strict bool, slotSize uint64, total *int) *txList {
 	return &txList{
-		strict:  strict,
-		txs:     newTxSortedMap(),
-		costcap: new(big.Int),
-		gascap:  new(big.Int),
+		strict:   strict,
+		txs:      newTxSortedMap(),
+		slotSize: slotSize,
+		total:    total,
+		costcap:  new(big.Int),
+		gascap:   new(big.Int),
 	}
 }
 
@@ -263,6 +270,10 @@ func (l *txList) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Tran
 	}
 	// Otherwise overwrite the old transaction with the current one
 	l.txs.Put(tx)
+	l.track(numSlots(tx, l.slotSize))
+	if old != nil {
+		l.track(-numSlots(old, l.slotSize))
+	}
 	if cost := tx.Cost(); l.costcap.Cmp(cost) < 0 {
 		l.costcap = cost
 	}
@@ -276,7 +287,7 @@ func (l *txList) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Tran
 // provided threshold. Every removed transaction is returned for any post-removal
 // maintenance.
 func (l *txList) Forward(threshold uint64) types.Transactions {
-	return l.txs.Forward(threshold)
+	return l.untrack(l.txs.Forward(threshold))
 }
 
 // Filter removes all transactions from the list with a cost or gas limit higher
@@ -297,7 +308,7 @@ func (l *txList) Filter(costLimit, gasLimit *big.Int) (types.Transactions, types
 	l.gascap = new(big.Int).Set(gasLimit)
 
 	// Filter out all the transactions above the account's funds
-	removed := l.txs.Filter(func(tx *types.Transaction) bool { return tx.Cost().Cmp(costLimit) > 0 || tx.Gas().Cmp(gasLimit) > 0 })
+	removed := l.untrack(l.txs.Filter(func(tx *types.Transaction) bool { return tx.Cost().Cmp(costLimit) > 0 || tx.Gas().Cmp(gasLimit) > 0 }))
 
 	// If the list was strict, filter anything above the lowest nonce
 	var invalids types.Transactions
@@ -309,7 +320,7 @@ func (l *txList) Filter(costLimit, gasLimit *big.Int) (types.Transactions, types
 				lowest = nonce
 			}
 		}
-		invalids = l.txs.Filter(func(tx *types.Transaction) bool { return tx.Nonce() > lowest })
+		invalids = l.untrack(l.txs.Filter(func(tx *types.Transaction) bool { return tx.Nonce() > lowest }))
 	}
 	return removed, invalids
 }
@@ -317,7 +328,29 @@ func (l *txList) Filter(costLimit, gasLimit *big.Int) (types.Transactions, types
 // Cap places a hard limit on the number of items, returning all transactions
 // exceeding that limit.
 func (l *txList) Cap(threshold int) types.Transactions {
-	return l.txs.Cap(threshold)
+	return l.untrack(l.txs.Cap(threshold))
+}
+
+// CapSlots places a hard limit on the number of slots occupied by the list,
+// dropping the highest nonce transactions until the list fits and returning
+// them for further removal.
+func (l *txList) CapSlots(threshold int) types.Transactions {
+	// Short circuit if the list fits into the allowance
+	if l.slots <= threshold {
+		return nil
+	}
+	// Otherwise count the lowest nonce transactions fitting and drop the rest
+	var (
+		keep  int
+		slots int
+	)
+	for _, tx := range l.txs.Flatten() {
+		if slots += numSlots(tx, l.slotSize); slots > threshold {
+			break
+		}
+		keep++
+	}
+	return l.Cap(keep)
 }
 
 // Remove deletes a transaction from the maintained list, returning whether the
@@ -326,12 +359,15 @@ func (l *txList) Cap(threshold int) types.Transactions {
 func (l *txList) Remove(tx *types.Transaction) (bool, types.Transactions) {
 	// Remove the transaction from the set
 	nonce := tx.Nonce()
+	stored := l.txs.Get(nonce)
 	if removed := l.txs.Remove(nonce); !removed {
 		return false, nil
 	}
+	l.track(-numSlots(stored, l.slotSize))
+
 	// In strict mode, filter out non-executable transactions
 	if l.strict {
-		return true, l.txs.Filter(func(tx *types.Transaction) bool { return tx.Nonce() > nonce })
+		return true, l.untrack(l.txs.Filter(func(tx *types.Transaction) bool { return tx.Nonce() > nonce }))
 	}
 	return true, nil
 }
@@ -344,7 +380,24 @@ func (l *txList) Remove(tx *types.Transaction) (bool, types.Transactions) {
 // prevent getting into and invalid state. This is not something that should ever
 // happen but better to be self correcting than failing!
 func (l *txList) Ready(start uint64) types.Transactions {
-	return l.txs.Ready(start)
+	return l.untrack(l.txs.Ready(start))
+}
+
+// untrack releases the slots occupied by a batch of transactions removed from
+// the list, returning the same batch for chaining.
+func (l *txList) untrack(txs types.Transactions) types.Transactions {
+	for _, tx := range txs {
+		l.track(-numSlots(tx, l.slotSize))
+	}
+	return txs
+}
+
+// track updates the slots occupied by the list and the total counter.
+func (l *txList) track(slots int) {
+	l.slots += slots
+	if l.total != nil {
+		*l.total += slots
+	}
 }
 
 // Len returns the length of the transaction list.
@@ -352,6 +405,11 @@ func (l *txList) Len() int {
 	return l.txs.Len()
 }
 
+// Slots returns the number of slots occupied by the transaction list.
+func (l *txList) Slots() int {
+	return l.slots
+}
+
 // Empty returns whether the list of transactions is empty or not.
 func (l *txList) Empty() bool {
 	return l.Len() == 0
@@ -384,6 +442,12 @@ func (h *priceHeap) Pop() interface{} {
 	return x
 }
 
+// numSlots calculates the number of slots of the given size needed to account
+// for a single transaction.
+func numSlots(tx *types.Transaction, slotSize uint64) int {
+	return int((uint64(tx.Size()) + slotSize - 1) / slotSize)
+}
+
 // txPricedList is a price-sorted heap to allow operating on transactions pool
 // contents in a price-incrementing way.
 type txPricedList struct {
@@ -481,13 +545,14 @@ func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet) boo
 	return cheapest.GasPrice().Cmp(tx.GasPrice()) >= 0
 }
 
-// Discard finds a number of most underpriced transactions, removes them from the
-// priced list and returns them for further removal from the entire pool.
-func (l *txPricedList) Discard(count int, local *accountSet) types.Transactions {
-	drop := make(types.Transactions, 0, count) // Remote underpriced transactions to drop
+// Discard finds the most underpriced transactions occupying at least the given
+// number of slots, removes them from the priced list and returns them for further
+// removal from the entire pool.
+func (l *txPricedList) Discard(slots int, slotSize uint64, local *accountSet) types.Transactions {
+	drop := make(types.Transactions, 0, slots) // Remote underpriced transactions to drop
 	save := make(types.Transactions, 0, 64)    // Local underpriced transactions to keep
 
-	for len(*l.items) > 0 && count > 0 {
+	for len(*l.items) > 0 && slots > 0 {
 		// Discard stale transactions if found during cleanup
 		tx := heap.Pop(l.items).(*types.Transaction)
 		if _, ok := (*l.all)[tx.Hash()]; !ok {
@@ -499,7 +564,7 @@ func (l *txPricedList) Discard(count int, local *accountSet) types.Transactions
 			save = append(save, tx)
 		} else {
 			drop = append(drop, tx)
-			count--
+			slots -= numSlots(tx, slotSize)
 		}
 	}
 	for _, tx := range save {
@@ -101,6 +101,11 @@ var (
 	// General tx metrics
 	invalidTxCounter     = metrics.NewCounter("txpool/invalid")
 	underpricedTxCounter = metrics.NewCounter("txpool/underpriced")
+
+	// Metrics for the pool memory usage
+	pendingSlotsGauge = metrics.NewGauge("txpool/pending/slots")
+	queuedSlotsGauge  = metrics.NewGauge("txpool/queued/slots")
+	memoryGauge       = metrics.NewGauge("txpool/memory") // Total size of all pooled transactions in bytes
 )
 
 // TxStatus is the current status of a transaction as seen by the pool.
@@ -132,12 +137,17 @@ type TxPoolConfig struct {
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
+	SlotSize  uint64 // Transaction size in bytes accounted as a single slot (larger ones take multiple)
+	MaxTxSize uint64 // Maximum size in bytes of a single transaction accepted into the pool
+
 	AccountSlots uint64 // Minimum number of executable transaction slots guaranteed per account
 	GlobalSlots  uint64 // Maximum number of executable transaction slots for all accounts
 	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	PrivateBlocks uint64 // Number of blocks private transactions are withheld from broadcast
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -149,12 +159,17 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	PriceLimit: 1,
 	PriceBump:  10,
 
+	SlotSize:  32 * 1024,
+	MaxTxSize: 32 * 1024,
+
 	AccountSlots: 16,
 	GlobalSlots:  4096,
 	AccountQueue: 64,
 	GlobalQueue:  1024,
 
 	Lifetime: 3 * time.Hour,
+
+	PrivateBlocks: 25,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -173,6 +188,14 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool price bump", "provided", conf.PriceBump, "updated", DefaultTxPoolConfig.PriceBump)
 		conf.PriceBump = DefaultTxPoolConfig.PriceBump
 	}
+	if conf.SlotSize < 1 {
+		log.Warn("Sanitizing invalid txpool slot size", "provided", conf.SlotSize, "updated", DefaultTxPoolConfig.SlotSize)
+		conf.SlotSize = DefaultTxPoolConfig.SlotSize
+	}
+	if conf.MaxTxSize < 1 {
+		log.Warn("Sanitizing invalid txpool max transaction size", "provided", conf.MaxTxSize, "updated", DefaultTxPoolConfig.MaxTxSize)
+		conf.MaxTxSize = DefaultTxPoolConfig.MaxTxSize
+	}
 	return conf
 }
 
@@ -189,6 +212,7 @@ type TxPool struct {
 	chain        blockChain
 	gasPrice     *big.Int
 	txFeed       event.Feed
+	releaseFeed  event.Feed
 	scope        event.SubscriptionScope
 	chainHeadCh  chan ChainHeadEvent
 	chainHeadSub event.Subscription
@@ -207,6 +231,10 @@ type TxPool struct {
 	beats   map[common.Address]time.Time       // Last heartbeat from each known account
 	all     map[common.Hash]*types.Transaction // All transactions to allow lookups
 	priced  *txPricedList                      // All transactions sorted by price
+	private map[common.Hash]uint64             // Transactions withheld from broadcast until a release block
+
+	pendingSlots int // Number of slots occupied by all the pending lists
+	queuedSlots  int // Number of slots occupied by all the queued lists
 
 	wg sync.WaitGroup // for shutdown sync
 
@@ -229,6 +257,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		queue:       make(map[common.Address]*txList),
 		beats:       make(map[common.Address]time.Time),
 		all:         make(map[common.Hash]*types.Transaction),
+		private:     make(map[common.Hash]uint64),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
 	}
@@ -301,9 +330,15 @@ func (pool *TxPool) loop() {
 		case <-report.C:
 			pool.mu.RLock()
 			pending, queued := pool.stats()
+			pendingSlots, queuedSlots := pool.slots()
+			memory := pool.memory()
 			stales := pool.priced.stales
 			pool.mu.RUnlock()
 
+			pendingSlotsGauge.Update(int64(pendingSlots))
+			queuedSlotsGauge.Update(int64(queuedSlots))
+			memoryGauge.Update(int64(memory))
+
 			if pending != prevPending || queued != prevQueued || stales != prevStales {
 				log.Debug("Transaction pool status report", "executable", pending, "queued", queued, "stales", stales)
 				prevPending, prevQueued, prevStales = pending, queued, stales
@@ -429,6 +464,28 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// Check the queue and move transactions over to the pending if possible
 	// or remove those that have become invalid
 	pool.promoteExecutables(nil)
+
+	// Release any private transactions that failed to be included in time
+	pool.releasePrivate(newHead.Number.Uint64())
+}
+
+// releasePrivate drops the tracking of private transactions no longer in the
+// pool and releases the ones reaching their release block for broadcast.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) releasePrivate(number uint64) {
+	for hash, release := range pool.private {
+		tx := pool.all[hash]
+		if tx == nil {
+			delete(pool.private, hash)
+			continue
+		}
+		if number >= release {
+			log.Debug("Releasing private transaction", "hash", hash, "number", number)
+			delete(pool.private, hash)
+			go pool.releaseFeed.Send(PrivateTxReleaseEvent{tx})
+		}
+	}
 }
 
 // Stop terminates the transaction pool.
@@ -452,6 +509,12 @@ func (pool *TxPool) SubscribeTxPreEvent(ch chan<- TxPreEvent) event.Subscription
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribePrivateTxReleaseEvent registers a subscription of PrivateTxReleaseEvent
+// and starts sending event to the given channel.
+func (pool *TxPool) SubscribePrivateTxReleaseEvent(ch chan<- PrivateTxReleaseEvent) event.Subscription {
+	return pool.scope.Track(pool.releaseFeed.Subscribe(ch))
+}
+
 // GasPrice returns the current gas price enforced by the transaction pool.
 func (pool *TxPool) GasPrice() *big.Int {
 	pool.mu.RLock()
@@ -504,6 +567,21 @@ func (pool *TxPool) stats() (int, int) {
 	return pending, queued
 }
 
+// slots retrieves the number of slots occupied by the pending and the queued
+// (non-executable) transactions.
+func (pool *TxPool) slots() (int, int) {
+	return pool.pendingSlots, pool.queuedSlots
+}
+
+// memory retrieves the total size of all the transactions tracked by the pool.
+func (pool *TxPool) memory() common.StorageSize {
+	var size common.StorageSize
+	for _, tx := range pool.all {
+		size += tx.Size()
+	}
+	return size
+}
+
 // Content retrieves the data content of the transaction pool, returning all the
 // pending as well as queued transactions, grouped by account and sorted by nonce.
 func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
@@ -535,17 +613,37 @@ func (pool *TxPool) Pending() (map[common.Address]types.Transactions, error) {
 	return pending, nil
 }
 
+// Locals retrieves the accounts currently considered local by the pool.
+func (pool *TxPool) Locals() []common.Address {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	locals := make([]common.Address, 0, len(pool.locals.accounts))
+	for addr := range pool.locals.accounts {
+		locals = append(locals, addr)
+	}
+	return locals
+}
+
 // local retrieves all currently known local transactions, groupped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
+//
+// Private transactions are omitted, as they would be broadcast after a restart.
 func (pool *TxPool) local() map[common.Address]types.Transactions {
 	txs := make(map[common.Address]types.Transactions)
 	for addr := range pool.locals.accounts {
+		var all types.Transactions
 		if pending := pool.pending[addr]; pending != nil {
-			txs[addr] = append(txs[addr], pending.Flatten()...)
+			all = append(all, pending.Flatten()...)
 		}
 		if queued := pool.queue[addr]; queued != nil {
-			txs[addr] = append(txs[addr], queued.Flatten()...)
+			all = append(all, queued.Flatten()...)
+		}
+		for _, tx := range all {
+			if _, private := pool.private[tx.Hash()]; !private {
+				txs[addr] = append(txs[addr], tx)
+			}
 		}
 	}
 	return txs
@@ -554,8 +652,8 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
-	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
-	if tx.Size() > 32*1024 {
+	// Heuristic limit, reject transactions over the size cap to prevent DOS attacks
+	if uint64(tx.Size()) > pool.config.MaxTxSize {
 		return ErrOversizedData
 	}
 	// Transactions can't be negative. This may never happen using RLP decoded
@@ -615,7 +713,8 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		return false, err
 	}
 	// If the transaction pool is full, discard underpriced transactions
-	if uint64(len(pool.all)) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
+	pending, queued := pool.slots()
+	if used := uint64(pending + queued + numSlots(tx, pool.config.SlotSize)); used > pool.config.GlobalSlots+pool.config.GlobalQueue {
 		// If the new transaction is underpriced, don't accept it
 		if pool.priced.Underpriced(tx, pool.locals) {
 			log.Trace("Discarding underpriced transaction", "hash", hash, "price", tx.GasPrice())
@@ -623,7 +722,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 			return false, ErrUnderpriced
 		}
 		// New transaction is better than our worse ones, make room for it
-		drop := pool.priced.Discard(len(pool.all)-int(pool.config.GlobalSlots+pool.config.GlobalQueue-1), pool.locals)
+		drop := pool.priced.Discard(int(used-pool.config.GlobalSlots-pool.config.GlobalQueue), pool.config.SlotSize, pool.locals)
 		for _, tx := range drop {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "price", tx.GasPrice())
 			underpricedTxCounter.Inc(1)
@@ -678,7 +777,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 	// Try to insert the transaction into the future queue
 	from, _ := types.Sender(pool.signer, tx) // already validated
 	if pool.queue[from] == nil {
-		pool.queue[from] = newTxList(false)
+		pool.queue[from] = newTxList(false, pool.config.SlotSize, &pool.queuedSlots)
 	}
 	inserted, old := pool.queue[from].Add(tx, pool.config.PriceBump)
 	if !inserted {
@@ -692,18 +791,24 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 		pool.priced.Removed()
 		queuedReplaceCounter.Inc(1)
 	}
-	pool.all[hash] = tx
-	pool.priced.Put(tx)
+	// Postponed pending transactions are already tracked, don't price them twice
+	if _, ok := pool.all[hash]; !ok {
+		pool.all[hash] = tx
+		pool.priced.Put(tx)
+	}
 	return old != nil, nil
 }
 
 // journalTx adds the specified transaction to the local disk journal if it is
 // deemed to have been sent from a local account.
 func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
-	// Only journal if it's enabled and the transaction is local
+	// Only journal if it's enabled and the transaction is local, but not private
 	if pool.journal == nil || !pool.locals.contains(from) {
 		return
 	}
+	if _, private := pool.private[tx.Hash()]; private {
+		return
+	}
 	if err := pool.journal.insert(tx); err != nil {
 		log.Warn("Failed to journal local transaction", "err", err)
 	}
@@ -715,7 +820,7 @@ func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
 func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.Transaction) {
 	// Try to insert the transaction into the pending queue
 	if pool.pending[addr] == nil {
-		pool.pending[addr] = newTxList(true)
+		pool.pending[addr] = newTxList(true, pool.config.SlotSize, &pool.pendingSlots)
 	}
 	list := pool.pending[addr]
 
@@ -754,6 +859,45 @@ func (pool *TxPool) AddLocal(tx *types.Transaction) error {
 	return pool.addTx(tx, !pool.config.NoLocals)
 }
 
+// AddPrivate enqueues a single transaction into the pool if it is valid, marking
+// it as a local one that is withheld from network broadcast. If the transaction
+// is not included within the configured number of blocks, it is released to be
+// broadcast like any other.
+func (pool *TxPool) AddPrivate(tx *types.Transaction) error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	// Mark the transaction private before insertion to avoid journaling it
+	hash := tx.Hash()
+	if pool.all[hash] != nil {
+		log.Trace("Discarding already known transaction", "hash", hash)
+		return fmt.Errorf("known transaction: %x", hash)
+	}
+	pool.private[hash] = pool.chain.CurrentBlock().NumberU64() + pool.config.PrivateBlocks
+
+	// Try to inject the transaction and update any state
+	replace, err := pool.add(tx, !pool.config.NoLocals)
+	if err != nil {
+		delete(pool.private, hash)
+		return err
+	}
+	// If we added a new transaction, run promotion checks and return
+	if !replace {
+		from, _ := types.Sender(pool.signer, tx) // already validated
+		pool.promoteExecutables([]common.Address{from})
+	}
+	return nil
+}
+
+// IsPrivate returns whether a transaction is withheld from network broadcast.
+func (pool *TxPool) IsPrivate(hash common.Hash) bool {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	_, private := pool.private[hash]
+	return private
+}
+
 // AddRemote enqueues a single transaction into the pool if it is valid. If the
 // sender is not among the locally tracked ones, full pricing constraints will
 // apply.
@@ -874,15 +1018,14 @@ func (pool *TxPool) removeTx(hash common.Hash) {
 	// Remove the transaction from the pending lists and reset the account nonce
 	if pending := pool.pending[addr]; pending != nil {
 		if removed, invalids := pending.Remove(tx); removed {
-			// If no more transactions are left, remove the list
+			// If no more pending transactions are left, remove the list
 			if pending.Empty() {
 				delete(pool.pending, addr)
 				delete(pool.beats, addr)
-			} else {
-				// Otherwise postpone any invalidated transactions
-				for _, tx := range invalids {
-					pool.enqueueTx(tx.Hash(), tx)
-				}
+			}
+			// Postpone any invalidated transactions
+			for _, tx := range invalids {
+				pool.enqueueTx(tx.Hash(), tx)
 			}
 			// Update the account nonce if needed
 			if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
@@ -941,7 +1084,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 		}
 		// Drop all transactions over the allowed limit
 		if !pool.locals.contains(addr) {
-			for _, tx := range list.Cap(int(pool.config.AccountQueue)) {
+			for _, tx := range list.CapSlots(int(pool.config.AccountQueue)) {
 				hash := tx.Hash()
 				delete(pool.all, hash)
 				pool.priced.Removed()
@@ -955,23 +1098,20 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 		}
 	}
 	// If the pending limit is overflown, start equalizing allowances
-	pending := uint64(0)
-	for _, list := range pool.pending {
-		pending += uint64(list.Len())
-	}
-	if pending > pool.config.GlobalSlots {
+	pending, queued := pool.slots()
+	if uint64(pending) > pool.config.GlobalSlots {
 		pendingBeforeCap := pending
 		// Assemble a spam order to penalize large transactors first
 		spammers := prque.New()
 		for addr, list := range pool.pending {
 			// Only evict transactions from high rollers
-			if !pool.locals.contains(addr) && uint64(list.Len()) > pool.config.AccountSlots {
-				spammers.Push(addr, float32(list.Len()))
+			if !pool.locals.contains(addr) && uint64(list.Slots()) > pool.config.AccountSlots {
+				spammers.Push(addr, float32(list.Slots()))
 			}
 		}
 		// Gradually drop transactions from offenders
 		offenders := []common.Address{}
-		for pending > pool.config.GlobalSlots && !spammers.Empty() {
+		for uint64(pending) > pool.config.GlobalSlots && !spammers.Empty() {
 			// Retrieve the next offender if not local address
 			offender, _ := spammers.Pop()
 			offenders = append(offenders, offender.(common.Address))
@@ -979,58 +1119,32 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			// Equalize balances until all the same or below threshold
 			if len(offenders) > 1 {
 				// Calculate the equalization threshold for all current offenders
-				threshold := pool.pending[offender.(common.Address)].Len()
+				threshold := pool.pending[offender.(common.Address)].Slots()
 
 				// Iteratively reduce all offenders until below limit or threshold reached
-				for pending > pool.config.GlobalSlots && pool.pending[offenders[len(offenders)-2]].Len() > threshold {
+				for uint64(pending) > pool.config.GlobalSlots && pool.pending[offenders[len(offenders)-2]].Slots() > threshold {
 					for i := 0; i < len(offenders)-1; i++ {
-						list := pool.pending[offenders[i]]
-						for _, tx := range list.Cap(list.Len() - 1) {
-							// Drop the transaction from the global pools too
-							hash := tx.Hash()
-							delete(pool.all, hash)
-							pool.priced.Removed()
-
-							// Update the account nonce to the dropped transaction
-							if nonce := tx.Nonce(); pool.pendingState.GetNonce(offenders[i]) > nonce {
-								pool.pendingState.SetNonce(offenders[i], nonce)
-							}
-							log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
+						if pool.pending[offenders[i]].Slots() > threshold {
+							pending -= pool.dropPendingTail(offenders[i])
 						}
-						pending--
 					}
 				}
 			}
 		}
 		// If still above threshold, reduce to limit or min allowance
-		if pending > pool.config.GlobalSlots && len(offenders) > 0 {
-			for pending > pool.config.GlobalSlots && uint64(pool.pending[offenders[len(offenders)-1]].Len()) > pool.config.AccountSlots {
+		if uint64(pending) > pool.config.GlobalSlots && len(offenders) > 0 {
+			for uint64(pending) > pool.config.GlobalSlots && uint64(pool.pending[offenders[len(offenders)-1]].Slots()) > pool.config.AccountSlots {
 				for _, addr := range offenders {
-					list := pool.pending[addr]
-					for _, tx := range list.Cap(list.Len() - 1) {
-						// Drop the transaction from the global pools too
-						hash := tx.Hash()
-						delete(pool.all, hash)
-						pool.priced.Removed()
-
-						// Update the account nonce to the dropped transaction
-						if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
-							pool.pendingState.SetNonce(addr, nonce)
-						}
-						log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
+					if uint64(pool.pending[addr].Slots()) > pool.config.AccountSlots {
+						pending -= pool.dropPendingTail(addr)
 					}
-					pending--
 				}
 			}
 		}
 		pendingRateLimitCounter.Inc(int64(pendingBeforeCap - pending))
 	}
 	// If we've queued more transactions than the hard limit, drop oldest ones
-	queued := uint64(0)
-	for _, list := range pool.queue {
-		queued += uint64(list.Len())
-	}
-	if queued > pool.config.GlobalQueue {
+	if uint64(queued) > pool.config.GlobalQueue {
 		// Sort all accounts with queued transactions by heartbeat
 		addresses := make(addresssByHeartbeat, 0, len(pool.queue))
 		for addr := range pool.queue {
@@ -1041,32 +1155,58 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 		sort.Sort(addresses)
 
 		// Drop transactions until the total is below the limit or only locals remain
-		for drop := queued - pool.config.GlobalQueue; drop > 0 && len(addresses) > 0; {
+		for drop := queued - int(pool.config.GlobalQueue); drop > 0 && len(addresses) > 0; {
 			addr := addresses[len(addresses)-1]
 			list := pool.queue[addr.address]
 
 			addresses = addresses[:len(addresses)-1]
 
 			// Drop all transactions if they are less than the overflow
-			if size := uint64(list.Len()); size <= drop {
+			if size := list.Slots(); size <= drop {
+				count := list.Len()
 				for _, tx := range list.Flatten() {
 					pool.removeTx(tx.Hash())
 				}
 				drop -= size
-				queuedRateLimitCounter.Inc(int64(size))
+				queuedRateLimitCounter.Inc(int64(count))
 				continue
 			}
 			// Otherwise drop only last few transactions
 			txs := list.Flatten()
 			for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
 				pool.removeTx(txs[i].Hash())
-				drop--
+				drop -= numSlots(txs[i], pool.config.SlotSize)
 				queuedRateLimitCounter.Inc(1)
 			}
 		}
 	}
 }
 
+// dropPendingTail removes the highest nonce pending transaction of an account
+// to make room for others, returning the number of slots released.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) dropPendingTail(addr common.Address) int {
+	var (
+		list  = pool.pending[addr]
+		slots int
+	)
+	for _, tx := range list.Cap(list.Len() - 1) {
+		// Drop the transaction from the global pools too
+		hash := tx.Hash()
+		delete(pool.all, hash)
+		pool.priced.Removed()
+
+		// Update the account nonce to the dropped transaction
+		if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
+			pool.pendingState.SetNonce(addr, nonce)
+		}
+		log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
+		slots += numSlots(tx, pool.config.SlotSize)
+	}
+	return slots
+}
+
 // demoteUnexecutables removes invalid and processed transactions from the pools
 // executable/pending queue and any subsequent transactions that become unexecutable
 // are moved back into the future queue.
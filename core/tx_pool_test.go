@@ -42,6 +42,7 @@ var testTxPoolConfig TxPoolConfig
 func init() {
 	testTxPoolConfig = DefaultTxPoolConfig
 	testTxPoolConfig.Journal = ""
+	testTxPoolConfig.MaxTxSize = 4 * testTxPoolConfig.SlotSize // Allow multi-slot transactions
 }
 
 type testBlockChain struct {
@@ -77,6 +78,12 @@ func pricedTransaction(nonce uint64, gaslimit, gasprice *big.Int, key *ecdsa.Pri
 	return tx
 }
 
+func dataTransaction(nonce uint64, gaslimit *big.Int, key *ecdsa.PrivateKey, bytes uint64) *types.Transaction {
+	data := make([]byte, bytes)
+	tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(100), gaslimit, big.NewInt(1), data), types.HorizonSigner{}, key)
+	return tx
+}
+
 func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
 	db, _ := ruedb.NewMemDatabase()
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
@@ -101,6 +108,23 @@ func validateTxPoolInternals(pool *TxPool) error {
 	if priced := pool.priced.items.Len() - pool.priced.stales; priced != pending+queued {
 		return fmt.Errorf("total priced transaction count %d != %d pending + %d queued", priced, pending, queued)
 	}
+	// Ensure the slot accounting of every list is consistent with its contents
+	var totals [2]int
+	for i, lists := range []map[common.Address]*txList{pool.pending, pool.queue} {
+		for addr, list := range lists {
+			slots := 0
+			for _, tx := range list.txs.items {
+				slots += numSlots(tx, pool.config.SlotSize)
+			}
+			if list.Slots() != slots {
+				return fmt.Errorf("account %x: slot count mismatch: have %d, want %d", addr, list.Slots(), slots)
+			}
+			totals[i] += slots
+		}
+	}
+	if pendingSlots, queuedSlots := pool.slots(); pendingSlots != totals[0] || queuedSlots != totals[1] {
+		return fmt.Errorf("slot totals mismatch: have %d pending + %d queued, want %d + %d", pendingSlots, queuedSlots, totals[0], totals[1])
+	}
 	// Ensure the next nonce to assign is the correct one
 	for addr, txs := range pool.pending {
 		// Find the last transaction
@@ -628,6 +652,44 @@ func TestTransactionPostponing(t *testing.T) {
 	}
 }
 
+// Tests that removing the lowest nonce pending transaction of an account (e.g.
+// discarding it as underpriced) postpones all the subsequent ones into the future
+// queue, even if none are left pending. Otherwise they would linger in the pool
+// without being tracked by any list or counted against the slot limits.
+func TestTransactionPendingRemovalPostponing(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+	pool.currentState.AddBalance(account, big.NewInt(1000000))
+
+	txs := []*types.Transaction{}
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		txs = append(txs, transaction(nonce, big.NewInt(100000), key))
+	}
+	for i, err := range pool.AddRemotes(txs) {
+		if err != nil {
+			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	pool.mu.Lock()
+	pool.removeTx(txs[0].Hash())
+	pool.mu.Unlock()
+
+	pending, queued := pool.Stats()
+	if pending != 0 {
+		t.Errorf("pending transactions mismatched: have %d, want %d", pending, 0)
+	}
+	if queued != 2 {
+		t.Errorf("queued transactions mismatched: have %d, want %d", queued, 2)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that if the transaction pool has both executable and non-executable
 // transactions from an origin account, filling the nonce gap moves all queued
 // ones into the pending pool.
@@ -720,6 +782,61 @@ func TestTransactionQueueAccountLimiting(t *testing.T) {
 	}
 }
 
+// Tests that transactions exceeding the maximum allowed size are rejected, while
+// large ones below it are accepted, occupying multiple slots.
+func TestTransactionOversizedRejection(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+	pool.currentState.AddBalance(account, big.NewInt(1000000000))
+
+	if err := pool.AddRemote(dataTransaction(0, big.NewInt(900000), key, testTxPoolConfig.MaxTxSize)); err != ErrOversizedData {
+		t.Errorf("oversized transaction error mismatch: have %v, want %v", err, ErrOversizedData)
+	}
+	tx := dataTransaction(0, big.NewInt(900000), key, testTxPoolConfig.SlotSize+1)
+	if err := pool.AddRemote(tx); err != nil {
+		t.Fatalf("failed to add multi-slot transaction: %v", err)
+	}
+	if slots := pool.pending[account].Slots(); slots != 2 {
+		t.Errorf("pending slot count mismatch: have %d, want %d", slots, 2)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that large queued transactions count against the account allowance
+// with all the slots they occupy, not just as a single transaction.
+func TestTransactionQueueAccountSlotLimiting(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+	pool.currentState.AddBalance(account, big.NewInt(1000000000))
+
+	// Queue up transactions taking three slots each, overflowing the allowance
+	limit := int(testTxPoolConfig.AccountQueue) / 3
+	for i := uint64(1); i <= uint64(limit+5); i++ {
+		if err := pool.AddRemote(dataTransaction(i, big.NewInt(900000), key, 2*testTxPoolConfig.SlotSize+1)); err != nil {
+			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	if queued := pool.queue[account].Len(); queued != limit {
+		t.Errorf("queue limit mismatch: have %d, want %d", queued, limit)
+	}
+	if slots := pool.queue[account].Slots(); slots > int(testTxPoolConfig.AccountQueue) {
+		t.Errorf("queue slots overflown allowance: %d > %d", slots, testTxPoolConfig.AccountQueue)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that if the transaction count belonging to multiple accounts go above
 // some threshold, the higher transactions are dropped to prevent DOS attacks.
 //
@@ -1022,6 +1139,54 @@ func TestTransactionPendingGlobalLimiting(t *testing.T) {
 	}
 }
 
+// Tests that if the slots occupied by large pending transactions of multiple
+// accounts go above the global limit, the pool is equalized by memory use rather
+// than transaction count.
+func TestTransactionPendingGlobalSlotLimiting(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool to test the limit enforcement with
+	db, _ := ruedb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, big.NewInt(100000000), new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.GlobalSlots = config.AccountSlots * 4
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	// Create a number of test accounts and fund them
+	keys := make([]*ecdsa.PrivateKey, 4)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	// Generate a batch of two-slot transactions, fitting by count but not by size
+	txs := types.Transactions{}
+	for _, key := range keys {
+		for j := uint64(0); j < config.AccountSlots; j++ {
+			txs = append(txs, dataTransaction(j, big.NewInt(900000), key, config.SlotSize+1))
+		}
+	}
+	pool.AddRemotes(txs)
+
+	pending, _ := pool.Stats()
+	slots := 0
+	for _, list := range pool.pending {
+		slots += list.Slots()
+	}
+	if slots > int(config.GlobalSlots) {
+		t.Fatalf("total pending slots overflow allowance: %d > %d", slots, config.GlobalSlots)
+	}
+	if pending >= len(txs) {
+		t.Fatalf("no pending transactions evicted: have %d, want < %d", pending, len(txs))
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that if transactions start being capped, transactions are also removed from 'all'
 func TestTransactionCapClearsFromAll(t *testing.T) {
 	t.Parallel()
@@ -1563,6 +1728,102 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	pool.Stop()
 }
 
+// Tests that private transactions are withheld from announcement until their
+// release block is reached, and that they are not journaled to disk.
+func TestTransactionPrivateRelease(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary file for the journal
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary journal: %v", err)
+	}
+	journal := file.Name()
+	defer os.Remove(journal)
+
+	// Clean up the temporary file, we only need the path for now
+	file.Close()
+	os.Remove(journal)
+
+	// Create the pool to test the private transactions with
+	db, _ := ruedb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, big.NewInt(1000000), new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.Journal = journal
+	config.PrivateBlocks = 2
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	releases := make(chan PrivateTxReleaseEvent, 4)
+	sub := pool.SubscribePrivateTxReleaseEvent(releases)
+	defer sub.Unsubscribe()
+
+	key, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+
+	private, public := transaction(0, big.NewInt(100000), key), transaction(1, big.NewInt(100000), key)
+	if err := pool.AddPrivate(private); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	if err := pool.AddLocal(public); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if err := pool.AddPrivate(private); err == nil {
+		t.Fatalf("added known private transaction twice")
+	}
+	if !pool.IsPrivate(private.Hash()) {
+		t.Fatalf("private transaction not marked private")
+	}
+	if pool.IsPrivate(public.Hash()) {
+		t.Fatalf("local transaction marked private")
+	}
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 2)
+	}
+	// Ensure the journal only retains the public transaction
+	pool.mu.Lock()
+	if err := pool.journal.rotate(pool.local()); err != nil {
+		t.Fatalf("failed to rotate journal: %v", err)
+	}
+	pool.mu.Unlock()
+
+	var journaled []*types.Transaction
+	load := newTxJournal(journal)
+	load.load(func(tx *types.Transaction) error {
+		journaled = append(journaled, tx)
+		return nil
+	})
+	load.close()
+	if len(journaled) != 1 || journaled[0].Hash() != public.Hash() {
+		t.Fatalf("journaled transactions mismatch: have %v, want [%x]", journaled, public.Hash())
+	}
+	// Advance the chain below and up to the release block
+	for number, released := int64(1), false; number <= 2; number++ {
+		pool.lockedReset(nil, &types.Header{Number: big.NewInt(number), GasLimit: blockchain.gasLimit})
+
+		select {
+		case ev := <-releases:
+			if ev.Tx.Hash() != private.Hash() {
+				t.Fatalf("released transaction mismatch: have %x, want %x", ev.Tx.Hash(), private.Hash())
+			}
+			released = true
+		case <-time.After(100 * time.Millisecond):
+		}
+		if want := number >= 2; released != want {
+			t.Fatalf("block %d: release mismatch: have %v, want %v", number, released, want)
+		}
+		if pool.IsPrivate(private.Hash()) == released {
+			t.Fatalf("block %d: private flag mismatch: have %v, want %v", number, !released, released)
+		}
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // TestTransactionStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestTransactionStatusCheck(t *testing.T) {
@@ -149,6 +149,14 @@ type FilterQuery struct {
 	Topics [][]common.Hash
 }
 
+// PendingTransactionQuery contains options for pending transaction filtering.
+// Empty fields match any transaction.
+type PendingTransactionQuery struct {
+	From    []common.Address // restricts matches to transactions signed by specific accounts
+	To      []common.Address // restricts matches to transactions sent to specific accounts
+	Methods [][4]byte        // restricts matches to calls of specific contract method selectors
+}
+
 // LogFilterer provides access to contract log events using a one-off query or continuous
 // event subscription.
 //
@@ -0,0 +1,140 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rueapi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/common/hexutil"
+	"github.com/Rue-Foundation/go-rue/core"
+	"github.com/Rue-Foundation/go-rue/core/state"
+	"github.com/Rue-Foundation/go-rue/core/types"
+	"github.com/Rue-Foundation/go-rue/crypto"
+	"github.com/Rue-Foundation/go-rue/rlp"
+	"github.com/Rue-Foundation/go-rue/rpc"
+	"github.com/Rue-Foundation/go-rue/trie"
+)
+
+// The methods below serve raw, self-authenticating chain data. They allow a
+// client that only tracks the header chain to retrieve and verify everything
+// else from an untrusted node.
+
+// GetRawHeaderByNumber retrieves the RLP encoded header of a block.
+func (s *PublicBlockChainAPI) GetRawHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
+	header, err := s.b.HeaderByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		if err == nil {
+			err = fmt.Errorf("header #%d not found", blockNr)
+		}
+		return nil, err
+	}
+	return rlp.EncodeToBytes(header)
+}
+
+// GetRawHeaderByHash retrieves the RLP encoded header of a block.
+func (s *PublicBlockChainAPI) GetRawHeaderByHash(ctx context.Context, blockHash common.Hash) (hexutil.Bytes, error) {
+	header := s.header(blockHash)
+	if header == nil {
+		return nil, fmt.Errorf("header %x not found", blockHash)
+	}
+	return rlp.EncodeToBytes(header)
+}
+
+// GetRawBlockBody retrieves the RLP encoded transactions and uncles of a block.
+func (s *PublicBlockChainAPI) GetRawBlockBody(ctx context.Context, blockHash common.Hash) (hexutil.Bytes, error) {
+	block, err := s.b.GetBlock(ctx, blockHash)
+	if block == nil || err != nil {
+		if err == nil {
+			err = fmt.Errorf("block %x not found", blockHash)
+		}
+		return nil, err
+	}
+	return rlp.EncodeToBytes(&types.Body{Transactions: block.Transactions(), Uncles: block.Uncles()})
+}
+
+// GetRawReceipts retrieves the consensus RLP encoding of the receipts of a block.
+func (s *PublicBlockChainAPI) GetRawReceipts(ctx context.Context, blockHash common.Hash) (hexutil.Bytes, error) {
+	receipts, err := s.b.GetReceipts(ctx, blockHash)
+	if receipts == nil || err != nil {
+		if err == nil {
+			err = fmt.Errorf("receipts of block %x not found", blockHash)
+		}
+		return nil, err
+	}
+	return rlp.EncodeToBytes(receipts)
+}
+
+// GetCodeByHash retrieves a contract code by its hash.
+func (s *PublicBlockChainAPI) GetCodeByHash(ctx context.Context, codeHash common.Hash) (hexutil.Bytes, error) {
+	if codeHash == crypto.Keccak256Hash(nil) {
+		return hexutil.Bytes{}, nil
+	}
+	code, err := s.b.ChainDb().Get(codeHash[:])
+	if err != nil {
+		return nil, fmt.Errorf("code %x not found", codeHash)
+	}
+	return code, nil
+}
+
+// GetTrieProof returns the Merkle proof of a key in the state trie of a block,
+// or in the storage trie of an account if accKey (the hash of the address) is
+// set. The key is the already hashed trie key, not the address or slot. The
+// proof nodes are returned ordered from the root downwards.
+func (s *PublicBlockChainAPI) GetTrieProof(ctx context.Context, blockHash common.Hash, accKey hexutil.Bytes, key hexutil.Bytes) ([]hexutil.Bytes, error) {
+	header := s.header(blockHash)
+	if header == nil {
+		return nil, fmt.Errorf("header %x not found", blockHash)
+	}
+	tr, err := trie.New(header.Root, s.b.ChainDb())
+	if err != nil {
+		return nil, err
+	}
+	if len(accKey) > 0 {
+		data, err := tr.TryGet(accKey)
+		if err != nil {
+			return nil, err
+		}
+		var acc state.Account
+		if err := rlp.DecodeBytes(data, &acc); err != nil {
+			return nil, fmt.Errorf("account %x not found", []byte(accKey))
+		}
+		if tr, err = trie.New(acc.Root, s.b.ChainDb()); err != nil {
+			return nil, err
+		}
+	}
+	var proof proofList
+	if err := tr.Prove(key, 0, &proof); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// header retrieves a header by hash from the local database.
+func (s *PublicBlockChainAPI) header(hash common.Hash) *types.Header {
+	db := s.b.ChainDb()
+	return core.GetHeader(db, hash, core.GetBlockNumber(db, hash))
+}
+
+// proofList collects the nodes of a Merkle proof in the order they are proven.
+type proofList []hexutil.Bytes
+
+func (n *proofList) Put(key []byte, value []byte) error {
+	*n = append(*n, value)
+	return nil
+}
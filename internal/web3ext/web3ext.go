@@ -23,6 +23,7 @@ var Modules = map[string]string{
 	"clique":     Clique_JS,
 	"debug":      Debug_JS,
 	"eth":        Eth_JS,
+	"les":        LES_JS,
 	"miner":      Miner_JS,
 	"net":        Net_JS,
 	"personal":   Personal_JS,
@@ -116,11 +117,36 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'addPeer',
 			call: 'admin_addPeer',
-			params: 1
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new web3._extend.Method({
 			name: 'removePeer',
 			call: 'admin_removePeer',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'addTrustedPeer',
+			call: 'admin_addTrustedPeer',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'removeTrustedPeer',
+			call: 'admin_removeTrustedPeer',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'banPeer',
+			call: 'admin_banPeer',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'unban',
+			call: 'admin_unban',
 			params: 1
 		}),
 		new web3._extend.Method({
@@ -173,6 +199,10 @@ web3._extend({
 			name: 'datadir',
 			getter: 'admin_datadir'
 		}),
+		new web3._extend.Property({
+			name: 'bans',
+			getter: 'admin_listBans'
+		}),
 	]
 });
 `
@@ -385,6 +415,11 @@ web3._extend({
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
 		}),
+		new web3._extend.Method({
+			name: 'sendPrivateRawTransaction',
+			call: 'eth_sendPrivateRawTransaction',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'resend',
 			call: 'eth_resend',
@@ -469,8 +504,40 @@ web3._extend({
 			name: 'getHashrate',
 			call: 'miner_getHashrate'
 		}),
+		new web3._extend.Method({
+			name: 'setOrdering',
+			call: 'miner_setOrdering',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setBlacklist',
+			call: 'miner_setBlacklist',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setMinGasPrice',
+			call: 'miner_setMinGasPrice',
+			params: 2,
+			inputFormatter: [null, web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'setMaxSenderGas',
+			call: 'miner_setMaxSenderGas',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'setStratumDifficulty',
+			call: 'miner_setStratumDifficulty',
+			params: 2
+		}),
 	],
-	properties: []
+	properties: [
+		new web3._extend.Property({
+			name: 'inclusionRules',
+			getter: 'miner_inclusionRules'
+		}),
+	]
 });
 `
 
@@ -610,3 +677,29 @@ web3._extend({
 	]
 });
 `
+
+const LES_JS = `
+web3._extend({
+	property: 'les',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'setClientCapacity',
+			call: 'les_setClientCapacity',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'clientInfo',
+			call: 'les_clientInfo',
+			params: 1
+		}),
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'totalCapacity',
+			getter: 'les_totalCapacity'
+		}),
+	]
+});
+`
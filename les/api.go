@@ -0,0 +1,51 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+)
+
+// PrivateLightServerAPI provides an API to manage the capacity assigned to the
+// light clients of a LES server.
+type PrivateLightServerAPI struct {
+	server *LesServer
+}
+
+// NewPrivateLightServerAPI creates a new LES server management API.
+func NewPrivateLightServerAPI(server *LesServer) *PrivateLightServerAPI {
+	return &PrivateLightServerAPI{server: server}
+}
+
+// SetClientCapacity assigns a guaranteed capacity to a client, turning it into a
+// priority client. A zero capacity reverts the client to the free pool. The sum
+// of the assigned capacities may not exceed the total capacity of the server.
+func (api *PrivateLightServerAPI) SetClientCapacity(id discover.NodeID, capacity uint64) error {
+	return api.server.clientPool.setCapacity(id, capacity)
+}
+
+// ClientInfo retrieves the capacity assigned to a client and its connection
+// status.
+func (api *PrivateLightServerAPI) ClientInfo(id discover.NodeID) ClientInfo {
+	return api.server.clientPool.clientInfo(id)
+}
+
+// TotalCapacity returns the total capacity shared by all connected clients,
+// measured in the same units as the minimum recharge rate of a client.
+func (api *PrivateLightServerAPI) TotalCapacity() uint64 {
+	return api.server.clientPool.totalCap
+}
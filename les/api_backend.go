@@ -18,6 +18,7 @@ package les
 
 import (
 	"context"
+	"errors"
 	"math/big"
 
 	"github.com/Rue-Foundation/go-rue/accounts"
@@ -37,6 +38,10 @@ import (
 	"github.com/Rue-Foundation/go-rue/rpc"
 )
 
+// errPrivateTxUnsupported is returned when submitting a private transaction to
+// a light client, which has no local miner to include it without broadcasting.
+var errPrivateTxUnsupported = errors.New("private transactions unsupported by light clients")
+
 type LesApiBackend struct {
 	eth *LightRuereum
 	gpo *gasprice.Oracle
@@ -101,6 +106,10 @@ func (b *LesApiBackend) SendTx(ctx context.Context, signedTx *types.Transaction)
 	return b.eth.txPool.Add(ctx, signedTx)
 }
 
+func (b *LesApiBackend) SendPrivateTx(ctx context.Context, signedTx *types.Transaction) error {
+	return errPrivateTxUnsupported
+}
+
 func (b *LesApiBackend) RemoveTx(txHash common.Hash) {
 	b.eth.txPool.RemoveTx(txHash)
 }
@@ -178,6 +187,14 @@ func (b *LesApiBackend) BloomStatus() (uint64, uint64) {
 		return 0, 0
 	}
 	sections, _, _ := b.eth.bloomIndexer.Sections()
+
+	// Sections covered by the BloomTrie can be filtered via ODR too, even if
+	// they were never indexed locally
+	if b.eth.bloomTrieIndexer != nil {
+		if trieSections, _, _ := b.eth.bloomTrieIndexer.Sections(); trieSections > sections {
+			sections = trieSections
+		}
+	}
 	return light.BloomTrieFrequency, sections
 }
 
@@ -114,6 +114,9 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightRuereum, error) {
 	if leth.blockchain, err = light.NewLightChain(leth.odr, leth.chainConfig, leth.engine); err != nil {
 		return nil, err
 	}
+	if config.LightCheckpoint != nil {
+		leth.blockchain.AddTrustedCheckpoint(*config.LightCheckpoint)
+	}
 	leth.bloomIndexer.Start(leth.blockchain)
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
@@ -122,8 +125,15 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightRuereum, error) {
 		core.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 
+	var ulc *ulc
+	if len(config.UltraLightServers) > 0 {
+		if ulc, err = newULC(config.UltraLightServers, config.UltraLightThreshold); err != nil {
+			return nil, err
+		}
+		log.Info("Ultra light client mode enabled", "servers", len(ulc.trusted), "threshold", ulc.threshold)
+	}
 	leth.txPool = light.NewTxPool(leth.chainConfig, leth.blockchain, leth.relay)
-	if leth.protocolManager, err = NewProtocolManager(leth.chainConfig, true, ClientProtocolVersions, config.NetworkId, leth.eventMux, leth.engine, leth.peers, leth.blockchain, nil, chainDb, leth.odr, leth.relay, quitSync, &leth.wg); err != nil {
+	if leth.protocolManager, err = NewProtocolManager(leth.chainConfig, true, ClientProtocolVersions, config.NetworkId, leth.eventMux, leth.engine, leth.peers, leth.blockchain, nil, chainDb, leth.odr, leth.relay, ulc, quitSync, &leth.wg); err != nil {
 		return nil, err
 	}
 	leth.ApiBackend = &LesApiBackend{leth, nil}
@@ -225,6 +235,11 @@ func (s *LightRuereum) Start(srvr *p2p.Server) error {
 	// servers always advertise all supported protocols
 	protocolVersion := ClientProtocolVersions[len(ClientProtocolVersions)-1]
 	s.serverPool.start(srvr, lesTopic(s.blockchain.Genesis().Hash(), protocolVersion))
+	if ulc := s.protocolManager.ulc; ulc != nil {
+		for _, node := range ulc.servers {
+			srvr.AddPeer(node)
+		}
+	}
 	s.protocolManager.Start()
 	return nil
 }
@@ -0,0 +1,233 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/core"
+	"github.com/Rue-Foundation/go-rue/crypto"
+	"github.com/Rue-Foundation/go-rue/light"
+	"github.com/Rue-Foundation/go-rue/log"
+	"github.com/Rue-Foundation/go-rue/rlp"
+	"github.com/Rue-Foundation/go-rue/ruedb"
+	"github.com/Rue-Foundation/go-rue/trie"
+)
+
+const (
+	benchmarkRounds  = 20                     // Maximum number of measured requests per request type and size
+	benchmarkTimeout = 500 * time.Millisecond // Maximum time spent measuring a single request type
+)
+
+var errBenchmarkUnavailable = errors.New("benchmark data unavailable")
+
+// requestBenchmark serves a request with the given number of items against the
+// local database, without sending out the results.
+type requestBenchmark func(pm *ProtocolManager, rnd *rand.Rand, count int) error
+
+// benchmarks are the request types calibrated at startup with their maximum
+// number of items per request.
+var benchmarks = map[uint64]struct {
+	max int
+	run requestBenchmark
+}{
+	GetBlockHeadersMsg:     {MaxHeaderFetch, benchmarkHeaders},
+	GetBlockBodiesMsg:      {MaxBodyFetch, benchmarkBodies},
+	GetReceiptsMsg:         {MaxReceiptFetch, benchmarkReceipts},
+	GetProofsV2Msg:         {MaxProofsFetch, benchmarkProofs},
+	GetHelperTrieProofsMsg: {MaxHelperTrieProofsFetch, benchmarkHelperTrie},
+	GetTxStatusMsg:         {MaxTxStatus, benchmarkTxStatus},
+}
+
+// runBenchmarks measures the serving time of each calibrated request type with
+// a single and with the maximum number of items, returning linear cost models
+// fitted to the measurements. Request types lacking the data to benchmark with
+// are omitted.
+func runBenchmarks(pm *ProtocolManager) map[uint64]*linReg {
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	results := make(map[uint64]*linReg)
+	for code, bench := range benchmarks {
+		single, err := measureBenchmark(pm, rnd, bench.run, 1)
+		if err != nil {
+			log.Debug("Skipping request benchmark", "code", code, "err", err)
+			continue
+		}
+		full, err := measureBenchmark(pm, rnd, bench.run, bench.max)
+		if err != nil {
+			log.Debug("Skipping request benchmark", "code", code, "err", err)
+			continue
+		}
+		// Seed the cost model with as many samples as the default prior
+		l := new(linReg)
+		for i := 0; i < 50; i++ {
+			l.add(1, single)
+			l.add(float64(bench.max), full)
+		}
+		results[code] = l
+		log.Debug("Benchmarked request type", "code", code, "single", time.Duration(single), "max", bench.max, "full", time.Duration(full))
+	}
+	return results
+}
+
+// measureBenchmark returns the average serving time in nanoseconds of requests
+// of the given size.
+func measureBenchmark(pm *ProtocolManager, rnd *rand.Rand, run requestBenchmark, count int) (float64, error) {
+	var (
+		start  = time.Now()
+		rounds int
+	)
+	for rounds < benchmarkRounds && time.Since(start) < benchmarkTimeout {
+		if err := run(pm, rnd, count); err != nil {
+			return 0, err
+		}
+		rounds++
+	}
+	return float64(time.Since(start)) / float64(rounds), nil
+}
+
+// randomBlocks picks the numbers of count random canonical blocks.
+func randomBlocks(pm *ProtocolManager, rnd *rand.Rand, count int) ([]uint64, error) {
+	head := pm.blockchain.CurrentHeader().Number.Uint64()
+	if head == 0 {
+		return nil, errBenchmarkUnavailable
+	}
+	numbers := make([]uint64, count)
+	for i := range numbers {
+		numbers[i] = 1 + uint64(rnd.Int63n(int64(head)))
+	}
+	return numbers, nil
+}
+
+// benchmarkHeaders serves a contiguous range of headers from a random origin.
+func benchmarkHeaders(pm *ProtocolManager, rnd *rand.Rand, count int) error {
+	head := pm.blockchain.CurrentHeader().Number.Uint64()
+	if head < uint64(count) {
+		return errBenchmarkUnavailable
+	}
+	origin := uint64(rnd.Int63n(int64(head - uint64(count) + 1)))
+	for i := 0; i < count; i++ {
+		header := pm.blockchain.GetHeaderByNumber(origin + uint64(i))
+		if header == nil {
+			return errBenchmarkUnavailable
+		}
+		if _, err := rlp.EncodeToBytes(header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// benchmarkBodies serves the bodies of random canonical blocks.
+func benchmarkBodies(pm *ProtocolManager, rnd *rand.Rand, count int) error {
+	numbers, err := randomBlocks(pm, rnd, count)
+	if err != nil {
+		return err
+	}
+	for _, number := range numbers {
+		hash := core.GetCanonicalHash(pm.chainDb, number)
+		if core.GetBodyRLP(pm.chainDb, hash, number) == nil {
+			return errBenchmarkUnavailable
+		}
+	}
+	return nil
+}
+
+// benchmarkReceipts serves the receipts of random canonical blocks.
+func benchmarkReceipts(pm *ProtocolManager, rnd *rand.Rand, count int) error {
+	numbers, err := randomBlocks(pm, rnd, count)
+	if err != nil {
+		return err
+	}
+	for _, number := range numbers {
+		hash := core.GetCanonicalHash(pm.chainDb, number)
+		receipts := core.GetBlockReceipts(pm.chainDb, hash, number)
+		if receipts == nil {
+			return errBenchmarkUnavailable
+		}
+		if _, err := rlp.EncodeToBytes(receipts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// benchmarkProofs proves random keys in the state trie of the head block.
+func benchmarkProofs(pm *ProtocolManager, rnd *rand.Rand, count int) error {
+	header := pm.blockchain.GetHeaderByHash(pm.blockchain.LastBlockHash())
+	if header == nil {
+		return errBenchmarkUnavailable
+	}
+	tr, err := trie.New(header.Root, pm.chainDb)
+	if err != nil {
+		return errBenchmarkUnavailable
+	}
+	nodes := light.NewNodeSet()
+	for i := 0; i < count; i++ {
+		var key common.Hash
+		rnd.Read(key[:])
+		if err := tr.Prove(crypto.Keccak256(key[:]), 0, nodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// benchmarkHelperTrie proves random block numbers in the latest CHT, including
+// the headers as auxiliary data.
+func benchmarkHelperTrie(pm *ProtocolManager, rnd *rand.Rand, count int) error {
+	sections := (pm.blockchain.CurrentHeader().Number.Uint64() + 1) / light.ChtFrequency
+	if sections == 0 {
+		return errBenchmarkUnavailable
+	}
+	root, prefix := pm.getHelperTrie(htCanonical, sections-1)
+	if root == (common.Hash{}) {
+		return errBenchmarkUnavailable
+	}
+	tr, err := trie.New(root, ruedb.NewTable(pm.chainDb, prefix))
+	if err != nil {
+		return errBenchmarkUnavailable
+	}
+	nodes := light.NewNodeSet()
+	for i := 0; i < count; i++ {
+		req := HelperTrieReq{HelperTrieType: htCanonical, TrieIdx: sections - 1, Key: make([]byte, 8), AuxReq: auxHeader}
+		binary.BigEndian.PutUint64(req.Key, uint64(rnd.Int63n(int64(sections*light.ChtFrequency))))
+		if err := tr.Prove(req.Key, 0, nodes); err != nil {
+			return err
+		}
+		pm.getHelperTrieAuxData(req)
+	}
+	return nil
+}
+
+// benchmarkTxStatus looks up the status of random transaction hashes, which is
+// the most expensive case as both the pool and the database are searched.
+func benchmarkTxStatus(pm *ProtocolManager, rnd *rand.Rand, count int) error {
+	if pm.txpool == nil {
+		return errBenchmarkUnavailable
+	}
+	hashes := make([]common.Hash, count)
+	for i := range hashes {
+		rnd.Read(hashes[i][:])
+	}
+	pm.txStatus(hashes)
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"testing"
+
+	"github.com/Rue-Foundation/go-rue/ruedb"
+)
+
+// Tests that the startup benchmarks measure the request types servable from the
+// local chain and that the calibrated costs are advertised.
+func TestRequestBenchmarks(t *testing.T) {
+	db, _ := ruedb.NewMemDatabase()
+	pm := newTestProtocolManagerMust(t, false, 200, testChainGen, nil, nil, db)
+
+	models := runBenchmarks(pm)
+	for _, code := range []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetReceiptsMsg, GetProofsV2Msg} {
+		if models[code] == nil {
+			t.Errorf("request type %d not benchmarked", code)
+		}
+	}
+	// Without a CHT section or transaction pool the rest must be skipped
+	for _, code := range []uint64{GetHelperTrieProofsMsg, GetTxStatusMsg} {
+		if models[code] != nil {
+			t.Errorf("request type %d benchmarked without data", code)
+		}
+	}
+	stats := newCostStats(nil)
+	stats.calibrate(models)
+	for _, cost := range stats.getCurrentList() {
+		if _, ok := models[cost.MsgCode]; ok && cost.BaseCost+cost.ReqCost*MaxHeaderFetch == 0 {
+			t.Errorf("request type %d advertised with zero cost", cost.MsgCode)
+		}
+	}
+}
+
+// Tests that calibration merges the benchmarks into the cost statistics gathered
+// while serving, instead of discarding them.
+func TestRequestCostCalibration(t *testing.T) {
+	stats := newCostStats(nil)
+	for i := 1; i <= 10; i++ {
+		stats.update(GetBlockHeadersMsg, uint64(i), uint64(i*1000))
+	}
+	served := *stats.stats[GetBlockHeadersMsg]
+
+	bench := new(linReg)
+	for i := 1; i <= 10; i++ {
+		bench.add(float64(i), float64(i*3000))
+	}
+	stats.calibrate(map[uint64]*linReg{GetBlockHeadersMsg: bench, GetBlockBodiesMsg: bench})
+
+	// Served statistics must be merged with the benchmark, defaults replaced
+	if have, want := stats.stats[GetBlockHeadersMsg].cnt, served.cnt+bench.cnt; have != want {
+		t.Errorf("merged sample count mismatch: have %d, want %d", have, want)
+	}
+	if have, want := stats.stats[GetBlockHeadersMsg].sumY, served.sumY+bench.sumY; have != want {
+		t.Errorf("merged cost sum mismatch: have %v, want %v", have, want)
+	}
+	if stats.stats[GetBlockBodiesMsg] != bench {
+		t.Errorf("default statistics not replaced by the benchmark")
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/consensus/ruehash"
+	"github.com/Rue-Foundation/go-rue/core"
+	"github.com/Rue-Foundation/go-rue/core/bloombits"
+	"github.com/Rue-Foundation/go-rue/core/types"
+	"github.com/Rue-Foundation/go-rue/event"
+	"github.com/Rue-Foundation/go-rue/light"
+	"github.com/Rue-Foundation/go-rue/params"
+	"github.com/Rue-Foundation/go-rue/rue"
+	"github.com/Rue-Foundation/go-rue/rue/filters"
+	"github.com/Rue-Foundation/go-rue/ruedb"
+)
+
+// testIndexerChain feeds a fixed head into chain indexers.
+type testIndexerChain struct {
+	head *types.Header
+	feed event.Feed
+}
+
+func (c *testIndexerChain) CurrentHeader() *types.Header { return c.head }
+
+func (c *testIndexerChain) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
+	return c.feed.Subscribe(ch)
+}
+
+var (
+	testLogAddress = common.HexToAddress("0x10")
+	testLogTopic   = common.HexToHash("0x5ea1ed")
+)
+
+// makeLogChain writes a chain of empty blocks on top of the genesis block of a
+// server, adding a matching log to the receipts of the given block numbers.
+// The blocks are written directly to keep the long chain needed for a complete
+// BloomTrie section cheap.
+func makeLogChain(db ruedb.Database, config *params.ChainConfig, genesis *types.Block, n int, logBlocks map[uint64]bool) *types.Block {
+	var (
+		parent = genesis
+		td     = new(big.Int).Set(genesis.Difficulty())
+	)
+	for i := 1; i <= n; i++ {
+		number := uint64(i)
+		time := parent.Time().Uint64() + 10
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).SetUint64(number),
+			Difficulty: ruehash.CalcDifficulty(config, time, parent.Header()),
+			GasLimit:   parent.GasLimit(),
+			GasUsed:    new(big.Int),
+			Time:       new(big.Int).SetUint64(time),
+			Root:       genesis.Root(),
+		}
+		var (
+			txs      types.Transactions
+			receipts types.Receipts
+		)
+		if logBlocks[number] {
+			tx, _ := types.SignTx(types.NewTransaction(0, testLogAddress, new(big.Int), bigTxGas, nil, nil), types.HorizonSigner{}, testBankKey)
+			receipt := types.NewReceipt(nil, false, new(big.Int))
+			receipt.Logs = []*types.Log{{Address: testLogAddress, Topics: []common.Hash{testLogTopic}}}
+			receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+			txs, receipts = types.Transactions{tx}, types.Receipts{receipt}
+		}
+		block := types.NewBlock(header, txs, nil, receipts)
+		hash := block.Hash()
+		td.Add(td, header.Difficulty)
+
+		core.WriteBlock(db, block)
+		core.WriteTd(db, hash, number, td)
+		core.WriteCanonicalHash(db, hash, number)
+		if receipts != nil {
+			core.WriteBlockReceipts(db, hash, number, receipts)
+		}
+		parent = block
+	}
+	core.WriteHeadBlockHash(db, parent.Hash())
+	core.WriteHeadHeaderHash(db, parent.Hash())
+	return parent
+}
+
+// Tests that a light client filters logs through the BloomTrie of a trusted
+// checkpoint, retrieving only the receipts of the candidate blocks.
+func TestLightLogFilter(t *testing.T) {
+	// Create a server with a chain long enough for a complete BloomTrie section
+	// and wait for all its indexers to process it
+	db, _ := ruedb.NewMemDatabase()
+	pm := newTestProtocolManagerMust(t, false, 0, nil, nil, nil, db)
+	chain := pm.blockchain.(*core.BlockChain)
+
+	logBlocks := map[uint64]bool{1: true, 4000: true, 20000: true, 32767: true}
+	head := makeLogChain(db, chain.Config(), chain.Genesis(), light.BloomTrieFrequency+light.HelperTrieConfirmations+100, logBlocks)
+	if err := chain.FastSyncCommitHead(head.Hash()); err != nil {
+		t.Fatalf("failed to set server head: %v", err)
+	}
+	indexerChain := &testIndexerChain{head: head.Header()}
+	bloomIndexer := eth.NewBloomIndexer(db, params.BloomBitsBlocks)
+	bloomTrieIndexer := light.NewBloomTrieIndexer(db, false)
+	bloomIndexer.AddChildIndexer(bloomTrieIndexer)
+	chtIndexer := light.NewChtIndexer(db, false)
+	bloomIndexer.Start(indexerChain)
+	chtIndexer.Start(indexerChain)
+	defer bloomIndexer.Close()
+	defer chtIndexer.Close()
+
+	var checkpoint *light.TrustedCheckpoint
+	for deadline := time.Now().Add(time.Minute); checkpoint == nil; {
+		if time.Now().After(deadline) {
+			t.Fatalf("server indexers did not finish")
+		}
+		time.Sleep(100 * time.Millisecond)
+		checkpoint, _ = light.ServerCheckpoint(db, "test")
+	}
+	// Create a light client trusting the server's checkpoint
+	peers := newPeerSet()
+	dist := newRequestDistributor(peers, make(chan struct{}))
+	rm := newRetrieveManager(peers, dist, nil)
+	ldb, _ := ruedb.NewMemDatabase()
+	odr := NewLesOdr(ldb, light.NewChtIndexer(ldb, true), light.NewBloomTrieIndexer(ldb, true), eth.NewBloomIndexer(ldb, light.BloomTrieFrequency), rm)
+	lpm := newTestProtocolManagerMust(t, true, 0, nil, peers, odr, ldb)
+	lchain := lpm.blockchain.(*light.LightChain)
+	lchain.AddTrustedCheckpoint(*checkpoint)
+
+	_, err1, lpeer, err2 := newTestPeerPair("peer", 2, pm, lpm)
+	select {
+	case <-time.After(time.Millisecond * 100):
+	case err := <-err1:
+		t.Fatalf("peer 1 handshake error: %v", err)
+	case err := <-err2:
+		t.Fatalf("peer 2 handshake error: %v", err)
+	}
+	lpeer.lock.Lock()
+	lpeer.hasBlock = func(common.Hash, uint64) bool { return true }
+	lpeer.lock.Unlock()
+
+	leth := &LightRuereum{
+		odr:              odr,
+		blockchain:       lchain,
+		chainDb:          ldb,
+		bloomRequests:    make(chan chan *bloombits.Retrieval),
+		bloomIndexer:     odr.BloomIndexer(),
+		bloomTrieIndexer: odr.BloomTrieIndexer(),
+		shutdownChan:     make(chan bool),
+	}
+	leth.startBloomHandlers()
+	defer close(leth.shutdownChan)
+	backend := &LesApiBackend{leth, nil}
+
+	if size, sections := backend.BloomStatus(); size != light.BloomTrieFrequency || sections != 1 {
+		t.Fatalf("bloom status mismatch: have %d sections of %d, want 1 of %d", sections, size, light.BloomTrieFrequency)
+	}
+	// Filter the logs of the checkpoint section and check only the candidates
+	// were retrieved
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := filters.New(backend, 0, light.BloomTrieFrequency-1, []common.Address{testLogAddress}, [][]common.Hash{{testLogTopic}})
+	logs, err := filter.Logs(ctx)
+	if err != nil {
+		t.Fatalf("failed to filter logs: %v", err)
+	}
+	if len(logs) != len(logBlocks) {
+		t.Fatalf("log count mismatch: have %d, want %d", len(logs), len(logBlocks))
+	}
+	for _, log := range logs {
+		if !logBlocks[log.BlockNumber] {
+			t.Errorf("unexpected log in block #%d", log.BlockNumber)
+		}
+	}
+	fetched := 0
+	for number := uint64(1); number < light.BloomTrieFrequency; number++ {
+		if hash := core.GetCanonicalHash(ldb, number); hash != (common.Hash{}) && core.GetBlockReceipts(ldb, hash, number) != nil {
+			fetched++
+		}
+	}
+	if fetched != len(logBlocks) {
+		t.Errorf("retrieved receipt count mismatch: have %d, want %d", fetched, len(logBlocks))
+	}
+}
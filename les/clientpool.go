@@ -0,0 +1,313 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/common/mclock"
+	"github.com/Rue-Foundation/go-rue/les/flowcontrol"
+	"github.com/Rue-Foundation/go-rue/log"
+	"github.com/Rue-Foundation/go-rue/p2p"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/rlp"
+	"github.com/Rue-Foundation/go-rue/ruedb"
+)
+
+// clientCapacityKey is the database key the priority client capacity
+// assignments are stored under.
+var clientCapacityKey = []byte("lesClientCapacities")
+
+var (
+	errNoCapacity       = errors.New("not enough free capacity")
+	errCapacityExceeded = errors.New("assigned capacities exceed total capacity")
+	errClientConnected  = errors.New("client already connected")
+)
+
+// clientPool decides which light clients are allowed to connect and what flow
+// control parameters they receive.
+//
+// Capacity is measured in minimum recharge rate units. Clients with a capacity
+// assigned by the operator are priority clients, served with buffer limits and
+// recharge rates proportional to their capacity. Everyone else is a free client
+// receiving the default parameters while spare capacity is available. Free
+// clients are kicked, most recently connected first, to make room for priority
+// clients connecting. Priority clients are trusted by the p2p server, so they
+// get this far even if all its peer slots are taken.
+type clientPool struct {
+	db        ruedb.Database
+	defParams flowcontrol.ServerParams // Parameters of free clients, scaled for priority ones
+	totalCap  uint64                   // Total capacity of all simultaneously connected clients
+
+	assigned     map[discover.NodeID]uint64       // Capacities of the priority clients
+	connected    map[discover.NodeID]*clientEntry // Currently connected clients
+	free         []*clientEntry                   // Connected free clients, in connection order
+	connectedCap uint64                           // Sum of the capacities of connected clients
+
+	trust func(id discover.NodeID, trusted bool) // Callback marking priority clients trusted, nil until started
+	lock  sync.Mutex
+}
+
+// clientEntry is a connected client tracked by the pool.
+type clientEntry struct {
+	id         discover.NodeID
+	capacity   uint64
+	priority   bool
+	params     *flowcontrol.ServerParams
+	since      mclock.AbsTime
+	disconnect func(p2p.DiscReason)
+}
+
+// ClientInfo is the capacity related information known about a light client.
+type ClientInfo struct {
+	Capacity      uint64 `json:"capacity"`      // Assigned capacity, or free client capacity
+	Priority      bool   `json:"priority"`      // Whether the capacity was assigned
+	Connected     bool   `json:"connected"`     // Whether the client is currently connected
+	ConnectedTime uint64 `json:"connectedTime"` // Seconds since the client connected
+}
+
+// newClientPool creates a client pool admitting clients up to a total capacity,
+// loading the persisted capacity assignments from db.
+func newClientPool(db ruedb.Database, defParams flowcontrol.ServerParams, totalCap uint64) *clientPool {
+	pool := &clientPool{
+		db:        db,
+		defParams: defParams,
+		totalCap:  totalCap,
+		assigned:  make(map[discover.NodeID]uint64),
+		connected: make(map[discover.NodeID]*clientEntry),
+	}
+	pool.loadAssignments()
+	return pool
+}
+
+// clientAssignment is the storage format of a priority client capacity.
+type clientAssignment struct {
+	Id       discover.NodeID
+	Capacity uint64
+}
+
+// loadAssignments retrieves the persisted priority client capacities.
+func (pool *clientPool) loadAssignments() {
+	if pool.db == nil {
+		return
+	}
+	data, err := pool.db.Get(clientCapacityKey)
+	if err != nil {
+		return
+	}
+	var list []clientAssignment
+	if err := rlp.DecodeBytes(data, &list); err != nil {
+		log.Error("Failed to decode client capacities", "err", err)
+		return
+	}
+	for _, a := range list {
+		pool.assigned[a.Id] = a.Capacity
+	}
+}
+
+// storeAssignments persists the priority client capacities.
+func (pool *clientPool) storeAssignments() {
+	if pool.db == nil {
+		return
+	}
+	list := make([]clientAssignment, 0, len(pool.assigned))
+	for id, capacity := range pool.assigned {
+		list = append(list, clientAssignment{Id: id, Capacity: capacity})
+	}
+	data, err := rlp.EncodeToBytes(list)
+	if err != nil {
+		log.Error("Failed to encode client capacities", "err", err)
+		return
+	}
+	if err := pool.db.Put(clientCapacityKey, data); err != nil {
+		log.Error("Failed to store client capacities", "err", err)
+	}
+}
+
+// start sets the callback used to mark priority clients trusted on the p2p
+// server, admitting them even if its peer slots are full, and applies it to the
+// clients that already have capacity assigned.
+func (pool *clientPool) start(trust func(id discover.NodeID, trusted bool)) {
+	pool.lock.Lock()
+	pool.trust = trust
+	ids := make([]discover.NodeID, 0, len(pool.assigned))
+	for id := range pool.assigned {
+		ids = append(ids, id)
+	}
+	pool.lock.Unlock()
+
+	for _, id := range ids {
+		trust(id, true)
+	}
+}
+
+// params calculates the flow control parameters of a client with the given
+// capacity.
+func (pool *clientPool) params(capacity uint64) *flowcontrol.ServerParams {
+	return &flowcontrol.ServerParams{
+		BufLimit:    pool.defParams.BufLimit / pool.defParams.MinRecharge * capacity,
+		MinRecharge: capacity,
+	}
+}
+
+// connect admits a new client if there is enough capacity for it, kicking free
+// clients if a priority client needs room. The disconnect callback is used to
+// drop the client if it's kicked later.
+func (pool *clientPool) connect(id discover.NodeID, disconnect func(p2p.DiscReason)) (*clientEntry, error) {
+	var kicked []*clientEntry
+	defer func() {
+		for _, entry := range kicked {
+			entry.disconnect(p2p.DiscTooManyPeers)
+		}
+	}()
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if _, ok := pool.connected[id]; ok {
+		return nil, errClientConnected
+	}
+	capacity, priority := pool.assigned[id]
+	if !priority {
+		capacity = pool.defParams.MinRecharge
+	}
+	if pool.connectedCap+capacity > pool.totalCap {
+		if !priority {
+			return nil, errNoCapacity
+		}
+		// Priority client without room, count the free clients that need kicking
+		var (
+			freed uint64
+			kick  int
+		)
+		for kick < len(pool.free) && pool.connectedCap-freed+capacity > pool.totalCap {
+			freed += pool.free[len(pool.free)-1-kick].capacity
+			kick++
+		}
+		if pool.connectedCap-freed+capacity > pool.totalCap {
+			return nil, errNoCapacity
+		}
+		for i := 0; i < kick; i++ {
+			entry := pool.free[len(pool.free)-1]
+			log.Debug("Kicking free client for priority client", "id", entry.id, "priority", id)
+			pool.remove(entry)
+			kicked = append(kicked, entry)
+		}
+	}
+	entry := &clientEntry{
+		id:         id,
+		capacity:   capacity,
+		priority:   priority,
+		params:     pool.params(capacity),
+		since:      mclock.Now(),
+		disconnect: disconnect,
+	}
+	pool.connected[id] = entry
+	pool.connectedCap += capacity
+	if !priority {
+		pool.free = append(pool.free, entry)
+	}
+	return entry, nil
+}
+
+// disconnect releases the capacity of a client leaving the pool. Clients that
+// were already kicked are ignored.
+func (pool *clientPool) disconnect(entry *clientEntry) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if pool.connected[entry.id] == entry {
+		pool.remove(entry)
+	}
+}
+
+// remove drops a connected client from the pool. The lock must be held.
+func (pool *clientPool) remove(entry *clientEntry) {
+	delete(pool.connected, entry.id)
+	pool.connectedCap -= entry.capacity
+	for i, e := range pool.free {
+		if e == entry {
+			pool.free = append(pool.free[:i], pool.free[i+1:]...)
+			break
+		}
+	}
+}
+
+// setCapacity assigns a capacity to a client, making it a priority client, or
+// reverts it to a free client if the capacity is zero. A connected client whose
+// capacity changes is disconnected to renegotiate its flow control parameters.
+func (pool *clientPool) setCapacity(id discover.NodeID, capacity uint64) error {
+	var (
+		kicked *clientEntry
+		trust  func(id discover.NodeID, trusted bool)
+	)
+	defer func() {
+		if trust != nil {
+			trust(id, capacity > 0)
+		}
+		if kicked != nil {
+			kicked.disconnect(p2p.DiscRequested)
+		}
+	}()
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	_, priority := pool.assigned[id]
+	if capacity > 0 {
+		sum := capacity
+		for other, c := range pool.assigned {
+			if other != id {
+				sum += c
+			}
+		}
+		if sum > pool.totalCap {
+			return errCapacityExceeded
+		}
+		pool.assigned[id] = capacity
+	} else {
+		delete(pool.assigned, id)
+	}
+	pool.storeAssignments()
+
+	if priority != (capacity > 0) {
+		trust = pool.trust
+	}
+	if entry, ok := pool.connected[id]; ok {
+		if entry.priority != (capacity > 0) || (capacity > 0 && entry.capacity != capacity) {
+			pool.remove(entry)
+			kicked = entry
+		}
+	}
+	return nil
+}
+
+// clientInfo returns the capacity related information about a client.
+func (pool *clientPool) clientInfo(id discover.NodeID) ClientInfo {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	info := ClientInfo{Capacity: pool.defParams.MinRecharge}
+	if capacity, ok := pool.assigned[id]; ok {
+		info.Capacity, info.Priority = capacity, true
+	}
+	if entry, ok := pool.connected[id]; ok {
+		info.Connected = true
+		info.ConnectedTime = uint64(time.Duration(mclock.Now()-entry.since) / time.Second)
+	}
+	return info
+}
@@ -0,0 +1,187 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/crypto"
+	"github.com/Rue-Foundation/go-rue/les/flowcontrol"
+	"github.com/Rue-Foundation/go-rue/p2p"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/ruedb"
+)
+
+// Tests that free clients fill the spare capacity and get kicked by priority
+// clients, and that capacity assignments survive a restart.
+func TestClientPool(t *testing.T) {
+	db, _ := ruedb.NewMemDatabase()
+	params := flowcontrol.ServerParams{BufLimit: 1000, MinRecharge: 10}
+	pool := newClientPool(db, params, 30)
+
+	kicked := make(map[discover.NodeID]p2p.DiscReason)
+	connect := func(id discover.NodeID) (*clientEntry, error) {
+		return pool.connect(id, func(reason p2p.DiscReason) { kicked[id] = reason })
+	}
+	free := []discover.NodeID{{1}, {2}, {3}, {4}}
+	prio := discover.NodeID{0xff}
+
+	// Fill the pool with free clients
+	for i, id := range free[:3] {
+		if _, err := connect(id); err != nil {
+			t.Fatalf("free client %d rejected: %v", i, err)
+		}
+	}
+	if _, err := connect(free[3]); err != errNoCapacity {
+		t.Fatalf("free client over capacity error mismatch: have %v, want %v", err, errNoCapacity)
+	}
+	// Assign capacity to a client and ensure it pushes out free ones
+	if err := pool.setCapacity(prio, 40); err != errCapacityExceeded {
+		t.Fatalf("oversized capacity error mismatch: have %v, want %v", err, errCapacityExceeded)
+	}
+	if err := pool.setCapacity(prio, 15); err != nil {
+		t.Fatalf("failed to assign capacity: %v", err)
+	}
+	entry, err := connect(prio)
+	if err != nil {
+		t.Fatalf("priority client rejected: %v", err)
+	}
+	if entry.params.MinRecharge != 15 || entry.params.BufLimit != 1500 {
+		t.Errorf("priority parameters mismatch: have %+v", entry.params)
+	}
+	if len(kicked) != 2 || kicked[free[2]] != p2p.DiscTooManyPeers || kicked[free[1]] != p2p.DiscTooManyPeers {
+		t.Errorf("kicked clients mismatch: have %v", kicked)
+	}
+	if info := pool.clientInfo(prio); !info.Priority || !info.Connected || info.Capacity != 15 {
+		t.Errorf("priority client info mismatch: have %+v", info)
+	}
+	if info := pool.clientInfo(free[1]); info.Priority || info.Connected || info.Capacity != 10 {
+		t.Errorf("kicked client info mismatch: have %+v", info)
+	}
+	// Changing the capacity of a connected client should drop it
+	if err := pool.setCapacity(prio, 20); err != nil {
+		t.Fatalf("failed to change capacity: %v", err)
+	}
+	if kicked[prio] != p2p.DiscRequested {
+		t.Errorf("priority client not dropped on capacity change")
+	}
+	pool.disconnect(entry)
+	if pool.connectedCap != 10 {
+		t.Errorf("connected capacity mismatch: have %d, want 10", pool.connectedCap)
+	}
+	// Recreate the pool and check the assignment was persisted
+	pool = newClientPool(db, params, 30)
+	if info := pool.clientInfo(prio); !info.Priority || info.Capacity != 20 {
+		t.Errorf("persisted client info mismatch: have %+v", info)
+	}
+	if err := pool.setCapacity(prio, 0); err != nil {
+		t.Fatalf("failed to revoke capacity: %v", err)
+	}
+	if info := newClientPool(db, params, 30).clientInfo(prio); info.Priority {
+		t.Errorf("revoked capacity persisted: have %+v", info)
+	}
+}
+
+// Tests that a priority client can connect to a server whose peer slots are all
+// taken by free clients, kicking one of them.
+func TestClientPoolPeerLimit(t *testing.T) {
+	params := flowcontrol.ServerParams{BufLimit: 1000, MinRecharge: 10}
+	pool := newClientPool(nil, params, 20)
+
+	// Start a server admitting clients through the pool like the LES handler
+	serve := func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+		entry, err := pool.connect(p.ID(), p.Disconnect)
+		if err != nil {
+			return err
+		}
+		defer pool.disconnect(entry)
+		for {
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				return err
+			}
+			msg.Discard()
+		}
+	}
+	key, _ := crypto.GenerateKey()
+	server := &p2p.Server{Config: p2p.Config{
+		PrivateKey:  key,
+		MaxPeers:    2,
+		ListenAddr:  "127.0.0.1:0",
+		NoDiscovery: true,
+		NoDial:      true,
+		Protocols:   []p2p.Protocol{{Name: "les", Version: 2, Length: 1, Run: serve}},
+	}}
+	if err := server.Start(); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop()
+	pool.start(trustPriorityClients(server))
+
+	// Start the clients, the free ones filling all the peer slots of the server
+	wait := func(cond func() bool, what string) {
+		for deadline := time.Now().Add(5 * time.Second); !cond(); {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	idle := func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+		for {
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				return err
+			}
+			msg.Discard()
+		}
+	}
+	clients := make([]*p2p.Server, 3)
+	ids := make([]discover.NodeID, 3)
+	for i := range clients {
+		key, _ := crypto.GenerateKey()
+		clients[i] = &p2p.Server{Config: p2p.Config{
+			PrivateKey:  key,
+			MaxPeers:    1,
+			NoDiscovery: true,
+			Protocols:   []p2p.Protocol{{Name: "les", Version: 2, Length: 1, Run: idle}},
+		}}
+		if err := clients[i].Start(); err != nil {
+			t.Fatalf("failed to start client %d: %v", i, err)
+		}
+		defer clients[i].Stop()
+		ids[i] = discover.PubkeyID(&key.PublicKey)
+	}
+	for i := 0; i < 2; i++ {
+		clients[i].AddPeer(server.Self())
+		wait(func() bool { return pool.clientInfo(ids[i]).Connected }, "free client to connect")
+	}
+	// Assign capacity to the last client and ensure it gets in
+	if err := pool.setCapacity(ids[2], 10); err != nil {
+		t.Fatalf("failed to assign capacity: %v", err)
+	}
+	clients[2].AddPeer(server.Self())
+	wait(func() bool { return pool.clientInfo(ids[2]).Connected }, "priority client to connect")
+
+	if pool.clientInfo(ids[0]).Connected == pool.clientInfo(ids[1]).Connected {
+		t.Errorf("free client not kicked exactly once")
+	}
+	if n := server.PeerCount(); n > 2 {
+		t.Errorf("peer count mismatch: have %d, want at most 2", n)
+	}
+}
@@ -67,8 +67,19 @@ const (
 // not compatible (low protocol version restrictions and high requirements).
 var errIncompatibleConfig = errors.New("incompatible configuration")
 
+// codeError is an error carrying one of the protocol error codes. It is
+// returned if the remote peer failed the handshake or sent an invalid message.
+type codeError struct {
+	code errCode
+	msg  string
+}
+
+func (e *codeError) Error() string {
+	return fmt.Sprintf("%v - %v", e.code, e.msg)
+}
+
 func errResp(code errCode, format string, v ...interface{}) error {
-	return fmt.Errorf("%v - %v", code, fmt.Sprintf(format, v...))
+	return &codeError{code, fmt.Sprintf(format, v...)}
 }
 
 type BlockChain interface {
@@ -110,6 +121,7 @@ type ProtocolManager struct {
 
 	downloader *downloader.Downloader
 	fetcher    *lightFetcher
+	ulc        *ulc
 	peers      *peerSet
 
 	SubProtocols []p2p.Protocol
@@ -128,7 +140,7 @@ type ProtocolManager struct {
 
 // NewProtocolManager returns a new ruereum sub protocol manager. The Ruereum sub protocol manages peers capable
 // with the ruereum network.
-func NewProtocolManager(chainConfig *params.ChainConfig, lightSync bool, protocolVersions []uint, networkId uint64, mux *event.TypeMux, engine consensus.Engine, peers *peerSet, blockchain BlockChain, txpool txPool, chainDb ruedb.Database, odr *LesOdr, txrelay *LesTxRelay, quitSync chan struct{}, wg *sync.WaitGroup) (*ProtocolManager, error) {
+func NewProtocolManager(chainConfig *params.ChainConfig, lightSync bool, protocolVersions []uint, networkId uint64, mux *event.TypeMux, engine consensus.Engine, peers *peerSet, blockchain BlockChain, txpool txPool, chainDb ruedb.Database, odr *LesOdr, txrelay *LesTxRelay, ulc *ulc, quitSync chan struct{}, wg *sync.WaitGroup) (*ProtocolManager, error) {
 	// Create the protocol manager with the base fields
 	manager := &ProtocolManager{
 		lightSync:   lightSync,
@@ -140,6 +152,7 @@ func NewProtocolManager(chainConfig *params.ChainConfig, lightSync bool, protoco
 		networkId:   networkId,
 		txpool:      txpool,
 		txrelay:     txrelay,
+		ulc:         ulc,
 		peers:       peers,
 		newPeerCh:   make(chan *peer),
 		quitSync:    quitSync,
@@ -205,9 +218,11 @@ func NewProtocolManager(chainConfig *params.ChainConfig, lightSync bool, protoco
 	}
 
 	if lightSync {
-		manager.downloader = downloader.New(downloader.LightSync, chainDb, manager.eventMux, nil, blockchain, removePeer)
+		manager.downloader = downloader.New(downloader.LightSync, nil, chainDb, manager.eventMux, nil, blockchain, removePeer)
 		manager.peers.notify((*downloaderPeerNotify)(manager))
-		manager.fetcher = newLightFetcher(manager)
+		if ulc == nil {
+			manager.fetcher = newLightFetcher(manager)
+		}
 	}
 
 	return manager, nil
@@ -261,6 +276,19 @@ func (pm *ProtocolManager) newPeer(pv int, nv uint64, p *p2p.Peer, rw p2p.MsgRea
 func (pm *ProtocolManager) handle(p *peer) error {
 	p.Log().Debug("Light Ruereum peer connected", "name", p.Name())
 
+	// Reserve capacity for the client if serving with a limited pool
+	if pm.server != nil && pm.server.clientPool != nil {
+		entry, err := pm.server.clientPool.connect(p.ID(), p.Peer.Disconnect)
+		if err != nil {
+			p.Log().Debug("Light Ruereum client rejected", "err", err)
+			return p2p.DiscTooManyPeers
+		}
+		defer pm.server.clientPool.disconnect(entry)
+		p.fcParams = entry.params
+	}
+	if pm.ulc != nil {
+		p.trusted = pm.ulc.isTrusted(p.ID())
+	}
 	// Execute the LES handshake
 	td, head, genesis := pm.blockchain.Status()
 	headNum := core.GetBlockNumber(pm.chainDb, head)
@@ -290,6 +318,16 @@ func (pm *ProtocolManager) handle(p *peer) error {
 		if pm.fetcher != nil {
 			pm.fetcher.announce(p, head)
 		}
+		if pm.ulc != nil {
+			// Without a fetcher, assume servers have everything up to their head
+			p.lock.Lock()
+			p.hasBlock = func(hash common.Hash, number uint64) bool {
+				p.lock.RLock()
+				defer p.lock.RUnlock()
+				return number <= p.headInfo.Number
+			}
+			p.lock.Unlock()
+		}
 
 		if p.poolEntry != nil {
 			pm.serverPool.registered(p.poolEntry)
@@ -314,6 +352,9 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	for {
 		if err := pm.handleMsg(p); err != nil {
 			p.Log().Debug("Light Ruereum message handling failed", "err", err)
+			if err, ok := err.(*codeError); ok {
+				p.Penalize(errOffence(err.code), err.Error())
+			}
 			return err
 		}
 	}
@@ -387,6 +428,17 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		if pm.fetcher != nil {
 			pm.fetcher.announce(p, &req)
 		}
+		if pm.ulc != nil {
+			p.lock.Lock()
+			p.headInfo = &req
+			p.lock.Unlock()
+
+			if p.trusted {
+				if signers := pm.ulc.announce(p.ID(), &req); signers != nil {
+					go pm.fetchTrustedHead(signers, req)
+				}
+			}
+		}
 
 	case GetBlockHeadersMsg:
 		p.Log().Trace("Received block header request")
@@ -484,7 +536,13 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
 		p.fcServer.GotReply(resp.ReqID, resp.BV)
-		if pm.fetcher != nil && pm.fetcher.requestedID(resp.ReqID) {
+		if pm.ulc != nil {
+			deliverMsg = &Msg{
+				MsgType: MsgBlockHeaders,
+				ReqID:   resp.ReqID,
+				Obj:     resp.Headers,
+			}
+		} else if pm.fetcher != nil && pm.fetcher.requestedID(resp.ReqID) {
 			pm.fetcher.deliverHeaders(p, resp.ReqID, resp.Headers)
 		} else {
 			err := pm.downloader.DeliverHeaders(p.id, resp.Headers)
@@ -984,7 +1042,12 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		if reject(uint64(reqCnt), MaxTxSend) {
 			return errResp(ErrRequestRejected, "")
 		}
-		pm.txpool.AddRemotes(txs)
+		for _, err := range pm.txpool.AddRemotes(txs) {
+			if err == core.ErrInvalidSender {
+				p.Penalize(p2p.OffenceMinor, "invalid transaction signature")
+				break
+			}
+		}
 
 		_, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
 		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
@@ -1055,13 +1118,29 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		p.Log().Trace("Received tx status response")
 		var resp struct {
 			ReqID, BV uint64
-			Status    []core.TxStatus
+			Status    []struct {
+				Status core.TxStatus
+				Lookup *core.TxLookupEntry `rlp:"nil"`
+				Error  rlp.RawValue // Not decodable, errors are sent as empty lists
+			}
 		}
 		if err := msg.Decode(&resp); err != nil {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
 
 		p.fcServer.GotReply(resp.ReqID, resp.BV)
+		// Replies to relayed transactions are not tracked by the retriever
+		if pm.retriever.requested(resp.ReqID) {
+			status := make([]light.TxStatus, len(resp.Status))
+			for i, stat := range resp.Status {
+				status[i] = light.TxStatus{Status: stat.Status, Lookup: stat.Lookup}
+			}
+			deliverMsg = &Msg{
+				MsgType: MsgTxStatus,
+				ReqID:   resp.ReqID,
+				Obj:     status,
+			}
+		}
 
 	default:
 		p.Log().Trace("Received unknown message", "code", msg.Code)
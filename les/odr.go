@@ -77,6 +77,8 @@ const (
 	MsgProofsV2
 	MsgHeaderProofs
 	MsgHelperTrieProofs
+	MsgBlockHeaders
+	MsgTxStatus
 )
 
 // Msg encodes a LES message that delivers reply data for a request
@@ -214,3 +214,43 @@ func testOdr(t *testing.T, protocol int, expFail uint64, fn odrTestFn) {
 	time.Sleep(time.Millisecond * 10) // ensure that all peerSetNotify callbacks are executed
 	test(5)
 }
+
+// Tests that the status of transactions can be retrieved from a server.
+func TestOdrTxStatusLes2(t *testing.T) {
+	peers := newPeerSet()
+	dist := newRequestDistributor(peers, make(chan struct{}))
+	rm := newRetrieveManager(peers, dist, nil)
+	db, _ := ruedb.NewMemDatabase()
+	ldb, _ := ruedb.NewMemDatabase()
+	odr := NewLesOdr(ldb, light.NewChtIndexer(db, true), light.NewBloomTrieIndexer(db, true), eth.NewBloomIndexer(db, light.BloomTrieFrequency), rm)
+	pm := newTestProtocolManagerMust(t, false, 4, testChainGen, nil, nil, db)
+	lpm := newTestProtocolManagerMust(t, true, 0, nil, peers, odr, ldb)
+
+	config := core.DefaultTxPoolConfig
+	config.Journal = ""
+	pm.txpool = core.NewTxPool(config, params.TestChainConfig, pm.blockchain.(*core.BlockChain))
+
+	_, err1, _, err2 := newTestPeerPair("peer", 2, pm, lpm)
+	select {
+	case <-time.After(time.Millisecond * 100):
+	case err := <-err1:
+		t.Fatalf("peer 1 handshake error: %v", err)
+	case err := <-err2:
+		t.Fatalf("peer 1 handshake error: %v", err)
+	}
+	block := pm.blockchain.GetHeaderByNumber(1)
+	mined := core.GetBlock(db, block.Hash(), 1).Transactions()[0].Hash()
+
+	req := &light.TxStatusRequest{Hashes: []common.Hash{mined, {0x01}}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := odr.Retrieve(ctx, req); err != nil {
+		t.Fatalf("failed to retrieve transaction status: %v", err)
+	}
+	if stat := req.Status[0]; stat.Status != core.TxStatusIncluded || stat.Lookup == nil || stat.Lookup.BlockHash != block.Hash() {
+		t.Errorf("mined transaction status mismatch: have %+v", stat)
+	}
+	if stat := req.Status[1]; stat.Status != core.TxStatusUnknown || stat.Lookup != nil {
+		t.Errorf("unknown transaction status mismatch: have %+v", stat)
+	}
+}
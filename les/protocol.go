@@ -30,6 +30,7 @@ import (
 	"github.com/Rue-Foundation/go-rue/core"
 	"github.com/Rue-Foundation/go-rue/crypto"
 	"github.com/Rue-Foundation/go-rue/crypto/secp256k1"
+	"github.com/Rue-Foundation/go-rue/p2p"
 	"github.com/Rue-Foundation/go-rue/rlp"
 )
 
@@ -105,6 +106,18 @@ func (e errCode) String() string {
 	return errorToString[int(e)]
 }
 
+// errOffence classifies a message handling error of a remote peer for its
+// reputation. Requests beyond the flow control limits and unrequested replies
+// might be caused by races, other errors are protocol violations.
+func errOffence(code errCode) p2p.Offence {
+	switch code {
+	case ErrRequestRejected, ErrUnexpectedResponse:
+		return p2p.OffenceMinor
+	default:
+		return p2p.OffenceMajor
+	}
+}
+
 // XXX change once legacy code is out
 var errorToString = map[int]string{
 	ErrMsgTooLarge:             "Message too long",
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"math"
 	"sync"
+	"time"
 
 	"github.com/Rue-Foundation/go-rue/common"
 	"github.com/Rue-Foundation/go-rue/core"
@@ -33,8 +34,10 @@ import (
 	"github.com/Rue-Foundation/go-rue/light"
 	"github.com/Rue-Foundation/go-rue/log"
 	"github.com/Rue-Foundation/go-rue/p2p"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
 	"github.com/Rue-Foundation/go-rue/p2p/discv5"
 	"github.com/Rue-Foundation/go-rue/rlp"
+	"github.com/Rue-Foundation/go-rue/rpc"
 )
 
 type LesServer struct {
@@ -42,6 +45,7 @@ type LesServer struct {
 	fcManager       *flowcontrol.ClientManager // nil if our node is client only
 	fcCostStats     *requestCostStats
 	defParams       *flowcontrol.ServerParams
+	clientPool      *clientPool // nil if clients are not capacity limited
 	lesTopics       []discv5.Topic
 	privateKey      *ecdsa.PrivateKey
 	quitSync        chan struct{}
@@ -51,7 +55,7 @@ type LesServer struct {
 
 func NewLesServer(eth *eth.Ruereum, config *eth.Config) (*LesServer, error) {
 	quitSync := make(chan struct{})
-	pm, err := NewProtocolManager(eth.BlockChain().Config(), false, ServerProtocolVersions, config.NetworkId, eth.EventMux(), eth.Engine(), newPeerSet(), eth.BlockChain(), eth.TxPool(), eth.ChainDb(), nil, nil, quitSync, new(sync.WaitGroup))
+	pm, err := NewProtocolManager(eth.BlockChain().Config(), false, ServerProtocolVersions, config.NetworkId, eth.EventMux(), eth.Engine(), newPeerSet(), eth.BlockChain(), eth.TxPool(), eth.ChainDb(), nil, nil, nil, quitSync, new(sync.WaitGroup))
 	if err != nil {
 		return nil, err
 	}
@@ -97,8 +101,15 @@ func NewLesServer(eth *eth.Ruereum, config *eth.Config) (*LesServer, error) {
 		BufLimit:    300000000,
 		MinRecharge: 50000,
 	}
+	srv.clientPool = newClientPool(eth.ChainDb(), *srv.defParams, uint64(config.LightPeers)*srv.defParams.MinRecharge)
 	srv.fcManager = flowcontrol.NewClientManager(uint64(config.LightServ), 10, 1000000000)
 	srv.fcCostStats = newCostStats(eth.ChainDb())
+
+	// Measure the serving costs on the local database before advertising them
+	start := time.Now()
+	models := runBenchmarks(pm)
+	srv.fcCostStats.calibrate(models)
+	logger.Info("Calibrated request costs", "types", len(models), "elapsed", common.PrettyDuration(time.Since(start)))
 	return srv, nil
 }
 
@@ -106,6 +117,18 @@ func (s *LesServer) Protocols() []p2p.Protocol {
 	return s.protocolManager.SubProtocols
 }
 
+// APIs returns the collection of RPC services the LES server offers.
+func (s *LesServer) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "les",
+			Version:   "1.0",
+			Service:   NewPrivateLightServerAPI(s),
+			Public:    false,
+		},
+	}
+}
+
 // Start starts the LES server
 func (s *LesServer) Start(srvr *p2p.Server) {
 	s.protocolManager.Start()
@@ -121,6 +144,30 @@ func (s *LesServer) Start(srvr *p2p.Server) {
 	}
 	s.privateKey = srvr.PrivateKey
 	s.protocolManager.blockLoop()
+
+	// Let priority clients past the peer limit, the client pool makes room for them
+	if s.clientPool != nil {
+		s.clientPool.start(trustPriorityClients(srvr))
+	}
+}
+
+// trustPriorityClients returns the callback of the client pool marking priority
+// clients trusted on the p2p server. Nodes trusted by configuration are never
+// untrusted.
+func trustPriorityClients(srvr *p2p.Server) func(id discover.NodeID, trusted bool) {
+	configured := make(map[discover.NodeID]bool)
+	for _, n := range srvr.TrustedNodes {
+		configured[n.ID] = true
+	}
+	return func(id discover.NodeID, trusted bool) {
+		node := discover.NewNode(id, nil, 0, 0)
+		switch {
+		case trusted:
+			srvr.AddTrustedPeer(node)
+		case !configured[id]:
+			srvr.RemoveTrustedPeer(node)
+		}
+	}
 }
 
 func (s *LesServer) SetBloomBitsIndexer(bloomIndexer *core.ChainIndexer) {
@@ -183,6 +230,28 @@ func (l *linReg) add(x, y float64) {
 	l.sumXY += x * y
 }
 
+// merge adds the samples of another regression, aging out old samples if the
+// combined count exceeds the limit.
+func (l *linReg) merge(o *linReg) {
+	if o.cnt >= linRegMaxCnt {
+		*l = *o
+		return
+	}
+	if l.cnt+o.cnt > linRegMaxCnt {
+		keep := float64(linRegMaxCnt-o.cnt) / float64(l.cnt)
+		l.sumX *= keep
+		l.sumY *= keep
+		l.sumXX *= keep
+		l.sumXY *= keep
+		l.cnt = linRegMaxCnt - o.cnt
+	}
+	l.cnt += o.cnt
+	l.sumX += o.sumX
+	l.sumY += o.sumY
+	l.sumXX += o.sumXX
+	l.sumXY += o.sumXY
+}
+
 func (l *linReg) calc() (b, m float64) {
 	if l.cnt == 0 {
 		return 0, 0
@@ -277,6 +346,26 @@ func (s *requestCostStats) store() {
 	}
 }
 
+// calibrate feeds the benchmark measurements of the request types into their
+// cost models. Types without any measurements yet (only the zero cost default)
+// take the benchmarked model, others keep the statistics of live serving too.
+func (s *requestCostStats) calibrate(models map[uint64]*linReg) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for code, l := range models {
+		stat, ok := s.stats[code]
+		if !ok {
+			continue
+		}
+		if stat.sumX == 0 && stat.sumY == 0 {
+			s.stats[code] = l
+		} else {
+			stat.merge(l)
+		}
+	}
+}
+
 func (s *requestCostStats) getCurrentList() RequestCostList {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -150,7 +150,10 @@ func (self *LesTxRelay) NewHead(head common.Hash, mined []common.Hash, rollback
 	}
 
 	for _, hash := range rollback {
-		self.txPending[hash] = struct{}{}
+		// Transactions restored by the pool after a restart may not have been sent
+		if _, ok := self.txSent[hash]; ok {
+			self.txPending[hash] = struct{}{}
+		}
 	}
 
 	if len(self.txPending) > 0 {
@@ -0,0 +1,191 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/core/types"
+	"github.com/Rue-Foundation/go-rue/light"
+	"github.com/Rue-Foundation/go-rue/log"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+)
+
+const (
+	ulcPendingLimit  = 64               // Maximum number of announced heads tracked at once
+	ulcFetchTimeout  = 10 * time.Second // Timeout of retrieving an accepted head header
+	ulcHeaderRequest = 1                // Number of headers requested for an accepted head
+)
+
+// ulc (ultra light client) tracks the signed head announcements of a set of
+// trusted servers, accepting a new head once enough of them have vouched for it.
+// Accepted heads are inserted into the light chain without verifying the proof
+// of work or downloading the intermediate headers.
+type ulc struct {
+	servers   []*discover.Node // Trusted servers, kept connected as static peers
+	trusted   map[discover.NodeID]struct{}
+	threshold int
+
+	pending    map[common.Hash]*ulcHead // Announced heads not yet accepted
+	acceptedTd *big.Int                 // Total difficulty of the last accepted head
+	lock       sync.Mutex
+}
+
+// ulcHead is a head announced by at least one trusted server.
+type ulcHead struct {
+	number  uint64
+	td      *big.Int
+	signers map[discover.NodeID]struct{}
+}
+
+// newULC creates an ultra light client trusting the servers given as enode URLs.
+// A head is accepted once threshold of them announced it, zero meaning all.
+func newULC(servers []string, threshold int) (*ulc, error) {
+	u := &ulc{
+		trusted: make(map[discover.NodeID]struct{}),
+		pending: make(map[common.Hash]*ulcHead),
+	}
+	for _, url := range servers {
+		node, err := discover.ParseNode(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted server %q: %v", url, err)
+		}
+		if _, ok := u.trusted[node.ID]; !ok {
+			u.servers = append(u.servers, node)
+			u.trusted[node.ID] = struct{}{}
+		}
+	}
+	if len(u.trusted) == 0 {
+		return nil, fmt.Errorf("no trusted servers")
+	}
+	if threshold <= 0 {
+		threshold = len(u.trusted)
+	}
+	if threshold > len(u.trusted) {
+		return nil, fmt.Errorf("threshold %d exceeds trusted server count %d", threshold, len(u.trusted))
+	}
+	u.threshold = threshold
+	return u, nil
+}
+
+// isTrusted returns whether the given server is one of the trusted ones.
+func (u *ulc) isTrusted(id discover.NodeID) bool {
+	_, ok := u.trusted[id]
+	return ok
+}
+
+// announce registers a signed head announcement of a trusted server. If the head
+// has just reached the signature threshold, the servers that announced it are
+// returned, otherwise nil.
+func (u *ulc) announce(id discover.NodeID, ann *announceData) map[discover.NodeID]struct{} {
+	if !u.isTrusted(id) {
+		return nil
+	}
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if u.acceptedTd != nil && ann.Td.Cmp(u.acceptedTd) <= 0 {
+		return nil
+	}
+	head := u.pending[ann.Hash]
+	if head == nil {
+		if len(u.pending) >= ulcPendingLimit {
+			u.prune()
+		}
+		head = &ulcHead{number: ann.Number, td: ann.Td, signers: make(map[discover.NodeID]struct{})}
+		u.pending[ann.Hash] = head
+	}
+	head.signers[id] = struct{}{}
+	if len(head.signers) < u.threshold {
+		return nil
+	}
+	// Head accepted, forget everything not heavier than it
+	u.acceptedTd = head.td
+	for hash, pending := range u.pending {
+		if pending.td.Cmp(u.acceptedTd) <= 0 {
+			delete(u.pending, hash)
+		}
+	}
+	return head.signers
+}
+
+// prune drops the pending head with the lowest total difficulty. The lock must
+// be held.
+func (u *ulc) prune() {
+	var (
+		lowest   common.Hash
+		lowestTd *big.Int
+	)
+	for hash, head := range u.pending {
+		if lowestTd == nil || head.td.Cmp(lowestTd) < 0 {
+			lowest, lowestTd = hash, head.td
+		}
+	}
+	delete(u.pending, lowest)
+}
+
+// fetchTrustedHead retrieves the header of an accepted head from one of the
+// trusted servers and inserts it into the light chain.
+func (pm *ProtocolManager) fetchTrustedHead(signers map[discover.NodeID]struct{}, ann announceData) {
+	var header *types.Header
+
+	reqID := genReqID()
+	rq := &distReq{
+		getCost: func(dp distPeer) uint64 {
+			return dp.(*peer).GetRequestCost(GetBlockHeadersMsg, ulcHeaderRequest)
+		},
+		canSend: func(dp distPeer) bool {
+			_, ok := signers[dp.(*peer).ID()]
+			return ok
+		},
+		request: func(dp distPeer) func() {
+			p := dp.(*peer)
+			cost := p.GetRequestCost(GetBlockHeadersMsg, ulcHeaderRequest)
+			p.fcServer.QueueRequest(reqID, cost)
+			return func() { p.RequestHeadersByHash(reqID, cost, ann.Hash, ulcHeaderRequest, 0, false) }
+		},
+	}
+	validate := func(p distPeer, msg *Msg) error {
+		if msg.MsgType != MsgBlockHeaders {
+			return errInvalidMessageType
+		}
+		headers := msg.Obj.([]*types.Header)
+		if len(headers) != 1 {
+			return errInvalidEntryCount
+		}
+		if headers[0].Hash() != ann.Hash || headers[0].Number.Uint64() != ann.Number {
+			return errHeaderUnavailable
+		}
+		header = headers[0]
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ulcFetchTimeout)
+	defer cancel()
+
+	if err := pm.retriever.retrieve(ctx, reqID, rq, validate, pm.quitSync); err != nil {
+		log.Debug("Failed to retrieve trusted head", "number", ann.Number, "hash", ann.Hash, "err", err)
+		return
+	}
+	if err := pm.blockchain.(*light.LightChain).InsertTrustedHeader(header, ann.Td); err != nil {
+		log.Warn("Failed to insert trusted head", "number", ann.Number, "hash", ann.Hash, "err", err)
+	}
+}
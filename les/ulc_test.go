@@ -0,0 +1,75 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/crypto"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+)
+
+// Tests that heads are accepted once announced by the threshold number of
+// trusted servers, and that untrusted and stale announcements are ignored.
+func TestULCAnnounceThreshold(t *testing.T) {
+	var (
+		urls []string
+		ids  []discover.NodeID
+	)
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		id := discover.PubkeyID(&key.PublicKey)
+		urls = append(urls, fmt.Sprintf("enode://%x@127.0.0.1:30303", id[:]))
+		ids = append(ids, id)
+	}
+	if _, err := newULC(urls, 4); err == nil {
+		t.Fatalf("threshold above server count accepted")
+	}
+	u, err := newULC(urls, 2)
+	if err != nil {
+		t.Fatalf("failed to create ultra light client: %v", err)
+	}
+	head := &announceData{Hash: common.Hash{1}, Number: 10, Td: big.NewInt(100)}
+
+	if signers := u.announce(discover.NodeID{0xff}, head); signers != nil {
+		t.Fatalf("untrusted announcement accepted")
+	}
+	if signers := u.announce(ids[0], head); signers != nil {
+		t.Fatalf("head accepted below threshold")
+	}
+	if signers := u.announce(ids[0], head); signers != nil {
+		t.Fatalf("repeated announcement counted twice")
+	}
+	signers := u.announce(ids[1], head)
+	if len(signers) != 2 {
+		t.Fatalf("head not accepted at threshold: signers %v", signers)
+	}
+	if signers := u.announce(ids[2], head); signers != nil {
+		t.Fatalf("accepted head accepted again")
+	}
+	stale := &announceData{Hash: common.Hash{2}, Number: 9, Td: big.NewInt(90)}
+	if signers := u.announce(ids[0], stale); signers != nil || len(u.pending) != 0 {
+		t.Fatalf("stale head tracked")
+	}
+	// Zero threshold requires all servers
+	if u, _ = newULC(urls, 0); u.threshold != len(urls) {
+		t.Fatalf("default threshold mismatch: have %d, want %d", u.threshold, len(urls))
+	}
+}
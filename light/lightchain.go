@@ -96,7 +96,7 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 		return nil, core.ErrNoGenesis
 	}
 	if cp, ok := trustedCheckpoints[bc.genesisBlock.Hash()]; ok {
-		bc.addTrustedCheckpoint(cp)
+		bc.AddTrustedCheckpoint(cp)
 	}
 
 	if err := bc.loadLastState(); err != nil {
@@ -113,20 +113,21 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 	return bc, nil
 }
 
-// addTrustedCheckpoint adds a trusted checkpoint to the blockchain
-func (self *LightChain) addTrustedCheckpoint(cp trustedCheckpoint) {
+// AddTrustedCheckpoint adds a trusted checkpoint to the blockchain, allowing
+// the light client to skip downloading the headers before it.
+func (self *LightChain) AddTrustedCheckpoint(cp TrustedCheckpoint) {
 	if self.odr.ChtIndexer() != nil {
-		StoreChtRoot(self.chainDb, cp.sectionIdx, cp.sectionHead, cp.chtRoot)
-		self.odr.ChtIndexer().AddKnownSectionHead(cp.sectionIdx, cp.sectionHead)
+		StoreChtRoot(self.chainDb, cp.SectionIdx, cp.SectionHead, cp.CHTRoot)
+		self.odr.ChtIndexer().AddKnownSectionHead(cp.SectionIdx, cp.SectionHead)
 	}
 	if self.odr.BloomTrieIndexer() != nil {
-		StoreBloomTrieRoot(self.chainDb, cp.sectionIdx, cp.sectionHead, cp.bloomTrieRoot)
-		self.odr.BloomTrieIndexer().AddKnownSectionHead(cp.sectionIdx, cp.sectionHead)
+		StoreBloomTrieRoot(self.chainDb, cp.SectionIdx, cp.SectionHead, cp.BloomTrieRoot)
+		self.odr.BloomTrieIndexer().AddKnownSectionHead(cp.SectionIdx, cp.SectionHead)
 	}
 	if self.odr.BloomIndexer() != nil {
-		self.odr.BloomIndexer().AddKnownSectionHead(cp.sectionIdx, cp.sectionHead)
+		self.odr.BloomIndexer().AddKnownSectionHead(cp.SectionIdx, cp.SectionHead)
 	}
-	log.Info("Added trusted checkpoint", "chain name", cp.name)
+	log.Info("Added trusted checkpoint", "chain name", cp.Name, "section", cp.SectionIdx, "head", cp.SectionHead)
 }
 
 func (self *LightChain) getProcInterrupt() bool {
@@ -397,6 +398,59 @@ func (self *LightChain) InsertHeaderChain(chain []*types.Header, checkFreq int)
 	return i, err
 }
 
+// InsertTrustedHeader sets a header vouched for by trusted servers as the new
+// head of the chain without verifying it or retrieving its ancestors. The header
+// is ignored if its total difficulty is not higher than that of the current head.
+// Canonical hashes are rewritten only as far back as the ancestors of the header
+// are known locally.
+func (self *LightChain) InsertTrustedHeader(header *types.Header, td *big.Int) error {
+	self.chainmu.Lock()
+	defer self.chainmu.Unlock()
+
+	self.wg.Add(1)
+	defer self.wg.Done()
+
+	self.mu.Lock()
+	head := self.hc.CurrentHeader()
+	if headTd := self.hc.GetTd(head.Hash(), head.Number.Uint64()); headTd != nil && td.Cmp(headTd) <= 0 {
+		self.mu.Unlock()
+		return nil
+	}
+	hash, number := header.Hash(), header.Number.Uint64()
+	if err := core.WriteHeader(self.chainDb, header); err != nil {
+		self.mu.Unlock()
+		return err
+	}
+	if err := core.WriteTd(self.chainDb, hash, number, td); err != nil {
+		self.mu.Unlock()
+		return err
+	}
+	// Drop canonical hashes of a previous longer chain and relink known ancestors
+	for i := number + 1; core.GetCanonicalHash(self.chainDb, i) != (common.Hash{}); i++ {
+		core.DeleteCanonicalHash(self.chainDb, i)
+	}
+	for ancestor := header; ancestor != nil; {
+		num := ancestor.Number.Uint64()
+		if core.GetCanonicalHash(self.chainDb, num) == ancestor.Hash() {
+			break
+		}
+		if err := core.WriteCanonicalHash(self.chainDb, ancestor.Hash(), num); err != nil {
+			self.mu.Unlock()
+			return err
+		}
+		if num == 0 {
+			break
+		}
+		ancestor = self.hc.GetHeader(ancestor.ParentHash, num-1)
+	}
+	self.hc.SetCurrentHeader(header)
+	self.mu.Unlock()
+
+	log.Debug("Inserted trusted header", "number", number, "hash", hash, "td", td)
+	go self.postChainEvents([]interface{}{core.ChainEvent{Block: types.NewBlockWithHeader(header), Hash: hash}})
+	return nil
+}
+
 // CurrentHeader retrieves the current head header of the canonical chain. The
 // header is retrieved from the HeaderChain's internal cache.
 func (self *LightChain) CurrentHeader() *types.Header {
@@ -346,3 +346,39 @@ func TestReorgBadHeaderHashes(t *testing.T) {
 		t.Errorf("last header hash mismatch: have: %x, want %x", ncm.CurrentHeader().Hash(), headers[2].Hash())
 	}
 }
+
+// Tests that trusted headers are set as the chain head without their ancestors,
+// and only if they are heavier than the current head.
+func TestInsertTrustedHeader(t *testing.T) {
+	bc := newTestLightChain()
+
+	local := makeHeaderChainWithDiff(bc.genesisBlock, []int{1, 2, 3}, 11)
+	if _, err := bc.InsertHeaderChain(local, 1); err != nil {
+		t.Fatalf("failed to insert local chain: %v", err)
+	}
+	// A lighter trusted head is ignored
+	remote := makeHeaderChainWithDiff(bc.genesisBlock, []int{1, 1, 1, 1, 1}, 22)
+	if err := bc.InsertTrustedHeader(remote[1], big.NewInt(3)); err != nil {
+		t.Fatalf("failed to insert trusted header: %v", err)
+	}
+	if head := bc.CurrentHeader(); head.Hash() != local[2].Hash() {
+		t.Fatalf("lighter trusted head accepted: have #%d", head.Number)
+	}
+	// A heavier one replaces the head, dropping the stale canonical hashes
+	td := big.NewInt(100)
+	if err := bc.InsertTrustedHeader(remote[1], td); err != nil {
+		t.Fatalf("failed to insert trusted header: %v", err)
+	}
+	if head := bc.CurrentHeader(); head.Hash() != remote[1].Hash() {
+		t.Fatalf("head mismatch: have #%d %x, want #2 %x", head.Number, head.Hash(), remote[1].Hash())
+	}
+	if have := bc.GetTdByHash(remote[1].Hash()); have.Cmp(td) != 0 {
+		t.Errorf("total difficulty mismatch: have %v, want %v", have, td)
+	}
+	if header := bc.GetHeaderByNumber(3); header != nil {
+		t.Errorf("stale canonical header #3 retained")
+	}
+	if header := bc.GetHeaderByNumber(1); header == nil || header.Hash() != local[0].Hash() {
+		t.Errorf("canonical header #1 mismatch: have %v", header)
+	}
+}
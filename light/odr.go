@@ -147,6 +147,24 @@ func (req *ChtRequest) StoreResult(db ruedb.Database) {
 	core.WriteCanonicalHash(db, hash, num)
 }
 
+// TxStatus describes the status of a transaction as reported by a server. The
+// lookup entry is only available for included transactions.
+type TxStatus struct {
+	Status core.TxStatus
+	Lookup *core.TxLookupEntry
+}
+
+// TxStatusRequest is the ODR request type for retrieving the status of
+// transactions. The results are unverified.
+type TxStatusRequest struct {
+	OdrRequest
+	Hashes []common.Hash
+	Status []TxStatus
+}
+
+// StoreResult stores the retrieved data in local database
+func (req *TxStatusRequest) StoreResult(db ruedb.Database) {}
+
 // BloomRequest is the ODR request type for retrieving bloom filters from a CHT structure
 type BloomRequest struct {
 	OdrRequest
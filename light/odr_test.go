@@ -82,6 +82,13 @@ func (odr *testOdr) Retrieve(ctx context.Context, req OdrRequest) error {
 		req.Proof = nodes
 	case *CodeRequest:
 		req.Data, _ = odr.sdb.Get(req.Hash[:])
+	case *TxStatusRequest:
+		req.Status = make([]TxStatus, len(req.Hashes))
+		for i, hash := range req.Hashes {
+			if block, number, index := core.GetTxLookupEntry(odr.sdb, hash); block != (common.Hash{}) {
+				req.Status[i] = TxStatus{Status: core.TxStatusIncluded, Lookup: &core.TxLookupEntry{BlockHash: block, BlockIndex: number, Index: index}}
+			}
+		}
 	}
 	req.StoreResult(odr.ldb)
 	return nil
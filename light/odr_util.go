@@ -137,7 +137,23 @@ func GetBlockReceipts(ctx context.Context, odr OdrBackend, hash common.Hash, num
 	if err := odr.Retrieve(ctx, r); err != nil {
 		return nil, err
 	}
-	return r.Receipts, nil
+	receipts = r.Receipts
+
+	// Receipts retrieved from the network carry only the consensus fields, fill
+	// in the derived ones (e.g. log positions) from the block itself
+	if len(receipts) > 0 {
+		block, err := GetBlock(ctx, odr, hash, number)
+		if err != nil {
+			return nil, err
+		}
+		config, err := core.GetChainConfig(odr.Database(), core.GetCanonicalHash(odr.Database(), 0))
+		if err != nil {
+			return nil, err
+		}
+		core.SetReceiptsData(config, block, receipts)
+		core.WriteBlockReceipts(odr.Database(), hash, number, receipts)
+	}
+	return receipts, nil
 }
 
 // GetBloomBits retrieves a batch of compressed bloomBits vectors belonging to the given bit index and section indexes
@@ -29,7 +29,6 @@ import (
 	"github.com/Rue-Foundation/go-rue/core/types"
 	"github.com/Rue-Foundation/go-rue/ruedb"
 	"github.com/Rue-Foundation/go-rue/log"
-	"github.com/Rue-Foundation/go-rue/params"
 	"github.com/Rue-Foundation/go-rue/rlp"
 	"github.com/Rue-Foundation/go-rue/trie"
 )
@@ -41,40 +40,23 @@ const (
 	HelperTrieProcessConfirmations = 256  // number of confirmations before a HelperTrie is generated
 )
 
-// trustedCheckpoint represents a set of post-processed trie roots (CHT and BloomTrie) associated with
+// TrustedCheckpoint represents a set of post-processed trie roots (CHT and BloomTrie) associated with
 // the appropriate section index and head hash. It is used to start light syncing from this checkpoint
 // and avoid downloading the entire header chain while still being able to securely access old headers/logs.
-type trustedCheckpoint struct {
-	name                                string
-	sectionIdx                          uint64
-	sectionHead, chtRoot, bloomTrieRoot common.Hash
+type TrustedCheckpoint struct {
+	Name          string      `json:"name"`
+	SectionIdx    uint64      `json:"sectionIndex"`
+	SectionHead   common.Hash `json:"sectionHead"`
+	CHTRoot       common.Hash `json:"chtRoot"`
+	BloomTrieRoot common.Hash `json:"bloomTrieRoot"`
 }
 
-var (
-	mainnetCheckpoint = trustedCheckpoint{
-		name:          "ETH mainnet",
-		sectionIdx:    129,
-		sectionHead:   common.HexToHash("64100587c8ec9a76870056d07cb0f58622552d16de6253a59cac4b580c899501"),
-		chtRoot:       common.HexToHash("bb4fb4076cbe6923c8a8ce8f158452bbe19564959313466989fda095a60884ca"),
-		bloomTrieRoot: common.HexToHash("0db524b2c4a2a9520a42fd842b02d2e8fb58ff37c75cf57bd0eb82daeace6716"),
-	}
-
-	ropstenCheckpoint = trustedCheckpoint{
-		name:          "Ropsten testnet",
-		sectionIdx:    50,
-		sectionHead:   common.HexToHash("00bd65923a1aa67f85e6b4ae67835784dd54be165c37f056691723c55bf016bd"),
-		chtRoot:       common.HexToHash("6f56dc61936752cc1f8c84b4addabdbe6a1c19693de3f21cb818362df2117f03"),
-		bloomTrieRoot: common.HexToHash("aca7d7c504d22737242effc3fdc604a762a0af9ced898036b5986c3a15220208"),
-	}
-)
-
-// trustedCheckpoints associates each known checkpoint with the genesis hash of the chain it belongs to
-var trustedCheckpoints = map[common.Hash]trustedCheckpoint{
-	params.MainnetGenesisHash: mainnetCheckpoint,
-	params.TestnetGenesisHash: ropstenCheckpoint,
-}
+// trustedCheckpoints associates each known checkpoint with the genesis hash of the chain it belongs to.
+// No Rue checkpoints are built in yet, light clients may be given one via configuration.
+var trustedCheckpoints = map[common.Hash]TrustedCheckpoint{}
 
 var (
+	ErrNoCheckpoint       = errors.New("No complete CHT and BloomTrie section available")
 	ErrNoTrustedCht       = errors.New("No trusted canonical hash trie")
 	ErrNoTrustedBloomTrie = errors.New("No trusted bloom trie")
 	ErrNoHeader           = errors.New("Header not found")
@@ -293,3 +275,41 @@ func (b *BloomTrieIndexerBackend) Commit() error {
 
 	return nil
 }
+
+// ServerCheckpoint assembles a trusted checkpoint out of the latest section
+// processed by both the CHT and BloomTrie indexers of a serving full node.
+func ServerCheckpoint(db ruedb.Database, name string) (*TrustedCheckpoint, error) {
+	chtIndexer := NewChtIndexer(db, false)
+	defer chtIndexer.Close()
+	bloomTrieIndexer := NewBloomTrieIndexer(db, false)
+	defer bloomTrieIndexer.Close()
+
+	// Servers generate LES/1 sized CHTs, find the last complete LES/2 section
+	chtSections, _, _ := chtIndexer.Sections()
+	sections, _, _ := bloomTrieIndexer.Sections()
+	if cs := chtSections / (ChtFrequency / ChtV1Frequency); cs < sections {
+		sections = cs
+	}
+	if sections == 0 {
+		return nil, ErrNoCheckpoint
+	}
+	idx := sections - 1
+	head := chtIndexer.SectionHead((idx+1)*(ChtFrequency/ChtV1Frequency) - 1)
+	if bloomHead := bloomTrieIndexer.SectionHead(idx); bloomHead != head {
+		return nil, fmt.Errorf("section %d head mismatch: CHT %x, BloomTrie %x", idx, head, bloomHead)
+	}
+	checkpoint := &TrustedCheckpoint{
+		Name:          name,
+		SectionIdx:    idx,
+		SectionHead:   head,
+		CHTRoot:       GetChtV2Root(db, idx, head),
+		BloomTrieRoot: GetBloomTrieRoot(db, idx, head),
+	}
+	if checkpoint.CHTRoot == (common.Hash{}) {
+		return nil, ErrNoTrustedCht
+	}
+	if checkpoint.BloomTrieRoot == (common.Hash{}) {
+		return nil, ErrNoTrustedBloomTrie
+	}
+	return checkpoint, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"testing"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/ruedb"
+)
+
+// Tests that a trusted checkpoint can be assembled from the helper trie sections
+// processed by a serving full node.
+func TestServerCheckpoint(t *testing.T) {
+	db, _ := ruedb.NewMemDatabase()
+	if _, err := ServerCheckpoint(db, "test"); err != ErrNoCheckpoint {
+		t.Fatalf("checkpoint error mismatch on empty database: have %v, want %v", err, ErrNoCheckpoint)
+	}
+	var (
+		ratio     = uint64(ChtFrequency / ChtV1Frequency)
+		head      = common.HexToHash("0x01")
+		chtRoot   = common.HexToHash("0x02")
+		bloomRoot = common.HexToHash("0x03")
+	)
+	// Mark a complete LES/2 section as processed by the server side indexers
+	chtIndexer := NewChtIndexer(db, false)
+	for i := uint64(0); i < ratio-1; i++ {
+		chtIndexer.AddKnownSectionHead(i, common.Hash{})
+	}
+	chtIndexer.AddKnownSectionHead(ratio-1, head)
+	chtIndexer.Close()
+	StoreChtRoot(db, ratio-1, head, chtRoot)
+
+	bloomTrieIndexer := NewBloomTrieIndexer(db, false)
+	bloomTrieIndexer.AddKnownSectionHead(0, head)
+	bloomTrieIndexer.Close()
+	if _, err := ServerCheckpoint(db, "test"); err != ErrNoTrustedBloomTrie {
+		t.Fatalf("checkpoint error mismatch without BloomTrie root: have %v, want %v", err, ErrNoTrustedBloomTrie)
+	}
+	StoreBloomTrieRoot(db, 0, head, bloomRoot)
+
+	checkpoint, err := ServerCheckpoint(db, "test")
+	if err != nil {
+		t.Fatalf("failed to assemble checkpoint: %v", err)
+	}
+	want := TrustedCheckpoint{Name: "test", SectionIdx: 0, SectionHead: head, CHTRoot: chtRoot, BloomTrieRoot: bloomRoot}
+	if *checkpoint != want {
+		t.Fatalf("checkpoint mismatch: have %+v, want %+v", *checkpoint, want)
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package rpcodr implements an on-demand retrieval backend for the light client
+// that fetches data from an untrusted full node over its JSON-RPC interface.
+//
+// The backend tracks the header chain locally and verifies every retrieved
+// trie node, contract code, block body and receipt set against it, so the
+// remote node cannot feed the client forged data.
+package rpcodr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/Rue-Foundation/go-rue/common/hexutil"
+	"github.com/Rue-Foundation/go-rue/core"
+	"github.com/Rue-Foundation/go-rue/core/types"
+	"github.com/Rue-Foundation/go-rue/crypto"
+	"github.com/Rue-Foundation/go-rue/light"
+	"github.com/Rue-Foundation/go-rue/log"
+	"github.com/Rue-Foundation/go-rue/rlp"
+	"github.com/Rue-Foundation/go-rue/rpc"
+	"github.com/Rue-Foundation/go-rue/ruedb"
+	"github.com/Rue-Foundation/go-rue/trie"
+)
+
+const (
+	headerBatch          = 192 // Number of headers to fetch in a single RPC batch
+	headerCheckFrequency = 100 // Verification frequency of the seals of synced headers
+)
+
+var (
+	errHeaderUnavailable    = errors.New("header unavailable")
+	errTxHashMismatch       = errors.New("transaction hash mismatch")
+	errUncleHashMismatch    = errors.New("uncle hash mismatch")
+	errReceiptHashMismatch  = errors.New("receipt hash mismatch")
+	errDataHashMismatch     = errors.New("data hash mismatch")
+	errUselessNodes         = errors.New("useless nodes in merkle proof nodeset")
+	errGenesisMismatch      = errors.New("remote genesis mismatch")
+	errUnsupportedRequest   = errors.New("request type not supported over RPC")
+	errInvalidHeaderRequest = errors.New("remote node returned a different header than requested")
+)
+
+// Backend is a light.OdrBackend retrieving data from a remote node's RPC API.
+type Backend struct {
+	client *rpc.Client
+	db     ruedb.Database
+}
+
+// New creates an RPC retrieval backend storing verified data into db.
+func New(client *rpc.Client, db ruedb.Database) *Backend {
+	return &Backend{client: client, db: db}
+}
+
+// Database returns the local database verified data is stored into.
+func (b *Backend) Database() ruedb.Database { return b.db }
+
+// ChtIndexer returns nil, as header lookups via CHTs are not supported.
+func (b *Backend) ChtIndexer() *core.ChainIndexer { return nil }
+
+// BloomTrieIndexer returns nil, as bloom filter lookups are not supported.
+func (b *Backend) BloomTrieIndexer() *core.ChainIndexer { return nil }
+
+// BloomIndexer returns nil, as bloom filter lookups are not supported.
+func (b *Backend) BloomIndexer() *core.ChainIndexer { return nil }
+
+// Retrieve fetches the data requested by an ODR request from the remote node,
+// verifies it against the locally known header chain and stores it into the
+// local database.
+func (b *Backend) Retrieve(ctx context.Context, req light.OdrRequest) error {
+	var err error
+	switch req := req.(type) {
+	case *light.TrieRequest:
+		err = b.retrieveTrie(ctx, req)
+	case *light.CodeRequest:
+		err = b.retrieveCode(ctx, req)
+	case *light.BlockRequest:
+		err = b.retrieveBody(ctx, req)
+	case *light.ReceiptsRequest:
+		err = b.retrieveReceipts(ctx, req)
+	default:
+		err = errUnsupportedRequest
+	}
+	if err != nil {
+		return err
+	}
+	req.StoreResult(b.db)
+	return nil
+}
+
+// retrieveTrie fetches and verifies a Merkle proof of a state or storage trie.
+func (b *Backend) retrieveTrie(ctx context.Context, req *light.TrieRequest) error {
+	log.Debug("Requesting trie proof over RPC", "root", req.Id.Root, "key", req.Key)
+
+	var proof []hexutil.Bytes
+	if err := b.client.CallContext(ctx, &proof, "eth_getTrieProof", req.Id.BlockHash, hexutil.Bytes(req.Id.AccKey), hexutil.Bytes(req.Key)); err != nil {
+		return err
+	}
+	nodes := make(light.NodeList, len(proof))
+	for i, node := range proof {
+		nodes[i] = rlp.RawValue(node)
+	}
+	nodeSet := nodes.NodeSet()
+	reads := &readTraceDB{db: nodeSet}
+	if _, err, _ := trie.VerifyProof(req.Id.Root, req.Key, reads); err != nil {
+		return fmt.Errorf("merkle proof verification failed: %v", err)
+	}
+	// Reject proofs padded with nodes not needed by the verification
+	if len(reads.reads) != nodeSet.KeyCount() {
+		return errUselessNodes
+	}
+	req.Proof = nodeSet
+	return nil
+}
+
+// retrieveCode fetches a contract code and verifies it against its hash.
+func (b *Backend) retrieveCode(ctx context.Context, req *light.CodeRequest) error {
+	log.Debug("Requesting code data over RPC", "hash", req.Hash)
+
+	var code hexutil.Bytes
+	if err := b.client.CallContext(ctx, &code, "eth_getCodeByHash", req.Hash); err != nil {
+		return err
+	}
+	if crypto.Keccak256Hash(code) != req.Hash {
+		return errDataHashMismatch
+	}
+	req.Data = code
+	return nil
+}
+
+// retrieveBody fetches a block body and verifies it against the local header.
+func (b *Backend) retrieveBody(ctx context.Context, req *light.BlockRequest) error {
+	log.Debug("Requesting block body over RPC", "hash", req.Hash)
+
+	header := core.GetHeader(b.db, req.Hash, req.Number)
+	if header == nil {
+		return errHeaderUnavailable
+	}
+	var data hexutil.Bytes
+	if err := b.client.CallContext(ctx, &data, "eth_getRawBlockBody", req.Hash); err != nil {
+		return err
+	}
+	body := new(types.Body)
+	if err := rlp.DecodeBytes(data, body); err != nil {
+		return err
+	}
+	if header.TxHash != types.DeriveSha(types.Transactions(body.Transactions)) {
+		return errTxHashMismatch
+	}
+	if header.UncleHash != types.CalcUncleHash(body.Uncles) {
+		return errUncleHashMismatch
+	}
+	req.Rlp = data
+	return nil
+}
+
+// retrieveReceipts fetches the receipts of a block and verifies them against
+// the local header.
+func (b *Backend) retrieveReceipts(ctx context.Context, req *light.ReceiptsRequest) error {
+	log.Debug("Requesting block receipts over RPC", "hash", req.Hash)
+
+	header := core.GetHeader(b.db, req.Hash, req.Number)
+	if header == nil {
+		return errHeaderUnavailable
+	}
+	var data hexutil.Bytes
+	if err := b.client.CallContext(ctx, &data, "eth_getRawReceipts", req.Hash); err != nil {
+		return err
+	}
+	var receipts types.Receipts
+	if err := rlp.DecodeBytes(data, &receipts); err != nil {
+		return err
+	}
+	if header.ReceiptHash != types.DeriveSha(receipts) {
+		return errReceiptHashMismatch
+	}
+	req.Receipts = receipts
+	return nil
+}
+
+// SyncHeaders imports the remote node's canonical headers into the light chain,
+// rewinding to the latest common ancestor if the remote chain was reorganised.
+// The chain must be backed by the same database as the retrieval backend.
+func (b *Backend) SyncHeaders(ctx context.Context, chain *light.LightChain) error {
+	head, err := b.headerByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	ancestor, err := b.findAncestor(ctx, chain)
+	if err != nil {
+		return err
+	}
+	target := head.Number.Uint64()
+	for from := ancestor + 1; from <= target; from += headerBatch {
+		count := target - from + 1
+		if count > headerBatch {
+			count = headerBatch
+		}
+		headers, err := b.headersByNumber(ctx, from, count)
+		if err != nil {
+			return err
+		}
+		if _, err := chain.InsertHeaderChain(headers, headerCheckFrequency); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findAncestor locates the highest block of the local chain that is also part
+// of the remote canonical chain.
+func (b *Backend) findAncestor(ctx context.Context, chain *light.LightChain) (uint64, error) {
+	number, step := chain.CurrentHeader().Number.Uint64(), uint64(1)
+	for {
+		header, err := b.headerByNumber(ctx, new(big.Int).SetUint64(number))
+		if err != nil {
+			return 0, err
+		}
+		if chain.HasHeader(header.Hash(), number) {
+			return number, nil
+		}
+		if number == 0 {
+			return 0, errGenesisMismatch
+		}
+		if step > number {
+			step = number
+		}
+		number, step = number-step, step*2
+	}
+}
+
+// headerByNumber retrieves a single header from the remote node, or its head
+// header if number is nil.
+func (b *Backend) headerByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	var data hexutil.Bytes
+	if err := b.client.CallContext(ctx, &data, "eth_getRawHeaderByNumber", toBlockNumArg(number)); err != nil {
+		return nil, err
+	}
+	header := new(types.Header)
+	if err := rlp.DecodeBytes(data, header); err != nil {
+		return nil, err
+	}
+	if number != nil && header.Number.Cmp(number) != 0 {
+		return nil, errInvalidHeaderRequest
+	}
+	return header, nil
+}
+
+// headersByNumber retrieves a batch of consecutive headers from the remote node.
+func (b *Backend) headersByNumber(ctx context.Context, from, count uint64) ([]*types.Header, error) {
+	var (
+		reqs    = make([]rpc.BatchElem, count)
+		results = make([]hexutil.Bytes, count)
+	)
+	for i := range reqs {
+		reqs[i] = rpc.BatchElem{
+			Method: "eth_getRawHeaderByNumber",
+			Args:   []interface{}{hexutil.EncodeUint64(from + uint64(i))},
+			Result: &results[i],
+		}
+	}
+	if err := b.client.BatchCallContext(ctx, reqs); err != nil {
+		return nil, err
+	}
+	headers := make([]*types.Header, count)
+	for i := range reqs {
+		if reqs[i].Error != nil {
+			return nil, reqs[i].Error
+		}
+		header := new(types.Header)
+		if err := rlp.DecodeBytes(results[i], header); err != nil {
+			return nil, err
+		}
+		if header.Number.Uint64() != from+uint64(i) {
+			return nil, errInvalidHeaderRequest
+		}
+		headers[i] = header
+	}
+	return headers, nil
+}
+
+func toBlockNumArg(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	return hexutil.EncodeBig(number)
+}
+
+// readTraceDB stores the keys of database reads. We use this to check that received node
+// sets contain only the trie nodes necessary to make proofs pass.
+type readTraceDB struct {
+	db    trie.DatabaseReader
+	reads map[string]struct{}
+}
+
+// Get returns a stored node
+func (db *readTraceDB) Get(k []byte) ([]byte, error) {
+	if db.reads == nil {
+		db.reads = make(map[string]struct{})
+	}
+	db.reads[string(k)] = struct{}{}
+	return db.db.Get(k)
+}
+
+// Has returns true if the node set contains the given key
+func (db *readTraceDB) Has(key []byte) (bool, error) {
+	_, err := db.Get(key)
+	return err == nil, nil
+}
@@ -0,0 +1,196 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpcodr
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/consensus/ruehash"
+	"github.com/Rue-Foundation/go-rue/core"
+	"github.com/Rue-Foundation/go-rue/core/types"
+	"github.com/Rue-Foundation/go-rue/core/vm"
+	"github.com/Rue-Foundation/go-rue/crypto"
+	"github.com/Rue-Foundation/go-rue/internal/rueapi"
+	"github.com/Rue-Foundation/go-rue/light"
+	"github.com/Rue-Foundation/go-rue/params"
+	"github.com/Rue-Foundation/go-rue/rpc"
+	"github.com/Rue-Foundation/go-rue/ruedb"
+)
+
+var (
+	testBankKey, _  = crypto.GenerateKey()
+	testBankAddress = crypto.PubkeyToAddress(testBankKey.PublicKey)
+
+	testContract     = common.HexToAddress("0xc0de")
+	testContractCode = common.Hex2Bytes("6001600055")
+	testSlot         = common.HexToHash("0x01")
+	testSlotValue    = common.HexToHash("0x2a")
+)
+
+// testBackend serves the chain data needed by the raw RPC endpoints out of a
+// local blockchain. Any other backend method is left unimplemented.
+type testBackend struct {
+	rueapi.Backend
+	db    ruedb.Database
+	chain *core.BlockChain
+}
+
+func (b *testBackend) ChainDb() ruedb.Database { return b.db }
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number < 0 {
+		return b.chain.CurrentBlock().Header(), nil
+	}
+	return b.chain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return b.chain.GetBlockByHash(hash), nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return core.GetBlockReceipts(b.db, hash, core.GetBlockNumber(b.db, hash)), nil
+}
+
+// newTestServer creates a full chain with a few value transfers and serves it
+// over an in-process RPC server.
+func newTestServer(t *testing.T, genesis *core.Genesis, blocks int) (*rpc.Client, *core.BlockChain, ruedb.Database) {
+	db, _ := ruedb.NewMemDatabase()
+	genesisBlock := genesis.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, genesis.Config, ruehash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	signer := types.HorizonSigner{}
+	gchain, _ := core.GenerateChain(genesis.Config, genesisBlock, ruehash.NewFaker(), db, blocks, func(i int, block *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBankAddress), common.Address{byte(i + 1)}, big.NewInt(1000), big.NewInt(21000), big.NewInt(1), nil), signer, testBankKey)
+		block.AddTx(tx)
+	})
+	if _, err := chain.InsertChain(gchain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", rueapi.NewPublicBlockChainAPI(&testBackend{db: db, chain: chain})); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	return rpc.DialInProc(server), chain, db
+}
+
+func newTestGenesis() *core.Genesis {
+	return &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			testBankAddress: {Balance: big.NewInt(100000000)},
+			testContract: {
+				Balance: big.NewInt(1),
+				Code:    testContractCode,
+				Storage: map[common.Hash]common.Hash{testSlot: testSlotValue},
+			},
+		},
+	}
+}
+
+// Tests that a light chain can be synced and its state, blocks and receipts
+// retrieved and verified through a remote node's RPC API.
+func TestRPCRetrieval(t *testing.T) {
+	genesis := newTestGenesis()
+	client, chain, _ := newTestServer(t, genesis, 4)
+
+	ldb, _ := ruedb.NewMemDatabase()
+	genesis.MustCommit(ldb)
+	odr := New(client, ldb)
+	lc, err := light.NewLightChain(odr, genesis.Config, ruehash.NewFaker())
+	if err != nil {
+		t.Fatalf("failed to create light chain: %v", err)
+	}
+	ctx := context.Background()
+	if err := odr.SyncHeaders(ctx, lc); err != nil {
+		t.Fatalf("failed to sync headers: %v", err)
+	}
+	head := lc.CurrentHeader()
+	if head.Hash() != chain.CurrentBlock().Hash() {
+		t.Fatalf("head mismatch: have #%d, want #%d", head.Number, chain.CurrentBlock().Number())
+	}
+	// Access the state of the head block
+	statedb := light.NewState(ctx, head, odr)
+	want, _ := chain.State()
+	for _, addr := range []common.Address{testBankAddress, testContract, {1}, {0xff}} {
+		if have, want := statedb.GetBalance(addr), want.GetBalance(addr); have.Cmp(want) != 0 {
+			t.Errorf("balance mismatch for %x: have %v, want %v", addr, have, want)
+		}
+	}
+	if code := statedb.GetCode(testContract); !bytes.Equal(code, testContractCode) {
+		t.Errorf("code mismatch: have %x, want %x", code, testContractCode)
+	}
+	if value := statedb.GetState(testContract, testSlot); value != testSlotValue {
+		t.Errorf("storage mismatch: have %x, want %x", value, testSlotValue)
+	}
+	if err := statedb.Error(); err != nil {
+		t.Fatalf("state retrieval failed: %v", err)
+	}
+	// Retrieve a block body and its receipts
+	block, err := lc.GetBlockByHash(ctx, head.Hash())
+	if err != nil {
+		t.Fatalf("failed to retrieve block: %v", err)
+	}
+	if block.Hash() != head.Hash() || len(block.Transactions()) != 1 {
+		t.Errorf("block mismatch: have %x with %d txs", block.Hash(), len(block.Transactions()))
+	}
+	receipts, err := light.GetBlockReceipts(ctx, odr, head.Hash(), head.Number.Uint64())
+	if err != nil || len(receipts) != 1 {
+		t.Fatalf("failed to retrieve receipts: %v (%d)", err, len(receipts))
+	}
+}
+
+// Tests that data forged by the remote node is rejected.
+func TestRPCForgedData(t *testing.T) {
+	genesis := newTestGenesis()
+	client, chain, sdb := newTestServer(t, genesis, 2)
+
+	ldb, _ := ruedb.NewMemDatabase()
+	genesis.MustCommit(ldb)
+	odr := New(client, ldb)
+	lc, _ := light.NewLightChain(odr, genesis.Config, ruehash.NewFaker())
+
+	ctx := context.Background()
+	if err := odr.SyncHeaders(ctx, lc); err != nil {
+		t.Fatalf("failed to sync headers: %v", err)
+	}
+	// Replace the contract code on the server and request it
+	codeHash := crypto.Keccak256Hash(testContractCode)
+	sdb.Put(codeHash[:], []byte{0xde, 0xad})
+	if err := odr.Retrieve(ctx, &light.CodeRequest{Id: light.StateTrieID(lc.CurrentHeader()), Hash: codeHash}); err != errDataHashMismatch {
+		t.Errorf("forged code error mismatch: have %v, want %v", err, errDataHashMismatch)
+	}
+	// Request a state proof against a root the server does not know
+	id := light.StateTrieID(lc.CurrentHeader())
+	id.Root = common.HexToHash("0xbad")
+	if err := odr.Retrieve(ctx, &light.TrieRequest{Id: id, Key: crypto.Keccak256(testBankAddress[:])}); err == nil {
+		t.Errorf("proof for unknown root accepted")
+	}
+	// Drop the receipts of the head block on the server and request them
+	head := chain.CurrentBlock()
+	core.WriteBlockReceipts(sdb, head.Hash(), head.NumberU64(), types.Receipts{})
+	if err := odr.Retrieve(ctx, &light.ReceiptsRequest{Hash: head.Hash(), Number: head.NumberU64()}); err != errReceiptHashMismatch {
+		t.Errorf("forged receipts error mismatch: have %v, want %v", err, errReceiptHashMismatch)
+	}
+}
@@ -42,6 +42,34 @@ const (
 // considered permanent and no rollback is expected
 var txPermanent = uint64(500)
 
+// txJournalKey is the database key the pending and recently mined transactions
+// are journaled under.
+var txJournalKey = []byte("lightTxPool")
+
+const (
+	// txStatusBatch is the maximum number of transactions queried in a single
+	// status request when resuming journaled transactions.
+	txStatusBatch = 256
+
+	// txStatusTimeout is the time limit of a status request, retried after
+	// txStatusRetry until it succeeds or the pool is stopped.
+	txStatusTimeout = time.Minute
+	txStatusRetry   = 10 * time.Second
+)
+
+// txJournal is the storage format of the transactions tracked by the pool.
+type txJournal struct {
+	Head    common.Hash
+	Pending []*types.Transaction
+	Mined   []minedTxs
+}
+
+// minedTxs is the storage format of the local transactions mined in a block.
+type minedTxs struct {
+	Block common.Hash
+	Txs   []*types.Transaction
+}
+
 // TxPool implements the transaction pool for light clients, which keeps track
 // of the status of locally created transactions, detecting if they are included
 // in a block (mined) or rolled back. There are no queued transactions since we
@@ -101,13 +129,161 @@ func NewTxPool(config *params.ChainConfig, chain *LightChain, relay TxRelayBacke
 		head:        chain.CurrentHeader().Hash(),
 		clearIdx:    chain.CurrentHeader().Number.Uint64(),
 	}
+	resume := pool.loadJournal()
+
 	// Subscribe events from blockchain
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
 	go pool.eventLoop()
-
+	if len(resume) > 0 {
+		go pool.resumeTxs(resume)
+	}
 	return pool
 }
 
+// loadJournal restores the pending and mined transactions tracked before the
+// last shutdown and returns the pending ones.
+func (pool *TxPool) loadJournal() []*types.Transaction {
+	data, err := pool.chainDb.Get(txJournalKey)
+	if err != nil {
+		return nil
+	}
+	var journal txJournal
+	if err := rlp.DecodeBytes(data, &journal); err != nil {
+		log.Error("Failed to decode transaction journal", "err", err)
+		return nil
+	}
+	// Continue tracking from the last processed head if it's still known, so
+	// any blocks since are checked for mined transactions or rolled back
+	if header := pool.chain.GetHeaderByHash(journal.Head); header != nil {
+		pool.head = journal.Head
+		if number := header.Number.Uint64(); number < pool.clearIdx {
+			pool.clearIdx = number
+		}
+	}
+	for _, tx := range journal.Pending {
+		pool.pending[tx.Hash()] = tx
+		pool.trackNonce(tx)
+	}
+	for _, mined := range journal.Mined {
+		pool.mined[mined.Block] = mined.Txs
+		for _, tx := range mined.Txs {
+			pool.trackNonce(tx)
+		}
+	}
+	log.Info("Restored light transactions", "pending", len(journal.Pending), "mined", len(journal.Mined))
+	return journal.Pending
+}
+
+// journal persists the currently tracked transactions. The lock must be held.
+func (pool *TxPool) journal() {
+	if len(pool.pending) == 0 && len(pool.mined) == 0 {
+		pool.chainDb.Delete(txJournalKey)
+		return
+	}
+	journal := txJournal{Head: pool.head}
+	for _, tx := range pool.pending {
+		journal.Pending = append(journal.Pending, tx)
+	}
+	for hash, txs := range pool.mined {
+		journal.Mined = append(journal.Mined, minedTxs{Block: hash, Txs: txs})
+	}
+	data, err := rlp.EncodeToBytes(&journal)
+	if err != nil {
+		log.Error("Failed to encode transaction journal", "err", err)
+		return
+	}
+	if err := pool.chainDb.Put(txJournalKey, data); err != nil {
+		log.Error("Failed to write transaction journal", "err", err)
+	}
+}
+
+// trackNonce raises the locally known pending nonce of the sender of a
+// transaction if necessary.
+func (pool *TxPool) trackNonce(tx *types.Transaction) {
+	addr, err := types.Sender(pool.signer, tx)
+	if err != nil {
+		return
+	}
+	if nonce := tx.Nonce() + 1; nonce > pool.nonce[addr] {
+		pool.nonce[addr] = nonce
+	}
+}
+
+// resumeTxs queries the status of restored pending transactions. The ones that
+// were mined in the meantime are checked against their blocks, the ones unknown
+// to the servers are sent again.
+func (pool *TxPool) resumeTxs(txs []*types.Transaction) {
+	for start := 0; start < len(txs); start += txStatusBatch {
+		end := start + txStatusBatch
+		if end > len(txs) {
+			end = len(txs)
+		}
+		req := &TxStatusRequest{Hashes: make([]common.Hash, end-start)}
+		for i, tx := range txs[start:end] {
+			req.Hashes[i] = tx.Hash()
+		}
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), txStatusTimeout)
+			err := pool.odr.Retrieve(ctx, req)
+			cancel()
+			if err == nil {
+				break
+			}
+			log.Debug("Failed to retrieve transaction status", "err", err)
+			select {
+			case <-pool.quit:
+				return
+			case <-time.After(txStatusRetry):
+			}
+		}
+		pool.resumeStatus(txs[start:end], req.Status)
+	}
+}
+
+// resumeStatus processes the retrieved status of restored transactions.
+func (pool *TxPool) resumeStatus(txs []*types.Transaction, status []TxStatus) {
+	ctx, cancel := context.WithTimeout(context.Background(), blockCheckTimeout)
+	defer cancel()
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var (
+		resend types.Transactions
+		txc    = make(txStateChanges)
+		head   = pool.chain.CurrentHeader()
+	)
+	for i, tx := range txs {
+		if _, ok := pool.pending[tx.Hash()]; !ok {
+			continue // mined or removed since restored
+		}
+		switch stat := status[i]; stat.Status {
+		case core.TxStatusUnknown:
+			resend = append(resend, tx)
+
+		case core.TxStatusIncluded:
+			// Only accept the inclusion if the block is in our canonical chain
+			if stat.Lookup == nil || stat.Lookup.BlockIndex > head.Number.Uint64() {
+				continue
+			}
+			header, err := GetHeaderByNumber(ctx, pool.odr, stat.Lookup.BlockIndex)
+			if err != nil || header.Hash() != stat.Lookup.BlockHash {
+				continue
+			}
+			if err := pool.checkMinedTxs(ctx, header.Hash(), stat.Lookup.BlockIndex, txc); err != nil {
+				log.Debug("Failed to check resumed transaction", "hash", tx.Hash(), "err", err)
+			}
+		}
+	}
+	if len(resend) > 0 {
+		pool.relay.Send(resend)
+	}
+	if mined, rollback := txc.getLists(); len(mined) > 0 {
+		pool.relay.NewHead(pool.head, mined, rollback)
+	}
+	pool.journal()
+}
+
 // currentState returns the light state of the current head header
 func (pool *TxPool) currentState(ctx context.Context) *state.StateDB {
 	return NewState(ctx, pool.chain.CurrentHeader(), pool.odr)
@@ -307,6 +483,7 @@ func (pool *TxPool) setNewHead(head *types.Header) {
 	txc, _ := pool.reorgOnNewHead(ctx, head)
 	m, r := txc.getLists()
 	pool.relay.NewHead(pool.head, m, r)
+	pool.journal()
 	pool.horizon = pool.config.IsHorizon(head.Number)
 	pool.signer = types.MakeSigner(pool.config, head.Number)
 }
@@ -435,6 +612,7 @@ func (self *TxPool) Add(ctx context.Context, tx *types.Transaction) error {
 	self.relay.Send(types.Transactions{tx})
 
 	self.chainDb.Put(tx.Hash().Bytes(), data)
+	self.journal()
 	return nil
 }
 
@@ -452,6 +630,7 @@ func (self *TxPool) AddBatch(ctx context.Context, txs []*types.Transaction) {
 	}
 	if len(sendTx) > 0 {
 		self.relay.Send(sendTx)
+		self.journal()
 	}
 }
 
@@ -510,6 +689,7 @@ func (self *TxPool) RemoveTransactions(txs types.Transactions) {
 		hashes = append(hashes, hash)
 	}
 	self.relay.Discard(hashes)
+	self.journal()
 }
 
 // RemoveTx removes the transaction with the given hash from the pool.
@@ -520,4 +700,5 @@ func (pool *TxPool) RemoveTx(hash common.Hash) {
 	delete(pool.pending, hash)
 	pool.chainDb.Delete(hash[:])
 	pool.relay.Discard([]common.Hash{hash})
+	pool.journal()
 }
@@ -141,3 +141,82 @@ func TestTxPool(t *testing.T) {
 		}
 	}
 }
+
+// Tests that pending transactions are restored after a restart, with the ones
+// mined in the meantime detected and the ones unknown to the servers resent.
+func TestTxPoolJournal(t *testing.T) {
+	var (
+		sdb, _  = ruedb.NewMemDatabase()
+		ldb, _  = ruedb.NewMemDatabase()
+		gspec   = core.Genesis{Alloc: core.GenesisAlloc{testBankAddress: {Balance: testBankFunds}}}
+		genesis = gspec.MustCommit(sdb)
+	)
+	gspec.MustCommit(ldb)
+
+	signer := types.HorizonSigner{}
+	mined, _ := types.SignTx(types.NewTransaction(0, acc1Addr, big.NewInt(10000), bigTxGas, nil, nil), signer, testBankKey)
+	lost, _ := types.SignTx(types.NewTransaction(1, acc1Addr, big.NewInt(10000), bigTxGas, nil, nil), signer, testBankKey)
+
+	blockchain, _ := core.NewBlockChain(sdb, params.TestChainConfig, ruehash.NewFullFaker(), vm.Config{})
+	gchain, _ := core.GenerateChain(params.TestChainConfig, genesis, ruehash.NewFaker(), sdb, 2, func(i int, block *core.BlockGen) {
+		if i == 0 {
+			block.AddTx(mined)
+		}
+	})
+	if _, err := blockchain.InsertChain(gchain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	odr := &testOdr{sdb: sdb, ldb: ldb}
+	relay := &testTxRelay{
+		send:    make(chan int, 1),
+		discard: make(chan int, 1),
+		mined:   make(chan int, 1),
+	}
+	lightchain, _ := NewLightChain(odr, params.TestChainConfig, ruehash.NewFullFaker())
+
+	// Send both transactions, then shut down and sync while offline
+	pool := NewTxPool(params.TestChainConfig, lightchain, relay)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, tx := range []*types.Transaction{mined, lost} {
+		if err := pool.Add(ctx, tx); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+		<-relay.send
+	}
+	pool.Stop()
+
+	headers := make([]*types.Header, len(gchain))
+	for i, block := range gchain {
+		headers[i] = block.Header()
+	}
+	if _, err := lightchain.InsertHeaderChain(headers, 1); err != nil {
+		t.Fatalf("failed to insert headers: %v", err)
+	}
+	// Restart the pool and check the transactions are resumed
+	pool = NewTxPool(params.TestChainConfig, lightchain, relay)
+	defer pool.Stop()
+
+	if nonce := pool.nonce[testBankAddress]; nonce != 2 {
+		t.Errorf("restored nonce mismatch: have %d, want 2", nonce)
+	}
+	select {
+	case n := <-relay.send:
+		if n != 1 {
+			t.Errorf("resent transaction count mismatch: have %d, want 1", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("unknown transaction not resent")
+	}
+	select {
+	case n := <-relay.mined:
+		if n != 1 {
+			t.Errorf("mined transaction count mismatch: have %d, want 1", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("mined transaction not detected")
+	}
+	if pool.Stats() != 1 || pool.GetTransaction(lost.Hash()) == nil {
+		t.Errorf("pending transactions mismatch: have %d", pool.Stats())
+	}
+}
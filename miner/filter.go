@@ -0,0 +1,126 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/core/types"
+)
+
+var (
+	errBlacklisted       = errors.New("blacklisted address")
+	errUnderpricedLocal  = errors.New("gas price below local minimum")
+	errUnderpricedRemote = errors.New("gas price below remote minimum")
+	errSenderGasExceeded = errors.New("sender gas allowance exceeded")
+)
+
+// InclusionConfig are the rules deciding which of the pending transactions the
+// worker is allowed to include in a block.
+type InclusionConfig struct {
+	Blacklist      []common.Address `toml:",omitempty"` // Senders and recipients whose transactions are never included
+	LocalMinPrice  *big.Int         `toml:",omitempty"` // Minimum gas price for transactions of local accounts
+	RemoteMinPrice *big.Int         `toml:",omitempty"` // Minimum gas price for transactions of remote accounts
+	MaxSenderGas   uint64           `toml:",omitempty"` // Maximum gas allowance of a single sender per block (0 = unlimited)
+}
+
+// copy creates a deep copy of the inclusion rules.
+func (config InclusionConfig) copy() InclusionConfig {
+	cpy := InclusionConfig{
+		Blacklist:    append([]common.Address(nil), config.Blacklist...),
+		MaxSenderGas: config.MaxSenderGas,
+	}
+	if config.LocalMinPrice != nil {
+		cpy.LocalMinPrice = new(big.Int).Set(config.LocalMinPrice)
+	}
+	if config.RemoteMinPrice != nil {
+		cpy.RemoteMinPrice = new(big.Int).Set(config.RemoteMinPrice)
+	}
+	return cpy
+}
+
+// inclusionFilter applies a set of inclusion rules to the transactions of a
+// single block, tracking the gas allowance used up by each sender.
+type inclusionFilter struct {
+	config    InclusionConfig
+	blacklist map[common.Address]struct{}
+	locals    map[common.Address]struct{}
+	used      map[common.Address]uint64
+}
+
+// newInclusionFilter creates a block filter enforcing the given rules, treating
+// the specified accounts as local.
+func newInclusionFilter(config InclusionConfig, locals []common.Address) *inclusionFilter {
+	filter := &inclusionFilter{
+		config:    config.copy(),
+		blacklist: make(map[common.Address]struct{}),
+		locals:    make(map[common.Address]struct{}),
+		used:      make(map[common.Address]uint64),
+	}
+	for _, addr := range config.Blacklist {
+		filter.blacklist[addr] = struct{}{}
+	}
+	for _, addr := range locals {
+		filter.locals[addr] = struct{}{}
+	}
+	return filter
+}
+
+// local reports whether an account is considered local.
+func (f *inclusionFilter) local(addr common.Address) bool {
+	_, ok := f.locals[addr]
+	return ok
+}
+
+// check validates whether a transaction may be included in the block. As a
+// rejection renders all subsequent transactions of the sender unexecutable,
+// the caller should skip the entire account on error.
+func (f *inclusionFilter) check(from common.Address, tx *types.Transaction) error {
+	if _, ok := f.blacklist[from]; ok {
+		return errBlacklisted
+	}
+	if to := tx.To(); to != nil {
+		if _, ok := f.blacklist[*to]; ok {
+			return errBlacklisted
+		}
+	}
+	if f.local(from) {
+		if f.config.LocalMinPrice != nil && tx.GasPrice().Cmp(f.config.LocalMinPrice) < 0 {
+			return errUnderpricedLocal
+		}
+	} else {
+		if f.config.RemoteMinPrice != nil && tx.GasPrice().Cmp(f.config.RemoteMinPrice) < 0 {
+			return errUnderpricedRemote
+		}
+	}
+	if f.config.MaxSenderGas > 0 {
+		if gas := tx.Gas(); !gas.IsUint64() || f.used[from]+gas.Uint64() > f.config.MaxSenderGas {
+			return errSenderGasExceeded
+		}
+	}
+	return nil
+}
+
+// include charges the gas allowance of a transaction included in the block to
+// its sender.
+func (f *inclusionFilter) include(from common.Address, tx *types.Transaction) {
+	if f.config.MaxSenderGas > 0 {
+		f.used[from] += tx.Gas().Uint64()
+	}
+}
@@ -181,3 +181,21 @@ func (self *Miner) SetEtherbase(addr common.Address) {
 	self.coinbase = addr
 	self.worker.setEtherbase(addr)
 }
+
+// SetOrdering changes the policy ordering pending transactions for inclusion
+// into newly mined blocks.
+func (self *Miner) SetOrdering(ordering Ordering) {
+	self.worker.setOrdering(ordering)
+}
+
+// Inclusion returns the rules currently filtering pending transactions from
+// inclusion into newly mined blocks.
+func (self *Miner) Inclusion() InclusionConfig {
+	return self.worker.inclusionConfig()
+}
+
+// SetInclusion replaces the rules filtering pending transactions from inclusion
+// into newly mined blocks.
+func (self *Miner) SetInclusion(config InclusionConfig) {
+	self.worker.setInclusion(config)
+}
@@ -0,0 +1,227 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"container/heap"
+	"fmt"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/core/types"
+)
+
+// Names of the built in transaction ordering policies.
+const (
+	OrderPriceAndNonce = "price"      // Highest gas price first, nonce honouring
+	OrderLocalFirst    = "localfirst" // Local accounts first, then by gas price
+	OrderArrival       = "fifo"       // Earliest seen transaction first, nonce honouring
+)
+
+// TransactionSet is a nonce-honouring stream of transactions the worker pulls
+// from when filling a block.
+type TransactionSet interface {
+	// Peek returns the next transaction to be included, or nil if the set is
+	// exhausted.
+	Peek() *types.Transaction
+
+	// Shift replaces the current head with the next transaction of the same
+	// account.
+	Shift()
+
+	// Pop removes the current head without shifting in the next transaction of
+	// the same account, dropping the account from the remainder of the block.
+	Pop()
+}
+
+// Ordering is a policy deciding in which order the executable transactions of
+// the pool are offered to the worker for inclusion.
+type Ordering interface {
+	// Name returns the identifier the policy can be selected with.
+	Name() string
+
+	// Order assembles a transaction set out of the per account nonce-sorted
+	// pending transactions. The input map is reowned by the ordering. The local
+	// callback reports whether an account is considered local.
+	Order(signer types.Signer, txs map[common.Address]types.Transactions, local func(common.Address) bool) TransactionSet
+}
+
+// orderings contains all the built in ordering policies, keyed by name.
+var orderings = map[string]Ordering{
+	OrderPriceAndNonce: priceOrdering{},
+	OrderLocalFirst:    localFirstOrdering{},
+	OrderArrival:       arrivalOrdering{},
+}
+
+// OrderingByName retrieves a built in transaction ordering policy.
+func OrderingByName(name string) (Ordering, error) {
+	if name == "" {
+		name = OrderPriceAndNonce
+	}
+	if ordering, ok := orderings[name]; ok {
+		return ordering, nil
+	}
+	return nil, fmt.Errorf("unknown transaction ordering %q", name)
+}
+
+// priceOrdering is the default policy, including transactions in a profit
+// maximizing order.
+type priceOrdering struct{}
+
+func (priceOrdering) Name() string { return OrderPriceAndNonce }
+
+func (priceOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions, local func(common.Address) bool) TransactionSet {
+	return types.NewTransactionsByPriceAndNonce(signer, txs)
+}
+
+// localFirstOrdering includes all transactions originating from local accounts
+// before any remote ones, ordering each group by price.
+type localFirstOrdering struct{}
+
+func (localFirstOrdering) Name() string { return OrderLocalFirst }
+
+func (localFirstOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions, local func(common.Address) bool) TransactionSet {
+	locals := make(map[common.Address]types.Transactions)
+	for addr, list := range txs {
+		if local(addr) {
+			locals[addr] = list
+			delete(txs, addr)
+		}
+	}
+	return &chainedSet{sets: []TransactionSet{
+		types.NewTransactionsByPriceAndNonce(signer, locals),
+		types.NewTransactionsByPriceAndNonce(signer, txs),
+	}}
+}
+
+// chainedSet drains a list of transaction sets one after the other.
+type chainedSet struct {
+	sets []TransactionSet
+}
+
+// Peek returns the head of the first non exhausted set.
+func (c *chainedSet) Peek() *types.Transaction {
+	for len(c.sets) > 0 {
+		if tx := c.sets[0].Peek(); tx != nil {
+			return tx
+		}
+		c.sets = c.sets[1:]
+	}
+	return nil
+}
+
+// Shift replaces the current head within the set that provided it.
+func (c *chainedSet) Shift() {
+	if c.Peek() != nil {
+		c.sets[0].Shift()
+	}
+}
+
+// Pop removes the current head within the set that provided it.
+func (c *chainedSet) Pop() {
+	if c.Peek() != nil {
+		c.sets[0].Pop()
+	}
+}
+
+// arrivalOrdering includes transactions in the order they were first seen by
+// the node, falling back to price for simultaneous arrivals.
+type arrivalOrdering struct{}
+
+func (arrivalOrdering) Name() string { return OrderArrival }
+
+func (arrivalOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions, local func(common.Address) bool) TransactionSet {
+	return newTransactionsByArrival(signer, txs)
+}
+
+// txByArrival implements the heap interface, ordering transactions by the time
+// they were first seen.
+type txByArrival types.Transactions
+
+func (s txByArrival) Len() int { return len(s) }
+func (s txByArrival) Less(i, j int) bool {
+	if ti, tj := s[i].Time(), s[j].Time(); !ti.Equal(tj) {
+		return ti.Before(tj)
+	}
+	return s[i].GasPrice().Cmp(s[j].GasPrice()) > 0
+}
+func (s txByArrival) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s *txByArrival) Push(x interface{}) {
+	*s = append(*s, x.(*types.Transaction))
+}
+
+func (s *txByArrival) Pop() interface{} {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	*s = old[0 : n-1]
+	return x
+}
+
+// transactionsByArrival is a transaction set returning transactions in their
+// order of arrival, while still honouring the nonce order within accounts.
+type transactionsByArrival struct {
+	txs    map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads  txByArrival                           // Next transaction for each unique account (arrival heap)
+	signer types.Signer                          // Signer for the set of transactions
+}
+
+// newTransactionsByArrival creates an arrival ordered transaction set. The input
+// map is reowned.
+func newTransactionsByArrival(signer types.Signer, txs map[common.Address]types.Transactions) *transactionsByArrival {
+	heads := make(txByArrival, 0, len(txs))
+	for acc, accTxs := range txs {
+		if len(accTxs) == 0 {
+			delete(txs, acc)
+			continue
+		}
+		heads = append(heads, accTxs[0])
+		txs[acc] = accTxs[1:]
+	}
+	heap.Init(&heads)
+
+	return &transactionsByArrival{
+		txs:    txs,
+		heads:  heads,
+		signer: signer,
+	}
+}
+
+// Peek returns the earliest seen executable transaction.
+func (t *transactionsByArrival) Peek() *types.Transaction {
+	if len(t.heads) == 0 {
+		return nil
+	}
+	return t.heads[0]
+}
+
+// Shift replaces the current head with the next one from the same account.
+func (t *transactionsByArrival) Shift() {
+	acc, _ := types.Sender(t.signer, t.heads[0])
+	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
+		t.heads[0], t.txs[acc] = txs[0], txs[1:]
+		heap.Fix(&t.heads, 0)
+	} else {
+		heap.Pop(&t.heads)
+	}
+}
+
+// Pop removes the current head, *not* replacing it with the next one from the
+// same account.
+func (t *transactionsByArrival) Pop() {
+	heap.Pop(&t.heads)
+}
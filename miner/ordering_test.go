@@ -0,0 +1,134 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/core/types"
+	"github.com/Rue-Foundation/go-rue/crypto"
+)
+
+var testSigner = types.HorizonSigner{}
+
+// orderingTx creates a signed test transaction, sleeping a bit afterwards to
+// ensure arrival times are strictly increasing.
+func orderingTx(nonce uint64, price int64, to common.Address, key *ecdsa.PrivateKey) *types.Transaction {
+	tx, _ := types.SignTx(types.NewTransaction(nonce, to, big.NewInt(0), big.NewInt(21000), big.NewInt(price), nil), testSigner, key)
+	time.Sleep(time.Millisecond)
+	return tx
+}
+
+// drain pulls all transactions out of a set, always shifting.
+func drain(set TransactionSet) []*types.Transaction {
+	var txs []*types.Transaction
+	for tx := set.Peek(); tx != nil; tx = set.Peek() {
+		txs = append(txs, tx)
+		set.Shift()
+	}
+	return txs
+}
+
+// Tests that the built in orderings return transactions in their expected order
+// while honouring account nonces.
+func TestOrderings(t *testing.T) {
+	localKey, _ := crypto.GenerateKey()
+	remoteKey, _ := crypto.GenerateKey()
+	local := crypto.PubkeyToAddress(localKey.PublicKey)
+	remote := crypto.PubkeyToAddress(remoteKey.PublicKey)
+
+	// Create the transactions in arrival order: local ones are cheap but early
+	l0 := orderingTx(0, 2, common.Address{}, localKey)
+	r0 := orderingTx(0, 3, common.Address{}, remoteKey)
+	l1 := orderingTx(1, 2, common.Address{}, localKey)
+	r1 := orderingTx(1, 1, common.Address{}, remoteKey)
+
+	tests := []struct {
+		name string
+		want []*types.Transaction
+	}{
+		{OrderPriceAndNonce, []*types.Transaction{r0, l0, l1, r1}},
+		{OrderLocalFirst, []*types.Transaction{l0, l1, r0, r1}},
+		{OrderArrival, []*types.Transaction{l0, r0, l1, r1}},
+	}
+	for _, tt := range tests {
+		ordering, err := OrderingByName(tt.name)
+		if err != nil {
+			t.Fatalf("%s: failed to retrieve ordering: %v", tt.name, err)
+		}
+		pending := map[common.Address]types.Transactions{
+			local:  {l0, l1},
+			remote: {r0, r1},
+		}
+		have := drain(ordering.Order(testSigner, pending, func(addr common.Address) bool { return addr == local }))
+		if len(have) != len(tt.want) {
+			t.Fatalf("%s: transaction count mismatch: have %d, want %d", tt.name, len(have), len(tt.want))
+		}
+		for i := range have {
+			if have[i] != tt.want[i] {
+				t.Errorf("%s: transaction %d mismatch: have %x, want %x", tt.name, i, have[i].Hash(), tt.want[i].Hash())
+			}
+		}
+	}
+	if _, err := OrderingByName("lottery"); err == nil {
+		t.Errorf("unknown ordering accepted")
+	}
+}
+
+// Tests that the inclusion filter rejects transactions violating each of the
+// configured rules.
+func TestInclusionFilter(t *testing.T) {
+	localKey, _ := crypto.GenerateKey()
+	remoteKey, _ := crypto.GenerateKey()
+	local := crypto.PubkeyToAddress(localKey.PublicKey)
+	remote := crypto.PubkeyToAddress(remoteKey.PublicKey)
+	banned := common.HexToAddress("0xdeadbeef")
+
+	filter := newInclusionFilter(InclusionConfig{
+		Blacklist:      []common.Address{banned},
+		LocalMinPrice:  big.NewInt(2),
+		RemoteMinPrice: big.NewInt(5),
+		MaxSenderGas:   50000,
+	}, []common.Address{local})
+
+	tests := []struct {
+		from common.Address
+		tx   *types.Transaction
+		err  error
+	}{
+		{local, orderingTx(0, 2, common.Address{}, localKey), nil},
+		{local, orderingTx(1, 1, common.Address{}, localKey), errUnderpricedLocal},
+		{remote, orderingTx(0, 4, common.Address{}, remoteKey), errUnderpricedRemote},
+		{remote, orderingTx(0, 5, banned, remoteKey), errBlacklisted},
+		{banned, orderingTx(0, 10, common.Address{}, remoteKey), errBlacklisted},
+		{remote, orderingTx(0, 5, common.Address{}, remoteKey), nil},
+		{local, orderingTx(1, 2, common.Address{}, localKey), nil},
+		{local, orderingTx(2, 2, common.Address{}, localKey), errSenderGasExceeded},
+	}
+	for i, tt := range tests {
+		if err := filter.check(tt.from, tt.tx); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+		if tt.err == nil {
+			filter.include(tt.from, tt.tx)
+		}
+	}
+}
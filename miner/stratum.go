@@ -0,0 +1,694 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/consensus"
+	"github.com/Rue-Foundation/go-rue/consensus/ruehash"
+	"github.com/Rue-Foundation/go-rue/core/types"
+	"github.com/Rue-Foundation/go-rue/crypto"
+	"github.com/Rue-Foundation/go-rue/log"
+)
+
+const (
+	// StratumProtocol is the protocol identifier negotiated with stratum clients.
+	StratumProtocol = "EthereumStratum/1.0.0"
+
+	stratumExtraNonceSize = 2                // Number of nonce bytes assigned by the server to a session
+	stratumHashrateWindow = 30 * time.Second // Period over which worker hashrates are estimated
+	stratumWorkTimeout    = 7 * (12 * time.Second)
+)
+
+// Stratum error codes, as per the EthereumStratum/1.0.0 specification.
+const (
+	stratumErrOther         = 20
+	stratumErrJobNotFound   = 21
+	stratumErrDuplicate     = 22
+	stratumErrLowDifficulty = 23
+	stratumErrUnauthorized  = 24
+	stratumErrNotSubscribed = 25
+)
+
+var (
+	errStratumEngine = errors.New("consensus engine cannot verify stratum shares")
+
+	// stratumDiffOne is the hash count represented by a share of difficulty 1.
+	stratumDiffOne = new(big.Float).SetInt(new(big.Int).Lsh(common.Big1, 32))
+
+	// maxUint256 is a big integer representing 2^256-1
+	maxUint256 = new(big.Int).Sub(new(big.Int).Exp(common.Big2, big.NewInt(256), nil), common.Big1)
+)
+
+// hashimotoEngine is a consensus engine able to recompute proof-of-work values
+// from a nonce alone, as required to verify stratum share submissions.
+type hashimotoEngine interface {
+	Hashimoto(number uint64, hash common.Hash, nonce types.BlockNonce) (common.Hash, common.Hash, error)
+}
+
+// StratumConfig are the configuration parameters of the stratum server.
+type StratumConfig struct {
+	Addr       string  // TCP endpoint to listen on for stratum clients
+	Difficulty float64 // Default share difficulty assigned to new workers
+}
+
+// StratumServer is a mining agent serving work to remote miners over the
+// EthereumStratum/1.0.0 TCP protocol. New work is pushed to all subscribed
+// clients on arrival, and shares are verified against per worker difficulties.
+type StratumServer struct {
+	config StratumConfig
+
+	chain  consensus.ChainReader
+	engine consensus.Engine
+	hasher hashimotoEngine
+
+	quitCh   chan struct{}
+	workCh   chan *Work
+	returnCh chan<- *Result
+
+	mu       sync.Mutex
+	listener net.Listener
+	sessions map[*stratumSession]struct{}
+	jobs     map[string]*stratumJob
+	current  *stratumJob
+	jobSeq   uint64
+	nonceSeq uint16              // Last extra nonce assigned to a session
+	nonces   map[uint16]struct{} // Extra nonces of the live sessions
+	diffs    map[string]float64  // Custom difficulties of individual workers
+
+	hashrateMu sync.RWMutex
+	hashrate   map[common.Hash]hashrate
+	shares     map[string]*stratumShares
+
+	wg      sync.WaitGroup
+	running int32 // running indicates whether the agent is active. Call atomically
+}
+
+// stratumJob is a work package announced to stratum clients.
+type stratumJob struct {
+	seq    uint64 // Sequence number of the job, newer jobs having higher ones
+	id     string
+	work   *Work
+	header *types.Header
+	seen   map[types.BlockNonce]struct{} // Nonces already submitted for the job
+}
+
+// stratumShares tracks the accepted shares of a worker within the current
+// hashrate estimation window.
+type stratumShares struct {
+	hashes *big.Float
+	since  time.Time
+}
+
+// NewStratumServer creates a stratum mining agent. The server does not accept
+// connections until Listen is called.
+func NewStratumServer(chain consensus.ChainReader, engine consensus.Engine, config StratumConfig) (*StratumServer, error) {
+	hasher, ok := engine.(hashimotoEngine)
+	if !ok {
+		return nil, errStratumEngine
+	}
+	if config.Difficulty <= 0 {
+		config.Difficulty = 1
+	}
+	return &StratumServer{
+		config:   config,
+		chain:    chain,
+		engine:   engine,
+		hasher:   hasher,
+		sessions: make(map[*stratumSession]struct{}),
+		jobs:     make(map[string]*stratumJob),
+		nonces:   make(map[uint16]struct{}),
+		diffs:    make(map[string]float64),
+		hashrate: make(map[common.Hash]hashrate),
+		shares:   make(map[string]*stratumShares),
+	}, nil
+}
+
+// Listen opens the configured TCP endpoint and starts accepting stratum clients.
+func (s *StratumServer) Listen() error {
+	listener, err := net.Listen("tcp", s.config.Addr)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	log.Info("Stratum endpoint opened", "addr", listener.Addr(), "difficulty", s.config.Difficulty)
+
+	s.wg.Add(1)
+	go s.accept(listener)
+	return nil
+}
+
+// Addr returns the address the server is listening on, or nil if not listening.
+func (s *StratumServer) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Close stops accepting new clients and disconnects all existing ones.
+func (s *StratumServer) Close() {
+	s.mu.Lock()
+	if s.listener != nil {
+		s.listener.Close()
+		s.listener = nil
+	}
+	for session := range s.sessions {
+		session.conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *StratumServer) Work() chan<- *Work {
+	return s.workCh
+}
+
+func (s *StratumServer) SetReturnCh(returnCh chan<- *Result) {
+	s.returnCh = returnCh
+}
+
+func (s *StratumServer) Start() {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return
+	}
+	s.quitCh = make(chan struct{})
+	s.workCh = make(chan *Work, 1)
+	go s.loop(s.workCh, s.quitCh)
+}
+
+func (s *StratumServer) Stop() {
+	if !atomic.CompareAndSwapInt32(&s.running, 1, 0) {
+		return
+	}
+	close(s.quitCh)
+	close(s.workCh)
+}
+
+// SubmitHashrate records the hashrate of a remote worker.
+func (s *StratumServer) SubmitHashrate(id common.Hash, rate uint64) {
+	s.hashrateMu.Lock()
+	defer s.hashrateMu.Unlock()
+
+	s.hashrate[id] = hashrate{time.Now(), rate}
+}
+
+// GetHashRate returns the accumulated hashrate of all stratum workers combined.
+func (s *StratumServer) GetHashRate() (tot int64) {
+	s.hashrateMu.RLock()
+	defer s.hashrateMu.RUnlock()
+
+	for _, hashrate := range s.hashrate {
+		tot += int64(hashrate.rate)
+	}
+	return
+}
+
+// SetWorkerDifficulty overrides the share difficulty of a worker, notifying all
+// its live sessions of the change.
+func (s *StratumServer) SetWorkerDifficulty(worker string, difficulty float64) {
+	s.mu.Lock()
+	s.diffs[worker] = difficulty
+	sessions := s.sessionList()
+	s.mu.Unlock()
+
+	// Notify the clients without holding the lock, they may be slow to read
+	for _, session := range sessions {
+		if session.workerName() == worker {
+			session.setDifficulty(difficulty)
+		}
+	}
+}
+
+// loop monitors mining events on the work and quit channels, announcing new
+// work to the connected clients and periodically reporting worker hashrates.
+func (s *StratumServer) loop(workCh chan *Work, quitCh chan struct{}) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-quitCh:
+			return
+		case work, ok := <-workCh:
+			// Stop closes the work channel too, don't announce its nil value
+			if !ok || work == nil {
+				return
+			}
+			s.announce(work)
+		case <-ticker.C:
+			s.mu.Lock()
+			for id, job := range s.jobs {
+				if time.Since(job.work.createdAt) > stratumWorkTimeout {
+					delete(s.jobs, id)
+				}
+			}
+			s.mu.Unlock()
+
+			s.reportHashrates(false)
+		}
+	}
+}
+
+// announce registers a new work package as the current job and pushes it to
+// all the authorized clients.
+func (s *StratumServer) announce(work *Work) {
+	s.mu.Lock()
+	s.jobSeq++
+	job := &stratumJob{
+		seq:    s.jobSeq,
+		id:     fmt.Sprintf("%x", s.jobSeq),
+		work:   work,
+		header: work.Block.Header(),
+		seen:   make(map[types.BlockNonce]struct{}),
+	}
+	s.jobs[job.id] = job
+	s.current = job
+	sessions := s.sessionList()
+	s.mu.Unlock()
+
+	// Notify the clients without holding the lock, they may be slow to read
+	for _, session := range sessions {
+		if session.authorized() {
+			session.notify(job, true)
+		}
+	}
+}
+
+// sessionList returns the live sessions. The lock must be held by the caller.
+func (s *StratumServer) sessionList() []*stratumSession {
+	sessions := make([]*stratumSession, 0, len(s.sessions))
+	for session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// reportHashrates estimates the hashrate of every worker from its accepted
+// shares, submitting it once the estimation window elapsed (or always if
+// forced).
+func (s *StratumServer) reportHashrates(force bool) {
+	rates := make(map[common.Hash]uint64)
+
+	s.hashrateMu.Lock()
+	for worker, shares := range s.shares {
+		elapsed := time.Since(shares.since)
+		if !force && elapsed < stratumHashrateWindow {
+			continue
+		}
+		rate, _ := new(big.Float).Quo(shares.hashes, big.NewFloat(elapsed.Seconds())).Uint64()
+		rates[crypto.Keccak256Hash([]byte(worker))] = rate
+
+		shares.hashes, shares.since = new(big.Float), time.Now()
+	}
+	for id, hashrate := range s.hashrate {
+		if time.Since(hashrate.ping) > 2*stratumHashrateWindow {
+			delete(s.hashrate, id)
+		}
+	}
+	s.hashrateMu.Unlock()
+
+	for id, rate := range rates {
+		s.SubmitHashrate(id, rate)
+	}
+}
+
+// accept waits for new stratum connections, serving each on its own goroutine.
+func (s *StratumServer) accept(listener net.Listener) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		nonce, ok := s.allocNonce()
+		if !ok {
+			s.mu.Unlock()
+			log.Warn("Rejected stratum client, no extra nonce available", "addr", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		session := newStratumSession(conn, nonce)
+		s.sessions[session] = struct{}{}
+		s.mu.Unlock()
+
+		log.Debug("Stratum client connected", "addr", conn.RemoteAddr())
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+
+			s.serve(session)
+
+			s.mu.Lock()
+			delete(s.sessions, session)
+			delete(s.nonces, session.nonce)
+			s.mu.Unlock()
+			conn.Close()
+
+			log.Debug("Stratum client disconnected", "addr", conn.RemoteAddr())
+		}()
+	}
+}
+
+// allocNonce assigns an extra nonce not used by any live session, so that no
+// two workers search the same nonce space. The lock must be held by the caller.
+func (s *StratumServer) allocNonce() (uint16, bool) {
+	for i := 0; i < 1<<16; i++ {
+		s.nonceSeq++
+		if _, ok := s.nonces[s.nonceSeq]; !ok {
+			s.nonces[s.nonceSeq] = struct{}{}
+			return s.nonceSeq, true
+		}
+	}
+	return 0, false
+}
+
+// serve processes the requests of a stratum client until it disconnects.
+func (s *StratumServer) serve(session *stratumSession) {
+	dec := json.NewDecoder(session.conn)
+	for {
+		var req stratumRequest
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		switch req.Method {
+		case "mining.subscribe":
+			s.handleSubscribe(session, &req)
+		case "mining.authorize":
+			s.handleAuthorize(session, &req)
+		case "mining.submit":
+			s.handleSubmit(session, &req)
+		case "mining.extranonce.subscribe":
+			session.reply(req.Id, true, nil)
+		default:
+			session.reply(req.Id, nil, stratumError(stratumErrOther, "unsupported method "+req.Method))
+		}
+	}
+}
+
+// handleSubscribe negotiates the protocol version and assigns the session its
+// extra nonce.
+func (s *StratumServer) handleSubscribe(session *stratumSession, req *stratumRequest) {
+	var params []string
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		session.reply(req.Id, nil, stratumError(stratumErrOther, "invalid parameters"))
+		return
+	}
+	if len(params) > 1 && params[1] != StratumProtocol {
+		session.reply(req.Id, nil, stratumError(stratumErrOther, "unsupported protocol "+params[1]))
+		return
+	}
+	session.mu.Lock()
+	session.subscribed = true
+	session.mu.Unlock()
+
+	session.reply(req.Id, []interface{}{
+		[]string{"mining.notify", session.extraNonce, StratumProtocol},
+		session.extraNonce,
+	}, nil)
+}
+
+// handleAuthorize registers the worker of a subscribed session and sends it its
+// difficulty and the current job.
+func (s *StratumServer) handleAuthorize(session *stratumSession, req *stratumRequest) {
+	var params []string
+	if err := json.Unmarshal(req.Params, &params); err != nil || len(params) == 0 || params[0] == "" {
+		session.reply(req.Id, nil, stratumError(stratumErrOther, "invalid parameters"))
+		return
+	}
+	session.mu.Lock()
+	subscribed := session.subscribed
+	session.mu.Unlock()
+	if !subscribed {
+		session.reply(req.Id, nil, stratumError(stratumErrNotSubscribed, "not subscribed"))
+		return
+	}
+	s.mu.Lock()
+	difficulty, ok := s.diffs[params[0]]
+	if !ok {
+		difficulty = s.config.Difficulty
+	}
+	session.mu.Lock()
+	session.worker = params[0]
+	session.mu.Unlock()
+	current := s.current
+	s.mu.Unlock()
+
+	session.reply(req.Id, true, nil)
+	session.setDifficulty(difficulty)
+	if current != nil {
+		session.notify(current, true)
+	}
+}
+
+// handleSubmit verifies a share submitted by an authorized worker, forwarding
+// it to the miner if it also satisfies the block difficulty.
+func (s *StratumServer) handleSubmit(session *stratumSession, req *stratumRequest) {
+	var params []string
+	if err := json.Unmarshal(req.Params, &params); err != nil || len(params) != 3 {
+		session.reply(req.Id, nil, stratumError(stratumErrOther, "invalid parameters"))
+		return
+	}
+	worker := session.workerName()
+	if worker == "" {
+		session.reply(req.Id, nil, stratumError(stratumErrUnauthorized, "unauthorized worker"))
+		return
+	}
+	// Reassemble the full nonce from the session and miner parts
+	blob, err := hex.DecodeString(session.extraNonce + strings.TrimPrefix(params[2], "0x"))
+	if err != nil || len(blob) != len(types.BlockNonce{}) {
+		session.reply(req.Id, nil, stratumError(stratumErrOther, "invalid nonce"))
+		return
+	}
+	var nonce types.BlockNonce
+	copy(nonce[:], blob)
+
+	s.mu.Lock()
+	job := s.jobs[params[1]]
+	if job == nil {
+		s.mu.Unlock()
+		session.reply(req.Id, nil, stratumError(stratumErrJobNotFound, "job not found"))
+		return
+	}
+	if _, ok := job.seen[nonce]; ok {
+		s.mu.Unlock()
+		session.reply(req.Id, nil, stratumError(stratumErrDuplicate, "duplicate share"))
+		return
+	}
+	job.seen[nonce] = struct{}{}
+	s.mu.Unlock()
+
+	// Recompute the proof-of-work and check it against the share target
+	digest, result, err := s.hasher.Hashimoto(job.header.Number.Uint64(), job.header.HashNoNonce(), nonce)
+	if err != nil {
+		session.reply(req.Id, nil, stratumError(stratumErrOther, err.Error()))
+		return
+	}
+	difficulty := session.difficulty()
+	if new(big.Int).SetBytes(result[:]).Cmp(stratumTarget(difficulty)) > 0 {
+		session.reply(req.Id, nil, stratumError(stratumErrLowDifficulty, "low difficulty share"))
+		return
+	}
+	s.hashrateMu.Lock()
+	shares := s.shares[worker]
+	if shares == nil {
+		shares = &stratumShares{hashes: new(big.Float), since: time.Now()}
+		s.shares[worker] = shares
+	}
+	shares.hashes.Add(shares.hashes, new(big.Float).Mul(big.NewFloat(difficulty), stratumDiffOne))
+	s.hashrateMu.Unlock()
+
+	session.reply(req.Id, true, nil)
+
+	// If the share is also a block solution, seal it and return it to the miner
+	target := new(big.Int).Div(maxUint256, job.header.Difficulty)
+	if new(big.Int).SetBytes(result[:]).Cmp(target) > 0 {
+		return
+	}
+	header := types.CopyHeader(job.header)
+	header.Nonce, header.MixDigest = nonce, digest
+	if err := s.engine.VerifySeal(s.chain, header); err != nil {
+		log.Warn("Invalid stratum proof-of-work submitted", "worker", worker, "number", header.Number, "err", err)
+		return
+	}
+	log.Info("Stratum worker found block", "worker", worker, "number", header.Number, "hash", header.Hash())
+
+	s.mu.Lock()
+	delete(s.jobs, job.id)
+	s.mu.Unlock()
+
+	if s.returnCh != nil {
+		s.returnCh <- &Result{job.work, job.work.Block.WithSeal(header)}
+	}
+}
+
+// stratumTarget converts a stratum share difficulty into a proof-of-work
+// boundary, a difficulty of 1 corresponding to 2^32 expected hashes.
+func stratumTarget(difficulty float64) *big.Int {
+	hashes, _ := new(big.Float).Mul(big.NewFloat(difficulty), stratumDiffOne).Int(nil)
+	if hashes.Sign() <= 0 {
+		return maxUint256
+	}
+	return new(big.Int).Div(maxUint256, hashes)
+}
+
+// stratumRequest is a JSON request sent by a stratum client.
+type stratumRequest struct {
+	Id     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// stratumResponse is a JSON reply to a stratum client request.
+type stratumResponse struct {
+	Id     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result"`
+	Error  interface{}     `json:"error"`
+}
+
+// stratumNotification is a JSON message pushed by the server to a client.
+type stratumNotification struct {
+	Id     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// stratumError creates a stratum error tuple.
+func stratumError(code int, message string) []interface{} {
+	return []interface{}{code, message, nil}
+}
+
+// stratumSession is the server side state of a stratum client connection.
+type stratumSession struct {
+	conn       net.Conn
+	nonce      uint16 // Extra nonce assigned to the session
+	extraNonce string
+
+	mu         sync.Mutex // Protects the session state
+	subscribed bool
+	worker     string
+	diff       float64
+
+	wmu sync.Mutex // Serializes writes, without blocking state access on slow clients
+	enc *json.Encoder
+	job uint64 // Sequence number of the last job pushed to the client
+}
+
+// newStratumSession creates a stratum session with its assigned extra nonce.
+func newStratumSession(conn net.Conn, nonce uint16) *stratumSession {
+	return &stratumSession{
+		conn:       conn,
+		nonce:      nonce,
+		extraNonce: fmt.Sprintf("%0*x", 2*stratumExtraNonceSize, nonce),
+		enc:        json.NewEncoder(conn),
+	}
+}
+
+// authorized returns whether a worker has been authorized on the session.
+func (s *stratumSession) authorized() bool {
+	return s.workerName() != ""
+}
+
+// workerName returns the name of the worker authorized on the session.
+func (s *stratumSession) workerName() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.worker
+}
+
+// difficulty returns the current share difficulty of the session.
+func (s *stratumSession) difficulty() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.diff
+}
+
+// send writes a message to the client.
+func (s *stratumSession) send(msg interface{}) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	s.write(msg)
+}
+
+// write encodes a message to the client. The lock must be held by the caller.
+func (s *stratumSession) write(msg interface{}) {
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if err := s.enc.Encode(msg); err != nil {
+		log.Debug("Failed to send stratum message", "addr", s.conn.RemoteAddr(), "err", err)
+		s.conn.Close()
+	}
+}
+
+// reply answers a client request.
+func (s *stratumSession) reply(id json.RawMessage, result interface{}, err interface{}) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	s.send(&stratumResponse{Id: id, Result: result, Error: err})
+}
+
+// setDifficulty changes the share difficulty of the session and notifies the
+// client about it.
+func (s *stratumSession) setDifficulty(difficulty float64) {
+	s.mu.Lock()
+	s.diff = difficulty
+	s.mu.Unlock()
+
+	s.send(&stratumNotification{Method: "mining.set_difficulty", Params: []interface{}{difficulty}})
+}
+
+// notify pushes a job to the client, unless a newer one was already pushed by a
+// concurrent announcement.
+func (s *stratumSession) notify(job *stratumJob, clean bool) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if job.seq <= s.job {
+		return
+	}
+	s.job = job.seq
+
+	seed := ruehash.SeedHash(job.header.Number.Uint64())
+	s.write(&stratumNotification{Method: "mining.notify", Params: []interface{}{
+		job.id,
+		hex.EncodeToString(seed),
+		hex.EncodeToString(job.header.HashNoNonce().Bytes()),
+		clean,
+	}})
+}
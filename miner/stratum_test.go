@@ -0,0 +1,304 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/consensus/ruehash"
+	"github.com/Rue-Foundation/go-rue/core/types"
+)
+
+// stratumMessage is any message received by the test stratum client.
+type stratumMessage struct {
+	Id     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  []interface{}   `json:"error"`
+}
+
+// stratumClient is a minimal in-process stratum miner.
+type stratumClient struct {
+	t    *testing.T
+	conn net.Conn
+	enc  *json.Encoder
+	dec  *json.Decoder
+	id   int
+}
+
+func newStratumClient(t *testing.T, addr net.Addr) *stratumClient {
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatalf("failed to dial stratum server: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return &stratumClient{t: t, conn: conn, enc: json.NewEncoder(conn), dec: json.NewDecoder(conn)}
+}
+
+// call sends a request and waits for its reply, collecting any notifications
+// received in between.
+func (c *stratumClient) call(method string, params ...interface{}) (*stratumMessage, []*stratumMessage) {
+	c.id++
+	if err := c.enc.Encode(map[string]interface{}{"id": c.id, "method": method, "params": params}); err != nil {
+		c.t.Fatalf("failed to send %s: %v", method, err)
+	}
+	var notifs []*stratumMessage
+	for {
+		msg := c.read()
+		if msg.Id != nil && *msg.Id == c.id {
+			return msg, notifs
+		}
+		notifs = append(notifs, msg)
+	}
+}
+
+// read retrieves the next message from the server.
+func (c *stratumClient) read() *stratumMessage {
+	msg := new(stratumMessage)
+	if err := c.dec.Decode(msg); err != nil {
+		c.t.Fatalf("failed to read stratum message: %v", err)
+	}
+	return msg
+}
+
+// Tests that remote workers can subscribe to a stratum server, receive work and
+// submit shares, getting solutions forwarded to the miner.
+func TestStratumMining(t *testing.T) {
+	engine := ruehash.NewTester()
+	server, err := NewStratumServer(nil, engine, StratumConfig{Addr: "127.0.0.1:0", Difficulty: 1e-9})
+	if err != nil {
+		t.Fatalf("failed to create stratum server: %v", err)
+	}
+	if err := server.Listen(); err != nil {
+		t.Fatalf("failed to start stratum server: %v", err)
+	}
+	defer server.Close()
+
+	results := make(chan *Result, 1)
+	server.SetReturnCh(results)
+	server.Start()
+	defer server.Stop()
+
+	// Subscribe and authorize a worker, checking the negotiated parameters
+	client := newStratumClient(t, server.Addr())
+	defer client.conn.Close()
+
+	if reply, _ := client.call("mining.authorize", "rig", "x"); reply.Error == nil {
+		t.Fatalf("unsubscribed worker authorized")
+	}
+	reply, _ := client.call("mining.subscribe", "tester/1.0.0", StratumProtocol)
+	var subscription []json.RawMessage
+	if err := json.Unmarshal(reply.Result, &subscription); err != nil || len(subscription) != 2 {
+		t.Fatalf("invalid subscription reply: %s (%v)", reply.Result, err)
+	}
+	var extraNonce string
+	json.Unmarshal(subscription[1], &extraNonce)
+	prefix, err := hex.DecodeString(extraNonce)
+	if err != nil || len(prefix) != stratumExtraNonceSize {
+		t.Fatalf("invalid extra nonce %q", extraNonce)
+	}
+	if reply, _ := client.call("mining.authorize", "rig", "x"); string(reply.Result) != "true" {
+		t.Fatalf("worker authorization failed: %s %v", reply.Result, reply.Error)
+	}
+	if msg := client.read(); msg.Method != "mining.set_difficulty" {
+		t.Fatalf("difficulty not announced, got %q", msg.Method)
+	}
+	// Push new work to the agent and wait for the notification
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(16), Time: big.NewInt(0)}
+	server.Work() <- &Work{Block: types.NewBlockWithHeader(header), createdAt: time.Now()}
+
+	msg := client.read()
+	if msg.Method != "mining.notify" {
+		t.Fatalf("work not announced, got %q", msg.Method)
+	}
+	var job []interface{}
+	if err := json.Unmarshal(msg.Params, &job); err != nil || len(job) != 4 {
+		t.Fatalf("invalid job notification: %s (%v)", msg.Params, err)
+	}
+	if job[2] != hex.EncodeToString(header.HashNoNonce().Bytes()) {
+		t.Fatalf("header hash mismatch: have %v, want %x", job[2], header.HashNoNonce())
+	}
+	jobId := job[0].(string)
+
+	if reply, _ := client.call("mining.submit", "rig", "ff", "000000000000"); reply.Error == nil || reply.Error[0].(float64) != stratumErrJobNotFound {
+		t.Fatalf("share for unknown job accepted: %v", reply.Error)
+	}
+	// Mine shares until a block solution is found
+	var (
+		shareTarget = stratumTarget(1e-9)
+		blockTarget = new(big.Int).Div(maxUint256, header.Difficulty)
+		lowDiff     bool
+	)
+	for i := uint64(0); ; i++ {
+		var nonce types.BlockNonce
+		copy(nonce[:], prefix)
+		suffix := make([]byte, 8)
+		binary.BigEndian.PutUint64(suffix, i)
+		copy(nonce[stratumExtraNonceSize:], suffix[stratumExtraNonceSize:])
+
+		_, result, err := engine.Hashimoto(1, header.HashNoNonce(), nonce)
+		if err != nil {
+			t.Fatalf("failed to compute proof-of-work: %v", err)
+		}
+		value := new(big.Int).SetBytes(result[:])
+
+		minerNonce := hex.EncodeToString(nonce[stratumExtraNonceSize:])
+		reply, _ := client.call("mining.submit", "rig", jobId, minerNonce)
+		if value.Cmp(shareTarget) > 0 {
+			if reply.Error == nil || reply.Error[0].(float64) != stratumErrLowDifficulty {
+				t.Fatalf("low difficulty share accepted: %v", reply.Error)
+			}
+			lowDiff = true
+			continue
+		}
+		if string(reply.Result) != "true" {
+			t.Fatalf("valid share rejected: %v", reply.Error)
+		}
+		if value.Cmp(blockTarget) <= 0 {
+			break
+		}
+		if reply, _ := client.call("mining.submit", "rig", jobId, minerNonce); reply.Error == nil || reply.Error[0].(float64) != stratumErrDuplicate {
+			t.Fatalf("duplicate share accepted: %v", reply.Error)
+		}
+	}
+	if !lowDiff {
+		t.Errorf("no low difficulty share tested")
+	}
+	select {
+	case result := <-results:
+		if err := engine.VerifySeal(nil, result.Block.Header()); err != nil {
+			t.Fatalf("invalid block solution forwarded: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("block solution not forwarded")
+	}
+	// Ensure the worker's hashrate is reported
+	server.reportHashrates(true)
+	if rate := server.GetHashRate(); rate == 0 {
+		t.Errorf("worker hashrate not reported")
+	}
+	// Override the worker's difficulty and check that it's pushed
+	server.SetWorkerDifficulty("rig", 2)
+	if msg := client.read(); msg.Method != "mining.set_difficulty" || string(msg.Params) != "[2]" {
+		t.Errorf("difficulty override not announced: %s %s", msg.Method, msg.Params)
+	}
+}
+
+// Tests that the stratum server can be stopped and restarted, and keeps serving
+// work afterwards.
+func TestStratumRestart(t *testing.T) {
+	server, err := NewStratumServer(nil, ruehash.NewTester(), StratumConfig{Addr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("failed to create stratum server: %v", err)
+	}
+	if err := server.Listen(); err != nil {
+		t.Fatalf("failed to start stratum server: %v", err)
+	}
+	defer server.Close()
+
+	// Cycle the agent, each stop closing the channels its loop waits on
+	for i := 0; i < 100; i++ {
+		server.Start()
+		server.Stop()
+	}
+	server.Start()
+	defer server.Stop()
+
+	client := newStratumClient(t, server.Addr())
+	defer client.conn.Close()
+
+	client.call("mining.subscribe", "tester/1.0.0", StratumProtocol)
+	if reply, _ := client.call("mining.authorize", "rig", "x"); string(reply.Result) != "true" {
+		t.Fatalf("worker authorization failed: %s %v", reply.Result, reply.Error)
+	}
+	if msg := client.read(); msg.Method != "mining.set_difficulty" {
+		t.Fatalf("difficulty not announced, got %q", msg.Method)
+	}
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(16), Time: big.NewInt(0)}
+	server.Work() <- &Work{Block: types.NewBlockWithHeader(header), createdAt: time.Now()}
+
+	if msg := client.read(); msg.Method != "mining.notify" {
+		t.Fatalf("work not announced after restart, got %q", msg.Method)
+	}
+}
+
+// Tests that extra nonces are never shared between live sessions, even after
+// the nonce sequence wraps around.
+func TestStratumNonceAllocation(t *testing.T) {
+	server, err := NewStratumServer(nil, ruehash.NewTester(), StratumConfig{})
+	if err != nil {
+		t.Fatalf("failed to create stratum server: %v", err)
+	}
+	// Exhaust all but one extra nonce and ensure the last one gets assigned
+	for i := 0; i < 1<<16-1; i++ {
+		if _, ok := server.allocNonce(); !ok {
+			t.Fatalf("allocation %d failed", i)
+		}
+	}
+	delete(server.nonces, 42)
+	if nonce, ok := server.allocNonce(); !ok || nonce == 42 {
+		t.Fatalf("free nonce not found: have %d (%v)", nonce, ok)
+	}
+	if nonce, ok := server.allocNonce(); !ok || nonce != 42 {
+		t.Fatalf("released nonce not reused: have %d (%v), want 42", nonce, ok)
+	}
+	if nonce, ok := server.allocNonce(); ok {
+		t.Fatalf("nonce %d assigned with all nonces in use", nonce)
+	}
+}
+
+// Tests that a client not reading its messages doesn't block the server.
+func TestStratumStalledClient(t *testing.T) {
+	server, err := NewStratumServer(nil, ruehash.NewTester(), StratumConfig{})
+	if err != nil {
+		t.Fatalf("failed to create stratum server: %v", err)
+	}
+	// Register an authorized session whose client never reads
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+
+	session := newStratumSession(conn, 1)
+	session.worker = "rig"
+	server.sessions[session] = struct{}{}
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(16), Time: big.NewInt(0)}
+	go server.announce(&Work{Block: types.NewBlockWithHeader(header), createdAt: time.Now()})
+
+	// Wait for the job notification to start, leaving it stuck mid-write
+	peer.Read(make([]byte, 1))
+
+	done := make(chan struct{})
+	go func() {
+		server.SetWorkerDifficulty("other", 2)
+		server.reportHashrates(true)
+		server.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("server blocked by stalled client")
+	}
+}
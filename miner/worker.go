@@ -77,6 +77,7 @@ type Work struct {
 	header   *types.Header
 	txs      []*types.Transaction
 	receipts []*types.Receipt
+	filter   *inclusionFilter // inclusion rules and per sender gas usage of the block
 
 	createdAt time.Time
 }
@@ -111,8 +112,10 @@ type worker struct {
 	proc    core.Validator
 	chainDb ruedb.Database
 
-	coinbase common.Address
-	extra    []byte
+	coinbase  common.Address
+	extra     []byte
+	ordering  Ordering        // policy ordering pending transactions for inclusion
+	inclusion InclusionConfig // rules filtering pending transactions from inclusion
 
 	currentMu sync.Mutex
 	current   *Work
@@ -142,6 +145,7 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase com
 		proc:           eth.BlockChain().Validator(),
 		possibleUncles: make(map[common.Hash]*types.Block),
 		coinbase:       coinbase,
+		ordering:       priceOrdering{},
 		agents:         make(map[Agent]struct{}),
 		unconfirmed:    newUnconfirmedBlocks(eth.BlockChain(), miningLogAtDepth),
 	}
@@ -170,6 +174,24 @@ func (self *worker) setExtra(extra []byte) {
 	self.extra = extra
 }
 
+func (self *worker) setOrdering(ordering Ordering) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.ordering = ordering
+}
+
+func (self *worker) setInclusion(config InclusionConfig) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.inclusion = config.copy()
+}
+
+func (self *worker) inclusionConfig() InclusionConfig {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.inclusion.copy()
+}
+
 func (self *worker) pending() (*types.Block, *state.StateDB) {
 	self.currentMu.Lock()
 	defer self.currentMu.Unlock()
@@ -454,7 +476,8 @@ func (self *worker) commitNewWork() {
 		log.Error("Failed to fetch pending transactions", "err", err)
 		return
 	}
-	txs := types.NewTransactionsByPriceAndNonce(self.current.signer, pending)
+	work.filter = newInclusionFilter(self.inclusion, self.eth.TxPool().Locals())
+	txs := self.ordering.Order(self.current.signer, pending, work.filter.local)
 	work.commitTransactions(self.mux, txs, self.chain, self.coinbase)
 
 	// compute uncles for the new block.
@@ -507,7 +530,7 @@ func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
 	return nil
 }
 
-func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPriceAndNonce, bc *core.BlockChain, coinbase common.Address) {
+func (env *Work) commitTransactions(mux *event.TypeMux, txs TransactionSet, bc *core.BlockChain, coinbase common.Address) {
 	gp := new(core.GasPool).AddGas(env.header.GasLimit)
 
 	var coalescedLogs []*types.Log
@@ -531,6 +554,15 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 			txs.Pop()
 			continue
 		}
+		// Skip the sender if the transaction violates the inclusion rules
+		if env.filter != nil {
+			if err := env.filter.check(from, tx); err != nil {
+				log.Trace("Skipping account excluded by inclusion rules", "sender", from, "hash", tx.Hash(), "err", err)
+
+				txs.Pop()
+				continue
+			}
+		}
 		// Start executing the transaction
 		env.state.Prepare(tx.Hash(), common.Hash{}, env.tcount)
 
@@ -555,6 +587,9 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 			// Everything ok, collect the logs and shift in the next transaction from the same account
 			coalescedLogs = append(coalescedLogs, logs...)
 			env.tcount++
+			if env.filter != nil {
+				env.filter.include(from, tx)
+			}
 			txs.Shift()
 
 		default:
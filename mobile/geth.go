@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/Rue-Foundation/go-rue/core"
 	"github.com/Rue-Foundation/go-rue/rue"
@@ -70,6 +71,15 @@ type NodeConfig struct {
 	// It has the form "nodename:secret@host:port"
 	RuereumNetStats string
 
+	// RuereumUltraLightServers is a comma separated list of enode URLs of trusted
+	// LES servers. If set, the node accepts chain heads announced by enough of them
+	// without verifying and downloading the headers itself.
+	RuereumUltraLightServers string
+
+	// RuereumUltraLightThreshold is the number of trusted servers that need to
+	// announce a head before it's accepted. Zero means all of them.
+	RuereumUltraLightThreshold int
+
 	// WhisperEnabled specifies whether the node should run the Whisper protocol.
 	WhisperEnabled bool
 }
@@ -150,6 +160,10 @@ func NewNode(datadir string, config *NodeConfig) (stack *Node, _ error) {
 		ethConf.SyncMode = downloader.LightSync
 		ethConf.NetworkId = uint64(config.RuereumNetworkID)
 		ethConf.DatabaseCache = config.RuereumDatabaseCache
+		if config.RuereumUltraLightServers != "" {
+			ethConf.UltraLightServers = strings.Split(config.RuereumUltraLightServers, ",")
+			ethConf.UltraLightThreshold = config.RuereumUltraLightThreshold
+		}
 		if err := rawStack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
 			return les.New(ctx, &ethConf)
 		}); err != nil {
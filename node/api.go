@@ -19,6 +19,7 @@ package node
 import (
 	"context"
 	"fmt"
+	"net"
 	"strings"
 	"time"
 
@@ -43,8 +44,9 @@ func NewPrivateAdminAPI(node *Node) *PrivateAdminAPI {
 }
 
 // AddPeer requests connecting to a remote node, and also maintaining the new
-// connection at all times, even reconnecting if it is lost.
-func (api *PrivateAdminAPI) AddPeer(url string) (bool, error) {
+// connection at all times, even reconnecting if it is lost. If persist is set,
+// the node is also added to the static node list of the data directory.
+func (api *PrivateAdminAPI) AddPeer(url string, persist *bool) (bool, error) {
 	// Make sure the server is running, fail otherwise
 	server := api.node.Server()
 	if server == nil {
@@ -56,11 +58,18 @@ func (api *PrivateAdminAPI) AddPeer(url string) (bool, error) {
 		return false, fmt.Errorf("invalid enode: %v", err)
 	}
 	server.AddPeer(node)
+	if persist != nil && *persist {
+		if err := api.persist(api.node.config.AddStaticNode, node); err != nil {
+			return false, err
+		}
+	}
 	return true, nil
 }
 
-// RemovePeer disconnects from a a remote node if the connection exists
-func (api *PrivateAdminAPI) RemovePeer(url string) (bool, error) {
+// RemovePeer disconnects from a a remote node if the connection exists. If
+// persist is set, the node is also removed from the static node list of the
+// data directory.
+func (api *PrivateAdminAPI) RemovePeer(url string, persist *bool) (bool, error) {
 	// Make sure the server is running, fail otherwise
 	server := api.node.Server()
 	if server == nil {
@@ -72,9 +81,149 @@ func (api *PrivateAdminAPI) RemovePeer(url string) (bool, error) {
 		return false, fmt.Errorf("invalid enode: %v", err)
 	}
 	server.RemovePeer(node)
+	if persist != nil && *persist {
+		if err := api.persist(api.node.config.RemoveStaticNode, node); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// AddTrustedPeer allows a remote node to always connect, even if slots are full.
+// If persist is set, the node is also added to the trusted node list of the
+// data directory.
+func (api *PrivateAdminAPI) AddTrustedPeer(url string, persist *bool) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	node, err := discover.ParseNode(url)
+	if err != nil {
+		return false, fmt.Errorf("invalid enode: %v", err)
+	}
+	server.AddTrustedPeer(node)
+	if persist != nil && *persist {
+		if err := api.persist(api.node.config.AddTrustedNode, node); err != nil {
+			return false, err
+		}
+	}
 	return true, nil
 }
 
+// RemoveTrustedPeer removes a remote node from the trusted peer set, but it
+// does not disconnect it automatically. If persist is set, the node is also
+// removed from the trusted node list of the data directory.
+func (api *PrivateAdminAPI) RemoveTrustedPeer(url string, persist *bool) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	node, err := discover.ParseNode(url)
+	if err != nil {
+		return false, fmt.Errorf("invalid enode: %v", err)
+	}
+	server.RemoveTrustedPeer(node)
+	if persist != nil && *persist {
+		if err := api.persist(api.node.config.RemoveTrustedNode, node); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// persist applies a change to one of the node lists of the data directory,
+// serializing concurrent updates of the files.
+func (api *PrivateAdminAPI) persist(update func(*discover.Node) error, node *discover.Node) error {
+	api.node.lock.Lock()
+	defer api.node.lock.Unlock()
+
+	if err := update(node); err != nil {
+		return fmt.Errorf("can't persist node: %v", err)
+	}
+	return nil
+}
+
+// BanPeer bans a remote node, given as an enode URL or node ID, or an IP address
+// for the given number of seconds. The ban is permanent if no duration is given.
+// Connected peers matching the ban are disconnected.
+func (api *PrivateAdminAPI) BanPeer(target string, seconds *uint64, reason *string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	id, ip, err := parseBanTarget(target)
+	if err != nil {
+		return false, err
+	}
+	var (
+		duration time.Duration
+		why      = "banned by admin"
+	)
+	if seconds != nil {
+		duration = time.Duration(*seconds) * time.Second
+	}
+	if reason != nil {
+		why = *reason
+	}
+	if id != nil {
+		server.BanNode(*id, duration, why)
+	} else {
+		server.BanIP(ip, duration, why)
+	}
+	return true, nil
+}
+
+// Unban lifts the ban of a remote node, given as an enode URL or node ID, or an
+// IP address. It returns false if the target wasn't banned.
+func (api *PrivateAdminAPI) Unban(target string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	id, ip, err := parseBanTarget(target)
+	if err != nil {
+		return false, err
+	}
+	if id != nil {
+		return server.UnbanNode(*id), nil
+	}
+	return server.UnbanIP(ip), nil
+}
+
+// ListBans retrieves the active bans of remote nodes and IP addresses.
+func (api *PrivateAdminAPI) ListBans() ([]*p2p.BanInfo, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.Bans(), nil
+}
+
+// parseBanTarget interprets a ban target as an enode URL, a hex node ID or an
+// IP address. Exactly one of the returned node ID and IP is non-nil on success.
+func parseBanTarget(target string) (*discover.NodeID, net.IP, error) {
+	if strings.HasPrefix(target, "enode://") {
+		node, err := discover.ParseNode(target)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid enode: %v", err)
+		}
+		return &node.ID, nil, nil
+	}
+	if ip := net.ParseIP(target); ip != nil {
+		return nil, ip, nil
+	}
+	id, err := discover.HexID(target)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid ban target %q: not an enode URL, node ID or IP address", target)
+	}
+	return &id, nil, nil
+}
+
 // PeerEvents creates an RPC subscription which receives peer events from the
 // node's p2p.Server
 func (api *PrivateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
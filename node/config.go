@@ -18,6 +18,7 @@ package node
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -363,6 +364,61 @@ func (c *Config) parsePersistentNodes(path string) []*discover.Node {
 	return nodes
 }
 
+// AddStaticNode adds the node to the persistent static node list.
+func (c *Config) AddStaticNode(node *discover.Node) error {
+	return c.updatePersistentNodes(datadirStaticNodes, node, true)
+}
+
+// RemoveStaticNode removes the node from the persistent static node list.
+func (c *Config) RemoveStaticNode(node *discover.Node) error {
+	return c.updatePersistentNodes(datadirStaticNodes, node, false)
+}
+
+// AddTrustedNode adds the node to the persistent trusted node list.
+func (c *Config) AddTrustedNode(node *discover.Node) error {
+	return c.updatePersistentNodes(datadirTrustedNodes, node, true)
+}
+
+// RemoveTrustedNode removes the node from the persistent trusted node list.
+func (c *Config) RemoveTrustedNode(node *discover.Node) error {
+	return c.updatePersistentNodes(datadirTrustedNodes, node, false)
+}
+
+// updatePersistentNodes adds a node to or removes it from the node list stored
+// in the given file of the data directory. Nodes are matched by their ID.
+func (c *Config) updatePersistentNodes(file string, node *discover.Node, add bool) error {
+	if c.DataDir == "" {
+		return ErrNoDatadir
+	}
+	path := c.resolvePath(file)
+
+	var nodelist []string
+	if _, err := os.Stat(path); err == nil {
+		if err := common.LoadJSON(path, &nodelist); err != nil {
+			return err
+		}
+	}
+	// Drop any existing entry of the node, then append it if requested.
+	urls := make([]string, 0, len(nodelist)+1)
+	for _, url := range nodelist {
+		if n, err := discover.ParseNode(url); err == nil && n.ID == node.ID {
+			continue
+		}
+		urls = append(urls, url)
+	}
+	if add {
+		urls = append(urls, node.String())
+	}
+	blob, err := json.MarshalIndent(urls, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, blob, 0644)
+}
+
 // AccountConfig determines the settings for scrypt and keydirectory
 func (c *Config) AccountConfig() (int, int, string, error) {
 	scryptN := keystore.StandardScryptN
@@ -26,6 +26,7 @@ import (
 
 	"github.com/Rue-Foundation/go-rue/crypto"
 	"github.com/Rue-Foundation/go-rue/p2p"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
 )
 
 // Tests that datadirs can be successfully created, be them manually configured
@@ -148,3 +149,55 @@ func TestNodeKeyPersistency(t *testing.T) {
 		t.Fatalf("ephemeral node key persisted to disk")
 	}
 }
+
+// Tests that static and trusted nodes added or removed at runtime are persisted
+// to the data directory and loaded back on the next start.
+func TestPersistentNodesUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		node1  = discover.MustParseNode("enode://a979fb575495b8d6db44f750317d0f4622bf4c2aa3365d6af7c284339968eef29b69ad0dce72a4d8db5ebb4968de0e3bec910127f134779fbcb0cb6d3331163c@52.16.188.185:30303")
+		node2  = discover.MustParseNode("enode://3f1d12044546b76342d59d4a05532c14b85aa669704bfe1f864fe079415aa2c02d743e03218e57a33fb94523adb54032871a6c51b2cc5514cb7c7e35b3ed0a99@13.93.211.84:30303")
+		config = &Config{Name: "unit-test", DataDir: dir}
+	)
+	if err := config.AddStaticNode(node1); err != nil {
+		t.Fatalf("failed to add static node: %v", err)
+	}
+	if err := config.AddStaticNode(node2); err != nil {
+		t.Fatalf("failed to add static node: %v", err)
+	}
+	if err := config.AddStaticNode(node1); err != nil {
+		t.Fatalf("failed to re-add static node: %v", err)
+	}
+	if err := config.AddTrustedNode(node2); err != nil {
+		t.Fatalf("failed to add trusted node: %v", err)
+	}
+	if nodes := config.StaticNodes(); len(nodes) != 2 || nodes[0].ID != node2.ID || nodes[1].ID != node1.ID {
+		t.Fatalf("static nodes mismatch: have %v", nodes)
+	}
+	if nodes := config.TrustedNodes(); len(nodes) != 1 || nodes[0].ID != node2.ID {
+		t.Fatalf("trusted nodes mismatch: have %v", nodes)
+	}
+	if err := config.RemoveStaticNode(node2); err != nil {
+		t.Fatalf("failed to remove static node: %v", err)
+	}
+	if err := config.RemoveTrustedNode(node2); err != nil {
+		t.Fatalf("failed to remove trusted node: %v", err)
+	}
+	if nodes := config.StaticNodes(); len(nodes) != 1 || nodes[0].ID != node1.ID {
+		t.Fatalf("static nodes mismatch after removal: have %v", nodes)
+	}
+	if nodes := config.TrustedNodes(); len(nodes) != 0 {
+		t.Fatalf("trusted nodes mismatch after removal: have %v", nodes)
+	}
+
+	// Ephemeral nodes can't persist anything
+	config = &Config{Name: "unit-test"}
+	if err := config.AddStaticNode(node1); err != ErrNoDatadir {
+		t.Fatalf("wrong error for ephemeral node: have %v, want %v", err, ErrNoDatadir)
+	}
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/Rue-Foundation/go-rue/log"
 	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/p2p/enr"
 	"github.com/Rue-Foundation/go-rue/p2p/netutil"
 )
 
@@ -71,12 +72,16 @@ func (t TCPDialer) Dial(dest *discover.Node) (net.Conn, error) {
 type dialstate struct {
 	maxDynDials int
 	ntab        discoverTable
+	sources     []nodeSource
+	preferred   []nodeSource // sources dialed before the table and other sources
 	netrestrict *netutil.Netlist
+	reputation  *reputation // banned nodes aren't dialed, may be nil
 
 	lookupRunning bool
 	dialing       map[discover.NodeID]connFlag
 	lookupBuf     []*discover.Node // current discovery lookup results
 	randomNodes   []*discover.Node // filled from Table
+	sourceNodes   []*discover.Node // filled from external node sources
 	static        map[discover.NodeID]*dialTask
 	hist          *dialHistory
 
@@ -90,6 +95,15 @@ type discoverTable interface {
 	Resolve(target discover.NodeID) *discover.Node
 	Lookup(target discover.NodeID) []*discover.Node
 	ReadRandomNodes([]*discover.Node) int
+	Record() *enr.Record
+	SetRecord(*enr.Record) error
+}
+
+// nodeSource provides dial candidates from outside the discovery table, such
+// as node lists published in DNS.
+type nodeSource interface {
+	ReadRandomNodes([]*discover.Node) int
+	Close()
 }
 
 // the dial history remembers recent dials.
@@ -136,6 +150,7 @@ func newDialState(static []*discover.Node, bootnodes []*discover.Node, ntab disc
 		dialing:     make(map[discover.NodeID]connFlag),
 		bootnodes:   make([]*discover.Node, len(bootnodes)),
 		randomNodes: make([]*discover.Node, maxdyn/2),
+		sourceNodes: make([]*discover.Node, maxdyn),
 		hist:        new(dialHistory),
 	}
 	copy(s.bootnodes, bootnodes)
@@ -151,9 +166,20 @@ func (s *dialstate) addStatic(n *discover.Node) {
 	s.static[n.ID] = &dialTask{flags: staticDialedConn, dest: n}
 }
 
+func (s *dialstate) addSource(src nodeSource) {
+	s.sources = append(s.sources, src)
+}
+
+func (s *dialstate) addPreferredSource(src nodeSource) {
+	s.preferred = append(s.preferred, src)
+}
+
 func (s *dialstate) removeStatic(n *discover.Node) {
 	// This removes a task so future attempts to connect will not be made.
 	delete(s.static, n.ID)
+	// This removes a previous dial timestamp so that application
+	// can force a server to reconnect with chosen peer immediately.
+	s.hist.remove(n.ID)
 }
 
 func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now time.Time) []task {
@@ -212,10 +238,23 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 			needDynDials--
 		}
 	}
+	// Fill the dynamic dials with nodes from the preferred sources first. These
+	// are known to run our protocols, unlike random nodes of the table.
+	for _, src := range s.preferred {
+		if needDynDials <= 0 {
+			break
+		}
+		n := src.ReadRandomNodes(s.sourceNodes)
+		for i := 0; i < n && needDynDials > 0; i++ {
+			if addDial(dynDialedConn, s.sourceNodes[i]) {
+				needDynDials--
+			}
+		}
+	}
 	// Use random nodes from the table for half of the necessary
 	// dynamic dials.
 	randomCandidates := needDynDials / 2
-	if randomCandidates > 0 {
+	if randomCandidates > 0 && s.ntab != nil {
 		n := s.ntab.ReadRandomNodes(s.randomNodes)
 		for i := 0; i < randomCandidates && i < n; i++ {
 			if addDial(dynDialedConn, s.randomNodes[i]) {
@@ -223,6 +262,23 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 			}
 		}
 	}
+	// Use random nodes from the external sources for half of the remaining
+	// dynamic dials, or for all of them if there is no discovery table.
+	for _, src := range s.sources {
+		sourceCandidates := needDynDials
+		if s.ntab != nil {
+			sourceCandidates /= 2
+		}
+		if sourceCandidates == 0 {
+			break
+		}
+		n := src.ReadRandomNodes(s.sourceNodes)
+		for i := 0; i < sourceCandidates && i < n; i++ {
+			if addDial(dynDialedConn, s.sourceNodes[i]) {
+				needDynDials--
+			}
+		}
+	}
 	// Create dynamic dials from random lookup results, removing tried
 	// items from the result buffer.
 	i := 0
@@ -233,7 +289,7 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 	}
 	s.lookupBuf = s.lookupBuf[:copy(s.lookupBuf, s.lookupBuf[i:])]
 	// Launch a discovery lookup if more candidates are needed.
-	if len(s.lookupBuf) < needDynDials && !s.lookupRunning {
+	if len(s.lookupBuf) < needDynDials && !s.lookupRunning && s.ntab != nil {
 		s.lookupRunning = true
 		newtasks = append(newtasks, &discoverTask{})
 	}
@@ -255,6 +311,7 @@ var (
 	errAlreadyConnected = errors.New("already connected")
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
+	errBanned           = errors.New("node is banned")
 )
 
 func (s *dialstate) checkDial(n *discover.Node, peers map[discover.NodeID]*Peer) error {
@@ -268,6 +325,8 @@ func (s *dialstate) checkDial(n *discover.Node, peers map[discover.NodeID]*Peer)
 		return errSelf
 	case s.netrestrict != nil && !s.netrestrict.Contains(n.IP):
 		return errNotWhitelisted
+	case s.reputation.isBanned(n.ID, n.IP):
+		return errBanned
 	case s.hist.contains(n.ID):
 		return errRecentlyDialed
 	}
@@ -399,6 +458,15 @@ func (h dialHistory) contains(id discover.NodeID) bool {
 	}
 	return false
 }
+func (h *dialHistory) remove(id discover.NodeID) bool {
+	for i, v := range *h {
+		if v.id == id {
+			heap.Remove(h, i)
+			return true
+		}
+	}
+	return false
+}
 func (h *dialHistory) expire(now time.Time) {
 	for h.Len() > 0 && h.min().exp.Before(now) {
 		heap.Pop(h)
@@ -24,7 +24,9 @@ import (
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/Rue-Foundation/go-rue/log"
 	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/p2p/enr"
 	"github.com/Rue-Foundation/go-rue/p2p/netutil"
 )
 
@@ -83,6 +85,8 @@ func (t fakeTable) Close()                                   {}
 func (t fakeTable) Lookup(discover.NodeID) []*discover.Node  { return nil }
 func (t fakeTable) Resolve(discover.NodeID) *discover.Node   { return nil }
 func (t fakeTable) ReadRandomNodes(buf []*discover.Node) int { return copy(buf, t) }
+func (t fakeTable) Record() *enr.Record                      { return nil }
+func (t fakeTable) SetRecord(*enr.Record) error              { return nil }
 
 // This test checks that dynamic dials are launched from discovery results.
 func TestDialStateDynDial(t *testing.T) {
@@ -307,6 +311,88 @@ func TestDialStateDynDialBootnode(t *testing.T) {
 	})
 }
 
+type fakeSource []*discover.Node
+
+func (s fakeSource) ReadRandomNodes(buf []*discover.Node) int { return copy(buf, s) }
+func (s fakeSource) Close()                                   {}
+
+// This test checks that dynamic dials are launched from external node sources
+// when discovery is disabled.
+func TestDialStateDynDialFromSource(t *testing.T) {
+	dialer := newDialState(nil, nil, nil, 5, nil)
+	dialer.addSource(fakeSource{
+		{ID: uintID(1)},
+		{ID: uintID(2)},
+		{ID: uintID(3)},
+	})
+	runDialTest(t, dialtest{
+		init: dialer,
+		rounds: []round{
+			// All source nodes are dialed, no discovery lookup is launched.
+			{
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(1)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(2)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(3)}},
+				},
+			},
+			// Connected and recently dialed nodes are not dialed again.
+			{
+				peers: []*Peer{
+					{rw: &conn{flags: dynDialedConn, id: uintID(1)}},
+				},
+				done: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(1)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(2)}},
+				},
+			},
+			// Once all dials are done, the dialer waits for the history to expire.
+			{
+				peers: []*Peer{
+					{rw: &conn{flags: dynDialedConn, id: uintID(1)}},
+				},
+				done: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(3)}},
+				},
+				new: []task{
+					&waitExpireTask{Duration: 14 * time.Second},
+				},
+			},
+		},
+	})
+}
+
+// This test checks that nodes of preferred sources are dialed before random
+// nodes of the table.
+func TestDialStateDynDialPreferred(t *testing.T) {
+	table := fakeTable{
+		{ID: uintID(1)},
+		{ID: uintID(2)},
+		{ID: uintID(3)},
+		{ID: uintID(4)},
+	}
+	dialer := newDialState(nil, nil, table, 6, nil)
+	dialer.addPreferredSource(fakeSource{
+		{ID: uintID(10)},
+		{ID: uintID(11)},
+	})
+	runDialTest(t, dialtest{
+		init: dialer,
+		rounds: []round{
+			// Both preferred nodes are dialed, the table fills half of the rest.
+			{
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(10)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(11)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(1)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(2)}},
+					&discoverTask{},
+				},
+			},
+		},
+	})
+}
+
 func TestDialStateDynDialFromTable(t *testing.T) {
 	// This table always returns the same random nodes
 	// in the order given below.
@@ -432,6 +518,32 @@ func TestDialStateNetRestrict(t *testing.T) {
 	})
 }
 
+// This test checks that banned nodes and nodes on banned IP addresses are not dialed.
+func TestDialStateBanned(t *testing.T) {
+	table := fakeTable{
+		{ID: uintID(1), IP: net.ParseIP("127.0.0.1")},
+		{ID: uintID(2), IP: net.ParseIP("127.0.0.2")},
+		{ID: uintID(3), IP: net.ParseIP("127.0.0.3")},
+	}
+	rep := newReputation(nil, log.Root())
+	rep.ban(nodeTarget(uintID(1)), 0, "test")
+	rep.ban(ipTarget(net.ParseIP("127.0.0.2")), 0, "test")
+
+	dialer := newDialState(nil, nil, table, 10, nil)
+	dialer.reputation = rep
+	runDialTest(t, dialtest{
+		init: dialer,
+		rounds: []round{
+			{
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: table[2]},
+					&discoverTask{},
+				},
+			},
+		},
+	})
+}
+
 // This test checks that static dials are launched.
 func TestDialStateStaticDial(t *testing.T) {
 	wantStatic := []*discover.Node{
@@ -583,6 +695,32 @@ func TestDialStateCache(t *testing.T) {
 	})
 }
 
+// This test checks that removing a static node also forgets its past dials, so
+// that it can be redialed right away when it is added again.
+func TestDialStateRemoveStatic(t *testing.T) {
+	var (
+		node  = &discover.Node{ID: uintID(1)}
+		state = newDialState([]*discover.Node{node}, nil, fakeTable{}, 0, nil)
+		now   time.Time
+	)
+	dial := &dialTask{flags: staticDialedConn, dest: node}
+	if tasks := state.newTasks(0, nil, now); !sametasks(tasks, []task{dial}) {
+		t.Fatalf("initial static dial mismatch: %v", spew.Sdump(tasks))
+	}
+	state.taskDone(dial, now)
+	if !state.hist.contains(node.ID) {
+		t.Fatal("finished dial not in history")
+	}
+	state.removeStatic(node)
+	if state.hist.contains(node.ID) {
+		t.Fatal("removed static node still in dial history")
+	}
+	state.addStatic(node)
+	if tasks := state.newTasks(0, nil, now); !sametasks(tasks, []task{dial}) {
+		t.Fatalf("re-added static node not redialed: %v", spew.Sdump(tasks))
+	}
+}
+
 func TestDialResolve(t *testing.T) {
 	resolved := discover.NewNode(uintID(1), net.IP{127, 0, 55, 234}, 3333, 4444)
 	table := &resolveMock{answer: resolved}
@@ -650,3 +788,5 @@ func (t *resolveMock) Close()                                   {}
 func (t *resolveMock) Bootstrap([]*discover.Node)               {}
 func (t *resolveMock) Lookup(discover.NodeID) []*discover.Node  { return nil }
 func (t *resolveMock) ReadRandomNodes(buf []*discover.Node) int { return 0 }
+func (t *resolveMock) Record() *enr.Record                      { return nil }
+func (t *resolveMock) SetRecord(*enr.Record) error              { return nil }
@@ -27,8 +27,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Rue-Foundation/go-rue/common"
 	"github.com/Rue-Foundation/go-rue/crypto"
 	"github.com/Rue-Foundation/go-rue/log"
+	"github.com/Rue-Foundation/go-rue/p2p/enr"
 	"github.com/Rue-Foundation/go-rue/rlp"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/errors"
@@ -61,6 +63,11 @@ var (
 	nodeDBDiscoverPing      = nodeDBDiscoverRoot + ":lastping"
 	nodeDBDiscoverPong      = nodeDBDiscoverRoot + ":lastpong"
 	nodeDBDiscoverFindFails = nodeDBDiscoverRoot + ":findfail"
+	nodeDBDiscoverRecord    = nodeDBDiscoverRoot + ":enr"
+
+	nodeDBLocalRecord = "local:enr" // Signed node record of the local node
+
+	nodeDBBanPrefix = []byte("ban:") // Identifier to prefix peer bans with
 )
 
 // newNodeDB creates a new node database for storing and retrieving infos about
@@ -298,6 +305,74 @@ func (db *nodeDB) updateFindFails(id NodeID, fails int) error {
 	return db.storeInt64(makeKey(id, nodeDBDiscoverFindFails), int64(fails))
 }
 
+// fetchRecord retrieves a signed node record stored at a particular database
+// key, verifying its signature.
+func (db *nodeDB) fetchRecord(key []byte) *enr.Record {
+	blob, err := db.lvl.Get(key, nil)
+	if err != nil {
+		return nil
+	}
+	record := new(enr.Record)
+	if err := rlp.DecodeBytes(blob, record); err != nil {
+		log.Warn("Failed to decode node record", "err", err)
+		return nil
+	}
+	return record
+}
+
+// storeRecord inserts - potentially overwriting - a signed node record at a
+// particular database key.
+func (db *nodeDB) storeRecord(key []byte, record *enr.Record) error {
+	blob, err := rlp.EncodeToBytes(record)
+	if err != nil {
+		return err
+	}
+	return db.lvl.Put(key, blob, nil)
+}
+
+// nodeRecord retrieves the last known node record of a remote node.
+func (db *nodeDB) nodeRecord(id NodeID) *enr.Record {
+	return db.fetchRecord(makeKey(id, nodeDBDiscoverRecord))
+}
+
+// updateNodeRecord updates the last known node record of a remote node.
+func (db *nodeDB) updateNodeRecord(id NodeID, record *enr.Record) error {
+	return db.storeRecord(makeKey(id, nodeDBDiscoverRecord), record)
+}
+
+// localRecord retrieves the last signed node record of the local node.
+func (db *nodeDB) localRecord() *enr.Record {
+	return db.fetchRecord(makeKey(nodeDBNilNodeID, nodeDBLocalRecord))
+}
+
+// updateLocalRecord updates the signed node record of the local node.
+func (db *nodeDB) updateLocalRecord(record *enr.Record) error {
+	return db.storeRecord(makeKey(nodeDBNilNodeID, nodeDBLocalRecord), record)
+}
+
+// bans retrieves all stored peer bans, keyed by the ban target.
+func (db *nodeDB) bans() map[string][]byte {
+	bans := make(map[string][]byte)
+	it := db.lvl.NewIterator(util.BytesPrefix(nodeDBBanPrefix), nil)
+	defer it.Release()
+
+	for it.Next() {
+		target := string(it.Key()[len(nodeDBBanPrefix):])
+		bans[target] = common.CopyBytes(it.Value())
+	}
+	return bans
+}
+
+// storeBan inserts - potentially overwriting - the ban of a target.
+func (db *nodeDB) storeBan(target string, blob []byte) error {
+	return db.lvl.Put(append(nodeDBBanPrefix, target...), blob, nil)
+}
+
+// deleteBan removes the ban of a target.
+func (db *nodeDB) deleteBan(target string) error {
+	return db.lvl.Delete(append(nodeDBBanPrefix, target...), nil)
+}
+
 // querySeeds retrieves random nodes to be used as potential seed nodes
 // for bootstrapping.
 func (db *nodeDB) querySeeds(n int, maxAge time.Duration) []*Node {
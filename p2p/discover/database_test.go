@@ -25,6 +25,9 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/Rue-Foundation/go-rue/crypto"
+	"github.com/Rue-Foundation/go-rue/p2p/enr"
 )
 
 var nodeDBKeyTests = []struct {
@@ -158,6 +161,45 @@ func TestNodeDBFetchStore(t *testing.T) {
 	}
 }
 
+func TestNodeDBRecords(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	id := PubkeyID(&key.PublicKey)
+
+	var record enr.Record
+	record.Set(enr.IP4(net.IP{192, 168, 0, 1}))
+	record.Set(enr.UDP(30303))
+	if err := record.Sign(key); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	db, _ := newNodeDB("", Version, NodeID{})
+	defer db.close()
+
+	// Check fetch/store operations on a remote node record
+	if stored := db.nodeRecord(id); stored != nil {
+		t.Errorf("record: non-existing object: %v", stored)
+	}
+	if err := db.updateNodeRecord(id, &record); err != nil {
+		t.Errorf("record: failed to update: %v", err)
+	}
+	if stored := db.nodeRecord(id); stored == nil {
+		t.Errorf("record: not found")
+	} else if stored.Seq() != record.Seq() || stored.NodeAddr() == nil {
+		t.Errorf("record: data mismatch: have seq %d, want %d", stored.Seq(), record.Seq())
+	}
+	// Check fetch/store operations on the local node record
+	if stored := db.localRecord(); stored != nil {
+		t.Errorf("local record: non-existing object: %v", stored)
+	}
+	if err := db.updateLocalRecord(&record); err != nil {
+		t.Errorf("local record: failed to update: %v", err)
+	}
+	if stored := db.localRecord(); stored == nil {
+		t.Errorf("local record: not found")
+	} else if stored.Seq() != record.Seq() {
+		t.Errorf("local record: seq mismatch: have %d, want %d", stored.Seq(), record.Seq())
+	}
+}
+
 var nodeDBSeedQueryNodes = []struct {
 	node *Node
 	pong time.Time
@@ -215,6 +257,36 @@ var nodeDBSeedQueryNodes = []struct {
 	},
 }
 
+func TestNodeDBBans(t *testing.T) {
+	db, _ := newNodeDB("", Version, NodeID{})
+	defer db.close()
+
+	if bans := db.bans(); len(bans) != 0 {
+		t.Errorf("bans: non-existing objects: %v", bans)
+	}
+	if err := db.storeBan("node:01", []byte{1}); err != nil {
+		t.Errorf("bans: failed to store: %v", err)
+	}
+	if err := db.storeBan("ip:10.0.0.1", []byte{2}); err != nil {
+		t.Errorf("bans: failed to store: %v", err)
+	}
+	want := map[string][]byte{"node:01": {1}, "ip:10.0.0.1": {2}}
+	if bans := db.bans(); !reflect.DeepEqual(bans, want) {
+		t.Errorf("bans: data mismatch: have %v, want %v", bans, want)
+	}
+	// Bans are not node entries, make sure expiration leaves them alone
+	if err := db.expireNodes(); err != nil {
+		t.Fatalf("failed to expire nodes: %v", err)
+	}
+	if err := db.deleteBan("node:01"); err != nil {
+		t.Errorf("bans: failed to delete: %v", err)
+	}
+	delete(want, "node:01")
+	if bans := db.bans(); !reflect.DeepEqual(bans, want) {
+		t.Errorf("bans: data mismatch after delete: have %v, want %v", bans, want)
+	}
+}
+
 func TestNodeDBSeedQuery(t *testing.T) {
 	db, _ := newNodeDB("", Version, nodeDBSeedQueryNodes[1].node.ID)
 	defer db.close()
@@ -33,6 +33,7 @@ import (
 	"github.com/Rue-Foundation/go-rue/common"
 	"github.com/Rue-Foundation/go-rue/crypto"
 	"github.com/Rue-Foundation/go-rue/crypto/secp256k1"
+	"github.com/Rue-Foundation/go-rue/p2p/enr"
 )
 
 const NodeIDBits = 512
@@ -71,6 +72,40 @@ func NewNode(id NodeID, ip net.IP, udpPort, tcpPort uint16) *Node {
 	}
 }
 
+// NodeFromRecord creates a node from a signed node record. The record must
+// contain the public key, the IP address and the TCP port of the node.
+func NodeFromRecord(r *enr.Record) (*Node, error) {
+	if !r.Signed() {
+		return nil, errors.New("unsigned node record")
+	}
+	var (
+		pubkey enr.Secp256k1
+		ip4    enr.IP4
+		ip6    enr.IP6
+		tcp    enr.TCP
+		udp    enr.UDP
+		ip     net.IP
+	)
+	if err := r.Load(&pubkey); err != nil {
+		return nil, err
+	}
+	switch {
+	case r.Load(&ip4) == nil:
+		ip = net.IP(ip4)
+	case r.Load(&ip6) == nil:
+		ip = net.IP(ip6)
+	default:
+		return nil, errors.New("node record has no IP address")
+	}
+	if err := r.Load(&tcp); err != nil {
+		return nil, err
+	}
+	if err := r.Load(&udp); err != nil && !enr.IsNotFound(err) {
+		return nil, err
+	}
+	return NewNode(PubkeyID((*ecdsa.PublicKey)(&pubkey)), ip, uint16(udp), uint16(tcp)), nil
+}
+
 func (n *Node) addr() *net.UDPAddr {
 	return &net.UDPAddr{IP: n.IP, Port: int(n.UDP)}
 }
@@ -30,6 +30,7 @@ import (
 
 	"github.com/Rue-Foundation/go-rue/common"
 	"github.com/Rue-Foundation/go-rue/crypto"
+	"github.com/Rue-Foundation/go-rue/p2p/enr"
 )
 
 func ExampleNewNode() {
@@ -180,6 +181,35 @@ func TestNodeString(t *testing.T) {
 	}
 }
 
+func TestNodeFromRecord(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+
+	var r enr.Record
+	r.Set(enr.IP4(net.IP{10, 0, 1, 2}))
+	r.Set(enr.TCP(30303))
+	r.Set(enr.UDP(30301))
+	if _, err := NodeFromRecord(&r); err == nil {
+		t.Fatal("unsigned record accepted")
+	}
+	if err := r.Sign(key); err != nil {
+		t.Fatalf("can't sign record: %v", err)
+	}
+	n, err := NodeFromRecord(&r)
+	if err != nil {
+		t.Fatalf("can't create node: %v", err)
+	}
+	want := NewNode(PubkeyID(&key.PublicKey), net.IP{10, 0, 1, 2}, 30301, 30303)
+	if !reflect.DeepEqual(n, want) {
+		t.Errorf("node mismatch:\ngot:  %v\nwant: %v", n, want)
+	}
+	// Records without an endpoint can't be dialed.
+	var empty enr.Record
+	empty.Sign(key)
+	if _, err := NodeFromRecord(&empty); err == nil {
+		t.Error("record without IP address accepted")
+	}
+}
+
 func TestHexID(t *testing.T) {
 	ref := NodeID{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 128, 106, 217, 182, 31, 165, 174, 1, 67, 7, 235, 220, 150, 66, 83, 173, 205, 159, 44, 10, 57, 42, 161, 26, 188}
 	id1 := MustHexID("0x000000000000000000000000000000000000000000000000000000000000000000000000000000806ad9b61fa5ae014307ebdc964253adcd9f2c0a392aa11abc")
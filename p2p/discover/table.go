@@ -35,6 +35,7 @@ import (
 	"github.com/Rue-Foundation/go-rue/common"
 	"github.com/Rue-Foundation/go-rue/crypto"
 	"github.com/Rue-Foundation/go-rue/log"
+	"github.com/Rue-Foundation/go-rue/p2p/enr"
 )
 
 const (
@@ -69,6 +70,9 @@ type Table struct {
 
 	net  transport
 	self *Node // metadata of the local node
+
+	recordLock sync.RWMutex
+	record     *enr.Record // signed node record of the local node
 }
 
 type bondproc struct {
@@ -84,6 +88,7 @@ type transport interface {
 	ping(NodeID, *net.UDPAddr) error
 	waitping(NodeID) error
 	findnode(toid NodeID, addr *net.UDPAddr, target NodeID) ([]*Node, error)
+	requestENR(toid NodeID, addr *net.UDPAddr) (*enr.Record, error)
 	close()
 }
 
@@ -101,6 +106,7 @@ func newTable(t transport, ourID NodeID, ourAddr *net.UDPAddr, nodeDBPath string
 		net:        t,
 		db:         db,
 		self:       NewNode(ourID, ourAddr.IP, uint16(ourAddr.Port), uint16(ourAddr.Port)),
+		record:     db.localRecord(),
 		bonding:    make(map[NodeID]*bondproc),
 		bondslots:  make(chan struct{}, maxBondingPingPongs),
 		refreshReq: make(chan chan struct{}),
@@ -123,6 +129,50 @@ func (tab *Table) Self() *Node {
 	return tab.self
 }
 
+// Record returns the signed node record of the local node, or nil if none has
+// been set yet. The returned record should not be modified by the caller.
+func (tab *Table) Record() *enr.Record {
+	tab.recordLock.RLock()
+	defer tab.recordLock.RUnlock()
+
+	return tab.record
+}
+
+// SetRecord updates the signed node record of the local node served to remote
+// nodes, also persisting it into the node database.
+func (tab *Table) SetRecord(record *enr.Record) error {
+	if !record.Signed() {
+		return errors.New("unsigned node record")
+	}
+	tab.recordLock.Lock()
+	defer tab.recordLock.Unlock()
+
+	tab.record = record
+	return tab.db.updateLocalRecord(record)
+}
+
+// NodeRecord returns the last known signed node record of a remote node, or
+// nil if none was retrieved yet.
+func (tab *Table) NodeRecord(id NodeID) *enr.Record {
+	return tab.db.nodeRecord(id)
+}
+
+// Bans returns the peer bans stored in the node database, keyed by the ban
+// target. The encoding of the bans is up to the caller.
+func (tab *Table) Bans() map[string][]byte {
+	return tab.db.bans()
+}
+
+// StoreBan persists the ban of a target in the node database.
+func (tab *Table) StoreBan(target string, blob []byte) error {
+	return tab.db.storeBan(target, blob)
+}
+
+// DeleteBan removes the ban of a target from the node database.
+func (tab *Table) DeleteBan(target string) error {
+	return tab.db.deleteBan(target)
+}
+
 // ReadRandomNodes fills the given slice with random nodes from the
 // table. It will not write the same node more than once. The nodes in
 // the slice are copies and can be modified by the caller.
@@ -524,6 +574,22 @@ func (tab *Table) pingpong(w *bondproc, pinged bool, id NodeID, addr *net.UDPAdd
 	w.n = NewNode(id, addr.IP, uint16(addr.Port), tcpPort)
 	tab.db.updateNode(w.n)
 	close(w.done)
+
+	go tab.requestRecord(id, addr)
+}
+
+// requestRecord retrieves the signed node record of a freshly bonded node,
+// storing it in the database if it's newer than the last known one.
+func (tab *Table) requestRecord(id NodeID, addr *net.UDPAddr) {
+	record, err := tab.net.requestENR(id, addr)
+	if err != nil {
+		log.Trace("Node record retrieval failed", "id", id, "addr", addr, "err", err)
+		return
+	}
+	if old := tab.db.nodeRecord(id); old != nil && old.Seq() >= record.Seq() {
+		return
+	}
+	tab.db.updateNodeRecord(id, record)
 }
 
 // ping a remote endpoint and wait for a reply, also updating the node
@@ -36,6 +36,7 @@ import (
 	"github.com/davecgh/go-spew/spew"
 	"github.com/Rue-Foundation/go-rue/common"
 	"github.com/Rue-Foundation/go-rue/crypto"
+	"github.com/Rue-Foundation/go-rue/p2p/enr"
 	"github.com/Rue-Foundation/go-rue/rlp"
 )
 
@@ -126,6 +127,7 @@ func TestUDP_packetErrors(t *testing.T) {
 	test.packetIn(errUnsolicitedReply, pongPacket, &pong{ReplyTok: []byte{}, Expiration: futureExp})
 	test.packetIn(errUnknownNode, findnodePacket, &findnode{Expiration: futureExp})
 	test.packetIn(errUnsolicitedReply, neighborsPacket, &neighbors{Expiration: futureExp})
+	test.packetIn(errUnknownNode, enrRequestPacket, &enrRequest{Expiration: futureExp})
 }
 
 func TestUDP_pingTimeout(t *testing.T) {
@@ -388,6 +390,82 @@ func TestUDP_successfulPing(t *testing.T) {
 	}
 }
 
+func TestUDP_enrRequest(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	// ensure there's a bond with the test node,
+	// enrRequest won't be accepted otherwise.
+	test.table.db.updateNode(NewNode(
+		PubkeyID(&test.remotekey.PublicKey),
+		test.remoteaddr.IP,
+		uint16(test.remoteaddr.Port),
+		99,
+	))
+	test.packetIn(errNoRecord, enrRequestPacket, &enrRequest{Expiration: futureExp})
+
+	// check that the local record is served once set.
+	var record enr.Record
+	record.Set(enr.IP4(testLocal.IP))
+	record.Set(enr.UDP(testLocal.UDP))
+	if err := record.Sign(test.localkey); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	if err := test.table.SetRecord(&record); err != nil {
+		t.Fatalf("failed to set record: %v", err)
+	}
+	test.packetIn(nil, enrRequestPacket, &enrRequest{Expiration: futureExp})
+	test.waitPacketOut(func(p *enrResponse) {
+		reqhash := test.sent[len(test.sent)-1][:macSize]
+		if !bytes.Equal(p.ReplyTok, reqhash) {
+			t.Errorf("got enrResponse.ReplyTok %x, want %x", p.ReplyTok, reqhash)
+		}
+		if p.Record.Seq() != record.Seq() {
+			t.Errorf("got record seq %d, want %d", p.Record.Seq(), record.Seq())
+		}
+		if !bytes.Equal(p.Record.NodeAddr(), record.NodeAddr()) {
+			t.Errorf("got record of node %x, want %x", p.Record.NodeAddr(), record.NodeAddr())
+		}
+	})
+}
+
+func TestUDP_requestENR(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	// queue a pending record request
+	recordc, errc := make(chan *enr.Record), make(chan error)
+	go func() {
+		rid := PubkeyID(&test.remotekey.PublicKey)
+		record, err := test.udp.requestENR(rid, test.remoteaddr)
+		if err != nil {
+			errc <- err
+		} else {
+			recordc <- record
+		}
+	}()
+	test.waitPacketOut(func(p *enrRequest) {})
+
+	// reply with the record of the remote node
+	var record enr.Record
+	record.Set(enr.IP4(testRemote.IP))
+	if err := record.Sign(test.remotekey); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	test.packetIn(nil, enrResponsePacket, &enrResponse{ReplyTok: []byte{}, Record: record})
+
+	select {
+	case result := <-recordc:
+		if result.Seq() != record.Seq() || !bytes.Equal(result.NodeAddr(), record.NodeAddr()) {
+			t.Errorf("record mismatch: got seq %d of %x, want seq %d of %x", result.Seq(), result.NodeAddr(), record.Seq(), record.NodeAddr())
+		}
+	case err := <-errc:
+		t.Errorf("requestENR error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Error("requestENR did not return within 5 seconds")
+	}
+}
+
 var testPackets = []struct {
 	input      string
 	wantPacket interface{}
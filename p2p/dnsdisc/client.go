@@ -0,0 +1,395 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dnsdisc implements node discovery via signed lists of node records
+// published in DNS TXT records.
+//
+// A list is structured as a merkle tree. The root of the tree lives at the
+// list's domain and is signed by the list publisher, all other entries live
+// at subdomains named after the hash of their content. Lists are identified
+// by URLs of the form
+//
+//	enrtree://<base32 compressed public key>@<domain>
+//
+// and may link to other lists, which are followed by the client as well.
+package dnsdisc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/log"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/hashicorp/golang-lru"
+)
+
+var (
+	errNoRoot        = errors.New("no valid root found")
+	errHashMismatch  = errors.New("hash mismatch")
+	errNoEntry       = errors.New("no valid tree entry found")
+	errTreeTooDeep   = errors.New("tree is too deep")
+	errLinkInENRTree = errors.New("link entry in node list subtree")
+	errENRInLinkTree = errors.New("node record entry in link subtree")
+)
+
+// maxTreeDepth is the maximum number of branch levels followed while syncing a
+// tree, protecting against cyclic or maliciously deep trees.
+const maxTreeDepth = 16
+
+// Resolver is a DNS resolver that can query TXT records.
+type Resolver interface {
+	LookupTXT(ctx context.Context, domain string) ([]string, error)
+}
+
+// Config holds the settings of a DNS discovery client.
+type Config struct {
+	Timeout         time.Duration // timeout used for DNS lookups (default 5s)
+	RecheckInterval time.Duration // time between tree root update checks (default 30min)
+	CacheLimit      int           // maximum number of cached tree entries (default 1000)
+	Resolver        Resolver      // the DNS resolver to use (defaults to system DNS)
+	Logger          log.Logger    // destination of client log messages (defaults to root logger)
+}
+
+func (cfg Config) withDefaults() Config {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.RecheckInterval == 0 {
+		cfg.RecheckInterval = 30 * time.Minute
+	}
+	if cfg.CacheLimit == 0 {
+		cfg.CacheLimit = 1000
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = new(net.Resolver)
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Root()
+	}
+	return cfg
+}
+
+// Client discovers nodes by querying DNS servers.
+type Client struct {
+	cfg     Config
+	entries *lru.Cache
+}
+
+// NewClient creates a client.
+func NewClient(cfg Config) *Client {
+	cfg = cfg.withDefaults()
+	cache, err := lru.New(cfg.CacheLimit)
+	if err != nil {
+		panic(err)
+	}
+	return &Client{cfg: cfg, entries: cache}
+}
+
+// SyncTree downloads the entire node tree at the given URL, verifying the root
+// signature and the hashes of all entries.
+func (c *Client) SyncTree(url string) (*Tree, error) {
+	loc, err := parseLink(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enrtree URL: %v", err)
+	}
+	return c.syncTree(loc)
+}
+
+// syncTree downloads the tree at the given location.
+func (c *Client) syncTree(loc *linkEntry) (*Tree, error) {
+	root, err := c.resolveRoot(loc)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tree{root: root, entries: make(map[string]entry)}
+	if err := c.syncSubtree(t, loc.domain, root.eroot, false, 0); err != nil {
+		return nil, err
+	}
+	if err := c.syncSubtree(t, loc.domain, root.lroot, true, 0); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// syncSubtree retrieves the entry with the given hash and all entries below it.
+// Link subtrees may only contain links, node subtrees only node records.
+func (c *Client) syncSubtree(t *Tree, domain, hash string, links bool, depth int) error {
+	if depth > maxTreeDepth {
+		return errTreeTooDeep
+	}
+	if _, ok := t.entries[hash]; ok {
+		return nil
+	}
+	e, err := c.resolveEntry(domain, hash)
+	if err != nil {
+		return err
+	}
+	t.entries[hash] = e
+
+	switch e := e.(type) {
+	case *branchEntry:
+		for _, child := range e.children {
+			if err := c.syncSubtree(t, domain, child, links, depth+1); err != nil {
+				return err
+			}
+		}
+	case *linkEntry:
+		if !links {
+			return errLinkInENRTree
+		}
+	case *enrEntry:
+		if links {
+			return errENRInLinkTree
+		}
+	}
+	return nil
+}
+
+// resolveRoot retrieves a root entry via DNS and verifies its signature.
+func (c *Client) resolveRoot(loc *linkEntry) (*rootEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, loc.domain)
+	c.cfg.Logger.Trace("Updating DNS discovery root", "tree", loc.domain, "err", err)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range txts {
+		if !strings.HasPrefix(txt, rootPrefix) {
+			continue
+		}
+		root, err := parseRoot(txt)
+		if err != nil {
+			return nil, err
+		}
+		if !root.verifySignature(loc.pubkey) {
+			return nil, entryError{"root", errInvalidSig}
+		}
+		return root, nil
+	}
+	return nil, errNoRoot
+}
+
+// resolveEntry retrieves an entry from the cache or fetches it from the network
+// if it isn't cached.
+func (c *Client) resolveEntry(domain, hash string) (entry, error) {
+	if e, ok := c.entries.Get(hash); ok {
+		return e.(entry), nil
+	}
+	e, err := c.doResolveEntry(domain, hash)
+	if err != nil {
+		return nil, err
+	}
+	c.entries.Add(hash, e)
+	return e, nil
+}
+
+// doResolveEntry fetches an entry via DNS, checking that its content matches
+// the hash it was requested by.
+func (c *Client) doResolveEntry(domain, hash string) (entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+
+	name := hash + "." + domain
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, name)
+	c.cfg.Logger.Trace("DNS discovery lookup", "name", name, "err", err)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range txts {
+		e, err := parseEntry(txt)
+		if err == errUnknownEntry {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if subdomain(e) != hash {
+			return nil, entryError{typ: "entry", err: errHashMismatch}
+		}
+		return e, nil
+	}
+	return nil, errNoEntry
+}
+
+// Source is a source of dial candidates backed by one or more DNS node lists.
+// The lists are re-synced periodically in the background, following any links
+// to other lists.
+type Source struct {
+	client *Client
+	urls   []*linkEntry
+
+	lock  sync.Mutex
+	trees map[string]*Tree // synced trees, keyed by URL
+	nodes []*discover.Node // dial candidates collected from all trees
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewSource creates a dial candidate source for the node lists at the given
+// URLs and starts syncing them in the background.
+func (c *Client) NewSource(urls ...string) (*Source, error) {
+	s, err := c.newSource(urls...)
+	if err != nil {
+		return nil, err
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return s, nil
+}
+
+func (c *Client) newSource(urls ...string) (*Source, error) {
+	s := &Source{
+		client: c,
+		trees:  make(map[string]*Tree),
+		quit:   make(chan struct{}),
+	}
+	for _, url := range urls {
+		loc, err := parseLink(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid enrtree URL %q: %v", url, err)
+		}
+		s.urls = append(s.urls, loc)
+	}
+	return s, nil
+}
+
+// ReadRandomNodes fills the given slice with random dial candidates from the
+// synced lists and returns the number of nodes written.
+func (s *Source) ReadRandomNodes(buf []*discover.Node) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n := 0
+	for _, i := range rand.Perm(len(s.nodes)) {
+		if n == len(buf) {
+			break
+		}
+		buf[n] = s.nodes[i]
+		n++
+	}
+	return n
+}
+
+// Close stops the background sync.
+func (s *Source) Close() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+// loop re-syncs the node lists every recheck interval.
+func (s *Source) loop() {
+	defer s.wg.Done()
+
+	recheck := time.NewTimer(0)
+	defer recheck.Stop()
+
+	for {
+		select {
+		case <-recheck.C:
+			s.refresh()
+			recheck.Reset(s.client.cfg.RecheckInterval)
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// refresh syncs all configured lists and the lists linked from them, and
+// rebuilds the set of dial candidates. Lists whose root didn't change since
+// the last sync are not downloaded again.
+func (s *Source) refresh() {
+	s.lock.Lock()
+	old := s.trees
+	s.lock.Unlock()
+
+	var (
+		trees   = make(map[string]*Tree)
+		pending = append([]*linkEntry{}, s.urls...)
+		logger  = s.client.cfg.Logger
+	)
+	for len(pending) > 0 {
+		loc := pending[0]
+		pending = pending[1:]
+
+		url := loc.String()
+		if _, ok := trees[url]; ok {
+			continue
+		}
+		tree, err := s.sync(loc, old[url])
+		if err != nil {
+			logger.Debug("Failed to sync DNS node list", "tree", url, "err", err)
+			// Keep serving the previous version of the list if there is one.
+			if tree = old[url]; tree == nil {
+				continue
+			}
+		}
+		trees[url] = tree
+		for _, link := range tree.Links() {
+			le, err := parseLink(link)
+			if err != nil {
+				continue
+			}
+			pending = append(pending, le)
+		}
+	}
+	// Collect the dial candidates of all reachable lists.
+	var (
+		nodes []*discover.Node
+		seen  = make(map[discover.NodeID]bool)
+	)
+	for url, tree := range trees {
+		for _, r := range tree.Nodes() {
+			n, err := discover.NodeFromRecord(r)
+			if err != nil {
+				logger.Trace("Skipping node list entry", "tree", url, "err", err)
+				continue
+			}
+			if !seen[n.ID] {
+				seen[n.ID] = true
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	s.lock.Lock()
+	s.trees, s.nodes = trees, nodes
+	s.lock.Unlock()
+
+	logger.Debug("Synced DNS node lists", "trees", len(trees), "nodes", len(nodes))
+}
+
+// sync downloads the tree at the given location unless the previous version of
+// it is still current.
+func (s *Source) sync(loc *linkEntry, prev *Tree) (*Tree, error) {
+	if prev != nil {
+		root, err := s.client.resolveRoot(loc)
+		if err != nil {
+			return nil, err
+		}
+		if root.String() == prev.root.String() {
+			return prev, nil
+		}
+	}
+	return s.client.syncTree(loc)
+}
@@ -0,0 +1,256 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Rue-Foundation/go-rue/crypto"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/p2p/enr"
+)
+
+const (
+	signingKeySeed = 0x111111
+	nodesSeed1     = 0x2945237
+	nodesSeed2     = 0x4567299
+)
+
+func TestClientSyncTree(t *testing.T) {
+	var (
+		key   = testKey(signingKeySeed)
+		nodes = testNodes(nodesSeed1, 30)
+		link  = "enrtree://AKPYQIUQIL7PSIACI32J7FGZW56E5FKHEFCCOFHILBIMW3M6LWXS2@nodes.example.org"
+	)
+	tree, url := makeTestTree("n", key, nodes, []string{link})
+	r := mapResolver(tree.ToTXT("n"))
+	c := NewClient(Config{Resolver: r})
+
+	synced, err := c.SyncTree(url)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if !reflect.DeepEqual(synced.Nodes(), sortByAddr(nodes)) {
+		t.Errorf("wrong nodes in synced tree:\ngot:  %d nodes\nwant: %d nodes", len(synced.Nodes()), len(nodes))
+	}
+	if !reflect.DeepEqual(synced.Links(), []string{link}) {
+		t.Errorf("wrong links in synced tree: %v", synced.Links())
+	}
+	if synced.Seq() != tree.Seq() || synced.Signature() != tree.Signature() {
+		t.Error("synced tree root doesn't match the published root")
+	}
+}
+
+// This test checks that the client rejects trees signed by the wrong key.
+func TestClientSyncTreeBadSignature(t *testing.T) {
+	var (
+		key      = testKey(signingKeySeed)
+		otherKey = testKey(nodesSeed2)
+	)
+	tree, _ := makeTestTree("n", key, testNodes(nodesSeed1, 3), nil)
+	url := (&linkEntry{domain: "n", pubkey: &otherKey.PublicKey}).String()
+
+	c := NewClient(Config{Resolver: mapResolver(tree.ToTXT("n"))})
+	if _, err := c.SyncTree(url); err != (entryError{"root", errInvalidSig}) {
+		t.Fatalf("expected signature error, got %v", err)
+	}
+}
+
+// This test checks that the client rejects entries whose content doesn't
+// match the hash they were requested by.
+func TestClientSyncTreeHashMismatch(t *testing.T) {
+	var (
+		key   = testKey(signingKeySeed)
+		nodes = testNodes(nodesSeed1, 1)
+	)
+	tree, url := makeTestTree("n", key, nodes, nil)
+	r := mapResolver(tree.ToTXT("n"))
+
+	// Replace the node record by a different one under the same name.
+	other := testNodes(nodesSeed2, 1)[0]
+	r[tree.root.eroot+".n"] = (&enrEntry{other}).String()
+
+	c := NewClient(Config{Resolver: r})
+	if _, err := c.SyncTree(url); err != (entryError{"entry", errHashMismatch}) {
+		t.Fatalf("expected hash mismatch error, got %v", err)
+	}
+}
+
+// This test checks that the source follows links to other trees and collects
+// the nodes of all of them.
+func TestSourceLinks(t *testing.T) {
+	var (
+		key1   = testKey(signingKeySeed)
+		key2   = testKey(nodesSeed2)
+		nodes1 = testNodes(nodesSeed1, 10)
+		nodes2 = testNodes(nodesSeed2, 10)
+	)
+	tree2, url2 := makeTestTree("b", key2, nodes2, nil)
+	tree1, url1 := makeTestTree("a", key1, nodes1, []string{url2})
+
+	r := mapResolver(tree1.ToTXT("a"))
+	r.add(tree2.ToTXT("b"))
+
+	c := NewClient(Config{Resolver: r})
+	s, err := c.newSource(url1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.refresh()
+	checkSourceNodes(t, s, append(nodes1, nodes2...))
+
+	// Unlink the second tree and check that its nodes are dropped.
+	tree1, _ = makeTestTree("a", key1, nodes1, nil)
+	tree1.root.seq = 2
+	tree1.Sign(key1, "a")
+	r.add(tree1.ToTXT("a"))
+
+	s.refresh()
+	checkSourceNodes(t, s, nodes1)
+}
+
+// This test checks that the source keeps serving the last synced nodes while
+// DNS is unreachable, and picks up updates once the root changes.
+func TestSourceUpdate(t *testing.T) {
+	var (
+		key    = testKey(signingKeySeed)
+		nodes1 = testNodes(nodesSeed1, 10)
+		nodes2 = testNodes(nodesSeed2, 10)
+	)
+	tree, url := makeTestTree("n", key, nodes1, nil)
+	r := mapResolver(tree.ToTXT("n"))
+
+	c := NewClient(Config{Resolver: r})
+	s, err := c.newSource(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.refresh()
+	checkSourceNodes(t, s, nodes1)
+
+	// Remove all records and check that the old nodes are still served.
+	for name := range r {
+		delete(r, name)
+	}
+	s.refresh()
+	checkSourceNodes(t, s, nodes1)
+
+	// Publish a new version of the tree.
+	tree, _ = makeTestTree("n", key, nodes2, nil)
+	tree.root.seq = 2
+	tree.Sign(key, "n")
+	r.add(tree.ToTXT("n"))
+
+	s.refresh()
+	checkSourceNodes(t, s, nodes2)
+}
+
+func checkSourceNodes(t *testing.T, s *Source, records []*enr.Record) {
+	t.Helper()
+
+	var want []string
+	for _, r := range records {
+		n, err := discover.NodeFromRecord(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, n.String())
+	}
+	buf := make([]*discover.Node, len(records)+10)
+	var got []string
+	for _, n := range buf[:s.ReadRandomNodes(buf)] {
+		got = append(got, n.String())
+	}
+	sort.Strings(want)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong source nodes:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func makeTestTree(domain string, key *ecdsa.PrivateKey, nodes []*enr.Record, links []string) (*Tree, string) {
+	tree, err := MakeTree(1, nodes, links)
+	if err != nil {
+		panic(err)
+	}
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		panic(err)
+	}
+	return tree, url
+}
+
+// testKeys creates a deterministic private key for testing.
+func testKey(seed int64) *ecdsa.PrivateKey {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(seed))
+	key, err := crypto.ToECDSA(crypto.Keccak256(buf[:]))
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// testNodes creates n deterministic, signed node records.
+func testNodes(seed int64, n int) []*enr.Record {
+	records := make([]*enr.Record, n)
+	for i := range records {
+		key := testKey(seed + int64(i))
+		r := new(enr.Record)
+		r.Set(enr.IP4(net.IP{127, 0, byte(i >> 8), byte(i)}))
+		r.Set(enr.TCP(30303))
+		r.Set(enr.UDP(30303))
+		if err := r.Sign(key); err != nil {
+			panic(err)
+		}
+		records[i] = r
+	}
+	return records
+}
+
+func sortByAddr(records []*enr.Record) []*enr.Record {
+	sorted := make([]*enr.Record, len(records))
+	copy(sorted, records)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].NodeAddr(), sorted[j].NodeAddr()) < 0
+	})
+	return sorted
+}
+
+// mapResolver is an in-memory resolver serving TXT records from a map.
+type mapResolver map[string]string
+
+func (mr mapResolver) add(m map[string]string) {
+	for k, v := range m {
+		mr[k] = v
+	}
+}
+
+func (mr mapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if record, ok := mr[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, fmt.Errorf("no such domain: %s", name)
+}
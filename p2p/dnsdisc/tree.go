@@ -0,0 +1,378 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Rue-Foundation/go-rue/crypto"
+	"github.com/Rue-Foundation/go-rue/p2p/enr"
+	"github.com/Rue-Foundation/go-rue/rlp"
+)
+
+const (
+	rootPrefix   = "enrtree-root:v1"
+	branchPrefix = "enrtree-branch:"
+	linkPrefix   = "enrtree://"
+	enrPrefix    = "enr:"
+
+	// maxChildren is the maximum number of hashes in a branch entry, chosen so
+	// that a branch fits into a single TXT record string.
+	maxChildren = 13
+
+	hashAbbrevSize = 16 // size of the subdomain hashes in bytes
+)
+
+var (
+	errUnknownEntry = errors.New("unknown entry type")
+	errNoPubkey     = errors.New("missing public key")
+	errBadPubkey    = errors.New("invalid public key")
+	errInvalidENR   = errors.New("invalid node record")
+	errInvalidChild = errors.New("invalid child hash")
+	errInvalidSig   = errors.New("invalid root signature")
+	errSyntax       = errors.New("invalid syntax")
+)
+
+var (
+	b32format = base32.StdEncoding.WithPadding(base32.NoPadding)
+	b64format = base64.RawURLEncoding
+)
+
+// Tree is a merkle tree of node records and links to other trees, in the form
+// it is published in DNS TXT records.
+type Tree struct {
+	root    *rootEntry
+	entries map[string]entry
+}
+
+// MakeTree creates a tree containing the given nodes and links. The tree must
+// be signed before it can be published.
+func MakeTree(seq uint, nodes []*enr.Record, links []string) (*Tree, error) {
+	// Sort the records by their node address to get a deterministic tree.
+	records := make([]*enr.Record, len(nodes))
+	copy(records, nodes)
+	sort.Slice(records, func(i, j int) bool {
+		return bytes.Compare(records[i].NodeAddr(), records[j].NodeAddr()) < 0
+	})
+	// Create the leaf entries.
+	enrEntries := make([]entry, len(records))
+	for i, r := range records {
+		if !r.Signed() {
+			return nil, fmt.Errorf("node record %d is not signed", i)
+		}
+		enrEntries[i] = &enrEntry{r}
+	}
+	linkEntries := make([]entry, len(links))
+	for i, l := range links {
+		le, err := parseLink(l)
+		if err != nil {
+			return nil, err
+		}
+		linkEntries[i] = le
+	}
+	// Create the intermediate branches.
+	t := &Tree{entries: make(map[string]entry)}
+	eroot := t.build(enrEntries)
+	t.entries[subdomain(eroot)] = eroot
+	lroot := t.build(linkEntries)
+	t.entries[subdomain(lroot)] = lroot
+	t.root = &rootEntry{seq: seq, eroot: subdomain(eroot), lroot: subdomain(lroot)}
+	return t, nil
+}
+
+// build creates the branch entries above the given leaves, returning the
+// top-level entry of the subtree.
+func (t *Tree) build(entries []entry) entry {
+	if len(entries) == 1 {
+		return entries[0]
+	}
+	if len(entries) <= maxChildren {
+		hashes := make([]string, len(entries))
+		for i, e := range entries {
+			hashes[i] = subdomain(e)
+			t.entries[hashes[i]] = e
+		}
+		return &branchEntry{hashes}
+	}
+	var subtrees []entry
+	for len(entries) > 0 {
+		n := maxChildren
+		if len(entries) < n {
+			n = len(entries)
+		}
+		sub := t.build(entries[:n])
+		entries = entries[n:]
+		subtrees = append(subtrees, sub)
+		t.entries[subdomain(sub)] = sub
+	}
+	return t.build(subtrees)
+}
+
+// Sign signs the tree with the given private key and returns the URL under
+// which the tree can be retrieved when it is published at the given domain.
+func (t *Tree) Sign(key *ecdsa.PrivateKey, domain string) (string, error) {
+	root := *t.root
+	sig, err := crypto.Sign(root.sigHash(), key)
+	if err != nil {
+		return "", err
+	}
+	root.sig = sig
+	t.root = &root
+	link := &linkEntry{domain: domain, pubkey: &key.PublicKey}
+	return link.String(), nil
+}
+
+// Seq returns the sequence number of the tree.
+func (t *Tree) Seq() uint {
+	return t.root.seq
+}
+
+// Signature returns the signature of the tree.
+func (t *Tree) Signature() string {
+	return b64format.EncodeToString(t.root.sig)
+}
+
+// ToTXT returns all DNS TXT records required to publish the tree at the given
+// domain, keyed by fully qualified name.
+func (t *Tree) ToTXT(domain string) map[string]string {
+	records := map[string]string{domain: t.root.String()}
+	for _, e := range t.entries {
+		sd := subdomain(e)
+		if domain != "" {
+			sd = sd + "." + domain
+		}
+		records[sd] = e.String()
+	}
+	return records
+}
+
+// Links returns all links contained in the tree.
+func (t *Tree) Links() []string {
+	var links []string
+	for _, e := range t.entries {
+		if le, ok := e.(*linkEntry); ok {
+			links = append(links, le.String())
+		}
+	}
+	sort.Strings(links)
+	return links
+}
+
+// Nodes returns all node records contained in the tree.
+func (t *Tree) Nodes() []*enr.Record {
+	var nodes []*enr.Record
+	for _, e := range t.entries {
+		if ee, ok := e.(*enrEntry); ok {
+			nodes = append(nodes, ee.node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return bytes.Compare(nodes[i].NodeAddr(), nodes[j].NodeAddr()) < 0
+	})
+	return nodes
+}
+
+// Entry types.
+
+type entry interface {
+	fmt.Stringer
+}
+
+type (
+	rootEntry struct {
+		eroot string
+		lroot string
+		seq   uint
+		sig   []byte
+	}
+	branchEntry struct {
+		children []string
+	}
+	enrEntry struct {
+		node *enr.Record
+	}
+	linkEntry struct {
+		domain string
+		pubkey *ecdsa.PublicKey
+	}
+)
+
+// subdomain returns the name under which an entry is published below the tree
+// domain, which is the abbreviated hash of its textual representation.
+func subdomain(e entry) string {
+	h := crypto.Keccak256([]byte(e.String()))
+	return b32format.EncodeToString(h[:hashAbbrevSize])
+}
+
+func (e *rootEntry) String() string {
+	return fmt.Sprintf(rootPrefix+" e=%s l=%s seq=%d sig=%s", e.eroot, e.lroot, e.seq, b64format.EncodeToString(e.sig))
+}
+
+// sigHash returns the hash of the root entry that is covered by the signature.
+func (e *rootEntry) sigHash() []byte {
+	desc := fmt.Sprintf(rootPrefix+" e=%s l=%s seq=%d", e.eroot, e.lroot, e.seq)
+	return crypto.Keccak256([]byte(desc))
+}
+
+// verifySignature checks that the root entry was signed by the given key.
+func (e *rootEntry) verifySignature(pubkey *ecdsa.PublicKey) bool {
+	sig := e.sig[:len(e.sig)-1] // remove recovery id
+	return crypto.VerifySignature(crypto.CompressPubkey(pubkey), e.sigHash(), sig)
+}
+
+func (e *branchEntry) String() string {
+	return branchPrefix + strings.Join(e.children, ",")
+}
+
+func (e *enrEntry) String() string {
+	blob, err := rlp.EncodeToBytes(e.node)
+	if err != nil {
+		panic(fmt.Errorf("can't encode node record: %v", err))
+	}
+	return enrPrefix + b64format.EncodeToString(blob)
+}
+
+func (e *linkEntry) String() string {
+	return linkPrefix + b32format.EncodeToString(crypto.CompressPubkey(e.pubkey)) + "@" + e.domain
+}
+
+// Entry parsing.
+
+// parseEntry decodes the textual form of any non-root tree entry.
+func parseEntry(e string) (entry, error) {
+	switch {
+	case strings.HasPrefix(e, linkPrefix):
+		le, err := parseLink(e)
+		if err != nil {
+			return nil, err
+		}
+		return le, nil
+	case strings.HasPrefix(e, branchPrefix):
+		return parseBranch(e[len(branchPrefix):])
+	case strings.HasPrefix(e, enrPrefix):
+		return parseENR(e[len(enrPrefix):])
+	default:
+		return nil, errUnknownEntry
+	}
+}
+
+// parseRoot decodes the textual form of a root entry.
+func parseRoot(e string) (*rootEntry, error) {
+	var (
+		eroot, lroot, sig string
+		seq               uint
+	)
+	if _, err := fmt.Sscanf(e, rootPrefix+" e=%s l=%s seq=%d sig=%s", &eroot, &lroot, &seq, &sig); err != nil {
+		return nil, entryError{"root", errSyntax}
+	}
+	if !isValidHash(eroot) || !isValidHash(lroot) {
+		return nil, entryError{"root", errInvalidChild}
+	}
+	sigb, err := b64format.DecodeString(sig)
+	if err != nil || len(sigb) != 65 {
+		return nil, entryError{"root", errInvalidSig}
+	}
+	return &rootEntry{eroot: eroot, lroot: lroot, seq: seq, sig: sigb}, nil
+}
+
+// parseLink decodes a tree URL of the form enrtree://<key>@<domain>.
+func parseLink(e string) (*linkEntry, error) {
+	if !strings.HasPrefix(e, linkPrefix) {
+		return nil, fmt.Errorf("wrong/missing scheme 'enrtree' in URL")
+	}
+	e = e[len(linkPrefix):]
+	pos := strings.IndexByte(e, '@')
+	if pos == -1 {
+		return nil, entryError{"link", errNoPubkey}
+	}
+	keystring, domain := e[:pos], e[pos+1:]
+	keybytes, err := b32format.DecodeString(keystring)
+	if err != nil {
+		return nil, entryError{"link", errBadPubkey}
+	}
+	key, err := crypto.DecompressPubkey(keybytes)
+	if err != nil {
+		return nil, entryError{"link", errBadPubkey}
+	}
+	return &linkEntry{domain: domain, pubkey: key}, nil
+}
+
+// parseBranch decodes the comma-separated child hashes of a branch entry.
+func parseBranch(e string) (entry, error) {
+	if e == "" {
+		return &branchEntry{}, nil // empty entry is OK
+	}
+	hashes := strings.Split(e, ",")
+	for _, c := range hashes {
+		if !isValidHash(c) {
+			return nil, entryError{"branch", errInvalidChild}
+		}
+	}
+	return &branchEntry{hashes}, nil
+}
+
+// parseENR decodes a base64 encoded node record, verifying its signature.
+func parseENR(e string) (entry, error) {
+	blob, err := b64format.DecodeString(e)
+	if err != nil {
+		return nil, entryError{"enr", errInvalidENR}
+	}
+	var record enr.Record
+	if err := rlp.DecodeBytes(blob, &record); err != nil {
+		return nil, entryError{"enr", err}
+	}
+	return &enrEntry{&record}, nil
+}
+
+// isValidHash reports whether s is a valid abbreviated entry hash.
+func isValidHash(s string) bool {
+	if len(s) != b32format.EncodedLen(hashAbbrevSize) {
+		return false
+	}
+	_, err := b32format.DecodeString(s)
+	return err == nil
+}
+
+// ParseRecord decodes the textual representation of a node record, which is
+// the URL-safe base64 encoding of its RLP form prefixed with "enr:".
+func ParseRecord(text string) (*enr.Record, error) {
+	if !strings.HasPrefix(text, enrPrefix) {
+		return nil, errInvalidENR
+	}
+	e, err := parseENR(text[len(enrPrefix):])
+	if err != nil {
+		return nil, err
+	}
+	return e.(*enrEntry).node, nil
+}
+
+// entryError wraps errors encountered while parsing a tree entry.
+type entryError struct {
+	typ string
+	err error
+}
+
+func (err entryError) Error() string {
+	return fmt.Sprintf("invalid %s entry: %v", err.typ, err.err)
+}
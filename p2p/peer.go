@@ -98,9 +98,15 @@ type PeerEvent struct {
 // Peer represents a connected remote node.
 type Peer struct {
 	rw      *conn
+	w       MsgWriter // writes to rw, metering the traffic
 	running map[string]*protoRW
 	log     log.Logger
 	created mclock.AbsTime
+	traffic *peerTraffic
+
+	// reputation receives the penalties reported for the peer, nil if the peer
+	// isn't managed by a server
+	reputation *reputation
 
 	wg       sync.WaitGroup
 	protoErr chan error
@@ -155,6 +161,16 @@ func (p *Peer) Disconnect(reason DiscReason) {
 	}
 }
 
+// Penalize reports misbehaviour of the peer to the reputation store of the
+// server. If the penalty score of the peer or its IP address exceeds the limit,
+// it is banned and disconnected.
+func (p *Peer) Penalize(offence Offence, reason string) {
+	if p.reputation.penalize(p.ID(), connIP(p.rw.fd), offence, reason) {
+		p.log.Debug("Disconnecting banned peer", "reason", reason)
+		p.Disconnect(DiscUselessPeer)
+	}
+}
+
 // String implements fmt.Stringer.
 func (p *Peer) String() string {
 	return fmt.Sprintf("Peer %x %v", p.rw.id[:8], p.RemoteAddr())
@@ -162,19 +178,23 @@ func (p *Peer) String() string {
 
 // Inbound returns true if the peer is an inbound connection
 func (p *Peer) Inbound() bool {
-	return p.rw.flags&inboundConn != 0
+	return p.rw.is(inboundConn)
 }
 
 func newPeer(conn *conn, protocols []Protocol) *Peer {
-	protomap := matchProtocols(protocols, conn.caps, conn)
+	traffic := newPeerTraffic()
+	w := &meteredMsgWriter{MsgWriter: conn, traffic: traffic}
+	protomap := matchProtocols(protocols, conn.caps, w)
 	p := &Peer{
 		rw:       conn,
+		w:        w,
 		running:  protomap,
 		created:  mclock.Now(),
+		traffic:  traffic,
 		disc:     make(chan DiscReason),
 		protoErr: make(chan error, len(protomap)+1), // protocols + pingLoop
 		closed:   make(chan struct{}),
-		log:      log.New("id", conn.id, "conn", conn.flags),
+		log:      log.New("id", conn.id, "conn", conn.loadFlags()),
 	}
 	return p
 }
@@ -239,7 +259,7 @@ func (p *Peer) pingLoop() {
 	for {
 		select {
 		case <-ping.C:
-			if err := SendItems(p.rw, pingMsg); err != nil {
+			if err := SendItems(p.w, pingMsg); err != nil {
 				p.protoErr <- err
 				return
 			}
@@ -259,6 +279,7 @@ func (p *Peer) readLoop(errc chan<- error) {
 			return
 		}
 		msg.ReceivedAt = time.Now()
+		p.traffic.markIngress(msg.Code, msg.Size)
 		if err = p.handle(msg); err != nil {
 			errc <- err
 			return
@@ -270,7 +291,7 @@ func (p *Peer) handle(msg Msg) error {
 	switch {
 	case msg.Code == pingMsg:
 		msg.Discard()
-		go SendItems(p.rw, pongMsg)
+		go SendItems(p.w, pongMsg)
 	case msg.Code == discMsg:
 		var reason [1]DiscReason
 		// This is the last message. We don't need to discard or
@@ -309,7 +330,7 @@ func countMatchingProtocols(protocols []Protocol, caps []Cap) int {
 }
 
 // matchProtocols creates structures for matching named subprotocols.
-func matchProtocols(protocols []Protocol, caps []Cap, rw MsgReadWriter) map[string]*protoRW {
+func matchProtocols(protocols []Protocol, caps []Cap, rw MsgWriter) map[string]*protoRW {
 	sort.Sort(capsByNameAndVersion(caps))
 	offset := baseProtocolLength
 	result := make(map[string]*protoRW)
@@ -359,6 +380,18 @@ func (p *Peer) startProtocols(writeStart <-chan struct{}, writeErr chan<- error)
 	}
 }
 
+// msgProtocol returns the name of the protocol a wire message code belongs to
+// and the message code within that protocol.
+func (p *Peer) msgProtocol(code uint64) (string, uint64) {
+	if code < baseProtocolLength {
+		return "p2p", code
+	}
+	if proto, err := p.getProto(code); err == nil {
+		return proto.Name, code - proto.offset
+	}
+	return "unknown", code
+}
+
 // getProto finds the protocol responsible for handling
 // the given message code.
 func (p *Peer) getProto(code uint64) (*protoRW, error) {
@@ -419,7 +452,11 @@ type PeerInfo struct {
 	Network struct {
 		LocalAddress  string `json:"localAddress"`  // Local endpoint of the TCP data connection
 		RemoteAddress string `json:"remoteAddress"` // Remote endpoint of the TCP data connection
+		Inbound       bool   `json:"inbound"`       // Whether the connection was initiated by the remote peer
+		Trusted       bool   `json:"trusted"`       // Whether the peer is in the trusted node set
+		Static        bool   `json:"static"`        // Whether the connection was dialed as a static peer
 	} `json:"network"`
+	Traffic   *PeerTrafficInfo       `json:"traffic"`   // Messages and bytes exchanged with the peer
 	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
 }
 
@@ -439,6 +476,10 @@ func (p *Peer) Info() *PeerInfo {
 	}
 	info.Network.LocalAddress = p.LocalAddr().String()
 	info.Network.RemoteAddress = p.RemoteAddr().String()
+	info.Network.Inbound = p.rw.is(inboundConn)
+	info.Network.Trusted = p.rw.is(trustedConn)
+	info.Network.Static = p.rw.is(staticDialedConn)
+	info.Traffic = p.traffic.info(p.msgProtocol)
 
 	// Gather all the running protocol infos
 	for _, proto := range p.running {
@@ -98,6 +98,52 @@ func TestPeerProtoReadMsg(t *testing.T) {
 	}
 }
 
+func TestPeerTrafficInfo(t *testing.T) {
+	sent, done := make(chan struct{}), make(chan struct{})
+	proto := Protocol{
+		Name:   "a",
+		Length: 5,
+		Run: func(peer *Peer, rw MsgReadWriter) error {
+			if err := ExpectMsg(rw, 2, []uint{1}); err != nil {
+				t.Error(err)
+			}
+			if err := ExpectMsg(rw, 2, []uint{2}); err != nil {
+				t.Error(err)
+			}
+			if err := SendItems(rw, 3, "foo"); err != nil {
+				t.Error(err)
+			}
+			close(sent)
+			<-done
+			return nil
+		},
+	}
+	closer, rw, peer, _ := testPeer([]Protocol{proto})
+	defer closer()
+	defer close(done)
+
+	Send(rw, baseProtocolLength+2, []uint{1})
+	Send(rw, baseProtocolLength+2, []uint{2})
+	if err := ExpectMsg(rw, baseProtocolLength+3, []string{"foo"}); err != nil {
+		t.Fatal(err)
+	}
+	<-sent
+	info := peer.Info().Traffic
+	if info.Ingress.Messages != 2 || info.Ingress.Bytes != 4 {
+		t.Errorf("wrong ingress totals: %+v", info.Ingress)
+	}
+	if info.Egress.Messages != 1 || info.Egress.Bytes != 5 {
+		t.Errorf("wrong egress totals: %+v", info.Egress)
+	}
+	want := map[uint64]*MsgCodeTraffic{
+		2: {Ingress: MsgTraffic{Messages: 2, Bytes: 4}},
+		3: {Egress: MsgTraffic{Messages: 1, Bytes: 5}},
+	}
+	if !reflect.DeepEqual(info.Protocols["a"], want) {
+		t.Errorf("wrong per message traffic of protocol a: %v", info.Protocols["a"])
+	}
+}
+
 func TestPeerProtoEncodeMsg(t *testing.T) {
 	proto := Protocol{
 		Name:   "a",
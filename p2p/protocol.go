@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/p2p/discv5"
 )
 
 // Protocol represents a P2P subprotocol implementation.
@@ -52,6 +53,11 @@ type Protocol struct {
 	// about a certain peer in the network. If an info retrieval function is set,
 	// but returns nil, it is assumed that the protocol handshake is still running.
 	PeerInfo func(id discover.NodeID) interface{}
+
+	// Topic is an optional discovery v5 topic advertised by nodes running the
+	// protocol. If it is set and discovery v5 is enabled, the server searches
+	// the topic and prefers the nodes found over other dial candidates.
+	Topic discv5.Topic
 }
 
 func (p Protocol) cap() Cap {
@@ -0,0 +1,182 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// limitChunkSize is the largest number of bytes a limited connection
+	// transfers at once, so large messages are streamed at the allowed rate
+	// instead of being held back until the whole message fits the limit.
+	limitChunkSize = 4 * 1024
+
+	// maxLimiterDebt caps the time a limiter can be overdrawn for. It bounds
+	// the wait of each transfer when many connections share a limiter, at
+	// the cost of exceeding the rate under heavy contention.
+	maxLimiterDebt = time.Second
+)
+
+// rateLimiter is a token bucket limiting the throughput of a byte stream. The
+// bucket holds at most one second worth of tokens. Callers reserve the bytes
+// they are about to transfer and wait until the bucket has refilled. The bucket
+// is never overdrawn by more than maxLimiterDebt worth of tokens.
+//
+// A nil rateLimiter imposes no limit.
+type rateLimiter struct {
+	lock   sync.Mutex
+	rate   float64 // tokens (bytes) added to the bucket per second
+	tokens float64 // available tokens, negative if the bucket is overdrawn
+	last   time.Time
+	now    func() time.Time // overridden in tests
+}
+
+// newRateLimiter creates a limiter allowing the given number of bytes per
+// second. It returns nil if rate is not positive.
+func newRateLimiter(rate int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: float64(rate), tokens: float64(rate), last: time.Now(), now: time.Now}
+}
+
+// reserve takes n tokens from the bucket and returns how long the caller must
+// wait before the transfer is within the rate limit.
+func (l *rateLimiter) reserve(n int) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// Refill the bucket for the time passed since the last reservation.
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	if debt := -l.rate * maxLimiterDebt.Seconds(); l.tokens < debt {
+		l.tokens = debt
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// limiterSet is the set of rate limiters applying to one direction of a
+// connection, typically the global limit of the server and the per peer limit.
+type limiterSet []*rateLimiter
+
+// wait reserves n bytes from all limiters of the set and blocks until the
+// transfer is allowed by all of them. It returns the time spent waiting.
+func (ls limiterSet) wait(n int) time.Duration {
+	var delay time.Duration
+	for _, l := range ls {
+		if d := l.reserve(n); d > delay {
+			delay = d
+		}
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return delay
+}
+
+// chunkSize returns the largest number of bytes to transfer at once through the
+// limiters of the set, which never exceeds the bucket size of any of them.
+func (ls limiterSet) chunkSize() int {
+	size := limitChunkSize
+	for _, l := range ls {
+		if l != nil && int(l.rate) < size {
+			size = int(l.rate)
+		}
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// active reports whether any limiter of the set imposes a limit.
+func (ls limiterSet) active() bool {
+	for _, l := range ls {
+		if l != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedConn is a connection whose reads and writes are throttled by rate
+// limiters. Data is transferred in small chunks, each waiting for the limiters
+// in turn, so the remote end sees a steady stream and the limiters are never
+// overdrawn by a single large message. The read or write deadline is extended
+// after waiting, so time spent throttled doesn't count against it.
+type limitedConn struct {
+	net.Conn
+	ingress, egress limiterSet
+}
+
+// newLimitedConn wraps a connection with the given limits, returning it as is
+// if there are none.
+func newLimitedConn(fd net.Conn, ingress, egress limiterSet) net.Conn {
+	if !ingress.active() && !egress.active() {
+		return fd
+	}
+	return &limitedConn{Conn: fd, ingress: ingress, egress: egress}
+}
+
+func (c *limitedConn) Read(b []byte) (int, error) {
+	if size := c.ingress.chunkSize(); len(b) > size {
+		b = b[:size]
+	}
+	n, err := c.Conn.Read(b)
+	if n > 0 && c.ingress.wait(n) > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(frameReadTimeout))
+	}
+	return n, err
+}
+
+func (c *limitedConn) Write(b []byte) (int, error) {
+	var (
+		size    = c.egress.chunkSize()
+		written int
+	)
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > size {
+			chunk = chunk[:size]
+		}
+		if c.egress.wait(len(chunk)) > 0 {
+			c.Conn.SetWriteDeadline(time.Now().Add(frameWriteTimeout))
+		}
+		n, err := c.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
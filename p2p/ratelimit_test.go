@@ -0,0 +1,219 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	var (
+		now = time.Unix(0, 0)
+		l   = newRateLimiter(1000)
+	)
+	l.now = func() time.Time { return now }
+	l.last = now
+
+	// The full bucket allows a burst of one second worth of bytes.
+	if d := l.reserve(1000); d != 0 {
+		t.Fatalf("unexpected delay for burst: %v", d)
+	}
+	// The bucket is empty now, further transfers must wait.
+	if d := l.reserve(500); d != 500*time.Millisecond {
+		t.Fatalf("wrong delay: got %v, want %v", d, 500*time.Millisecond)
+	}
+	// Transfers larger than the bucket are allowed, but the debt is capped.
+	now = now.Add(500 * time.Millisecond)
+	if d := l.reserve(3000); d != maxLimiterDebt {
+		t.Fatalf("wrong delay for large transfer: got %v, want %v", d, maxLimiterDebt)
+	}
+	// Idle time refills the bucket, but never beyond one second worth of bytes.
+	now = now.Add(time.Hour)
+	if d := l.reserve(1000); d != 0 {
+		t.Fatalf("unexpected delay after refill: %v", d)
+	}
+	if d := l.reserve(1); d == 0 {
+		t.Fatal("bucket refilled beyond its size")
+	}
+	// A nil limiter doesn't limit anything.
+	if l := newRateLimiter(0); l != nil || l.reserve(1<<30) != 0 {
+		t.Fatal("zero rate should disable the limiter")
+	}
+}
+
+// This test checks that the egress limit of an RLPx connection slows down
+// message writes without failing them.
+func TestRLPXEgressLimit(t *testing.T) {
+	fd0, fd1 := net.Pipe()
+	c0, c1 := newRLPX(fd0).(*rlpx), newRLPX(fd1).(*rlpx)
+	c0.egressLimit = limiterSet{newRateLimiter(10000)}
+	prv0, prv1 := newkey(), newkey()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c1.doEncHandshake(prv1, nil)
+		errc <- err
+	}()
+	if _, err := c0.doEncHandshake(prv0, &discover.Node{ID: discover.PubkeyID(&prv1.PublicKey)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	// Send three messages of 5000 bytes. The first one fits the initial burst,
+	// the others have to wait for the bucket to refill.
+	payload := make([]byte, 5000)
+	go func() {
+		for i := 0; i < 3; i++ {
+			if err := Send(c0, 0x10, payload); err != nil {
+				errc <- err
+				return
+			}
+		}
+		errc <- nil
+	}()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		msg, err := c1.ReadMsg()
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg.Discard()
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("messages written too fast: %v", elapsed)
+	}
+}
+
+// firstReadConn records when data was first read from a connection.
+type firstReadConn struct {
+	net.Conn
+	first chan struct{}
+	once  sync.Once
+}
+
+func (c *firstReadConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.once.Do(func() { close(c.first) })
+	}
+	return n, err
+}
+
+// This test checks that a message much larger than the egress limit of an RLPx
+// connection is streamed to the remote end at the allowed rate, instead of being
+// held back until the whole message fits, and that the connection survives it.
+func TestRLPXEgressLimitLargeMessage(t *testing.T) {
+	fd0, fd1 := net.Pipe()
+	c0, c1 := newRLPX(fd0).(*rlpx), newRLPX(fd1).(*rlpx)
+	global := newRateLimiter(1000000)
+	c0.egressLimit = limiterSet{global, newRateLimiter(20000)}
+	prv0, prv1 := newkey(), newkey()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c1.doEncHandshake(prv1, nil)
+		errc <- err
+	}()
+	if _, err := c0.doEncHandshake(prv0, &discover.Node{ID: discover.PubkeyID(&prv1.PublicKey)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	recv := &firstReadConn{Conn: fd1, first: make(chan struct{})}
+	c1.rw.conn = recv
+
+	// Send a message taking about a second beyond the burst of the limiter.
+	payload := make([]byte, 40000)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	start := time.Now()
+	go func() {
+		errc <- Send(c0, 0x10, payload)
+	}()
+	readc := make(chan Msg, 1)
+	go func() {
+		msg, err := c1.ReadMsg()
+		if err != nil {
+			errc <- err
+			return
+		}
+		readc <- msg
+	}()
+	select {
+	case <-recv.first:
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("message held back before streaming: %v", elapsed)
+		}
+	case err := <-errc:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no data received")
+	}
+	var msg Msg
+	select {
+	case msg = <-readc:
+	case err := <-errc:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+	if elapsed := time.Since(start); elapsed < 800*time.Millisecond {
+		t.Errorf("message written too fast: %v", elapsed)
+	}
+	var content []byte
+	if err := msg.Decode(&content); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, payload) {
+		t.Fatal("message content mismatch")
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	// The shared limiter must not be overdrawn beyond the cap, and the
+	// connection must still work in both directions.
+	global.lock.Lock()
+	if global.tokens < -global.rate*maxLimiterDebt.Seconds() {
+		t.Errorf("global limiter overdrawn: %v tokens", global.tokens)
+	}
+	global.lock.Unlock()
+
+	go func() { errc <- Send(c0, 0x11, []uint{1}) }()
+	if err := ExpectMsg(c1, 0x11, []uint{1}); err != nil {
+		t.Fatal(err)
+	}
+	go func() { errc <- Send(c1, 0x12, []uint{2}) }()
+	if err := ExpectMsg(c0, 0x12, []uint{2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
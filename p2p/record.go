@@ -0,0 +1,137 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"encoding/base64"
+	"net"
+	"reflect"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/p2p/enr"
+	"github.com/Rue-Foundation/go-rue/rlp"
+)
+
+// natRefreshInterval is the time interval between checks of the external IP
+// address reported by the NAT gateway.
+const natRefreshInterval = 5 * time.Minute
+
+// recordInfo contains the details announced in the local node record. It is
+// used to detect when the record needs to be re-signed.
+type recordInfo struct {
+	IP       net.IP
+	TCP, UDP uint16
+	Caps     []Cap
+}
+
+// Record returns the signed node record of the local node, or nil if the
+// server was not started yet.
+func (srv *Server) Record() *enr.Record {
+	srv.recordLock.Lock()
+	defer srv.recordLock.Unlock()
+
+	return srv.record
+}
+
+// updateRecord assembles the local node record from the current endpoint and
+// protocol details, and re-signs it with an increased sequence number if any
+// of them changed. If ip is nil, the address of the local node is used.
+func (srv *Server) updateRecord(ip net.IP) {
+	srv.recordLock.Lock()
+	defer srv.recordLock.Unlock()
+
+	self := srv.makeSelf(srv.listener, srv.ntab)
+	if ip == nil {
+		ip = self.IP
+	}
+	info := recordInfo{IP: ip, TCP: self.TCP, UDP: self.UDP, Caps: srv.ourHandshake.Caps}
+	if srv.record != nil && reflect.DeepEqual(info, srv.recordInfo) {
+		return
+	}
+	// Something changed, assemble and sign a new version of the record
+	var record enr.Record
+	if ip4 := ip.To4(); ip4 != nil {
+		record.Set(enr.IP4(ip4))
+	} else if len(ip) == net.IPv6len {
+		record.Set(enr.IP6(ip))
+	}
+	record.Set(enr.TCP(info.TCP))
+	record.Set(enr.UDP(info.UDP))
+	record.Set(enr.WithEntry("caps", info.Caps))
+
+	switch {
+	case srv.record != nil:
+		record.SetSeq(srv.record.Seq())
+	case srv.ntab != nil && srv.ntab.Record() != nil:
+		// Continue the sequence of the record signed before a restart
+		record.SetSeq(srv.ntab.Record().Seq())
+	}
+	if err := record.Sign(srv.PrivateKey); err != nil {
+		srv.log.Error("Failed to sign node record", "err", err)
+		return
+	}
+	srv.record, srv.recordInfo = &record, info
+
+	if srv.ntab != nil {
+		if err := srv.ntab.SetRecord(&record); err != nil {
+			srv.log.Warn("Failed to update discovery node record", "err", err)
+		}
+	}
+	srv.log.Debug("Updated local node record", "seq", record.Seq(), "ip", ip, "tcp", info.TCP, "udp", info.UDP)
+}
+
+// natRefreshLoop runs in its own goroutine, periodically checking the external
+// IP address reported by the NAT gateway and re-signing the local node record
+// whenever it changes.
+func (srv *Server) natRefreshLoop() {
+	defer srv.loopWG.Done()
+
+	var ip net.IP
+	refresh := time.NewTimer(0)
+	defer refresh.Stop()
+
+	for {
+		select {
+		case <-refresh.C:
+			ext, err := srv.NAT.ExternalIP()
+			if err != nil {
+				srv.log.Debug("Couldn't query external IP", "interface", srv.NAT, "err", err)
+			} else if !ext.Equal(ip) {
+				ip = ext
+				srv.log.Debug("External IP changed", "ip", ip)
+				srv.updateRecord(ip)
+			}
+			refresh.Reset(natRefreshInterval)
+
+		case <-srv.quit:
+			return
+		}
+	}
+}
+
+// encodeRecord returns the textual representation of a node record, which is
+// the URL-safe base64 encoding of its RLP form prefixed with "enr:".
+func encodeRecord(record *enr.Record) string {
+	if record == nil {
+		return ""
+	}
+	blob, err := rlp.EncodeToBytes(record)
+	if err != nil {
+		return ""
+	}
+	return "enr:" + base64.RawURLEncoding.EncodeToString(blob)
+}
@@ -0,0 +1,311 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/log"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/rlp"
+)
+
+const (
+	banThreshold    = 100              // penalty score at which a node is banned
+	ipBanThreshold  = 3 * banThreshold // penalty score at which an IP address is banned
+	scoreHalfLife   = 30 * time.Minute // time after which half of a penalty is forgiven
+	tempBanDuration = time.Hour        // duration of the first automatic ban of a target
+	maxTempBans     = 5                // automatic bans of a target before it's banned permanently
+	maxScoreAge     = 24 * time.Hour   // time after which idle penalty scores are dropped
+	maxBanAge       = 30 * maxScoreAge // time after the expiry of a ban when its count is forgotten
+	scoreCleanup    = 1000             // number of penalties between cleanups of idle scores
+)
+
+// Offence classifies misbehaviour of a peer reported by a protocol handler.
+type Offence int
+
+const (
+	// OffenceMinor is misbehaviour which might be caused by a bug or a race, such
+	// as sending invalid transactions. Peers are banned after repeated offences.
+	OffenceMinor Offence = iota
+
+	// OffenceMajor is a clear protocol violation, like a malformed message.
+	OffenceMajor
+
+	// OffenceSevere is misbehaviour which can only be malicious, like relaying an
+	// invalid block. The peer is banned immediately.
+	OffenceSevere
+)
+
+// offencePenalty is the score added for an offence of each class.
+var offencePenalty = [...]float64{
+	OffenceMinor:  10,
+	OffenceMajor:  40,
+	OffenceSevere: banThreshold,
+}
+
+// BanInfo describes an active ban of a node or an IP address.
+type BanInfo struct {
+	Target  string     `json:"target"`            // Banned node ID or IP address
+	Reason  string     `json:"reason"`            // Reason given for the ban
+	Expires *time.Time `json:"expires,omitempty"` // Expiry of the ban, nil for permanent bans
+}
+
+// banDB is implemented by discovery tables which can persist bans in the node
+// database.
+type banDB interface {
+	Bans() map[string][]byte
+	StoreBan(target string, blob []byte) error
+	DeleteBan(target string) error
+}
+
+// ban is the persisted form of a ban. Expired bans are kept for a while to
+// remember the number of automatic bans of the target.
+type ban struct {
+	Reason  string
+	Expires uint64 // unix time, zero for permanent bans
+	Count   uint   // number of automatic bans of the target so far
+}
+
+// expired reports whether a temporary ban is over.
+func (b *ban) expired(now time.Time) bool {
+	return b.Expires != 0 && int64(b.Expires) <= now.Unix()
+}
+
+// penaltyScore tracks the decaying penalty score of a ban target.
+type penaltyScore struct {
+	value   float64
+	updated time.Time
+}
+
+// reputation keeps track of the misbehaviour of remote nodes by node ID and IP
+// address, and bans the ones exceeding the penalty threshold. It is safe for
+// concurrent use.
+type reputation struct {
+	lock      sync.Mutex
+	db        banDB // nil if bans aren't persisted
+	scores    map[string]*penaltyScore
+	bans      map[string]*ban
+	penalties int
+	now       func() time.Time // overridden in tests
+	log       log.Logger
+}
+
+// newReputation creates a reputation store, loading the bans persisted in db
+// if it is non-nil.
+func newReputation(db banDB, logger log.Logger) *reputation {
+	r := &reputation{
+		db:     db,
+		scores: make(map[string]*penaltyScore),
+		bans:   make(map[string]*ban),
+		now:    time.Now,
+		log:    logger,
+	}
+	if db != nil {
+		for target, blob := range db.Bans() {
+			b := new(ban)
+			if err := rlp.DecodeBytes(blob, b); err != nil {
+				r.log.Warn("Dropping invalid peer ban", "target", target, "err", err)
+				db.DeleteBan(target)
+				continue
+			}
+			r.bans[target] = b
+		}
+	}
+	return r
+}
+
+// nodeTarget and ipTarget return the ban target keys of a node ID and an IP.
+func nodeTarget(id discover.NodeID) string { return "node:" + id.String() }
+func ipTarget(ip net.IP) string            { return "ip:" + ip.String() }
+
+// penalize adds the penalty of an offence to the scores of the node and its IP
+// address. It returns true if the node or its IP got banned as a result.
+func (r *reputation) penalize(id discover.NodeID, ip net.IP, offence Offence, reason string) bool {
+	if r == nil {
+		return false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	if r.penalties++; r.penalties%scoreCleanup == 0 {
+		r.dropIdleScores(now)
+	}
+	penalty := offencePenalty[offence]
+	banned := r.addPenalty(nodeTarget(id), penalty, banThreshold, reason, now)
+	if ip != nil {
+		banned = r.addPenalty(ipTarget(ip), penalty, ipBanThreshold, reason, now) || banned
+	}
+	return banned
+}
+
+// addPenalty increases the score of a target, banning it if the score reaches
+// the threshold. Automatic bans double in duration every time until the target
+// is banned permanently, even if the score was dropped in between. The lock must
+// be held.
+func (r *reputation) addPenalty(target string, penalty, threshold float64, reason string, now time.Time) bool {
+	s := r.scores[target]
+	if s == nil {
+		s = &penaltyScore{updated: now}
+		r.scores[target] = s
+	}
+	s.value *= math.Exp2(-float64(now.Sub(s.updated)) / float64(scoreHalfLife))
+	s.value += penalty
+	s.updated = now
+	if s.value < threshold {
+		return false
+	}
+	s.value = 0
+
+	count := uint(1)
+	if b := r.bans[target]; b != nil {
+		count = b.Count + 1
+	}
+	var duration time.Duration
+	if count <= maxTempBans {
+		duration = tempBanDuration << (count - 1)
+	}
+	r.log.Debug("Banning misbehaving peer", "target", target, "duration", duration, "reason", reason)
+	r.addBan(target, duration, reason, count, now)
+	return true
+}
+
+// dropIdleScores forgets the scores of targets which weren't penalized for a
+// long time, and the bans which expired long ago. The lock must be held.
+func (r *reputation) dropIdleScores(now time.Time) {
+	for target, s := range r.scores {
+		if now.Sub(s.updated) > maxScoreAge {
+			delete(r.scores, target)
+		}
+	}
+	for target, b := range r.bans {
+		if b.expired(now.Add(-maxBanAge)) {
+			r.removeBan(target)
+		}
+	}
+}
+
+// addBan bans a target for the given duration, or permanently if the duration
+// is zero, recording the number of automatic bans so far. The lock must be held.
+func (r *reputation) addBan(target string, duration time.Duration, reason string, count uint, now time.Time) {
+	b := &ban{Reason: reason, Count: count}
+	if duration > 0 {
+		b.Expires = uint64(now.Add(duration).Unix())
+	}
+	r.bans[target] = b
+	if r.db != nil {
+		blob, _ := rlp.EncodeToBytes(b)
+		if err := r.db.StoreBan(target, blob); err != nil {
+			r.log.Warn("Failed to persist peer ban", "target", target, "err", err)
+		}
+	}
+}
+
+// activeBan returns the ban of a target if it hasn't expired yet. Expired bans
+// are kept until dropIdleScores forgets them. The lock must be held.
+func (r *reputation) activeBan(target string, now time.Time) *ban {
+	b := r.bans[target]
+	if b == nil || b.expired(now) {
+		return nil
+	}
+	return b
+}
+
+// removeBan deletes the ban of a target. The lock must be held.
+func (r *reputation) removeBan(target string) bool {
+	if _, ok := r.bans[target]; !ok {
+		return false
+	}
+	delete(r.bans, target)
+	if r.db != nil {
+		if err := r.db.DeleteBan(target); err != nil {
+			r.log.Warn("Failed to delete peer ban", "target", target, "err", err)
+		}
+	}
+	return true
+}
+
+// isBanned reports whether the node or the IP address is banned.
+func (r *reputation) isBanned(id discover.NodeID, ip net.IP) bool {
+	if r == nil {
+		return false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	if r.activeBan(nodeTarget(id), now) != nil {
+		return true
+	}
+	return ip != nil && r.activeBan(ipTarget(ip), now) != nil
+}
+
+// ban bans a target for the given duration, or permanently if the duration is
+// zero.
+func (r *reputation) ban(target string, duration time.Duration, reason string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var count uint
+	if b := r.bans[target]; b != nil {
+		count = b.Count
+	}
+	r.addBan(target, duration, reason, count, r.now())
+}
+
+// unban lifts the ban of a target and forgets its penalty score and previous
+// bans. It returns false if the target wasn't banned.
+func (r *reputation) unban(target string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.scores, target)
+	banned := r.activeBan(target, r.now()) != nil
+	r.removeBan(target)
+	return banned
+}
+
+// list returns all active bans, sorted by target.
+func (r *reputation) list() []*BanInfo {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var (
+		now   = r.now()
+		infos = make([]*BanInfo, 0, len(r.bans))
+	)
+	for target := range r.bans {
+		b := r.activeBan(target, now)
+		if b == nil {
+			continue
+		}
+		info := &BanInfo{Target: target[strings.IndexByte(target, ':')+1:], Reason: b.Reason}
+		if b.Expires != 0 {
+			expires := time.Unix(int64(b.Expires), 0)
+			info.Expires = &expires
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Target < infos[j].Target })
+	return infos
+}
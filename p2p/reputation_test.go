@@ -0,0 +1,222 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/log"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+)
+
+// memoryBanDB is an in-memory banDB.
+type memoryBanDB map[string][]byte
+
+func (db memoryBanDB) Bans() map[string][]byte {
+	cpy := make(map[string][]byte, len(db))
+	for k, v := range db {
+		cpy[k] = v
+	}
+	return cpy
+}
+func (db memoryBanDB) StoreBan(target string, blob []byte) error { db[target] = blob; return nil }
+func (db memoryBanDB) DeleteBan(target string) error             { delete(db, target); return nil }
+
+func newTestReputation(db banDB) (*reputation, *time.Time) {
+	now := time.Unix(1000000, 0)
+	r := newReputation(db, log.Root())
+	r.now = func() time.Time { return now }
+	return r, &now
+}
+
+func TestReputationPenalties(t *testing.T) {
+	var (
+		r, now = newTestReputation(nil)
+		id     = discover.NodeID{1}
+		ip     = net.IP{10, 0, 0, 1}
+	)
+	// Minor offences only lead to a ban after repeating.
+	for i := 0; i < 9; i++ {
+		if r.penalize(id, ip, OffenceMinor, "minor") {
+			t.Fatalf("banned after %d minor offences", i+1)
+		}
+	}
+	if r.isBanned(id, ip) {
+		t.Fatal("node banned below the threshold")
+	}
+	// Penalties decay over time.
+	*now = now.Add(4 * scoreHalfLife)
+	if r.penalize(id, ip, OffenceMinor, "minor") {
+		t.Fatal("banned although the score should have decayed")
+	}
+	// Severe offences ban immediately, but not the IP address.
+	if !r.penalize(id, ip, OffenceSevere, "severe") {
+		t.Fatal("not banned after severe offence")
+	}
+	if !r.isBanned(id, nil) {
+		t.Fatal("node not banned")
+	}
+	if r.isBanned(discover.NodeID{2}, ip) {
+		t.Fatal("IP address banned after a single node ban")
+	}
+	// The ban expires after the temporary ban duration.
+	*now = now.Add(tempBanDuration)
+	if r.isBanned(id, ip) {
+		t.Fatal("temporary ban didn't expire")
+	}
+}
+
+func TestReputationEscalation(t *testing.T) {
+	var (
+		r, now = newTestReputation(nil)
+		id     = discover.NodeID{1}
+	)
+	for i := 0; i < maxTempBans; i++ {
+		r.penalize(id, nil, OffenceSevere, "severe")
+		duration := tempBanDuration << uint(i)
+		*now = now.Add(duration - time.Second)
+		if !r.isBanned(id, nil) {
+			t.Fatalf("ban %d expired too early", i+1)
+		}
+		*now = now.Add(time.Second)
+		if r.isBanned(id, nil) {
+			t.Fatalf("ban %d didn't expire after %v", i+1, duration)
+		}
+	}
+	r.penalize(id, nil, OffenceSevere, "severe")
+	*now = now.Add(365 * 24 * time.Hour)
+	if !r.isBanned(id, nil) {
+		t.Fatal("node not banned permanently after repeated bans")
+	}
+	if bans := r.list(); len(bans) != 1 || bans[0].Expires != nil {
+		t.Fatalf("wrong ban list: %v", bans)
+	}
+}
+
+func TestReputationSlowEscalation(t *testing.T) {
+	var (
+		db     = make(memoryBanDB)
+		r, now = newTestReputation(db)
+		id     = discover.NodeID{1}
+	)
+	// Offend again only after the idle score was dropped, reloading the bans
+	// from the database every time.
+	for i := 0; i < maxTempBans; i++ {
+		r.penalize(id, nil, OffenceSevere, "severe")
+		*now = now.Add(tempBanDuration<<uint(i) + 2*maxScoreAge)
+		r.lock.Lock()
+		r.dropIdleScores(*now)
+		r.lock.Unlock()
+		if r.isBanned(id, nil) {
+			t.Fatalf("ban %d didn't expire", i+1)
+		}
+		r, _ = newTestReputation(db)
+		r.now = func() time.Time { return *now }
+	}
+	r.penalize(id, nil, OffenceSevere, "severe")
+	if bans := r.list(); len(bans) != 1 || bans[0].Expires != nil {
+		t.Fatalf("slow repeat offender not banned permanently: %v", bans)
+	}
+	// Expired bans are forgotten eventually.
+	r.unban(nodeTarget(id))
+	r.penalize(id, nil, OffenceSevere, "severe")
+	*now = now.Add(tempBanDuration + maxBanAge + time.Second)
+	r.lock.Lock()
+	r.dropIdleScores(*now)
+	r.lock.Unlock()
+	if len(db) != 0 {
+		t.Fatalf("expired ban not forgotten: %d persisted", len(db))
+	}
+}
+
+func TestReputationIPBan(t *testing.T) {
+	r, _ := newTestReputation(nil)
+	ip := net.IP{10, 0, 0, 1}
+
+	// Each node gets banned, the shared IP address only after three of them.
+	for i := byte(0); i < 3; i++ {
+		if r.isBanned(discover.NodeID{i + 10}, ip) {
+			t.Fatalf("IP address banned after %d node bans", i)
+		}
+		r.penalize(discover.NodeID{i}, ip, OffenceSevere, "severe")
+	}
+	if !r.isBanned(discover.NodeID{10}, ip) {
+		t.Fatal("IP address not banned")
+	}
+}
+
+func TestReputationPersistence(t *testing.T) {
+	db := make(memoryBanDB)
+	r, _ := newTestReputation(db)
+	r.ban(nodeTarget(discover.NodeID{1}), 0, "forever")
+	r.ban(ipTarget(net.IP{10, 0, 0, 1}), time.Hour, "for a while")
+
+	// Bans are loaded from the database.
+	r2, _ := newTestReputation(db)
+	bans := r2.list()
+	if len(bans) != 2 {
+		t.Fatalf("wrong number of bans loaded: %d", len(bans))
+	}
+	if bans[0].Target != (discover.NodeID{1}).String() || bans[0].Reason != "forever" || bans[0].Expires != nil {
+		t.Errorf("wrong node ban: %+v", bans[0])
+	}
+	if bans[1].Target != "10.0.0.1" || bans[1].Reason != "for a while" || bans[1].Expires == nil {
+		t.Errorf("wrong IP ban: %+v", bans[1])
+	}
+	// Unbanning removes the ban from the database.
+	if !r2.unban(nodeTarget(discover.NodeID{1})) {
+		t.Fatal("unban returned false for banned node")
+	}
+	if r2.unban(nodeTarget(discover.NodeID{1})) {
+		t.Fatal("unban returned true for unbanned node")
+	}
+	if len(db) != 1 {
+		t.Fatalf("wrong number of persisted bans: %d", len(db))
+	}
+}
+
+func TestServerBannedConn(t *testing.T) {
+	srv := &Server{
+		Config: Config{
+			PrivateKey: newkey(),
+			MaxPeers:   10,
+			NoDial:     true,
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	id := randomID()
+	newconn := func() *conn {
+		fd, _ := net.Pipe()
+		return &conn{fd: fd, transport: newTestTransport(id, fd), flags: inboundConn, id: id, cont: make(chan error)}
+	}
+	srv.BanNode(id, 0, "test")
+	if err := srv.checkpoint(newconn(), srv.posthandshake); err != DiscUselessPeer {
+		t.Fatalf("wrong error for banned node: %v", err)
+	}
+	if !srv.UnbanNode(id) {
+		t.Fatal("node wasn't banned")
+	}
+	if err := srv.checkpoint(newconn(), srv.posthandshake); err != nil {
+		t.Fatalf("unexpected error for unbanned node: %v", err)
+	}
+}
@@ -81,6 +81,9 @@ type rlpx struct {
 
 	rmu, wmu sync.Mutex
 	rw       *rlpxFrameRW
+
+	// Bandwidth limits applied to the connection after the encryption handshake.
+	ingressLimit, egressLimit limiterSet
 }
 
 func newRLPX(fd net.Conn) transport {
@@ -183,7 +186,7 @@ func (t *rlpx) doEncHandshake(prv *ecdsa.PrivateKey, dial *discover.Node) (disco
 		return discover.NodeID{}, err
 	}
 	t.wmu.Lock()
-	t.rw = newRLPXFrameRW(t.fd, sec)
+	t.rw = newRLPXFrameRW(newLimitedConn(t.fd, t.ingressLimit, t.egressLimit), sec)
 	t.wmu.Unlock()
 	return sec.RemoteID, nil
 }
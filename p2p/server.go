@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Rue-Foundation/go-rue/common"
@@ -31,6 +32,8 @@ import (
 	"github.com/Rue-Foundation/go-rue/log"
 	"github.com/Rue-Foundation/go-rue/p2p/discover"
 	"github.com/Rue-Foundation/go-rue/p2p/discv5"
+	"github.com/Rue-Foundation/go-rue/p2p/dnsdisc"
+	"github.com/Rue-Foundation/go-rue/p2p/enr"
 	"github.com/Rue-Foundation/go-rue/p2p/nat"
 	"github.com/Rue-Foundation/go-rue/p2p/netutil"
 )
@@ -81,6 +84,10 @@ type Config struct {
 	// Listener address for the V5 discovery protocol UDP traffic.
 	DiscoveryV5Addr string `toml:",omitempty"`
 
+	// DNSDiscovery contains the enrtree:// URLs of signed node lists published
+	// in DNS, which are used as an additional source of dial candidates.
+	DNSDiscovery []string `toml:",omitempty"`
+
 	// Name sets the node name of this server.
 	// Use common.MakeName to create a name that follows existing conventions.
 	Name string `toml:"-"`
@@ -111,6 +118,16 @@ type Config struct {
 	// live nodes in the network.
 	NodeDatabase string `toml:",omitempty"`
 
+	// MaxIngressRate and MaxEgressRate limit the total download and upload
+	// bandwidth of all peer connections in bytes per second. Zero means no limit.
+	MaxIngressRate int `toml:",omitempty"`
+	MaxEgressRate  int `toml:",omitempty"`
+
+	// PeerIngressRate and PeerEgressRate limit the download and upload bandwidth
+	// of each peer connection in bytes per second. Zero means no limit.
+	PeerIngressRate int `toml:",omitempty"`
+	PeerEgressRate  int `toml:",omitempty"`
+
 	// Protocols should contain the protocols supported
 	// by the server. Matching protocols are launched for
 	// each peer.
@@ -158,11 +175,22 @@ type Server struct {
 	running bool
 
 	ntab         discoverTable
+	dnsdisc      nodeSource
+	topicSources []nodeSource
 	listener     net.Listener
 	ourHandshake *protoHandshake
 	lastLookup   time.Time
 	DiscV5       *discv5.Network
 
+	// Global bandwidth limits, shared by all connections.
+	ingressLimit, egressLimit *rateLimiter
+
+	reputation *reputation // penalty scores and bans of remote nodes
+
+	recordLock sync.Mutex  // protects record and recordInfo
+	record     *enr.Record // signed node record of the local node
+	recordInfo recordInfo  // details announced in the current record
+
 	// These are for Peers, PeerCount (and nothing else).
 	peerOp     chan peerOpFunc
 	peerOpDone chan struct{}
@@ -170,6 +198,8 @@ type Server struct {
 	quit          chan struct{}
 	addstatic     chan *discover.Node
 	removestatic  chan *discover.Node
+	addtrusted    chan *discover.Node
+	removetrusted chan *discover.Node
 	posthandshake chan *conn
 	addpeer       chan *conn
 	delpeer       chan peerDrop
@@ -186,7 +216,7 @@ type peerDrop struct {
 	requested bool // true if signaled by the peer
 }
 
-type connFlag int
+type connFlag int32
 
 const (
 	dynDialedConn connFlag = 1 << iota
@@ -200,7 +230,7 @@ const (
 type conn struct {
 	fd net.Conn
 	transport
-	flags connFlag
+	flags connFlag        // accessed atomically, trusted flag may change while the peer runs
 	cont  chan error      // The run loop uses cont to signal errors to SetupConn.
 	id    discover.NodeID // valid after the encryption handshake
 	caps  []Cap           // valid after the protocol handshake
@@ -222,7 +252,7 @@ type transport interface {
 }
 
 func (c *conn) String() string {
-	s := c.flags.String()
+	s := c.loadFlags().String()
 	if (c.id != discover.NodeID{}) {
 		s += " " + c.id.String()
 	}
@@ -250,8 +280,30 @@ func (f connFlag) String() string {
 	return s
 }
 
+// loadFlags retrieves the flags of the connection atomically, as trusted peers
+// can be changed at runtime.
+func (c *conn) loadFlags() connFlag {
+	return connFlag(atomic.LoadInt32((*int32)(&c.flags)))
+}
+
 func (c *conn) is(f connFlag) bool {
-	return c.flags&f != 0
+	return c.loadFlags()&f != 0
+}
+
+// set sets or clears the given flags of the connection.
+func (c *conn) set(f connFlag, val bool) {
+	for {
+		oldFlags := c.loadFlags()
+		flags := oldFlags
+		if val {
+			flags |= f
+		} else {
+			flags &= ^f
+		}
+		if atomic.CompareAndSwapInt32((*int32)(&c.flags), int32(oldFlags), int32(flags)) {
+			return
+		}
+	}
 }
 
 // Peers returns all connected peers.
@@ -301,6 +353,81 @@ func (srv *Server) RemovePeer(node *discover.Node) {
 	}
 }
 
+// AddTrustedPeer adds the given node to a reserved whitelist which allows the
+// node to always connect, even if the slots are full. The change also applies
+// to the node if it is already connected.
+func (srv *Server) AddTrustedPeer(node *discover.Node) {
+	select {
+	case srv.addtrusted <- node:
+	case <-srv.quit:
+	}
+}
+
+// RemoveTrustedPeer removes the given node from the trusted peer set. An
+// existing connection to the node is kept, but it counts against the peer
+// limit from now on.
+func (srv *Server) RemoveTrustedPeer(node *discover.Node) {
+	select {
+	case srv.removetrusted <- node:
+	case <-srv.quit:
+	}
+}
+
+// BanNode bans the given node for the given duration, or permanently if the
+// duration is zero. The node is disconnected if it is connected.
+func (srv *Server) BanNode(id discover.NodeID, duration time.Duration, reason string) {
+	if srv.reputation == nil {
+		return
+	}
+	srv.reputation.ban(nodeTarget(id), duration, reason)
+	for _, p := range srv.Peers() {
+		if p.ID() == id {
+			p.Disconnect(DiscUselessPeer)
+		}
+	}
+}
+
+// BanIP bans all nodes connecting from or listening on the given IP address for
+// the given duration, or permanently if the duration is zero. Connected peers
+// using the address are disconnected.
+func (srv *Server) BanIP(ip net.IP, duration time.Duration, reason string) {
+	if srv.reputation == nil {
+		return
+	}
+	srv.reputation.ban(ipTarget(ip), duration, reason)
+	for _, p := range srv.Peers() {
+		if ip.Equal(connIP(p.rw.fd)) {
+			p.Disconnect(DiscUselessPeer)
+		}
+	}
+}
+
+// UnbanNode lifts the ban of the given node. It returns false if the node
+// wasn't banned.
+func (srv *Server) UnbanNode(id discover.NodeID) bool {
+	if srv.reputation == nil {
+		return false
+	}
+	return srv.reputation.unban(nodeTarget(id))
+}
+
+// UnbanIP lifts the ban of the given IP address. It returns false if the
+// address wasn't banned.
+func (srv *Server) UnbanIP(ip net.IP) bool {
+	if srv.reputation == nil {
+		return false
+	}
+	return srv.reputation.unban(ipTarget(ip))
+}
+
+// Bans returns the active bans of nodes and IP addresses.
+func (srv *Server) Bans() []*BanInfo {
+	if srv.reputation == nil {
+		return nil
+	}
+	return srv.reputation.list()
+}
+
 // SubscribePeers subscribes the given channel to peer events
 func (srv *Server) SubscribeEvents(ch chan *PeerEvent) event.Subscription {
 	return srv.peerFeed.Subscribe(ch)
@@ -376,6 +503,8 @@ func (srv *Server) Start() (err error) {
 	if srv.newTransport == nil {
 		srv.newTransport = newRLPX
 	}
+	srv.ingressLimit = newRateLimiter(srv.MaxIngressRate)
+	srv.egressLimit = newRateLimiter(srv.MaxEgressRate)
 	if srv.Dialer == nil {
 		srv.Dialer = TCPDialer{&net.Dialer{Timeout: defaultDialTimeout}}
 	}
@@ -385,6 +514,8 @@ func (srv *Server) Start() (err error) {
 	srv.posthandshake = make(chan *conn)
 	srv.addstatic = make(chan *discover.Node)
 	srv.removestatic = make(chan *discover.Node)
+	srv.addtrusted = make(chan *discover.Node)
+	srv.removetrusted = make(chan *discover.Node)
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
 
@@ -409,13 +540,46 @@ func (srv *Server) Start() (err error) {
 			return err
 		}
 		srv.DiscV5 = ntab
+
+		// search the topics of our protocols for preferred dial candidates
+		searched := make(map[discv5.Topic]bool)
+		for _, p := range srv.Protocols {
+			if p.Topic != "" && !searched[p.Topic] {
+				searched[p.Topic] = true
+				srv.topicSources = append(srv.topicSources, newTopicSource(ntab, p.Topic))
+			}
+		}
+	}
+
+	// peer bans, persisted in the node database if discovery is running
+	var bans banDB
+	if db, ok := srv.ntab.(banDB); ok {
+		bans = db
+	}
+	srv.reputation = newReputation(bans, srv.log)
+
+	// DNS node lists
+	if len(srv.DNSDiscovery) > 0 {
+		client := dnsdisc.NewClient(dnsdisc.Config{Logger: srv.log})
+		src, err := client.NewSource(srv.DNSDiscovery...)
+		if err != nil {
+			return err
+		}
+		srv.dnsdisc = src
 	}
 
 	dynPeers := (srv.MaxPeers + 1) / 2
-	if srv.NoDiscovery {
+	if srv.NoDiscovery && srv.dnsdisc == nil && len(srv.topicSources) == 0 {
 		dynPeers = 0
 	}
 	dialer := newDialState(srv.StaticNodes, srv.BootstrapNodes, srv.ntab, dynPeers, srv.NetRestrict)
+	if srv.dnsdisc != nil {
+		dialer.addSource(srv.dnsdisc)
+	}
+	for _, src := range srv.topicSources {
+		dialer.addPreferredSource(src)
+	}
+	dialer.reputation = srv.reputation
 
 	// handshake
 	srv.ourHandshake = &protoHandshake{Version: baseProtocolVersion, Name: srv.Name, ID: discover.PubkeyID(&srv.PrivateKey.PublicKey)}
@@ -431,6 +595,12 @@ func (srv *Server) Start() (err error) {
 	if srv.NoDial && srv.ListenAddr == "" {
 		srv.log.Warn("P2P server will be useless, neither dialing nor listening")
 	}
+	// sign the local node record, tracking external IP changes if behind a NAT
+	srv.updateRecord(nil)
+	if srv.NAT != nil {
+		srv.loopWG.Add(1)
+		go srv.natRefreshLoop()
+	}
 
 	srv.loopWG.Add(1)
 	go srv.run(dialer)
@@ -477,8 +647,8 @@ func (srv *Server) run(dialstate dialer) {
 		queuedTasks  []task // tasks that can't run yet
 	)
 	// Put trusted nodes into a map to speed up checks.
-	// Trusted peers are loaded on startup and cannot be
-	// modified while the server is running.
+	// Trusted peers are loaded on startup and can be
+	// modified through AddTrustedPeer and RemoveTrustedPeer.
 	for _, n := range srv.TrustedNodes {
 		trusted[n.ID] = true
 	}
@@ -536,6 +706,24 @@ running:
 			if p, ok := peers[n.ID]; ok {
 				p.Disconnect(DiscRequested)
 			}
+		case n := <-srv.addtrusted:
+			// This channel is used by AddTrustedPeer to add an enode
+			// to the trusted node set.
+			srv.log.Debug("Adding trusted node", "node", n)
+			trusted[n.ID] = true
+			// Mark any already-connected peer as trusted
+			if p, ok := peers[n.ID]; ok {
+				p.rw.set(trustedConn, true)
+			}
+		case n := <-srv.removetrusted:
+			// This channel is used by RemoveTrustedPeer to remove an enode
+			// from the trusted node set.
+			srv.log.Debug("Removing trusted node", "node", n)
+			delete(trusted, n.ID)
+			// Unmark any already-connected peer as trusted
+			if p, ok := peers[n.ID]; ok {
+				p.rw.set(trustedConn, false)
+			}
 		case op := <-srv.peerOp:
 			// This channel is used by Peers and PeerCount.
 			op(peers)
@@ -552,7 +740,7 @@ running:
 			// the remote identity is known (but hasn't been verified yet).
 			if trusted[c.id] {
 				// Ensure that the trusted flag is set before checking against MaxPeers.
-				c.flags |= trustedConn
+				c.set(trustedConn, true)
 			}
 			// TODO: track in-progress inbound node IDs (pre-Peer) to avoid dialing them.
 			select {
@@ -567,6 +755,7 @@ running:
 			if err == nil {
 				// The handshakes are done and it passed all checks.
 				p := newPeer(c, srv.Protocols)
+				p.reputation = srv.reputation
 				// If message events are enabled, pass the peerFeed
 				// to the peer
 				if srv.EnableMsgEvents {
@@ -599,9 +788,15 @@ running:
 	if srv.ntab != nil {
 		srv.ntab.Close()
 	}
+	for _, src := range srv.topicSources {
+		src.Close()
+	}
 	if srv.DiscV5 != nil {
 		srv.DiscV5.Close()
 	}
+	if srv.dnsdisc != nil {
+		srv.dnsdisc.Close()
+	}
 	// Disconnect all peers.
 	for _, p := range peers {
 		p.Disconnect(DiscQuitting)
@@ -628,6 +823,8 @@ func (srv *Server) protoHandshakeChecks(peers map[discover.NodeID]*Peer, c *conn
 
 func (srv *Server) encHandshakeChecks(peers map[discover.NodeID]*Peer, c *conn) error {
 	switch {
+	case srv.reputation.isBanned(c.id, connIP(c.fd)):
+		return DiscUselessPeer
 	case !c.is(trustedConn|staticDialedConn) && len(peers) >= srv.MaxPeers:
 		return DiscTooManyPeers
 	case peers[c.id] != nil:
@@ -639,6 +836,15 @@ func (srv *Server) encHandshakeChecks(peers map[discover.NodeID]*Peer, c *conn)
 	}
 }
 
+// connIP returns the remote IP address of a network connection, or nil if the
+// connection isn't an IP connection.
+func connIP(fd net.Conn) net.IP {
+	if addr, ok := fd.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
+
 type tempError interface {
 	Temporary() bool
 }
@@ -712,6 +918,10 @@ func (srv *Server) SetupConn(fd net.Conn, flags connFlag, dialDest *discover.Nod
 		return errors.New("shutdown")
 	}
 	c := &conn{fd: fd, transport: srv.newTransport(fd), flags: flags, cont: make(chan error)}
+	if t, ok := c.transport.(*rlpx); ok {
+		t.ingressLimit = limiterSet{srv.ingressLimit, newRateLimiter(srv.PeerIngressRate)}
+		t.egressLimit = limiterSet{srv.egressLimit, newRateLimiter(srv.PeerEgressRate)}
+	}
 	err := srv.setupConn(c, flags, dialDest)
 	if err != nil {
 		c.close(err)
@@ -731,10 +941,10 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *discover.Node) e
 	// Run the encryption handshake.
 	var err error
 	if c.id, err = c.doEncHandshake(srv.PrivateKey, dialDest); err != nil {
-		srv.log.Trace("Failed RLPx handshake", "addr", c.fd.RemoteAddr(), "conn", c.flags, "err", err)
+		srv.log.Trace("Failed RLPx handshake", "addr", c.fd.RemoteAddr(), "conn", c.loadFlags(), "err", err)
 		return err
 	}
-	clog := srv.log.New("id", c.id, "addr", c.fd.RemoteAddr(), "conn", c.flags)
+	clog := srv.log.New("id", c.id, "addr", c.fd.RemoteAddr(), "conn", c.loadFlags())
 	// For dialed connections, check that the remote public key matches.
 	if dialDest != nil && c.id != dialDest.ID {
 		clog.Trace("Dialed identity mismatch", "want", c, dialDest.ID)
@@ -824,6 +1034,7 @@ type NodeInfo struct {
 	ID    string `json:"id"`    // Unique node identifier (also the encryption key)
 	Name  string `json:"name"`  // Name of the node, including client type, version, OS, custom data
 	Enode string `json:"enode"` // Enode URL for adding this peer from remote peers
+	ENR   string `json:"enr"`   // Signed node record of the host
 	IP    string `json:"ip"`    // IP address of the node
 	Ports struct {
 		Discovery int `json:"discovery"` // UDP listening port for discovery protocol
@@ -841,6 +1052,7 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	info := &NodeInfo{
 		Name:       srv.Name,
 		Enode:      node.String(),
+		ENR:        encodeRecord(srv.Record()),
 		ID:         node.ID.String(),
 		IP:         node.IP.String(),
 		ListenAddr: srv.ListenAddr,
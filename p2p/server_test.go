@@ -17,11 +17,13 @@
 package p2p
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"errors"
 	"math/rand"
 	"net"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -29,6 +31,7 @@ import (
 	"github.com/Rue-Foundation/go-rue/crypto/sha3"
 	"github.com/Rue-Foundation/go-rue/log"
 	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/p2p/enr"
 )
 
 func init() {
@@ -179,6 +182,52 @@ func TestServerDial(t *testing.T) {
 	}
 }
 
+// This test checks that the server signs a local node record announcing its
+// endpoint, and re-signs it with a higher sequence number when it changes.
+func TestServerRecord(t *testing.T) {
+	srv := startTestServer(t, randomID(), nil)
+	defer srv.Stop()
+
+	record := srv.Record()
+	if record == nil || !record.Signed() {
+		t.Fatalf("local node record not signed: %v", record)
+	}
+	var (
+		ip  enr.IP4
+		tcp enr.TCP
+		udp enr.UDP
+	)
+	if err := record.Load(&ip); err != nil || !net.IP(ip).Equal(net.IP{127, 0, 0, 1}) {
+		t.Errorf("record IP mismatch: have %v, err %v", net.IP(ip), err)
+	}
+	self := srv.Self()
+	if err := record.Load(&tcp); err != nil || uint16(tcp) != self.TCP {
+		t.Errorf("record TCP port mismatch: have %d, want %d, err %v", tcp, self.TCP, err)
+	}
+	if err := record.Load(&udp); err != nil || uint16(udp) != self.UDP {
+		t.Errorf("record UDP port mismatch: have %d, want %d, err %v", udp, self.UDP, err)
+	}
+	if !bytes.Equal(record.NodeAddr(), crypto.Keccak256(crypto.CompressPubkey(&srv.PrivateKey.PublicKey))) {
+		t.Errorf("record signed by wrong key")
+	}
+	if info := srv.NodeInfo(); !strings.HasPrefix(info.ENR, "enr:") {
+		t.Errorf("node info record mismatch: have %q", info.ENR)
+	}
+	// Unchanged details should not re-sign the record
+	srv.updateRecord(nil)
+	if seq := srv.Record().Seq(); seq != record.Seq() {
+		t.Errorf("unchanged record re-signed: have seq %d, want %d", seq, record.Seq())
+	}
+	// Changing the external IP should re-sign the record and update discovery
+	srv.updateRecord(net.IP{1, 2, 3, 4})
+	if seq := srv.Record().Seq(); seq != record.Seq()+1 {
+		t.Errorf("changed record seq mismatch: have %d, want %d", seq, record.Seq()+1)
+	}
+	if srv.ntab.Record() != srv.Record() {
+		t.Errorf("discovery not serving the updated record")
+	}
+}
+
 // This test checks that tasks generated by dialstate are
 // actually executed and taskdone is called for them.
 func TestServerTaskScheduling(t *testing.T) {
@@ -364,6 +413,65 @@ func TestServerAtCap(t *testing.T) {
 		t.Error("Server did not set trusted flag")
 	}
 
+	// Remove from trusted set and try again
+	srv.RemoveTrustedPeer(&discover.Node{ID: trustedID})
+	c = newconn(trustedID)
+	if err := srv.checkpoint(c, srv.posthandshake); err != DiscTooManyPeers {
+		t.Error("wrong error for insert:", err)
+	}
+
+	// Add anotherID to trusted set and try again
+	anotherID := randomID()
+	srv.AddTrustedPeer(&discover.Node{ID: anotherID})
+	c = newconn(anotherID)
+	if err := srv.checkpoint(c, srv.posthandshake); err != nil {
+		t.Error("unexpected error for trusted conn @posthandshake:", err)
+	}
+	if !c.is(trustedConn) {
+		t.Error("Server did not set trusted flag")
+	}
+}
+
+func TestServerTrustedPeerRuntime(t *testing.T) {
+	srv := &Server{
+		Config: Config{
+			PrivateKey: newkey(),
+			MaxPeers:   10,
+			NoDial:     true,
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	// Connect a regular peer and mark it trusted while it is running.
+	id := randomID()
+	fd, _ := net.Pipe()
+	c := &conn{fd: fd, transport: newTestTransport(id, fd), flags: inboundConn, id: id, cont: make(chan error)}
+	if err := srv.checkpoint(c, srv.addpeer); err != nil {
+		t.Fatalf("could not add conn: %v", err)
+	}
+	peerInfo := func() *PeerInfo {
+		for _, info := range srv.PeersInfo() {
+			if info.ID == id.String() {
+				return info
+			}
+		}
+		t.Fatal("peer not found")
+		return nil
+	}
+	if info := peerInfo(); info.Network.Trusted || !info.Network.Inbound || info.Network.Static {
+		t.Fatalf("wrong peer info before trusting: %+v", info.Network)
+	}
+	srv.AddTrustedPeer(&discover.Node{ID: id})
+	if info := peerInfo(); !info.Network.Trusted {
+		t.Fatal("peer not marked trusted after AddTrustedPeer")
+	}
+	srv.RemoveTrustedPeer(&discover.Node{ID: id})
+	if info := peerInfo(); info.Network.Trusted {
+		t.Fatal("peer still marked trusted after RemoveTrustedPeer")
+	}
 }
 
 func TestServerSetupConn(t *testing.T) {
@@ -32,11 +32,13 @@ import (
 )
 
 // SimAdapter is a NodeAdapter which creates in-memory simulation nodes and
-// connects them using in-memory net.Pipe connections
+// connects them using in-memory net.Pipe connections. The connections are
+// subject to the link model set through the LinkController methods.
 type SimAdapter struct {
 	mtx      sync.RWMutex
 	nodes    map[discover.NodeID]*SimNode
 	services map[string]ServiceFunc
+	links    *links
 }
 
 // NewSimAdapter creates a SimAdapter which is capable of running in-memory
@@ -46,6 +48,7 @@ func NewSimAdapter(services map[string]ServiceFunc) *SimAdapter {
 	return &SimAdapter{
 		nodes:    make(map[discover.NodeID]*SimNode),
 		services: services,
+		links:    newLinks(),
 	}
 }
 
@@ -80,7 +83,7 @@ func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 			PrivateKey:      config.PrivateKey,
 			MaxPeers:        math.MaxInt32,
 			NoDiscovery:     true,
-			Dialer:          s,
+			Dialer:          &simDialer{adapter: s, src: id},
 			EnableMsgEvents: true,
 		},
 		NoUSB:  true,
@@ -102,8 +105,14 @@ func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 }
 
 // Dial implements the p2p.NodeDialer interface by connecting to the node using
-// an in-memory net.Pipe connection
+// an in-memory net.Pipe connection. The source of the connection is unknown,
+// so it uses the default link. Nodes of the adapter dial through simDialer.
 func (s *SimAdapter) Dial(dest *discover.Node) (conn net.Conn, err error) {
+	return s.dial(discover.NodeID{}, dest)
+}
+
+// dial connects the source node to the destination node over their link.
+func (s *SimAdapter) dial(src discover.NodeID, dest *discover.Node) (net.Conn, error) {
 	node, ok := s.GetNode(dest.ID)
 	if !ok {
 		return nil, fmt.Errorf("unknown node: %s", dest.ID)
@@ -112,11 +121,46 @@ func (s *SimAdapter) Dial(dest *discover.Node) (conn net.Conn, err error) {
 	if srv == nil {
 		return nil, fmt.Errorf("node not running: %s", dest.ID)
 	}
-	pipe1, pipe2 := net.Pipe()
+	key := newLinkKey(src, dest.ID)
+	if s.links.isBlocked(key) {
+		return nil, fmt.Errorf("link to %s is down", dest.ID)
+	}
+	pipe1, pipe2 := s.links.pipe(key)
 	go srv.SetupConn(pipe1, 0, nil)
 	return pipe2, nil
 }
 
+// SetDefaultLink implements LinkController.
+func (s *SimAdapter) SetDefaultLink(link LinkConfig) {
+	s.links.setDefault(link)
+}
+
+// SetLink implements LinkController.
+func (s *SimAdapter) SetLink(one, other discover.NodeID, link LinkConfig) {
+	s.links.set(newLinkKey(one, other), link)
+}
+
+// Link implements LinkController.
+func (s *SimAdapter) Link(one, other discover.NodeID) LinkConfig {
+	return s.links.get(newLinkKey(one, other))
+}
+
+// SetBlocked implements LinkController.
+func (s *SimAdapter) SetBlocked(one, other discover.NodeID, blocked bool) {
+	s.links.setBlocked(newLinkKey(one, other), blocked)
+}
+
+// simDialer is the p2p.NodeDialer of a SimNode, dialing over the links of the
+// node.
+type simDialer struct {
+	adapter *SimAdapter
+	src     discover.NodeID
+}
+
+func (d *simDialer) Dial(dest *discover.Node) (net.Conn, error) {
+	return d.adapter.dial(d.src, dest)
+}
+
 // DialRPC implements the RPCDialer interface by creating an in-memory RPC
 // client of the given node
 func (s *SimAdapter) DialRPC(id discover.NodeID) (*rpc.Client, error) {
@@ -0,0 +1,266 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package adapters
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+)
+
+const (
+	// minRetransmitTimeout is the minimum delay of a lost write until it is
+	// retransmitted, like the minimum retransmission timeout of TCP.
+	minRetransmitTimeout = 200 * time.Millisecond
+
+	// linkQueueSize is the number of writes which may be in flight on a link
+	// before writers block.
+	linkQueueSize = 64
+)
+
+// LinkConfig models the network link between two simulation nodes. The zero
+// value is a perfect link.
+//
+// Connections between nodes are reliable streams, so lost writes aren't
+// dropped but delivered after a retransmission timeout, delaying all
+// subsequent writes on the connection.
+type LinkConfig struct {
+	Latency   time.Duration `json:"latency"`   // one-way delay of every write
+	Bandwidth int           `json:"bandwidth"` // bytes per second in each direction, zero for unlimited
+	DropRate  float64       `json:"dropRate"`  // probability of losing a write
+}
+
+// retransmitTimeout returns the delay of a lost write.
+func (l LinkConfig) retransmitTimeout() time.Duration {
+	return minRetransmitTimeout + 2*l.Latency
+}
+
+// linkKey identifies the link between two nodes regardless of direction.
+type linkKey [2]discover.NodeID
+
+func newLinkKey(one, other discover.NodeID) linkKey {
+	if bytes.Compare(one[:], other[:]) > 0 {
+		one, other = other, one
+	}
+	return linkKey{one, other}
+}
+
+// links holds the link models and the live connections between the nodes of
+// an adapter. It is safe for concurrent use.
+type links struct {
+	lock        sync.RWMutex
+	defaultLink LinkConfig
+	config      map[linkKey]LinkConfig
+	blocked     map[linkKey]bool
+	conns       map[linkKey]map[*linkConn]struct{}
+}
+
+func newLinks() *links {
+	return &links{
+		config:  make(map[linkKey]LinkConfig),
+		blocked: make(map[linkKey]bool),
+		conns:   make(map[linkKey]map[*linkConn]struct{}),
+	}
+}
+
+func (l *links) setDefault(link LinkConfig) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.defaultLink = link
+}
+
+func (l *links) set(key linkKey, link LinkConfig) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.config[key] = link
+}
+
+func (l *links) get(key linkKey) LinkConfig {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if link, ok := l.config[key]; ok {
+		return link
+	}
+	return l.defaultLink
+}
+
+func (l *links) isBlocked(key linkKey) bool {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.blocked[key]
+}
+
+// setBlocked blocks or unblocks a link. Blocking a link closes the connections
+// using it.
+func (l *links) setBlocked(key linkKey, blocked bool) {
+	l.lock.Lock()
+	var conns []*linkConn
+	if blocked {
+		l.blocked[key] = true
+		for c := range l.conns[key] {
+			conns = append(conns, c)
+		}
+	} else {
+		delete(l.blocked, key)
+	}
+	l.lock.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// pipe creates an in-memory connection over the link between two nodes.
+func (l *links) pipe(key linkKey) (net.Conn, net.Conn) {
+	pipe1, pipe2 := net.Pipe()
+	c1, c2 := newLinkConn(pipe1, l, key), newLinkConn(pipe2, l, key)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.conns[key] == nil {
+		l.conns[key] = make(map[*linkConn]struct{})
+	}
+	l.conns[key][c1] = struct{}{}
+	l.conns[key][c2] = struct{}{}
+	return c1, c2
+}
+
+func (l *links) remove(c *linkConn) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.conns[c.key], c)
+	if len(l.conns[c.key]) == 0 {
+		delete(l.conns, c.key)
+	}
+}
+
+// linkWrite is a write in flight on a link.
+type linkWrite struct {
+	data []byte
+	at   time.Time // delivery time
+}
+
+// linkConn is one end of an in-memory connection whose writes are delayed
+// according to the link model. Writes are queued and delivered in order by a
+// background goroutine, reads pass through to the underlying pipe.
+type linkConn struct {
+	net.Conn
+	links *links
+	key   linkKey
+	queue chan linkWrite
+	quit  chan struct{}
+	once  sync.Once
+
+	lock     sync.Mutex
+	sendFree time.Time // time at which the writes queued so far are sent
+	err      error     // delivery error
+}
+
+func newLinkConn(conn net.Conn, links *links, key linkKey) *linkConn {
+	c := &linkConn{
+		Conn:  conn,
+		links: links,
+		key:   key,
+		queue: make(chan linkWrite, linkQueueSize),
+		quit:  make(chan struct{}),
+	}
+	go c.deliver()
+	return c
+}
+
+// Write queues the data for delivery after the link delay.
+func (c *linkConn) Write(b []byte) (int, error) {
+	link := c.links.get(c.key)
+	now := time.Now()
+
+	select {
+	case <-c.quit:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+	c.lock.Lock()
+	if c.err != nil {
+		c.lock.Unlock()
+		return 0, c.err
+	}
+	if c.sendFree.Before(now) {
+		c.sendFree = now
+	}
+	if link.Bandwidth > 0 {
+		c.sendFree = c.sendFree.Add(time.Duration(len(b)) * time.Second / time.Duration(link.Bandwidth))
+	}
+	at := c.sendFree.Add(link.Latency)
+	if link.DropRate > 0 && rand.Float64() < link.DropRate {
+		at = at.Add(link.retransmitTimeout())
+	}
+	c.lock.Unlock()
+
+	w := linkWrite{data: make([]byte, len(b)), at: at}
+	copy(w.data, b)
+	select {
+	case c.queue <- w:
+		return len(b), nil
+	case <-c.quit:
+		return 0, io.ErrClosedPipe
+	}
+}
+
+// Close closes the connection, discarding the writes in flight.
+func (c *linkConn) Close() error {
+	var err error
+	c.once.Do(func() {
+		close(c.quit)
+		err = c.Conn.Close()
+		c.links.remove(c)
+	})
+	return err
+}
+
+// deliver writes the queued data to the underlying pipe at its delivery time.
+func (c *linkConn) deliver() {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	for {
+		select {
+		case w := <-c.queue:
+			if d := time.Until(w.at); d > 0 {
+				timer.Reset(d)
+				select {
+				case <-timer.C:
+				case <-c.quit:
+					return
+				}
+			}
+			if _, err := c.Conn.Write(w.data); err != nil {
+				c.lock.Lock()
+				c.err = err
+				c.lock.Unlock()
+				c.Close()
+				return
+			}
+		case <-c.quit:
+			return
+		}
+	}
+}
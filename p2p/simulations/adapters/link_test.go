@@ -0,0 +1,127 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package adapters
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+)
+
+// timedRead reads n bytes from r and returns them along with the time it took.
+func timedRead(t *testing.T, r io.Reader, n int) ([]byte, time.Duration) {
+	start := time.Now()
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	return buf, time.Since(start)
+}
+
+func TestLinkLatency(t *testing.T) {
+	links := newLinks()
+	key := newLinkKey(discover.NodeID{1}, discover.NodeID{2})
+	links.set(key, LinkConfig{Latency: 100 * time.Millisecond})
+	c1, c2 := links.pipe(key)
+	defer c1.Close()
+	defer c2.Close()
+
+	// Writes don't block for the latency, but are delivered after it in order.
+	start := time.Now()
+	c1.Write([]byte("foo"))
+	c1.Write([]byte("bar"))
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Errorf("writes blocked for %v", d)
+	}
+	data, d := timedRead(t, c2, 6)
+	if !bytes.Equal(data, []byte("foobar")) {
+		t.Errorf("wrong data received: %q", data)
+	}
+	if d < 90*time.Millisecond || d > time.Second {
+		t.Errorf("wrong delivery delay %v, want ~100ms", d)
+	}
+
+	// Link changes apply to existing connections.
+	links.set(key, LinkConfig{})
+	c2.Write([]byte("baz"))
+	if _, d := timedRead(t, c1, 3); d > 50*time.Millisecond {
+		t.Errorf("perfect link delayed delivery by %v", d)
+	}
+}
+
+func TestLinkBandwidth(t *testing.T) {
+	links := newLinks()
+	links.setDefault(LinkConfig{Bandwidth: 10000})
+	key := newLinkKey(discover.NodeID{1}, discover.NodeID{2})
+	c1, c2 := links.pipe(key)
+	defer c1.Close()
+	defer c2.Close()
+
+	// Sending 2000 bytes at 10000 bytes/s takes 200ms.
+	for i := 0; i < 4; i++ {
+		c1.Write(make([]byte, 500))
+	}
+	if _, d := timedRead(t, c2, 2000); d < 180*time.Millisecond || d > time.Second {
+		t.Errorf("wrong transfer time %v, want ~200ms", d)
+	}
+}
+
+func TestLinkDrops(t *testing.T) {
+	links := newLinks()
+	links.setDefault(LinkConfig{DropRate: 1})
+	key := newLinkKey(discover.NodeID{1}, discover.NodeID{2})
+	c1, c2 := links.pipe(key)
+	defer c1.Close()
+	defer c2.Close()
+
+	// Lost writes are delivered after the retransmission timeout.
+	c1.Write([]byte("foo"))
+	if _, d := timedRead(t, c2, 3); d < minRetransmitTimeout-10*time.Millisecond {
+		t.Errorf("lost write delivered after %v, want %v", d, minRetransmitTimeout)
+	}
+}
+
+func TestLinkBlocked(t *testing.T) {
+	links := newLinks()
+	key := newLinkKey(discover.NodeID{2}, discover.NodeID{1})
+	if key != newLinkKey(discover.NodeID{1}, discover.NodeID{2}) {
+		t.Fatal("link key depends on direction")
+	}
+	c1, c2 := links.pipe(key)
+
+	// Blocking the link closes its connections.
+	links.setBlocked(key, true)
+	if !links.isBlocked(key) {
+		t.Fatal("link not blocked")
+	}
+	if _, err := c1.Write([]byte("foo")); err == nil {
+		t.Error("write succeeded on blocked link")
+	}
+	if _, err := c2.Read(make([]byte, 1)); err == nil {
+		t.Error("read succeeded on blocked link")
+	}
+	if len(links.conns) != 0 {
+		t.Errorf("closed connections still tracked: %d", len(links.conns))
+	}
+	links.setBlocked(key, false)
+	if links.isBlocked(key) {
+		t.Fatal("link still blocked")
+	}
+}
@@ -72,6 +72,23 @@ type NodeAdapter interface {
 	NewNode(config *NodeConfig) (Node, error)
 }
 
+// LinkController is implemented by NodeAdapters which model the network links
+// between their nodes (currently only the SimAdapter)
+type LinkController interface {
+	// SetDefaultLink sets the model of all links without a specific model
+	SetDefaultLink(link LinkConfig)
+
+	// SetLink sets the model of the link between two nodes
+	SetLink(one, other discover.NodeID, link LinkConfig)
+
+	// Link returns the model of the link between two nodes
+	Link(one, other discover.NodeID) LinkConfig
+
+	// SetBlocked cuts or restores the link between two nodes. Cutting a
+	// link drops all connections over it and makes dials fail.
+	SetBlocked(one, other discover.NodeID, blocked bool)
+}
+
 // NodeConfig is the configuration used to start a node in a simulation
 // network
 type NodeConfig struct {
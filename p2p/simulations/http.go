@@ -205,6 +205,32 @@ func (c *Client) DisconnectNode(nodeID, peerID string) error {
 	return c.Delete(fmt.Sprintf("/nodes/%s/conn/%s", nodeID, peerID))
 }
 
+// SetDefaultLink sets the model of all links without a specific model
+func (c *Client) SetDefaultLink(link adapters.LinkConfig) error {
+	return c.Post("/links", link, nil)
+}
+
+// SetLink sets the model of the link between a node and a peer node
+func (c *Client) SetLink(nodeID, peerID string, link adapters.LinkConfig) error {
+	return c.Post(fmt.Sprintf("/nodes/%s/link/%s", nodeID, peerID), link, nil)
+}
+
+// PartitionOpts are the node groups of a network partition, each node given
+// by its ID or name
+type PartitionOpts struct {
+	Groups [][]string `json:"groups"`
+}
+
+// Partition splits the network into the given groups of nodes
+func (c *Client) Partition(groups ...[]string) error {
+	return c.Post("/partition", &PartitionOpts{Groups: groups}, nil)
+}
+
+// Heal restores the links cut by partitions
+func (c *Client) Heal() error {
+	return c.Post("/heal", nil, nil)
+}
+
 // RPCClient returns an RPC client connected to a node
 func (c *Client) RPCClient(ctx context.Context, nodeID string) (*rpc.Client, error) {
 	baseURL := strings.Replace(c.URL, "http", "ws", 1)
@@ -285,6 +311,9 @@ func NewServer(network *Network) *Server {
 	s.POST("/mocker/stop", s.StopMocker)
 	s.GET("/mocker", s.GetMockers)
 	s.POST("/reset", s.ResetNetwork)
+	s.POST("/links", s.SetDefaultLink)
+	s.POST("/partition", s.Partition)
+	s.POST("/heal", s.Heal)
 	s.GET("/events", s.StreamNetworkEvents)
 	s.GET("/snapshot", s.CreateSnapshot)
 	s.POST("/snapshot", s.LoadSnapshot)
@@ -295,6 +324,7 @@ func NewServer(network *Network) *Server {
 	s.POST("/nodes/:nodeid/stop", s.StopNode)
 	s.POST("/nodes/:nodeid/conn/:peerid", s.ConnectNode)
 	s.DELETE("/nodes/:nodeid/conn/:peerid", s.DisconnectNode)
+	s.POST("/nodes/:nodeid/link/:peerid", s.SetLink)
 	s.GET("/nodes/:nodeid/rpc", s.NodeRPC)
 
 	return s
@@ -646,6 +676,75 @@ func (s *Server) DisconnectNode(w http.ResponseWriter, req *http.Request) {
 	s.JSON(w, http.StatusOK, node.NodeInfo())
 }
 
+// SetDefaultLink sets the model of all links without a specific model
+func (s *Server) SetDefaultLink(w http.ResponseWriter, req *http.Request) {
+	var link adapters.LinkConfig
+	if err := json.NewDecoder(req.Body).Decode(&link); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.network.SetDefaultLink(link); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// SetLink sets the model of the link between a node and a peer node
+func (s *Server) SetLink(w http.ResponseWriter, req *http.Request) {
+	node := req.Context().Value("node").(*Node)
+	peer := req.Context().Value("peer").(*Node)
+
+	var link adapters.LinkConfig
+	if err := json.NewDecoder(req.Body).Decode(&link); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.network.SetLink(node.ID(), peer.ID(), link); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Partition splits the network into groups of nodes
+func (s *Server) Partition(w http.ResponseWriter, req *http.Request) {
+	var opts PartitionOpts
+	if err := json.NewDecoder(req.Body).Decode(&opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	groups := make([][]discover.NodeID, len(opts.Groups))
+	for i, g := range opts.Groups {
+		for _, id := range g {
+			node := s.lookupNode(id)
+			if node == nil {
+				http.Error(w, fmt.Sprintf("unknown node %q", id), http.StatusBadRequest)
+				return
+			}
+			groups[i] = append(groups[i], node.ID())
+		}
+	}
+	if err := s.network.Partition(groups...); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Heal restores the links cut by partitions
+func (s *Server) Heal(w http.ResponseWriter, req *http.Request) {
+	if err := s.network.Heal(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
 // Options responds to the OPTIONS HTTP method by returning a 200 OK response
 // with the "Access-Control-Allow-Headers" header set to "Content-Type"
 func (s *Server) Options(w http.ResponseWriter, req *http.Request) {
@@ -708,12 +807,7 @@ func (s *Server) wrapHandler(handler http.HandlerFunc) httprouter.Handle {
 		ctx := context.Background()
 
 		if id := params.ByName("nodeid"); id != "" {
-			var node *Node
-			if nodeID, err := discover.HexID(id); err == nil {
-				node = s.network.GetNode(nodeID)
-			} else {
-				node = s.network.GetNodeByName(id)
-			}
+			node := s.lookupNode(id)
 			if node == nil {
 				http.NotFound(w, req)
 				return
@@ -722,12 +816,7 @@ func (s *Server) wrapHandler(handler http.HandlerFunc) httprouter.Handle {
 		}
 
 		if id := params.ByName("peerid"); id != "" {
-			var peer *Node
-			if peerID, err := discover.HexID(id); err == nil {
-				peer = s.network.GetNode(peerID)
-			} else {
-				peer = s.network.GetNodeByName(id)
-			}
+			peer := s.lookupNode(id)
 			if peer == nil {
 				http.NotFound(w, req)
 				return
@@ -738,3 +827,11 @@ func (s *Server) wrapHandler(handler http.HandlerFunc) httprouter.Handle {
 		handler(w, req.WithContext(ctx))
 	}
 }
+
+// lookupNode returns the node with the given ID or name
+func (s *Server) lookupNode(id string) *Node {
+	if nodeID, err := discover.HexID(id); err == nil {
+		return s.network.GetNode(nodeID)
+	}
+	return s.network.GetNodeByName(id)
+}
@@ -138,6 +138,13 @@ func (t *testService) handshake(rw p2p.MsgReadWriter, code uint64) error {
 func (t *testService) RunTest(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 	peer := t.peer(p.ID())
 
+	// forget the peer when it's dropped so that it can reconnect
+	defer func() {
+		t.peersMtx.Lock()
+		delete(t.peers, p.ID())
+		t.peersMtx.Unlock()
+	}()
+
 	// perform three handshakes with three different message codes,
 	// used to test message sending and filtering
 	if err := t.handshake(rw, 2); err != nil {
@@ -343,6 +350,51 @@ func TestHTTPNetwork(t *testing.T) {
 	)
 }
 
+// TestHTTPPartition tests partitioning and healing a simulation network using
+// the HTTP API
+func TestHTTPPartition(t *testing.T) {
+	_, s := testHTTPServer(t)
+	defer s.Close()
+
+	client := NewClient(s.URL)
+	events := make(chan *Event, 100)
+	sub, err := client.SubscribeNetwork(events, SubscribeOpts{})
+	if err != nil {
+		t.Fatalf("error subscribing to network events: %s", err)
+	}
+	defer sub.Unsubscribe()
+
+	nodeIDs := startTestNetwork(t, client)
+	x := &expectEvents{t, events, sub}
+	x.expect(
+		x.nodeEvent(nodeIDs[0], false),
+		x.nodeEvent(nodeIDs[1], false),
+		x.nodeEvent(nodeIDs[0], true),
+		x.nodeEvent(nodeIDs[1], true),
+		x.connEvent(nodeIDs[0], nodeIDs[1], false),
+		x.connEvent(nodeIDs[0], nodeIDs[1], true),
+	)
+
+	// check the connection drops when the nodes are partitioned and can't
+	// be established again
+	if err := client.Partition([]string{nodeIDs[0]}, []string{nodeIDs[1]}); err != nil {
+		t.Fatalf("error partitioning network: %s", err)
+	}
+	x.expect(x.connEvent(nodeIDs[0], nodeIDs[1], false))
+	if err := client.Partition([]string{nodeIDs[0]}, []string{"unknown"}); err == nil {
+		t.Fatal("expected error partitioning unknown node")
+	}
+
+	// check the connection comes back when the network is healed
+	if err := client.Heal(); err != nil {
+		t.Fatalf("error healing network: %s", err)
+	}
+	x.expect(
+		x.connEvent(nodeIDs[0], nodeIDs[1], false),
+		x.connEvent(nodeIDs[0], nodeIDs[1], true),
+	)
+}
+
 func startTestNetwork(t *testing.T, client *Client) []string {
 	// create two nodes
 	nodeCount := 2
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -60,6 +61,10 @@ type Network struct {
 	events      event.Feed
 	lock        sync.RWMutex
 	quitc       chan struct{}
+
+	// links cut by partitions and the connections they dropped, by ConnLabel
+	partitioned map[string][2]discover.NodeID
+	cut         map[string]*Conn
 }
 
 // NewNetwork returns a Network which uses the given NodeAdapter and NetworkConfig
@@ -70,6 +75,8 @@ func NewNetwork(nodeAdapter adapters.NodeAdapter, conf *NetworkConfig) *Network
 		nodeMap:       make(map[discover.NodeID]int),
 		connMap:       make(map[string]int),
 		quitc:         make(chan struct{}),
+		partitioned:   make(map[string][2]discover.NodeID),
+		cut:           make(map[string]*Conn),
 	}
 }
 
@@ -310,6 +317,141 @@ func (self *Network) Disconnect(oneID, otherID discover.NodeID) error {
 	return client.Call(nil, "admin_removePeer", string(conn.other.Addr()))
 }
 
+// linkController returns the node adapter as a LinkController, or an error if
+// the adapter doesn't model links
+func (self *Network) linkController() (adapters.LinkController, error) {
+	links, ok := self.nodeAdapter.(adapters.LinkController)
+	if !ok {
+		return nil, fmt.Errorf("node adapter %s doesn't support links", self.nodeAdapter.Name())
+	}
+	return links, nil
+}
+
+// SetDefaultLink sets the model of all links between nodes which don't have
+// a specific link model
+func (self *Network) SetDefaultLink(link adapters.LinkConfig) error {
+	links, err := self.linkController()
+	if err != nil {
+		return err
+	}
+	links.SetDefaultLink(link)
+	return nil
+}
+
+// SetLink sets the model of the link between two nodes, which applies to
+// existing connections immediately
+func (self *Network) SetLink(oneID, otherID discover.NodeID, link adapters.LinkConfig) error {
+	links, err := self.linkController()
+	if err != nil {
+		return err
+	}
+	if self.GetNode(oneID) == nil {
+		return fmt.Errorf("node %v does not exist", oneID)
+	}
+	if self.GetNode(otherID) == nil {
+		return fmt.Errorf("node %v does not exist", otherID)
+	}
+	links.SetLink(oneID, otherID, link)
+	return nil
+}
+
+// Partition splits the network by cutting the links between nodes of different
+// groups. Connections between the groups are dropped and the nodes can't
+// connect to each other until the network is healed. Nodes which aren't in
+// any group keep their links.
+func (self *Network) Partition(groups ...[]discover.NodeID) error {
+	links, err := self.linkController()
+	if err != nil {
+		return err
+	}
+	if len(groups) < 2 {
+		return errors.New("partition needs at least two groups")
+	}
+	group := make(map[discover.NodeID]int)
+	for i, g := range groups {
+		for _, id := range g {
+			if self.GetNode(id) == nil {
+				return fmt.Errorf("node %v does not exist", id)
+			}
+			if _, ok := group[id]; ok {
+				return fmt.Errorf("node %v is in multiple groups", id)
+			}
+			group[id] = i
+		}
+	}
+
+	self.lock.Lock()
+	var cut [][2]discover.NodeID
+	for i, g := range groups {
+		for _, one := range g {
+			for _, other := range groups[i+1:] {
+				for _, id := range other {
+					label := ConnLabel(one, id)
+					if conn := self.getConn(one, id); conn != nil && conn.Up {
+						self.cut[label] = conn
+					}
+					self.partitioned[label] = [2]discover.NodeID{one, id}
+					cut = append(cut, [2]discover.NodeID{one, id})
+				}
+			}
+		}
+	}
+	self.lock.Unlock()
+
+	log.Info("Partitioning simulation network", "groups", len(groups), "links", len(cut))
+	for _, link := range cut {
+		links.SetBlocked(link[0], link[1], true)
+	}
+	return nil
+}
+
+// Heal restores all links cut by partitions and reconnects the connections
+// which were dropped by them
+func (self *Network) Heal() error {
+	links, err := self.linkController()
+	if err != nil {
+		return err
+	}
+	self.lock.Lock()
+	partitioned, cut := self.partitioned, self.cut
+	self.partitioned = make(map[string][2]discover.NodeID)
+	self.cut = make(map[string]*Conn)
+	self.lock.Unlock()
+
+	log.Info("Healing simulation network", "links", len(partitioned), "conns", len(cut))
+	for _, link := range partitioned {
+		links.SetBlocked(link[0], link[1], false)
+	}
+	for _, conn := range cut {
+		self.lock.RLock()
+		reconnect := !conn.Up && conn.nodesUp() == nil
+		self.lock.RUnlock()
+		if !reconnect {
+			continue
+		}
+		// the nodes might have redialed each other already
+		err := self.reconnect(conn)
+		if err != nil && !self.GetConn(conn.One, conn.Other).Up {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconnect makes the "one" node of a dropped connection dial the "other"
+// node again. The peer is removed first, which resets the dial history of
+// the node that would otherwise delay the dial.
+func (self *Network) reconnect(conn *Conn) error {
+	client, err := conn.one.Client()
+	if err != nil {
+		return err
+	}
+	if err := client.Call(nil, "admin_removePeer", string(conn.other.Addr())); err != nil {
+		return err
+	}
+	return self.Connect(conn.One, conn.Other)
+}
+
 // DidConnect tracks the fact that the "one" node connected to the "other" node
 func (self *Network) DidConnect(one, other discover.NodeID) error {
 	conn, err := self.GetOrCreateConn(one, other)
@@ -510,6 +652,8 @@ func (self *Network) Reset() {
 	//re-initialize the maps
 	self.connMap = make(map[string]int)
 	self.nodeMap = make(map[discover.NodeID]int)
+	self.partitioned = make(map[string][2]discover.NodeID)
+	self.cut = make(map[string]*Conn)
 
 	self.Nodes = nil
 	self.Conns = nil
@@ -0,0 +1,616 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/p2p/simulations/adapters"
+)
+
+// defaultAssertTimeout is the time an assertion may take to pass if the
+// scenario doesn't specify it.
+const defaultAssertTimeout = 10 * time.Second
+
+// assertPollInterval is the interval at which pending assertions are checked.
+const assertPollInterval = 50 * time.Millisecond
+
+// Duration is a time.Duration which is encoded as a string like "1m30s" in
+// scenario files.
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(input []byte) error {
+	var s string
+	if err := json.Unmarshal(input, &s); err != nil {
+		return fmt.Errorf("invalid duration %s, want a string like \"5s\"", input)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Scenario is a declarative description of a simulation run: the nodes of the
+// network and how they are connected initially, the events happening at fixed
+// times after the start and the assertions checked once all events are done.
+//
+// An example scenario in JSON:
+//
+//     {
+//       "name":     "ring with crash",
+//       "services": ["ping-pong"],
+//       "nodes":    [{"name": "a"}, {"name": "b"}, {"name": "c"}],
+//       "topology": {"type": "ring"},
+//       "events": [
+//         {"at": "1s", "action": "crash", "nodes": ["b"]},
+//         {"at": "2s", "action": "partition", "groups": [["a"], ["c"]]}
+//       ],
+//       "assertions": [
+//         {"type": "down", "nodes": ["b"]},
+//         {"type": "peers", "nodes": ["a", "c"], "count": 0, "within": "5s"}
+//       ]
+//     }
+type Scenario struct {
+	Name       string               `json:"name"`
+	Services   []string             `json:"services,omitempty"` // services of nodes which don't list their own
+	Nodes      []*ScenarioNode      `json:"nodes"`
+	Link       *ScenarioLink        `json:"link,omitempty"` // model of all links, perfect links if nil
+	Topology   *ScenarioTopology    `json:"topology,omitempty"`
+	Events     []*ScenarioEvent     `json:"events,omitempty"`
+	Assertions []*ScenarioAssertion `json:"assertions,omitempty"`
+}
+
+// ScenarioNode is a node of a scenario. Nodes are started when the scenario
+// begins unless they are marked as down.
+type ScenarioNode struct {
+	Name     string   `json:"name"`
+	Services []string `json:"services,omitempty"`
+	Down     bool     `json:"down,omitempty"`
+}
+
+// ScenarioLink is the model of a network link, see adapters.LinkConfig.
+type ScenarioLink struct {
+	Latency   Duration `json:"latency,omitempty"`
+	Bandwidth int      `json:"bandwidth,omitempty"` // bytes per second
+	DropRate  float64  `json:"dropRate,omitempty"`
+}
+
+func (l *ScenarioLink) config() adapters.LinkConfig {
+	return adapters.LinkConfig{
+		Latency:   time.Duration(l.Latency),
+		Bandwidth: l.Bandwidth,
+		DropRate:  l.DropRate,
+	}
+}
+
+// Topology types of a scenario.
+const (
+	TopologyChain = "chain" // every node connects to the next one
+	TopologyRing  = "ring"  // a chain whose last node connects to the first
+	TopologyStar  = "star"  // all nodes connect to the center node
+	TopologyFull  = "full"  // all nodes connect to each other
+)
+
+// ScenarioTopology describes the initial connections of a scenario. The nodes
+// are connected in the order they are listed in the scenario.
+type ScenarioTopology struct {
+	Type   string `json:"type"`
+	Center string `json:"center,omitempty"` // center of a star, the first node if empty
+}
+
+// Actions of scenario events.
+const (
+	ActionConnect    = "connect"    // connect the two nodes
+	ActionDisconnect = "disconnect" // disconnect the two nodes
+	ActionCrash      = "crash"      // stop the nodes
+	ActionRestart    = "restart"    // start the stopped nodes again
+	ActionPartition  = "partition"  // cut the links between the groups
+	ActionHeal       = "heal"       // restore the links cut by partitions
+	ActionLink       = "link"       // change the link between two nodes, or all links
+)
+
+// ScenarioEvent is an action performed at a fixed time after the scenario
+// started. Partitions take the node groups, all other actions the nodes.
+type ScenarioEvent struct {
+	At     Duration      `json:"at"`
+	Action string        `json:"action"`
+	Nodes  []string      `json:"nodes,omitempty"`
+	Groups [][]string    `json:"groups,omitempty"`
+	Link   *ScenarioLink `json:"link,omitempty"`
+}
+
+func (e *ScenarioEvent) String() string {
+	if e.Action == ActionPartition {
+		groups := make([]string, len(e.Groups))
+		for i, g := range e.Groups {
+			groups[i] = strings.Join(g, ",")
+		}
+		return fmt.Sprintf("%s %s", e.Action, strings.Join(groups, " | "))
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %s", e.Action, strings.Join(e.Nodes, " ")))
+}
+
+// Assertion types of a scenario.
+const (
+	AssertConnected    = "connected"    // the two nodes are connected
+	AssertDisconnected = "disconnected" // the two nodes are not connected
+	AssertUp           = "up"           // the nodes are running
+	AssertDown         = "down"         // the nodes are stopped
+	AssertPeers        = "peers"        // the nodes have exactly count peers
+)
+
+// ScenarioAssertion is a condition on the network which must hold after all
+// events of the scenario have been performed. Connections are established
+// asynchronously, so the condition may take up to the given duration to hold.
+type ScenarioAssertion struct {
+	Type   string   `json:"type"`
+	Nodes  []string `json:"nodes"`
+	Count  int      `json:"count,omitempty"`
+	Within Duration `json:"within,omitempty"`
+}
+
+func (a *ScenarioAssertion) String() string {
+	s := fmt.Sprintf("%s %s", a.Type, strings.Join(a.Nodes, " "))
+	if a.Type == AssertPeers {
+		s += fmt.Sprintf(" = %d", a.Count)
+	}
+	return s
+}
+
+// LoadScenario reads and validates a JSON scenario file.
+func LoadScenario(file string) (*Scenario, error) {
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	scenario := new(Scenario)
+	if err := json.Unmarshal(blob, scenario); err != nil {
+		return nil, fmt.Errorf("invalid scenario file %s: %v", file, err)
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario file %s: %v", file, err)
+	}
+	return scenario, nil
+}
+
+// Validate checks that the scenario is well-formed and only refers to nodes it
+// defines.
+func (s *Scenario) Validate() error {
+	if len(s.Nodes) == 0 {
+		return errors.New("scenario has no nodes")
+	}
+	names := make(map[string]bool, len(s.Nodes))
+	for i, n := range s.Nodes {
+		if n.Name == "" {
+			return fmt.Errorf("node %d has no name", i)
+		}
+		if names[n.Name] {
+			return fmt.Errorf("duplicate node %q", n.Name)
+		}
+		names[n.Name] = true
+		if len(n.Services) == 0 && len(s.Services) == 0 {
+			return fmt.Errorf("node %q has no services", n.Name)
+		}
+	}
+	checkNodes := func(nodes []string, want int) error {
+		if want > 0 && len(nodes) != want {
+			return fmt.Errorf("got %d nodes, want %d", len(nodes), want)
+		}
+		if len(nodes) == 0 {
+			return errors.New("no nodes given")
+		}
+		for _, name := range nodes {
+			if !names[name] {
+				return fmt.Errorf("unknown node %q", name)
+			}
+		}
+		return nil
+	}
+
+	if t := s.Topology; t != nil {
+		switch t.Type {
+		case TopologyChain, TopologyRing, TopologyFull:
+		case TopologyStar:
+			if t.Center != "" && !names[t.Center] {
+				return fmt.Errorf("unknown star center %q", t.Center)
+			}
+		default:
+			return fmt.Errorf("unknown topology %q", t.Type)
+		}
+	}
+	for i, e := range s.Events {
+		var err error
+		switch e.Action {
+		case ActionConnect, ActionDisconnect:
+			err = checkNodes(e.Nodes, 2)
+		case ActionCrash, ActionRestart:
+			err = checkNodes(e.Nodes, 0)
+		case ActionPartition:
+			if len(e.Groups) < 2 {
+				err = errors.New("partition needs at least two groups")
+			}
+			seen := make(map[string]bool)
+			for _, g := range e.Groups {
+				if err == nil {
+					err = checkNodes(g, 0)
+				}
+				for _, name := range g {
+					if seen[name] && err == nil {
+						err = fmt.Errorf("node %q is in multiple groups", name)
+					}
+					seen[name] = true
+				}
+			}
+		case ActionHeal:
+		case ActionLink:
+			if e.Link == nil {
+				err = errors.New("no link given")
+			} else if len(e.Nodes) > 0 {
+				err = checkNodes(e.Nodes, 2)
+			}
+		default:
+			err = fmt.Errorf("unknown action %q", e.Action)
+		}
+		if err != nil {
+			return fmt.Errorf("event %d (%s): %v", i, e.Action, err)
+		}
+	}
+	for i, a := range s.Assertions {
+		var err error
+		switch a.Type {
+		case AssertConnected, AssertDisconnected:
+			err = checkNodes(a.Nodes, 2)
+		case AssertUp, AssertDown, AssertPeers:
+			err = checkNodes(a.Nodes, 0)
+		default:
+			err = fmt.Errorf("unknown assertion %q", a.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("assertion %d (%s): %v", i, a.Type, err)
+		}
+	}
+	return nil
+}
+
+// ScenarioReport is the outcome of a scenario run.
+type ScenarioReport struct {
+	Name       string             `json:"name"`
+	Pass       bool               `json:"pass"`
+	Error      string             `json:"error,omitempty"` // error setting up the network
+	StartedAt  time.Time          `json:"startedAt"`
+	FinishedAt time.Time          `json:"finishedAt"`
+	Nodes      []*NodeResult      `json:"nodes"`
+	Events     []*EventResult     `json:"events"`
+	Assertions []*AssertionResult `json:"assertions"`
+
+	// Log holds the events emitted by the network during the run.
+	Log []*Event `json:"log"`
+}
+
+// NodeResult identifies a node created for the scenario.
+type NodeResult struct {
+	Name string          `json:"name"`
+	ID   discover.NodeID `json:"id"`
+}
+
+// EventResult is the outcome of a scenario event.
+type EventResult struct {
+	Event *ScenarioEvent `json:"event"`
+	Time  time.Time      `json:"time"`
+	Error string         `json:"error,omitempty"`
+}
+
+// AssertionResult is the outcome of a scenario assertion.
+type AssertionResult struct {
+	Assertion *ScenarioAssertion `json:"assertion"`
+	Pass      bool               `json:"pass"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// RunScenario runs a scenario in an empty network. The nodes are left running
+// when the scenario finishes, it's up to the caller to shut the network down.
+func RunScenario(ctx context.Context, net *Network, scenario *Scenario) *ScenarioReport {
+	report := &ScenarioReport{
+		Name:       scenario.Name,
+		StartedAt:  time.Now(),
+		Nodes:      []*NodeResult{},
+		Events:     []*EventResult{},
+		Assertions: []*AssertionResult{},
+	}
+	defer func() { report.FinishedAt = time.Now() }()
+
+	if err := scenario.Validate(); err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	// record all network events for the duration of the run
+	stop := recordEvents(net, &report.Log)
+	defer stop()
+
+	r := &scenarioRun{net: net, scenario: scenario, ids: make(map[string]discover.NodeID)}
+	err := r.setup()
+	for _, n := range scenario.Nodes {
+		if id, ok := r.ids[n.Name]; ok {
+			report.Nodes = append(report.Nodes, &NodeResult{Name: n.Name, ID: id})
+		}
+	}
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	// perform the events in order of their time
+	events := make([]*ScenarioEvent, len(scenario.Events))
+	copy(events, scenario.Events)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At < events[j].At })
+
+	start := time.Now()
+	for _, e := range events {
+		select {
+		case <-time.After(time.Until(start.Add(time.Duration(e.At)))):
+		case <-ctx.Done():
+			report.Error = ctx.Err().Error()
+			return report
+		}
+		result := &EventResult{Event: e, Time: time.Now()}
+		if err := r.perform(e); err != nil {
+			result.Error = err.Error()
+		}
+		report.Events = append(report.Events, result)
+	}
+
+	// check the assertions
+	for _, a := range scenario.Assertions {
+		result := &AssertionResult{Assertion: a}
+		if err := r.check(ctx, a); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Pass = true
+		}
+		report.Assertions = append(report.Assertions, result)
+	}
+
+	report.Pass = true
+	for _, e := range report.Events {
+		report.Pass = report.Pass && e.Error == ""
+	}
+	for _, a := range report.Assertions {
+		report.Pass = report.Pass && a.Pass
+	}
+	return report
+}
+
+// recordEvents appends the events of the network to log until the returned
+// function is called.
+func recordEvents(net *Network, log *[]*Event) func() {
+	var (
+		events = make(chan *Event, 100)
+		sub    = net.Events().Subscribe(events)
+		stop   = make(chan struct{})
+		done   = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case event := <-events:
+				*log = append(*log, event)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// scenarioRun is the state of a running scenario.
+type scenarioRun struct {
+	net      *Network
+	scenario *Scenario
+	ids      map[string]discover.NodeID
+}
+
+// setup creates and starts the nodes of the scenario and connects them
+// according to the topology.
+func (r *scenarioRun) setup() error {
+	if r.scenario.Link != nil {
+		if err := r.net.SetDefaultLink(r.scenario.Link.config()); err != nil {
+			return err
+		}
+	}
+	var nodes []discover.NodeID
+	for _, n := range r.scenario.Nodes {
+		conf := adapters.RandomNodeConfig()
+		conf.Name = n.Name
+		conf.Services = n.Services
+		if len(conf.Services) == 0 {
+			conf.Services = r.scenario.Services
+		}
+		node, err := r.net.NewNodeWithConfig(conf)
+		if err != nil {
+			return fmt.Errorf("error creating node %q: %v", n.Name, err)
+		}
+		r.ids[n.Name] = node.ID()
+		nodes = append(nodes, node.ID())
+	}
+	for _, n := range r.scenario.Nodes {
+		if n.Down {
+			continue
+		}
+		if err := r.net.Start(r.ids[n.Name]); err != nil {
+			return fmt.Errorf("error starting node %q: %v", n.Name, err)
+		}
+	}
+
+	t := r.scenario.Topology
+	if t == nil {
+		return nil
+	}
+	var conns [][2]discover.NodeID
+	switch t.Type {
+	case TopologyChain, TopologyRing:
+		for i := 1; i < len(nodes); i++ {
+			conns = append(conns, [2]discover.NodeID{nodes[i-1], nodes[i]})
+		}
+		if t.Type == TopologyRing && len(nodes) > 2 {
+			conns = append(conns, [2]discover.NodeID{nodes[len(nodes)-1], nodes[0]})
+		}
+	case TopologyStar:
+		center := nodes[0]
+		if t.Center != "" {
+			center = r.ids[t.Center]
+		}
+		for _, id := range nodes {
+			if id != center {
+				conns = append(conns, [2]discover.NodeID{id, center})
+			}
+		}
+	case TopologyFull:
+		for i := range nodes {
+			for j := i + 1; j < len(nodes); j++ {
+				conns = append(conns, [2]discover.NodeID{nodes[i], nodes[j]})
+			}
+		}
+	}
+	for _, c := range conns {
+		if err := r.net.Connect(c[0], c[1]); err != nil {
+			return fmt.Errorf("error connecting %s to %s: %v", c[0].TerminalString(), c[1].TerminalString(), err)
+		}
+	}
+	return nil
+}
+
+// perform executes a scenario event.
+func (r *scenarioRun) perform(e *ScenarioEvent) error {
+	switch e.Action {
+	case ActionConnect:
+		return r.net.Connect(r.ids[e.Nodes[0]], r.ids[e.Nodes[1]])
+	case ActionDisconnect:
+		return r.net.Disconnect(r.ids[e.Nodes[0]], r.ids[e.Nodes[1]])
+	case ActionCrash:
+		for _, name := range e.Nodes {
+			if err := r.net.Stop(r.ids[name]); err != nil {
+				return err
+			}
+		}
+	case ActionRestart:
+		for _, name := range e.Nodes {
+			if err := r.net.Start(r.ids[name]); err != nil {
+				return err
+			}
+		}
+	case ActionPartition:
+		groups := make([][]discover.NodeID, len(e.Groups))
+		for i, g := range e.Groups {
+			for _, name := range g {
+				groups[i] = append(groups[i], r.ids[name])
+			}
+		}
+		return r.net.Partition(groups...)
+	case ActionHeal:
+		return r.net.Heal()
+	case ActionLink:
+		if len(e.Nodes) == 0 {
+			return r.net.SetDefaultLink(e.Link.config())
+		}
+		return r.net.SetLink(r.ids[e.Nodes[0]], r.ids[e.Nodes[1]], e.Link.config())
+	}
+	return nil
+}
+
+// check waits until an assertion holds, returning an error if it doesn't hold
+// within the assertion timeout.
+func (r *scenarioRun) check(ctx context.Context, a *ScenarioAssertion) error {
+	timeout := time.Duration(a.Within)
+	if timeout == 0 {
+		timeout = defaultAssertTimeout
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	poll := time.NewTicker(assertPollInterval)
+	defer poll.Stop()
+
+	for {
+		err := r.holds(a)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-poll.C:
+		case <-deadline.C:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// holds returns an error describing why the assertion doesn't hold currently.
+func (r *scenarioRun) holds(a *ScenarioAssertion) error {
+	r.net.lock.RLock()
+	defer r.net.lock.RUnlock()
+
+	switch a.Type {
+	case AssertConnected, AssertDisconnected:
+		var up bool
+		if c := r.net.getConn(r.ids[a.Nodes[0]], r.ids[a.Nodes[1]]); c != nil {
+			up = c.Up
+		}
+		if up != (a.Type == AssertConnected) {
+			return fmt.Errorf("%s and %s are not %s", a.Nodes[0], a.Nodes[1], a.Type)
+		}
+	case AssertUp, AssertDown:
+		for _, name := range a.Nodes {
+			if r.net.getNode(r.ids[name]).Up != (a.Type == AssertUp) {
+				return fmt.Errorf("%s is not %s", name, a.Type)
+			}
+		}
+	case AssertPeers:
+		for _, name := range a.Nodes {
+			id, peers := r.ids[name], 0
+			for _, c := range r.net.Conns {
+				if c.Up && (c.One == id || c.Other == id) {
+					peers++
+				}
+			}
+			if peers != a.Count {
+				return fmt.Errorf("%s has %d peers, want %d", name, peers, a.Count)
+			}
+		}
+	}
+	return nil
+}
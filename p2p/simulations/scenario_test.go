@@ -0,0 +1,112 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/p2p/simulations/adapters"
+)
+
+const testScenario = `{
+  "name":     "partitioned ring",
+  "services": ["test"],
+  "nodes":    [{"name": "a"}, {"name": "b"}, {"name": "c"}, {"name": "d"}],
+  "topology": {"type": "ring"},
+  "events": [
+    {"at": "1s",   "action": "partition", "groups": [["a", "b"], ["c", "d"]]},
+    {"at": "1.5s", "action": "crash", "nodes": ["d"]}
+  ],
+  "assertions": [
+    {"type": "connected", "nodes": ["a", "b"]},
+    {"type": "disconnected", "nodes": ["b", "c"]},
+    {"type": "down", "nodes": ["d"]},
+    {"type": "peers", "nodes": ["a", "b"], "count": 1},
+    {"type": "peers", "nodes": ["c"], "count": 0, "within": "5s"}
+  ]
+}`
+
+func TestRunScenario(t *testing.T) {
+	scenario := new(Scenario)
+	if err := json.Unmarshal([]byte(testScenario), scenario); err != nil {
+		t.Fatal(err)
+	}
+	adapter := adapters.NewSimAdapter(adapters.Services{"test": newTestService})
+	network := NewNetwork(adapter, &NetworkConfig{})
+	defer network.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	report := RunScenario(ctx, network, scenario)
+
+	if report.Error != "" {
+		t.Fatalf("scenario setup failed: %s", report.Error)
+	}
+	for _, e := range report.Events {
+		if e.Error != "" {
+			t.Errorf("event %q failed: %s", e.Event, e.Error)
+		}
+	}
+	for _, a := range report.Assertions {
+		if !a.Pass {
+			t.Errorf("assertion %q failed: %s", a.Assertion, a.Error)
+		}
+	}
+	if !report.Pass {
+		t.Error("report doesn't pass")
+	}
+	if len(report.Log) == 0 {
+		t.Error("no network events recorded")
+	}
+}
+
+func TestScenarioValidate(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{`{"nodes": []}`, "scenario has no nodes"},
+		{`{"nodes": [{"name": "a"}]}`, `node "a" has no services`},
+		{`{"services": ["test"], "nodes": [{"name": "a"}, {"name": "a"}]}`, `duplicate node "a"`},
+		{`{"services": ["test"], "nodes": [{"name": "a"}], "topology": {"type": "mesh"}}`, `unknown topology "mesh"`},
+		{`{"services": ["test"], "nodes": [{"name": "a"}], "events": [{"action": "connect", "nodes": ["a", "b"]}]}`, `event 0 (connect): unknown node "b"`},
+		{`{"services": ["test"], "nodes": [{"name": "a"}], "events": [{"action": "crash"}]}`, `event 0 (crash): no nodes given`},
+		{`{"services": ["test"], "nodes": [{"name": "a"}, {"name": "b"}], "events": [{"action": "partition", "groups": [["a"], ["a", "b"]]}]}`, `event 0 (partition): node "a" is in multiple groups`},
+		{`{"services": ["test"], "nodes": [{"name": "a"}], "assertions": [{"type": "synced", "nodes": ["a"]}]}`, `assertion 0 (synced): unknown assertion "synced"`},
+	}
+	for _, test := range tests {
+		scenario := new(Scenario)
+		if err := json.Unmarshal([]byte(test.input), scenario); err != nil {
+			t.Fatalf("invalid test input %s: %v", test.input, err)
+		}
+		err := scenario.Validate()
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("wrong error for %s:\ngot:  %v\nwant: %s", test.input, err, test.err)
+		}
+	}
+	if err := json.Unmarshal([]byte(testScenario), new(Scenario)); err != nil {
+		t.Fatal(err)
+	}
+	var d Duration
+	if err := json.Unmarshal([]byte(`5`), &d); err == nil {
+		t.Error("numeric duration accepted")
+	}
+}
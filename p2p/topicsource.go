@@ -0,0 +1,150 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/common/mclock"
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/p2p/discv5"
+)
+
+const (
+	topicSearchFast     = 100 * time.Millisecond // search period until the topic radius converges
+	topicSearchSlow     = time.Minute            // search period after the topic radius converged
+	topicConvergeTime   = time.Minute            // time after the first converged lookup to slow down
+	topicConvergeLookup = 50                     // converged lookups after which the search slows down
+	maxTopicNodes       = 200                    // number of found nodes kept as dial candidates
+)
+
+// topicSearcher is implemented by discv5.Network.
+type topicSearcher interface {
+	SearchTopic(topic discv5.Topic, setPeriod <-chan time.Duration, found chan<- *discv5.Node, lookup chan<- bool)
+}
+
+// topicSource is a node source providing the nodes advertising a discovery v5
+// topic. The search runs quickly until the topic radius has been estimated and
+// slows down afterwards.
+type topicSource struct {
+	topic     discv5.Topic
+	setPeriod chan time.Duration
+	found     chan *discv5.Node
+	lookups   chan bool
+	quit      chan struct{}
+	wg        sync.WaitGroup
+
+	lock  sync.Mutex
+	nodes []*discover.Node // found nodes, least recently found first
+}
+
+// newTopicSource starts searching for the nodes advertising topic.
+func newTopicSource(searcher topicSearcher, topic discv5.Topic) *topicSource {
+	s := &topicSource{
+		topic:     topic,
+		setPeriod: make(chan time.Duration, 1),
+		found:     make(chan *discv5.Node, 100),
+		lookups:   make(chan bool, 100),
+		quit:      make(chan struct{}),
+	}
+	go searcher.SearchTopic(topic, s.setPeriod, s.found, s.lookups)
+	s.wg.Add(1)
+	go s.loop()
+	return s
+}
+
+// ReadRandomNodes fills the given slice with random nodes found by the search
+// and returns the number of nodes written.
+func (s *topicSource) ReadRandomNodes(buf []*discover.Node) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n := 0
+	for _, i := range rand.Perm(len(s.nodes)) {
+		if n == len(buf) {
+			break
+		}
+		buf[n] = s.nodes[i]
+		n++
+	}
+	return n
+}
+
+// Close stops the topic search.
+func (s *topicSource) Close() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+func (s *topicSource) loop() {
+	defer s.wg.Done()
+
+	var (
+		converged int
+		convTime  mclock.AbsTime
+		fast      = true
+	)
+	s.setPeriod <- topicSearchFast
+	for {
+		select {
+		case n := <-s.found:
+			s.add(discover.NewNode(discover.NodeID(n.ID), n.IP, n.UDP, n.TCP))
+
+		case conv := <-s.lookups:
+			if !conv || !fast {
+				continue
+			}
+			if converged == 0 {
+				convTime = mclock.Now()
+			}
+			converged++
+			if converged >= topicConvergeLookup || time.Duration(mclock.Now()-convTime) > topicConvergeTime {
+				fast = false
+				select {
+				case s.setPeriod <- topicSearchSlow:
+				case <-s.quit:
+					close(s.setPeriod)
+					return
+				}
+			}
+
+		case <-s.quit:
+			close(s.setPeriod)
+			return
+		}
+	}
+}
+
+// add inserts a found node, replacing the previous entry of the same node. The
+// least recently found node is dropped if the source is full.
+func (s *topicSource) add(n *discover.Node) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i, old := range s.nodes {
+		if old.ID == n.ID {
+			s.nodes = append(s.nodes[:i], s.nodes[i+1:]...)
+			break
+		}
+	}
+	if len(s.nodes) == maxTopicNodes {
+		s.nodes = s.nodes[1:]
+	}
+	s.nodes = append(s.nodes, n)
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/p2p/discv5"
+)
+
+// fakeSearcher hands the channels of a topic search to the test.
+type fakeSearcher struct {
+	periods chan time.Duration
+	found   chan chan<- *discv5.Node
+	lookups chan chan<- bool
+}
+
+func (s *fakeSearcher) SearchTopic(topic discv5.Topic, setPeriod <-chan time.Duration, found chan<- *discv5.Node, lookup chan<- bool) {
+	s.found <- found
+	s.lookups <- lookup
+	for period := range setPeriod {
+		s.periods <- period
+	}
+	close(s.periods)
+}
+
+func TestTopicSource(t *testing.T) {
+	searcher := &fakeSearcher{
+		periods: make(chan time.Duration, 10),
+		found:   make(chan chan<- *discv5.Node, 1),
+		lookups: make(chan chan<- bool, 1),
+	}
+	src := newTopicSource(searcher, "test")
+	found, lookups := <-searcher.found, <-searcher.lookups
+
+	if period := <-searcher.periods; period != topicSearchFast {
+		t.Fatalf("wrong initial search period: got %v, want %v", period, topicSearchFast)
+	}
+	// Found nodes become dial candidates, duplicates are replaced.
+	found <- discv5.NewNode(discv5.NodeID{1}, net.IP{10, 0, 0, 1}, 30303, 30303)
+	found <- discv5.NewNode(discv5.NodeID{2}, net.IP{10, 0, 0, 2}, 30303, 30303)
+	found <- discv5.NewNode(discv5.NodeID{1}, net.IP{10, 0, 0, 3}, 30303, 30303)
+	for i := 0; i < topicConvergeLookup; i++ {
+		lookups <- true
+	}
+	if period := <-searcher.periods; period != topicSearchSlow {
+		t.Fatalf("wrong search period after convergence: got %v, want %v", period, topicSearchSlow)
+	}
+	buf := make([]*discover.Node, 10)
+	nodes := make(map[discover.NodeID]string)
+	for _, n := range buf[:src.ReadRandomNodes(buf)] {
+		nodes[n.ID] = n.IP.String()
+	}
+	if len(nodes) != 2 || nodes[discover.NodeID{1}] != "10.0.0.3" || nodes[discover.NodeID{2}] != "10.0.0.2" {
+		t.Errorf("wrong source nodes: %v", nodes)
+	}
+
+	// Closing the source stops the search.
+	src.Close()
+	if _, ok := <-searcher.periods; ok {
+		t.Error("search not stopped after close")
+	}
+}
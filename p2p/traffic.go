@@ -0,0 +1,123 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import "sync"
+
+// MsgTraffic counts the messages and payload bytes transferred in one direction.
+type MsgTraffic struct {
+	Messages uint64 `json:"messages"`
+	Bytes    uint64 `json:"bytes"`
+}
+
+func (t *MsgTraffic) add(size uint32) {
+	t.Messages++
+	t.Bytes += uint64(size)
+}
+
+// MsgCodeTraffic is the traffic of a single message code in both directions.
+type MsgCodeTraffic struct {
+	Ingress MsgTraffic `json:"ingress"`
+	Egress  MsgTraffic `json:"egress"`
+}
+
+// PeerTrafficInfo is the traffic summary of a peer connection. Per message code
+// counters are grouped by protocol name ("p2p" for the base protocol) and use
+// the message codes of the protocol, not the offset codes sent on the wire.
+type PeerTrafficInfo struct {
+	Ingress   MsgTraffic                            `json:"ingress"`
+	Egress    MsgTraffic                            `json:"egress"`
+	Protocols map[string]map[uint64]*MsgCodeTraffic `json:"protocols"`
+}
+
+// peerTraffic accumulates the traffic of a peer connection by wire message code.
+// It is safe for concurrent use.
+type peerTraffic struct {
+	lock    sync.Mutex
+	ingress MsgTraffic
+	egress  MsgTraffic
+	codes   map[uint64]*MsgCodeTraffic
+}
+
+func newPeerTraffic() *peerTraffic {
+	return &peerTraffic{codes: make(map[uint64]*MsgCodeTraffic)}
+}
+
+// markIngress records a message received from the peer.
+func (t *peerTraffic) markIngress(code uint64, size uint32) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.ingress.add(size)
+	t.code(code).Ingress.add(size)
+}
+
+// markEgress records a message sent to the peer.
+func (t *peerTraffic) markEgress(code uint64, size uint32) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.egress.add(size)
+	t.code(code).Egress.add(size)
+}
+
+// code returns the counters of a wire message code, creating them if needed.
+// The lock must be held.
+func (t *peerTraffic) code(code uint64) *MsgCodeTraffic {
+	c := t.codes[code]
+	if c == nil {
+		c = new(MsgCodeTraffic)
+		t.codes[code] = c
+	}
+	return c
+}
+
+// info returns a copy of the counters. The protocol function resolves a wire
+// message code to the protocol name and the code within that protocol.
+func (t *peerTraffic) info(protocol func(code uint64) (string, uint64)) *PeerTrafficInfo {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	info := &PeerTrafficInfo{
+		Ingress:   t.ingress,
+		Egress:    t.egress,
+		Protocols: make(map[string]map[uint64]*MsgCodeTraffic),
+	}
+	for wire, c := range t.codes {
+		name, code := protocol(wire)
+		if info.Protocols[name] == nil {
+			info.Protocols[name] = make(map[uint64]*MsgCodeTraffic)
+		}
+		cpy := *c
+		info.Protocols[name][code] = &cpy
+	}
+	return info
+}
+
+// meteredMsgWriter counts the messages written to a peer.
+type meteredMsgWriter struct {
+	MsgWriter
+	traffic *peerTraffic
+}
+
+func (w *meteredMsgWriter) WriteMsg(msg Msg) error {
+	if err := w.MsgWriter.WriteMsg(msg); err != nil {
+		return err
+	}
+	w.traffic.markEgress(msg.Code, msg.Size)
+	return nil
+}
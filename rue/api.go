@@ -19,6 +19,7 @@ package eth
 import (
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -198,6 +199,82 @@ func (api *PrivateMinerAPI) GetHashrate() uint64 {
 	return uint64(api.e.miner.HashRate())
 }
 
+// SetOrdering selects the policy ordering pending transactions for inclusion
+// into mined blocks (price, localfirst or fifo).
+func (api *PrivateMinerAPI) SetOrdering(name string) (bool, error) {
+	ordering, err := miner.OrderingByName(name)
+	if err != nil {
+		return false, err
+	}
+	api.e.Miner().SetOrdering(ordering)
+	return true, nil
+}
+
+// InclusionRules returns the rules filtering pending transactions from inclusion
+// into mined blocks.
+func (api *PrivateMinerAPI) InclusionRules() miner.InclusionConfig {
+	return api.e.Miner().Inclusion()
+}
+
+// SetBlacklist replaces the set of addresses whose transactions, either as
+// sender or recipient, are never included into mined blocks.
+func (api *PrivateMinerAPI) SetBlacklist(addrs []common.Address) bool {
+	api.e.lock.Lock()
+	defer api.e.lock.Unlock()
+
+	rules := api.e.Miner().Inclusion()
+	rules.Blacklist = addrs
+	api.e.Miner().SetInclusion(rules)
+	return true
+}
+
+// SetMinGasPrice sets the minimum gas price a transaction needs to be included
+// into mined blocks, separately for the "local" and "remote" sender classes.
+func (api *PrivateMinerAPI) SetMinGasPrice(class string, gasPrice hexutil.Big) (bool, error) {
+	api.e.lock.Lock()
+	defer api.e.lock.Unlock()
+
+	rules := api.e.Miner().Inclusion()
+	switch class {
+	case "local":
+		rules.LocalMinPrice = (*big.Int)(&gasPrice)
+	case "remote":
+		rules.RemoteMinPrice = (*big.Int)(&gasPrice)
+	default:
+		return false, fmt.Errorf("unknown sender class %q", class)
+	}
+	api.e.Miner().SetInclusion(rules)
+	return true, nil
+}
+
+// SetMaxSenderGas limits the gas a single sender may use within a mined block.
+// Zero removes the limit.
+func (api *PrivateMinerAPI) SetMaxSenderGas(gas hexutil.Uint64) bool {
+	api.e.lock.Lock()
+	defer api.e.lock.Unlock()
+
+	rules := api.e.Miner().Inclusion()
+	rules.MaxSenderGas = uint64(gas)
+	api.e.Miner().SetInclusion(rules)
+	return true
+}
+
+// SetStratumDifficulty overrides the share difficulty of a stratum worker (1 =
+// 2^32 hashes), notifying its connected sessions of the change.
+func (api *PrivateMinerAPI) SetStratumDifficulty(worker string, difficulty float64) (bool, error) {
+	if api.e.stratum == nil {
+		return false, errors.New("stratum server not enabled")
+	}
+	if worker == "" {
+		return false, errors.New("empty worker name")
+	}
+	if difficulty <= 0 {
+		return false, fmt.Errorf("invalid difficulty %v", difficulty)
+	}
+	api.e.stratum.SetWorkerDifficulty(worker, difficulty)
+	return true, nil
+}
+
 // PrivateAdminAPI is the collection of Ruereum full node-related APIs
 // exposed over the private admin endpoint.
 type PrivateAdminAPI struct {
@@ -17,12 +17,17 @@
 package eth
 
 import (
+	"encoding/json"
+	"net"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/consensus/ruehash"
 	"github.com/Rue-Foundation/go-rue/core/state"
+	"github.com/Rue-Foundation/go-rue/miner"
 	"github.com/Rue-Foundation/go-rue/ruedb"
 )
 
@@ -89,3 +94,56 @@ func TestStorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that stratum worker difficulties can be overridden through the miner API.
+func TestSetStratumDifficulty(t *testing.T) {
+	api := NewPrivateMinerAPI(&Ruereum{})
+	if _, err := api.SetStratumDifficulty("rig", 2); err == nil {
+		t.Fatal("difficulty set without stratum server")
+	}
+	server, err := miner.NewStratumServer(nil, ruehash.NewTester(), miner.StratumConfig{Addr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("failed to create stratum server: %v", err)
+	}
+	if err := server.Listen(); err != nil {
+		t.Fatalf("failed to start stratum server: %v", err)
+	}
+	defer server.Close()
+	api = NewPrivateMinerAPI(&Ruereum{stratum: server})
+
+	if _, err := api.SetStratumDifficulty("rig", 0); err == nil {
+		t.Fatal("invalid difficulty accepted")
+	}
+	// Authorize a worker and check that the override is pushed to it
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial stratum server: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var (
+		enc = json.NewEncoder(conn)
+		dec = json.NewDecoder(conn)
+		msg struct {
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+	)
+	enc.Encode(map[string]interface{}{"id": 1, "method": "mining.subscribe", "params": []string{"tester", miner.StratumProtocol}})
+	enc.Encode(map[string]interface{}{"id": 2, "method": "mining.authorize", "params": []string{"rig", "x"}})
+	for msg.Method != "mining.set_difficulty" {
+		if err := dec.Decode(&msg); err != nil {
+			t.Fatalf("failed to read authorization: %v", err)
+		}
+	}
+	if ok, err := api.SetStratumDifficulty("rig", 2); !ok || err != nil {
+		t.Fatalf("failed to set difficulty: %v", err)
+	}
+	if err := dec.Decode(&msg); err != nil {
+		t.Fatalf("failed to read difficulty: %v", err)
+	}
+	if msg.Method != "mining.set_difficulty" || string(msg.Params) != "[2]" {
+		t.Errorf("difficulty override not announced: %s %s", msg.Method, msg.Params)
+	}
+}
@@ -54,6 +54,7 @@ type LesServer interface {
 	Start(srvr *p2p.Server)
 	Stop()
 	Protocols() []p2p.Protocol
+	APIs() []rpc.API
 	SetBloomBitsIndexer(bbIndexer *core.ChainIndexer)
 }
 
@@ -85,6 +86,7 @@ type Ruereum struct {
 	ApiBackend *RueApiBackend
 
 	miner     *miner.Miner
+	stratum   *miner.StratumServer // Optional stratum endpoint for remote miners
 	gasPrice  *big.Int
 	etherbase common.Address
 
@@ -163,12 +165,34 @@ func New(ctx *node.ServiceContext, config *Config) (*Ruereum, error) {
 	}
 	eth.txPool = core.NewTxPool(config.TxPool, eth.chainConfig, eth.blockchain)
 
-	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb); err != nil {
+	// Trust the configured sync checkpoint, falling back to the genesis one
+	checkpoint := config.SyncCheckpoint
+	if checkpoint == nil {
+		checkpoint = eth.chainConfig.SyncCheckpoint
+	}
+	if checkpoint != nil {
+		log.Info("Trusted sync checkpoint configured", "checkpoint", checkpoint)
+	}
+	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, checkpoint, config.NetworkId, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb); err != nil {
 		return nil, err
 	}
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine)
 	eth.miner.SetExtra(makeExtraData(config.ExtraData))
 
+	ordering, err := miner.OrderingByName(config.MinerOrdering)
+	if err != nil {
+		return nil, err
+	}
+	eth.miner.SetOrdering(ordering)
+	eth.miner.SetInclusion(config.MinerInclusion)
+
+	if config.Stratum.Addr != "" {
+		if eth.stratum, err = miner.NewStratumServer(eth.blockchain, eth.engine, config.Stratum); err != nil {
+			return nil, err
+		}
+		eth.miner.Register(eth.stratum)
+	}
+
 	eth.ApiBackend = &RueApiBackend{eth, nil}
 	gpoParams := config.GPO
 	if gpoParams.Default == nil {
@@ -247,6 +271,11 @@ func (s *Ruereum) APIs() []rpc.API {
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
 
+	// Append the light server management APIs if serving light clients
+	if s.lesServer != nil {
+		apis = append(apis, s.lesServer.APIs()...)
+	}
+
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -400,9 +429,18 @@ func (s *Ruereum) Start(srvr *p2p.Server) error {
 	}
 	// Start the networking layer and the light server if requested
 	s.protocolManager.Start(maxPeers)
+	if srvr.DiscV5 != nil {
+		s.protocolManager.advertise(srvr.DiscV5)
+	}
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
 	}
+	// Open the stratum endpoint if remote miners are served
+	if s.stratum != nil {
+		if err := s.stratum.Listen(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -420,6 +458,9 @@ func (s *Ruereum) Stop() error {
 	}
 	s.txPool.Stop()
 	s.miner.Stop()
+	if s.stratum != nil {
+		s.stratum.Close()
+	}
 	s.eventMux.Stop()
 
 	s.chainDb.Close()
@@ -27,6 +27,8 @@ import (
 	"github.com/Rue-Foundation/go-rue/common/hexutil"
 	"github.com/Rue-Foundation/go-rue/consensus/ruehash"
 	"github.com/Rue-Foundation/go-rue/core"
+	"github.com/Rue-Foundation/go-rue/light"
+	"github.com/Rue-Foundation/go-rue/miner"
 	"github.com/Rue-Foundation/go-rue/rue/downloader"
 	"github.com/Rue-Foundation/go-rue/rue/gasprice"
 	"github.com/Rue-Foundation/go-rue/params"
@@ -79,10 +81,20 @@ type Config struct {
 	NetworkId uint64 // Network ID to use for selecting peers to connect to
 	SyncMode  downloader.SyncMode
 
+	// Trusted block the synced chain must contain, overriding the genesis one
+	SyncCheckpoint *params.SyncCheckpoint `toml:",omitempty"`
+
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
 
+	// Trusted CHT checkpoint to start light syncing from
+	LightCheckpoint *light.TrustedCheckpoint `toml:",omitempty"`
+
+	// Ultra light client options, trusting heads announced by the given servers
+	UltraLightServers   []string `toml:",omitempty"` // Enode URLs of the trusted LES servers
+	UltraLightThreshold int      `toml:",omitempty"` // Number of trusted servers that must announce a head, 0 for all
+
 	// Database options
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
@@ -94,6 +106,13 @@ type Config struct {
 	ExtraData    []byte         `toml:",omitempty"`
 	GasPrice     *big.Int
 
+	// Block inclusion options
+	MinerOrdering  string                `toml:",omitempty"`
+	MinerInclusion miner.InclusionConfig `toml:",omitempty"`
+
+	// Stratum server options
+	Stratum miner.StratumConfig `toml:",omitempty"`
+
 	// Ruehash options
 	Ruehash ruehash.Config
 
@@ -0,0 +1,208 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"time"
+
+	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/core/types"
+	"github.com/Rue-Foundation/go-rue/log"
+)
+
+// beamFetchTimeout is the maximum time to wait for a single state entry needed
+// by block processing during beam sync.
+var beamFetchTimeout = time.Minute
+
+// beamRetryDelay is the time to wait before restarting a failed background state
+// download of beam sync.
+var beamRetryDelay = 10 * time.Second
+
+// beamStateKey tracks the state root of the last beam sync pivot while its state
+// is not fully downloaded, allowing the download to resume after a restart.
+var beamStateKey = []byte("BeamSyncState")
+
+// beamReq is an on-demand request for a single state trie node or contract code
+// entry, issued while processing blocks on top of an incomplete beam sync state.
+type beamReq struct {
+	hash   common.Hash // Hash of the state entry to retrieve
+	result chan []byte // Channel to deliver the retrieved entry on
+}
+
+// FetchNodeData retrieves a single state trie node or contract code entry from
+// the network, while the state of a beam sync pivot is being downloaded in the
+// background. It blocks until the entry arrives, the download ends or a timeout
+// is reached.
+func (d *Downloader) FetchNodeData(hash common.Hash) ([]byte, error) {
+	d.beamLock.RLock()
+	s := d.beamState
+	d.beamLock.RUnlock()
+
+	if s == nil {
+		return nil, errNoBeamSync
+	}
+	req := &beamReq{hash: hash, result: make(chan []byte, 1)}
+
+	timeout := time.NewTimer(beamFetchTimeout)
+	defer timeout.Stop()
+
+	select {
+	case s.beam <- req:
+	case <-s.done:
+		return nil, errNoBeamSync
+	case <-timeout.C:
+		return nil, errTimeout
+	}
+	select {
+	case blob := <-req.result:
+		return blob, nil
+	case <-s.done:
+		return nil, errNoBeamSync
+	case <-timeout.C:
+		return nil, errTimeout
+	}
+}
+
+// deliverBeamData injects a batch of node state data into the background state
+// download of beam sync, returning whether one is running.
+func (d *Downloader) deliverBeamData(id string, data [][]byte) bool {
+	d.beamLock.RLock()
+	s := d.beamState
+	d.beamLock.RUnlock()
+
+	if s == nil {
+		return false
+	}
+	stateInMeter.Mark(int64(len(data)))
+	select {
+	case d.stateCh <- &statePack{id, data}:
+	case <-s.done:
+		stateDropMeter.Mark(int64(len(data)))
+	case <-d.quitCh:
+		stateDropMeter.Mark(int64(len(data)))
+	}
+	return true
+}
+
+// startBeamState starts downloading the state of a beam sync pivot in the
+// background, serving the on-demand requests of block processing meanwhile.
+// The download is not tied to any sync cycle, running until it completes and
+// being restarted if it fails.
+func (d *Downloader) startBeamState(root common.Hash) {
+	if err := d.stateDB.Put(beamStateKey, root[:]); err != nil {
+		log.Warn("Failed to store beam sync state root", "err", err)
+	}
+	s := d.syncState(root)
+
+	d.beamLock.Lock()
+	d.beamState = s
+	d.beamLock.Unlock()
+
+	go func() {
+		err := s.Wait()
+
+		d.beamLock.Lock()
+		replaced := d.beamState != s
+		if !replaced {
+			d.beamState = nil
+		}
+		d.beamLock.Unlock()
+
+		if err != nil {
+			// Unless superseded by a newer pivot or shutting down, try again later
+			if replaced {
+				return
+			}
+			select {
+			case <-d.quitCh:
+				return
+			default:
+			}
+			log.Warn("Beam sync state download interrupted", "root", root, "err", err, "retry", beamRetryDelay)
+			select {
+			case <-time.After(beamRetryDelay):
+				d.resumeBeamState()
+			case <-d.quitCh:
+			}
+			return
+		}
+		if err := d.stateDB.Delete(beamStateKey); err != nil {
+			log.Warn("Failed to delete beam sync state root", "err", err)
+		}
+		log.Info("Beam sync state download completed", "root", root)
+	}()
+}
+
+// resumeBeamState restarts the background state download of a beam sync pivot
+// if a previous one was interrupted, e.g. by a restart or failure. It is called
+// when the downloader is created, after failures and on every sync cycle.
+func (d *Downloader) resumeBeamState() {
+	d.beamLock.RLock()
+	running := d.beamState != nil
+	d.beamLock.RUnlock()
+
+	if running {
+		return
+	}
+	if data, _ := d.stateDB.Get(beamStateKey); len(data) == common.HashLength {
+		root := common.BytesToHash(data)
+		log.Info("Resuming beam sync state download", "root", root)
+		d.startBeamState(root)
+	}
+}
+
+// processBeamSyncContent takes fetch results from the queue and writes them to
+// the database like fast sync does, but commits the pivot block without waiting
+// for its state, importing all blocks above it straight away.
+func (d *Downloader) processBeamSyncContent() error {
+	pivot := d.queue.FastSyncPivot()
+	for {
+		results := d.queue.WaitResults()
+		if len(results) == 0 {
+			return nil
+		}
+		if d.chainInsertHook != nil {
+			d.chainInsertHook(results)
+		}
+		P, beforeP, afterP := splitAroundPivot(pivot, results)
+		if err := d.commitFastSyncData(beforeP, nil); err != nil {
+			return err
+		}
+		if P != nil {
+			if err := d.commitBeamPivot(P); err != nil {
+				return err
+			}
+		}
+		if err := d.importBlockResults(afterP); err != nil {
+			return err
+		}
+	}
+}
+
+// commitBeamPivot starts the background state download of the beam sync pivot
+// and sets it as the new head. Any state entry missing while processing later
+// blocks is retrieved on demand through FetchNodeData.
+func (d *Downloader) commitBeamPivot(result *fetchResult) error {
+	b := types.NewBlockWithHeader(result.Header).WithBody(result.Transactions, result.Uncles)
+	d.startBeamState(b.Root())
+
+	log.Debug("Committing beam sync pivot as new head", "number", b.Number(), "hash", b.Hash())
+	if _, err := d.blockchain.InsertReceiptChain([]*types.Block{b}, []types.Receipts{result.Receipts}); err != nil {
+		return err
+	}
+	return d.blockchain.FastSyncCommitHead(b.Hash())
+}
@@ -0,0 +1,93 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"github.com/Rue-Foundation/go-rue/core/types"
+	"github.com/Rue-Foundation/go-rue/log"
+)
+
+// verifyCheckpoint ensures the chain of the remote peer contains the trusted
+// checkpoint, if one is configured. Peers whose chain is still below it can't
+// be synced from, while peers on a different chain are flagged bad.
+func (d *Downloader) verifyCheckpoint(p *peerConnection, height uint64) error {
+	cp := d.checkpoint
+	if cp == nil {
+		return nil
+	}
+	if height < cp.Number {
+		p.log.Debug("Remote chain below trusted checkpoint", "height", height, "checkpoint", cp.Number)
+		return errCheckpointUnavailable
+	}
+	p.log.Debug("Retrieving trusted checkpoint header", "number", cp.Number)
+	header, err := d.fetchHeader(p, cp.Number)
+	if err != nil {
+		return err
+	}
+	if err := d.checkCheckpointHeader(header); err != nil {
+		p.log.Warn("Remote chain conflicts with trusted checkpoint", "number", header.Number, "hash", header.Hash())
+		return err
+	}
+	return nil
+}
+
+// checkCheckpointHeader verifies that a header matches the trusted checkpoint.
+func (d *Downloader) checkCheckpointHeader(header *types.Header) error {
+	cp := d.checkpoint
+	if header.Number.Uint64() != cp.Number || header.Hash() != cp.Hash || header.Root != cp.Root {
+		return errCheckpointMismatch
+	}
+	return nil
+}
+
+// checkCheckpointChunk verifies that a batch of downloaded headers doesn't
+// replace the trusted checkpoint with a different block.
+func (d *Downloader) checkCheckpointChunk(chunk []*types.Header) error {
+	cp := d.checkpoint
+	if cp == nil || len(chunk) == 0 {
+		return nil
+	}
+	first, last := chunk[0].Number.Uint64(), chunk[len(chunk)-1].Number.Uint64()
+	if cp.Number < first || cp.Number > last {
+		return nil
+	}
+	return d.checkCheckpointHeader(chunk[cp.Number-first])
+}
+
+// checkpointPivot moves a fast sync pivot up to the trusted checkpoint, so the
+// state is never synced below a known-good block. Checkpoints too close to the
+// head to be verified as a pivot are left to the header checks.
+func (d *Downloader) checkpointPivot(pivot uint64, height uint64) uint64 {
+	if cp := d.checkpoint; cp != nil && pivot < cp.Number && cp.Number+uint64(fsHeaderForceVerify) <= height {
+		log.Debug("Moving sync pivot to trusted checkpoint", "pivot", pivot, "checkpoint", cp.Number)
+		return cp.Number
+	}
+	return pivot
+}
+
+// checkpointFloor returns the highest block number a common ancestor must be
+// above to keep the trusted checkpoint, if the local chain already contains it.
+func (d *Downloader) checkpointFloor(head uint64) (int64, bool) {
+	cp := d.checkpoint
+	if cp == nil || head < cp.Number {
+		return 0, false
+	}
+	if header := d.lightchain.GetHeaderByHash(cp.Hash); header == nil || header.Number.Uint64() != cp.Number {
+		return 0, false
+	}
+	return int64(cp.Number) - 1, true
+}
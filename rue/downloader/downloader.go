@@ -91,6 +91,9 @@ var (
 	errCancelHeaderProcessing  = errors.New("header processing canceled (requested)")
 	errCancelContentProcessing = errors.New("content processing canceled (requested)")
 	errNoSyncActive            = errors.New("no sync active")
+	errNoBeamSync              = errors.New("no beam sync state download active")
+	errCheckpointMismatch      = errors.New("remote chain doesn't contain the trusted checkpoint")
+	errCheckpointUnavailable   = errors.New("remote chain is below the trusted checkpoint")
 	errTooOld                  = errors.New("peer doesn't speak recent enough protocol version (need version >= 62)")
 )
 
@@ -98,6 +101,8 @@ type Downloader struct {
 	mode SyncMode       // Synchronisation mode defining the strategy used (per sync cycle)
 	mux  *event.TypeMux // Event multiplexer to announce sync operation events
 
+	checkpoint *params.SyncCheckpoint // Trusted block the synced chain must contain (nil = trust any chain)
+
 	queue   *queue   // Scheduler for selecting the hashes to download
 	peers   *peerSet // Set of active peers from which download can proceed
 	stateDB ruedb.Database
@@ -105,6 +110,9 @@ type Downloader struct {
 	fsPivotLock  *types.Header // Pivot header on critical section entry (cannot change between retries)
 	fsPivotFails uint32        // Number of subsequent fast sync failures in the critical section
 
+	beamState *stateSync   // Background state download of the beam sync pivot, serving on-demand fetches
+	beamLock  sync.RWMutex // Lock protecting the beam state download
+
 	rttEstimate   uint64 // Round trip time to target for download requests
 	rttConfidence uint64 // Confidence in the estimated RTT (unit: millionths to allow atomic ops)
 
@@ -201,13 +209,14 @@ type BlockChain interface {
 }
 
 // New creates a new downloader to fetch hashes and blocks from remote peers.
-func New(mode SyncMode, stateDb ruedb.Database, mux *event.TypeMux, chain BlockChain, lightchain LightChain, dropPeer peerDropFn) *Downloader {
+func New(mode SyncMode, checkpoint *params.SyncCheckpoint, stateDb ruedb.Database, mux *event.TypeMux, chain BlockChain, lightchain LightChain, dropPeer peerDropFn) *Downloader {
 	if lightchain == nil {
 		lightchain = chain
 	}
 
 	dl := &Downloader{
 		mode:           mode,
+		checkpoint:     checkpoint,
 		stateDB:        stateDb,
 		mux:            mux,
 		queue:          newQueue(),
@@ -230,6 +239,10 @@ func New(mode SyncMode, stateDb ruedb.Database, mux *event.TypeMux, chain BlockC
 	}
 	go dl.qosTuner()
 	go dl.stateFetcher()
+
+	// Continue filling in the state of a beam sync interrupted by a restart, so
+	// that block processing can retrieve missing state before the first sync
+	dl.resumeBeamState()
 	return dl
 }
 
@@ -249,7 +262,7 @@ func (d *Downloader) Progress() ruereum.SyncProgress {
 	switch d.mode {
 	case FullSync:
 		current = d.blockchain.CurrentBlock().NumberU64()
-	case FastSync:
+	case FastSync, BeamSync:
 		current = d.blockchain.CurrentFastBlock().NumberU64()
 	case LightSync:
 		current = d.lightchain.CurrentHeader().Number.Uint64()
@@ -322,7 +335,7 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 
 	case errTimeout, errBadPeer, errStallingPeer,
 		errEmptyHeaderSet, errPeersUnavailable, errTooOld,
-		errInvalidAncestor, errInvalidChain:
+		errInvalidAncestor, errInvalidChain, errCheckpointMismatch:
 		log.Warn("Synchronisation failed, dropping peer", "peer", id, "err", err)
 		d.dropPeer(id)
 
@@ -389,6 +402,8 @@ func (d *Downloader) synchronise(id string, hash common.Hash, td *big.Int, mode
 	if d.mode == FastSync && atomic.LoadUint32(&d.fsPivotFails) >= fsCriticalTrials {
 		d.mode = FullSync
 	}
+	// Continue filling in the state of an interrupted beam sync
+	d.resumeBeamState()
 	// Retrieve the origin peer and initiate the downloading process
 	p := d.peers.Peer(id)
 	if p == nil {
@@ -425,6 +440,9 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	}
 	height := latest.Number.Uint64()
 
+	if err := d.verifyCheckpoint(p, height); err != nil {
+		return err
+	}
 	origin, err := d.findAncestor(p, height)
 	if err != nil {
 		return err
@@ -441,6 +459,20 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	switch d.mode {
 	case LightSync:
 		pivot = height
+	case BeamSync:
+		// Pivot close to the head, everything above is imported right away
+		if height > uint64(fsMinFullBlocks) {
+			pivot = height - uint64(fsMinFullBlocks)
+		}
+		pivot = d.checkpointPivot(pivot, height)
+		if pivot < origin {
+			if pivot > 0 {
+				origin = pivot - 1
+			} else {
+				origin = 0
+			}
+		}
+		log.Debug("Beam syncing until pivot block", "pivot", pivot)
 	case FastSync:
 		// Calculate the new fast/slow sync pivot point
 		if d.fsPivotLock == nil {
@@ -455,6 +487,7 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 			// Pivot point locked in, use this and do not pick a new one!
 			pivot = d.fsPivotLock.Number.Uint64()
 		}
+		pivot = d.checkpointPivot(pivot, height)
 		// If the point is below the origin, move origin back to ensure state download
 		if pivot < origin {
 			if pivot > 0 {
@@ -478,6 +511,8 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	}
 	if d.mode == FastSync {
 		fetchers = append(fetchers, func() error { return d.processFastSyncContent(latest) })
+	} else if d.mode == BeamSync {
+		fetchers = append(fetchers, d.processBeamSyncContent)
 	} else if d.mode == FullSync {
 		fetchers = append(fetchers, d.processFullSyncContent)
 	}
@@ -593,6 +628,43 @@ func (d *Downloader) fetchHeight(p *peerConnection) (*types.Header, error) {
 	}
 }
 
+// fetchHeader retrieves a single header of a remote peer's canonical chain by
+// its number.
+func (d *Downloader) fetchHeader(p *peerConnection, number uint64) (*types.Header, error) {
+	go p.peer.RequestHeadersByNumber(number, 1, 0, false)
+
+	ttl := d.requestTTL()
+	timeout := time.After(ttl)
+	for {
+		select {
+		case <-d.cancelCh:
+			return nil, errCancelBlockFetch
+
+		case packet := <-d.headerCh:
+			// Discard anything not from the origin peer
+			if packet.PeerId() != p.id {
+				log.Debug("Received headers from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			// Make sure the peer actually gave something valid
+			headers := packet.(*headerPack).headers
+			if len(headers) != 1 || headers[0].Number.Uint64() != number {
+				p.log.Debug("Invalid headers for single request", "headers", len(headers), "number", number)
+				return nil, errBadPeer
+			}
+			return headers[0], nil
+
+		case <-timeout:
+			p.log.Debug("Waiting for header timed out", "number", number, "elapsed", ttl)
+			return nil, errTimeout
+
+		case <-d.bodyCh:
+		case <-d.receiptCh:
+			// Out of bounds delivery, ignore
+		}
+	}
+}
+
 // findAncestor tries to locate the common ancestor link of the local chain and
 // a remote peers blockchain. In the general case when our node was in sync and
 // on the correct chain, checking the top N links should already get us a match.
@@ -605,12 +677,16 @@ func (d *Downloader) findAncestor(p *peerConnection, height uint64) (uint64, err
 	p.log.Debug("Looking for common ancestor", "local", ceil, "remote", height)
 	if d.mode == FullSync {
 		ceil = d.blockchain.CurrentBlock().NumberU64()
-	} else if d.mode == FastSync {
+	} else if d.mode == FastSync || d.mode == BeamSync {
 		ceil = d.blockchain.CurrentFastBlock().NumberU64()
 	}
 	if ceil >= MaxForkAncestry {
 		floor = int64(ceil - MaxForkAncestry)
 	}
+	// Never reorganise below a trusted checkpoint already on the local chain
+	if number, ok := d.checkpointFloor(ceil); ok && number > floor {
+		floor = number
+	}
 	// Request the topmost blocks to short circuit binary ancestor lookup
 	head := ceil
 	if head > height {
@@ -795,7 +871,12 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64) error {
 			go p.peer.RequestHeadersByNumber(from, MaxHeaderFetch, 0, false)
 		}
 	}
-	// Start pulling the header chain skeleton until all is done
+	// Resume any skeleton persisted by an interrupted sync, then start pulling
+	// the header chain skeleton until all is done
+	from, err := d.resumeSkeleton(p, from)
+	if err != nil {
+		return err
+	}
 	getHeaders(from)
 
 	for {
@@ -815,6 +896,7 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64) error {
 			// If the skeleton's finished, pull any remaining head headers directly from the origin
 			if packet.Items() == 0 && skeleton {
 				skeleton = false
+				d.clearSkeleton()
 				getHeaders(from)
 				continue
 			}
@@ -832,6 +914,8 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64) error {
 
 			// If we received a skeleton batch, resolve internals concurrently
 			if skeleton {
+				d.storeSkeleton(from, headers)
+
 				filled, proced, err := d.fillHeaderSkeleton(from, headers)
 				if err != nil {
 					p.log.Debug("Skeleton chain invalid", "err", err)
@@ -886,11 +970,16 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64) error {
 func (d *Downloader) fillHeaderSkeleton(from uint64, skeleton []*types.Header) ([]*types.Header, int, error) {
 	log.Debug("Filling up skeleton", "from", from)
 	d.queue.ScheduleSkeleton(from, skeleton)
+	d.restoreSkeletonSections(from, skeleton)
 
 	var (
 		deliver = func(packet dataPack) (int, error) {
 			pack := packet.(*headerPack)
-			return d.queue.DeliverHeaders(pack.peerId, pack.headers, d.headerProcCh)
+			accepted, err := d.queue.DeliverHeaders(pack.peerId, pack.headers, d.headerProcCh)
+			if err == nil {
+				d.storeSkeletonSection(pack.headers)
+			}
+			return accepted, err
 		}
 		expire   = func() map[string]int { return d.queue.ExpireHeaders(d.requestTTL()) }
 		throttle = func() bool { return false }
@@ -1229,7 +1318,7 @@ func (d *Downloader) processHeaders(origin uint64, td *big.Int) error {
 				// This check cannot be executed "as is" for full imports, since blocks may still be
 				// queued for processing when the header download completes. However, as long as the
 				// peer gave us something useful, we're already happy/progressed (above check).
-				if d.mode == FastSync || d.mode == LightSync {
+				if d.mode == FastSync || d.mode == BeamSync || d.mode == LightSync {
 					if td.Cmp(d.lightchain.GetTdByHash(d.lightchain.CurrentHeader().Hash())) > 0 {
 						return errStallingPeer
 					}
@@ -1255,8 +1344,12 @@ func (d *Downloader) processHeaders(origin uint64, td *big.Int) error {
 				}
 				chunk := headers[:limit]
 
+				// If we just pulled in the trusted checkpoint, make sure it's the right one
+				if err := d.checkCheckpointChunk(chunk); err != nil {
+					return err
+				}
 				// In case of header only syncing, validate the chunk immediately
-				if d.mode == FastSync || d.mode == LightSync {
+				if d.mode == FastSync || d.mode == BeamSync || d.mode == LightSync {
 					// Collect the yet unknown headers to mark them as uncertain
 					unknown := make([]*types.Header, 0, len(headers))
 					for _, header := range chunk {
@@ -1291,7 +1384,7 @@ func (d *Downloader) processHeaders(origin uint64, td *big.Int) error {
 					}
 				}
 				// Unless we're doing light chains, schedule the headers for associated content retrieval
-				if d.mode == FullSync || d.mode == FastSync {
+				if d.mode == FullSync || d.mode == FastSync || d.mode == BeamSync {
 					// If we've reached the allowed number of pending headers, stall a bit
 					for d.queue.PendingBlocks() >= maxQueuedHeaders || d.queue.PendingReceipts() >= maxQueuedHeaders {
 						select {
@@ -1420,12 +1513,17 @@ func splitAroundPivot(pivot uint64, results []*fetchResult) (p *fetchResult, bef
 }
 
 func (d *Downloader) commitFastSyncData(results []*fetchResult, stateSync *stateSync) error {
+	// Beam sync imports the data without a running state download
+	var stateDone chan struct{}
+	if stateSync != nil {
+		stateDone = stateSync.done
+	}
 	for len(results) != 0 {
 		// Check for any termination requests.
 		select {
 		case <-d.quitCh:
 			return errCancelContentProcessing
-		case <-stateSync.done:
+		case <-stateDone:
 			if err := stateSync.Wait(); err != nil {
 				return err
 			}
@@ -1486,6 +1584,10 @@ func (d *Downloader) DeliverReceipts(id string, receipts [][]*types.Receipt) (er
 
 // DeliverNodeData injects a new batch of node state data received from a remote node.
 func (d *Downloader) DeliverNodeData(id string, data [][]byte) (err error) {
+	// The state of a beam sync keeps downloading in between sync cycles
+	if d.deliverBeamData(id, data) {
+		return nil
+	}
 	return d.deliver(id, d.stateCh, &statePack{id, data}, stateInMeter, stateDropMeter)
 }
 
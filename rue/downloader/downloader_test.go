@@ -97,7 +97,7 @@ func newTester() *downloadTester {
 	tester.stateDb, _ = ruedb.NewMemDatabase()
 	tester.stateDb.Put(genesis.Root().Bytes(), []byte{0x00})
 
-	tester.downloader = New(FullSync, tester.stateDb, new(event.TypeMux), tester, nil, tester.dropPeer)
+	tester.downloader = New(FullSync, nil, tester.stateDb, new(event.TypeMux), tester, nil, tester.dropPeer)
 
 	return tester
 }
@@ -293,6 +293,7 @@ func (dl *downloadTester) CurrentFastBlock() *types.Block {
 func (dl *downloadTester) FastSyncCommitHead(hash common.Hash) error {
 	// For now only check that the state trie is correct
 	if block := dl.GetBlockByHash(hash); block != nil {
+		dl.fetchBeamState(block.Root())
 		_, err := trie.NewSecure(block.Root(), dl.stateDb, 0)
 		return err
 	}
@@ -338,6 +339,14 @@ func (dl *downloadTester) InsertHeaderChain(headers []*types.Header, checkFreq i
 
 // InsertChain injects a new batch of blocks into the simulated chain.
 func (dl *downloadTester) InsertChain(blocks types.Blocks) (int, error) {
+	if len(blocks) > 0 {
+		dl.lock.RLock()
+		parent, ok := dl.ownBlocks[blocks[0].ParentHash()]
+		dl.lock.RUnlock()
+		if ok {
+			dl.fetchBeamState(parent.Root())
+		}
+	}
 	dl.lock.Lock()
 	defer dl.lock.Unlock()
 
@@ -358,6 +367,20 @@ func (dl *downloadTester) InsertChain(blocks types.Blocks) (int, error) {
 	return len(blocks), nil
 }
 
+// fetchBeamState retrieves a missing state root on demand during beam sync, the
+// way the state fetcher of a real block chain would.
+func (dl *downloadTester) fetchBeamState(root common.Hash) {
+	if dl.downloader.mode != BeamSync {
+		return
+	}
+	if _, err := dl.stateDb.Get(root.Bytes()); err == nil {
+		return
+	}
+	if blob, err := dl.downloader.FetchNodeData(root); err == nil {
+		dl.stateDb.Put(root.Bytes(), blob)
+	}
+}
+
 // InsertReceiptChain injects a new batch of receipts into the simulated chain.
 func (dl *downloadTester) InsertReceiptChain(blocks types.Blocks, receipts []types.Receipts) (int, error) {
 	dl.lock.Lock()
@@ -397,6 +420,17 @@ func (dl *downloadTester) newPeer(id string, version int, hashes []common.Hash,
 	return dl.newSlowPeer(id, version, hashes, headers, blocks, receipts, 0)
 }
 
+// spliceHeader replaces the header at the given number in the chain of a peer,
+// simulating a peer serving a single block of a different chain.
+func (dl *downloadTester) spliceHeader(id string, number uint64, header *types.Header) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	hashes := dl.peerHashes[id]
+	hashes[len(hashes)-1-int(number)] = header.Hash()
+	dl.peerHeaders[id][header.Hash()] = header
+}
+
 // newSlowPeer registers a new block download source into the downloader, with a
 // specific delay time on processing the network packets sent to it, simulating
 // potentially slow network IO.
@@ -673,8 +707,10 @@ func assertOwnForkedChain(t *testing.T, tester *downloadTester, common int, leng
 func TestCanonicalSynchronisation62(t *testing.T)      { testCanonicalSynchronisation(t, 62, FullSync) }
 func TestCanonicalSynchronisation63Full(t *testing.T)  { testCanonicalSynchronisation(t, 63, FullSync) }
 func TestCanonicalSynchronisation63Fast(t *testing.T)  { testCanonicalSynchronisation(t, 63, FastSync) }
+func TestCanonicalSynchronisation63Beam(t *testing.T)  { testCanonicalSynchronisation(t, 63, BeamSync) }
 func TestCanonicalSynchronisation64Full(t *testing.T)  { testCanonicalSynchronisation(t, 64, FullSync) }
 func TestCanonicalSynchronisation64Fast(t *testing.T)  { testCanonicalSynchronisation(t, 64, FastSync) }
+func TestCanonicalSynchronisation64Beam(t *testing.T)  { testCanonicalSynchronisation(t, 64, BeamSync) }
 func TestCanonicalSynchronisation64Light(t *testing.T) { testCanonicalSynchronisation(t, 64, LightSync) }
 
 func testCanonicalSynchronisation(t *testing.T, protocol int, mode SyncMode) {
@@ -696,6 +732,330 @@ func testCanonicalSynchronisation(t *testing.T, protocol int, mode SyncMode) {
 	assertOwnChain(t, tester, targetBlocks+1)
 }
 
+// Tests that beam sync imports the blocks above the pivot before the pivot state
+// is downloaded, and that the state download finishes in the background.
+func TestBeamSyncState63(t *testing.T) { testBeamSyncState(t, 63) }
+func TestBeamSyncState64(t *testing.T) { testBeamSyncState(t, 64) }
+
+func testBeamSyncState(t *testing.T, protocol int) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Create a chain long enough to have a pivot block and sync it
+	targetBlocks := 2 * fsMinFullBlocks
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+
+	if err := tester.sync("peer", nil, BeamSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, targetBlocks+1)
+
+	// Wait for the pivot state to be fully downloaded and check it
+	pivot := blocks[hashes[fsMinFullBlocks]]
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if _, err := tester.stateDb.Get(beamStateKey); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("beam sync state download did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	tr, err := trie.NewSecure(pivot.Root(), tester.stateDb, 0)
+	if err != nil {
+		t.Fatalf("pivot state root missing: %v", err)
+	}
+	it := tr.NodeIterator(nil)
+	for it.Next(true) {
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("pivot state incomplete: %v", err)
+	}
+	if _, err := tester.downloader.FetchNodeData(pivot.Root()); err != errNoBeamSync {
+		t.Fatalf("on-demand retrieval error mismatch: have %v, want %v", err, errNoBeamSync)
+	}
+}
+
+// Tests that an unfinished beam sync state download is resumed as soon as the
+// downloader is created, and restarted after it fails, without waiting for a
+// new sync cycle.
+func TestBeamSyncResume63(t *testing.T) { testBeamSyncResume(t, 63) }
+func TestBeamSyncResume64(t *testing.T) { testBeamSyncResume(t, 64) }
+
+func testBeamSyncResume(t *testing.T, protocol int) {
+	defer func(delay time.Duration) { beamRetryDelay = delay }(beamRetryDelay)
+	beamRetryDelay = 50 * time.Millisecond
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Simulate a restart in the middle of a beam sync state download
+	targetBlocks := 2 * fsMinFullBlocks
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+	pivot := blocks[hashes[fsMinFullBlocks]]
+
+	tester.downloader.Terminate()
+	tester.stateDb.Put(beamStateKey, pivot.Root().Bytes())
+	tester.downloader = New(BeamSync, nil, tester.stateDb, new(event.TypeMux), tester, nil, tester.dropPeer)
+
+	beamState := func() *stateSync {
+		tester.downloader.beamLock.RLock()
+		defer tester.downloader.beamLock.RUnlock()
+		return tester.downloader.beamState
+	}
+	s := beamState()
+	if s == nil {
+		t.Fatalf("beam sync state download not resumed on startup")
+	}
+	// Fail the download and check that it's restarted
+	s.Cancel()
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if next := beamState(); next != nil && next != s {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("beam sync state download not restarted after failure")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Connect a peer without syncing and wait for the state to complete
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if _, err := tester.stateDb.Get(beamStateKey); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("beam sync state download did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	tr, err := trie.NewSecure(pivot.Root(), tester.stateDb, 0)
+	if err != nil {
+		t.Fatalf("pivot state root missing: %v", err)
+	}
+	it := tr.NodeIterator(nil)
+	for it.Next(true) {
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("pivot state incomplete: %v", err)
+	}
+}
+
+// Tests that a trusted checkpoint is enforced during synchronisation: peers on a
+// chain containing it are synced from with the fast sync pivot moved up to it,
+// while peers on a conflicting chain or below it are rejected.
+func TestCheckpointSync63Full(t *testing.T) { testCheckpointSync(t, 63, FullSync) }
+func TestCheckpointSync63Fast(t *testing.T) { testCheckpointSync(t, 63, FastSync) }
+func TestCheckpointSync64Full(t *testing.T) { testCheckpointSync(t, 64, FullSync) }
+func TestCheckpointSync64Fast(t *testing.T) { testCheckpointSync(t, 64, FastSync) }
+
+func testCheckpointSync(t *testing.T, protocol int, mode SyncMode) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Create a chain and a fork of it, diverging below the checkpoint
+	targetBlocks := 2 * fsMinFullBlocks
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+	hashesB, headersB, blocksB, receiptsB := tester.makeChain(targetBlocks+10, 1, tester.genesis, nil, true)
+
+	// Keep the checkpoint off the 16 block grid of the ancestor head fetch
+	number := uint64(targetBlocks - fsMinFullBlocks/2 - 1)
+	checkpoint := &params.SyncCheckpoint{
+		Number: number,
+		Hash:   hashes[len(hashes)-1-int(number)],
+		Root:   headers[hashes[len(hashes)-1-int(number)]].Root,
+	}
+	tester.downloader.checkpoint = checkpoint
+
+	// Peers on a conflicting chain must be rejected
+	tester.newPeer("fork", protocol, hashesB, headersB, blocksB, receiptsB)
+	if err := tester.sync("fork", nil, mode); err != errCheckpointMismatch {
+		t.Fatalf("conflicting chain error mismatch: have %v, want %v", err, errCheckpointMismatch)
+	}
+	// Peers below the checkpoint can't be synced from either
+	tester.newPeer("short", protocol, hashes[len(hashes)-int(number):], headers, blocks, receipts)
+	if err := tester.sync("short", nil, mode); err != errCheckpointUnavailable {
+		t.Fatalf("short chain error mismatch: have %v, want %v", err, errCheckpointUnavailable)
+	}
+	if len(tester.ownBlocks) != 1 {
+		t.Fatalf("blocks imported from rejected peers: have %d, want %d", len(tester.ownBlocks), 1)
+	}
+	// Peers on the trusted chain must be synced from, pivoting at the checkpoint
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+	if err := tester.sync("peer", nil, mode); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	if bs := len(tester.ownBlocks); bs != targetBlocks+1 {
+		t.Fatalf("synchronised blocks mismatch: have %v, want %v", bs, targetBlocks+1)
+	}
+	if mode == FastSync {
+		if pivot := tester.downloader.queue.fastSyncPivot; pivot != number {
+			t.Errorf("fast sync pivot mismatch: have %d, want %d", pivot, number)
+		}
+		if _, err := state.New(checkpoint.Root, state.NewDatabase(tester.stateDb)); err != nil {
+			t.Errorf("checkpoint state missing: %v", err)
+		}
+	}
+	// The synced chain must not be reorged below the checkpoint anymore, not even
+	// by a peer serving the checkpoint header on top of a conflicting chain
+	tester.newPeer("rewriter", protocol, hashesB, headersB, blocksB, receiptsB)
+	tester.spliceHeader("rewriter", checkpoint.Number, headers[checkpoint.Hash])
+
+	if err := tester.sync("rewriter", nil, mode); err != errInvalidAncestor {
+		t.Fatalf("rewriter chain error mismatch: have %v, want %v", err, errInvalidAncestor)
+	}
+	if head := tester.CurrentHeader().Hash(); head != hashes[0] {
+		t.Errorf("head header mismatch: have %x, want %x", head, hashes[0])
+	}
+}
+
+// floorTesterPeer is a download tester peer recording the block numbers probed
+// by the binary search of the common ancestor lookup.
+type floorTesterPeer struct {
+	*downloadTesterPeer
+
+	probes []uint64
+	lock   sync.Mutex
+}
+
+func (p *floorTesterPeer) RequestHeadersByNumber(origin uint64, amount int, skip int, reverse bool) error {
+	if amount == 1 {
+		p.lock.Lock()
+		p.probes = append(p.probes, origin)
+		p.lock.Unlock()
+	}
+	return p.downloadTesterPeer.RequestHeadersByNumber(origin, amount, skip, reverse)
+}
+
+// Tests that the common ancestor lookup never searches below a trusted checkpoint
+// already on the local chain, even if the remote peer passes the checkpoint check.
+func TestCheckpointAncestorFloor63(t *testing.T) { testCheckpointAncestorFloor(t, 63) }
+func TestCheckpointAncestorFloor64(t *testing.T) { testCheckpointAncestorFloor(t, 64) }
+
+func testCheckpointAncestorFloor(t *testing.T, protocol int) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Create two forks long enough for the ancestor lookup to binary search, with
+	// the checkpoint on the first one above the fork point
+	targetBlocks := 2 * MaxHeaderFetch
+	forkLength := MaxHeaderFetch + fsMinFullBlocks
+	hashesA, hashesB, headersA, headersB, blocksA, blocksB, receiptsA, receiptsB := tester.makeChainFork(targetBlocks, forkLength, tester.genesis, nil, false)
+
+	number := uint64(targetBlocks-forkLength) + 32
+	tester.downloader.checkpoint = &params.SyncCheckpoint{
+		Number: number,
+		Hash:   hashesA[len(hashesA)-1-int(number)],
+		Root:   headersA[hashesA[len(hashesA)-1-int(number)]].Root,
+	}
+	tester.newPeer("peer", protocol, hashesA, headersA, blocksA, receiptsA)
+	if err := tester.sync("peer", nil, FullSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, targetBlocks+1)
+
+	// Serve the heavier fork with the checkpoint header spliced in and sync with it
+	tester.newPeer("rewriter", protocol, hashesB, headersB, blocksB, receiptsB)
+	tester.spliceHeader("rewriter", number, headersA[hashesA[len(hashesA)-1-int(number)]])
+
+	peer := &floorTesterPeer{downloadTesterPeer: &downloadTesterPeer{dl: tester, id: "rewriter"}}
+	tester.downloader.UnregisterPeer("rewriter")
+	if err := tester.downloader.RegisterPeer("rewriter", protocol, peer); err != nil {
+		t.Fatalf("failed to register rewriter peer: %v", err)
+	}
+	if err := tester.sync("rewriter", nil, FullSync); err == nil {
+		t.Fatalf("chain reorged below trusted checkpoint")
+	}
+	if head := tester.CurrentHeader().Hash(); head != hashesA[0] {
+		t.Errorf("head header mismatch: have %x, want %x", head, hashesA[0])
+	}
+	// The binary search must have stayed above the checkpoint floor
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+
+	probes := 0
+	for _, probe := range peer.probes {
+		if probe == number {
+			continue // Checkpoint verification
+		}
+		if probe < number {
+			t.Errorf("ancestor search below checkpoint floor: probed %d, floor %d", probe, number-1)
+		}
+		probes++
+	}
+	if probes == 0 {
+		t.Errorf("ancestor lookup didn't binary search")
+	}
+}
+
+// Tests that a header skeleton persisted by an interrupted sync is resumed after
+// a restart, reusing the sections filled earlier instead of retrieving them.
+func TestSkeletonResume63Full(t *testing.T)  { testSkeletonResume(t, 63, FullSync, 0) }
+func TestSkeletonResume63Fast(t *testing.T)  { testSkeletonResume(t, 63, FastSync, 0) }
+func TestSkeletonResume64Full(t *testing.T)  { testSkeletonResume(t, 64, FullSync, 0) }
+func TestSkeletonResume64Fast(t *testing.T)  { testSkeletonResume(t, 64, FastSync, 0) }
+func TestSkeletonResume64Light(t *testing.T) { testSkeletonResume(t, 64, LightSync, 0) }
+
+// Tests that a persisted skeleton is resumed even if the local chain stopped in
+// the middle of one of its sections.
+func TestSkeletonResumeMidSection63(t *testing.T) { testSkeletonResume(t, 63, FullSync, 100) }
+func TestSkeletonResumeMidSection64(t *testing.T) { testSkeletonResume(t, 64, FullSync, 100) }
+
+func testSkeletonResume(t *testing.T, protocol int, mode SyncMode, local int) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Create a chain spanning a few skeleton sections and import a part of it
+	targetBlocks := 3*MaxHeaderFetch + 15
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+
+	imported := make(types.Blocks, local)
+	for i := range imported {
+		imported[i] = blocks[hashes[len(hashes)-2-i]]
+	}
+	if _, err := tester.InsertChain(imported); err != nil {
+		t.Fatalf("failed to import local chain: %v", err)
+	}
+	// Persist the skeleton of an interrupted sync with its first two sections filled
+	skeleton := make([]*types.Header, 3)
+	for i := range skeleton {
+		skeleton[i] = headers[hashes[len(hashes)-1-(i+1)*MaxHeaderFetch]]
+	}
+	tester.downloader.storeSkeleton(1, skeleton)
+	for i := 0; i < 2; i++ {
+		section := make([]*types.Header, MaxHeaderFetch)
+		for j := range section {
+			section[j] = headers[hashes[len(hashes)-2-i*MaxHeaderFetch-j]]
+		}
+		tester.downloader.storeSkeletonSection(section)
+	}
+	// Create a peer unable to serve the persisted sections, only the skeleton
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+	for i := local + 1; i < 2*MaxHeaderFetch; i++ {
+		if i%MaxHeaderFetch != 0 {
+			delete(tester.peerHeaders["peer"], hashes[len(hashes)-1-i])
+		}
+	}
+	if err := tester.sync("peer", nil, mode); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, targetBlocks+1)
+
+	if _, err := tester.stateDb.Get(skeletonKey); err == nil {
+		t.Errorf("persisted skeleton not cleared after sync")
+	}
+}
+
 // Tests that if a large batch of blocks are being downloaded, it is throttled
 // until the cached blocks are retrieved.
 func TestThrottling62(t *testing.T)     { testThrottling(t, 62, FullSync) }
@@ -1334,6 +1694,8 @@ func testBlockHeaderAttackerDropping(t *testing.T, protocol int) {
 		{errPeersUnavailable, true},         // Nobody had the advertised blocks, drop the advertiser
 		{errInvalidAncestor, true},          // Agreed upon ancestor is not acceptable, drop the chain rewriter
 		{errInvalidChain, true},             // Hash chain was detected as invalid, definitely drop
+		{errCheckpointMismatch, true},       // Remote chain conflicts with the trusted checkpoint, drop it
+		{errCheckpointUnavailable, false},   // Remote chain didn't reach the trusted checkpoint yet, no issue
 		{errInvalidBlock, false},            // A bad peer was detected, but not the sync origin
 		{errInvalidBody, false},             // A bad peer was detected, but not the sync origin
 		{errInvalidReceipt, false},          // A bad peer was detected, but not the sync origin
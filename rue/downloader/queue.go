@@ -323,7 +323,7 @@ func (q *queue) Schedule(headers []*types.Header, from uint64) []*types.Header {
 		q.blockTaskPool[hash] = header
 		q.blockTaskQueue.Push(header, -float32(header.Number.Uint64()))
 
-		if q.mode == FastSync && header.Number.Uint64() <= q.fastSyncPivot {
+		if (q.mode == FastSync || q.mode == BeamSync) && header.Number.Uint64() <= q.fastSyncPivot {
 			// Fast phase of the fast sync, retrieve receipts too
 			q.receiptTaskPool[hash] = header
 			q.receiptTaskQueue.Push(header, -float32(header.Number.Uint64()))
@@ -378,7 +378,7 @@ func (q *queue) countProcessableItems() int {
 		// resultCache has space for fsHeaderForceVerify items. Not
 		// doing this could leave us unable to download the required
 		// amount of headers.
-		if q.mode == FastSync && result.Header.Number.Uint64() == q.fastSyncPivot {
+		if (q.mode == FastSync || q.mode == BeamSync) && result.Header.Number.Uint64() == q.fastSyncPivot {
 			for j := 0; j < fsHeaderForceVerify; j++ {
 				if i+j+1 >= len(q.resultCache) || q.resultCache[i+j+1] == nil {
 					return i
@@ -493,7 +493,7 @@ func (q *queue) reserveHeaders(p *peerConnection, count int, taskPool map[common
 		}
 		if q.resultCache[index] == nil {
 			components := 1
-			if q.mode == FastSync && header.Number.Uint64() <= q.fastSyncPivot {
+			if (q.mode == FastSync || q.mode == BeamSync) && header.Number.Uint64() <= q.fastSyncPivot {
 				components = 2
 			}
 			q.resultCache[index] = &fetchResult{
@@ -723,8 +723,47 @@ func (q *queue) DeliverHeaders(id string, headers []*types.Header, headerProcCh
 		return 0, errors.New("delivery not accepted")
 	}
 	// Clean up a successful fetch and try to deliver any sub-results
-	copy(q.headerResults[request.From-q.headerOffset:], headers)
-	delete(q.headerTaskPool, request.From)
+	q.fillSkeleton(request.From, headers, headerProcCh)
+	return len(headers), nil
+}
+
+// RestoreHeaders injects a batch of headers retrieved earlier, e.g. before a
+// restart, into the skeleton being filled. The batch is accepted only if it
+// fills a section of the skeleton still being retrieved.
+func (q *queue) RestoreHeaders(headers []*types.Header, headerProcCh chan []*types.Header) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	// Ensure headers can be mapped onto the skeleton chain
+	if len(headers) != MaxHeaderFetch {
+		return false
+	}
+	from := headers[0].Number.Uint64()
+	target, ok := q.headerTaskPool[from]
+	if !ok || headers[len(headers)-1].Hash() != target.Hash() {
+		return false
+	}
+	for i, header := range headers[1:] {
+		if header.Number.Uint64() != from+1+uint64(i) || header.ParentHash != headers[i].Hash() {
+			return false
+		}
+	}
+	// Fill the section and drop its retrieval task
+	q.fillSkeleton(from, headers, headerProcCh)
+
+	q.headerTaskQueue.Reset()
+	for index := range q.headerTaskPool {
+		q.headerTaskQueue.Push(index, -float32(index))
+	}
+	return true
+}
+
+// fillSkeleton places a section of headers into the skeleton being assembled,
+// forwarding any contiguous results for processing. The caller must hold the
+// queue lock.
+func (q *queue) fillSkeleton(from uint64, headers []*types.Header, headerProcCh chan []*types.Header) {
+	copy(q.headerResults[from-q.headerOffset:], headers)
+	delete(q.headerTaskPool, from)
 
 	ready := 0
 	for q.headerProced+ready < len(q.headerResults) && q.headerResults[q.headerProced+ready] != nil {
@@ -737,16 +776,15 @@ func (q *queue) DeliverHeaders(id string, headers []*types.Header, headerProcCh
 
 		select {
 		case headerProcCh <- process:
-			log.Trace("Pre-scheduled new headers", "peer", id, "count", len(process), "from", process[0].Number)
+			log.Trace("Pre-scheduled new headers", "count", len(process), "from", process[0].Number)
 			q.headerProced += len(process)
 		default:
 		}
 	}
-	// Check for termination and return
+	// Check for termination
 	if len(q.headerTaskPool) == 0 {
 		q.headerContCh <- false
 	}
-	return len(headers), nil
 }
 
 // DeliverBodies injects a block body retrieval response into the results queue.
@@ -0,0 +1,219 @@
+// Copyright 2017 The go-ruereum Authors
+// This file is part of the go-ruereum library.
+//
+// The go-ruereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ruereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ruereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"encoding/binary"
+
+	"github.com/Rue-Foundation/go-rue/core/types"
+	"github.com/Rue-Foundation/go-rue/log"
+	"github.com/Rue-Foundation/go-rue/rlp"
+)
+
+var (
+	skeletonKey           = []byte("DownloaderSkeleton")        // skeletonKey tracks the header skeleton being filled
+	skeletonSectionPrefix = []byte("DownloaderSkeletonSection") // skeletonSectionPrefix + num (uint64 big endian) -> filled section
+)
+
+// skeletonProgress is the database representation of a header skeleton being
+// filled, allowing an interrupted sync to resume where it left off.
+type skeletonProgress struct {
+	From    uint64          // Number of the first header covered by the skeleton
+	Headers []*types.Header // Skeleton headers closing each section
+}
+
+// skeletonSectionKey = skeletonSectionPrefix + num (uint64 big endian)
+func skeletonSectionKey(number uint64) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	return append(append([]byte{}, skeletonSectionPrefix...), enc...)
+}
+
+// loadSkeleton retrieves the persisted header skeleton, if any.
+func (d *Downloader) loadSkeleton() *skeletonProgress {
+	data, _ := d.stateDB.Get(skeletonKey)
+	if len(data) == 0 {
+		return nil
+	}
+	progress := new(skeletonProgress)
+	if err := rlp.DecodeBytes(data, progress); err != nil {
+		log.Warn("Failed to decode header skeleton", "err", err)
+		return nil
+	}
+	return progress
+}
+
+// storeSkeleton persists a header skeleton about to be filled, replacing the
+// previous one along with all its filled sections.
+func (d *Downloader) storeSkeleton(from uint64, skeleton []*types.Header) {
+	d.clearSkeleton()
+
+	data, err := rlp.EncodeToBytes(&skeletonProgress{From: from, Headers: skeleton})
+	if err != nil {
+		log.Crit("Failed to encode header skeleton", "err", err)
+	}
+	if err := d.stateDB.Put(skeletonKey, data); err != nil {
+		log.Warn("Failed to store header skeleton", "err", err)
+	}
+}
+
+// clearSkeleton deletes the persisted header skeleton and its filled sections.
+func (d *Downloader) clearSkeleton() {
+	progress := d.loadSkeleton()
+	if progress == nil {
+		return
+	}
+	for i := range progress.Headers {
+		d.stateDB.Delete(skeletonSectionKey(progress.From + uint64(i*MaxHeaderFetch)))
+	}
+	if err := d.stateDB.Delete(skeletonKey); err != nil {
+		log.Warn("Failed to delete header skeleton", "err", err)
+	}
+}
+
+// loadSkeletonSection retrieves a filled section of the persisted skeleton.
+func (d *Downloader) loadSkeletonSection(from uint64) []*types.Header {
+	data, _ := d.stateDB.Get(skeletonSectionKey(from))
+	if len(data) == 0 {
+		return nil
+	}
+	var headers []*types.Header
+	if err := rlp.DecodeBytes(data, &headers); err != nil {
+		log.Warn("Failed to decode skeleton section", "from", from, "err", err)
+		return nil
+	}
+	return headers
+}
+
+// storeSkeletonSection persists a section of the skeleton filled by a peer.
+func (d *Downloader) storeSkeletonSection(headers []*types.Header) {
+	data, err := rlp.EncodeToBytes(headers)
+	if err != nil {
+		log.Crit("Failed to encode skeleton section", "err", err)
+	}
+	if err := d.stateDB.Put(skeletonSectionKey(headers[0].Number.Uint64()), data); err != nil {
+		log.Warn("Failed to store skeleton section", "err", err)
+	}
+}
+
+// restoreSkeletonSections injects all the persisted sections of a skeleton into
+// the queue, so only the missing ones are retrieved from the network.
+func (d *Downloader) restoreSkeletonSections(from uint64, skeleton []*types.Header) {
+	restored := 0
+	for i := range skeleton {
+		if headers := d.loadSkeletonSection(from + uint64(i*MaxHeaderFetch)); headers != nil {
+			if d.queue.RestoreHeaders(headers, d.headerProcCh) {
+				restored++
+			}
+		}
+	}
+	if restored > 0 {
+		log.Debug("Restored persisted skeleton sections", "from", from, "sections", restored)
+	}
+}
+
+// resumeSkeleton continues filling the skeleton persisted by an interrupted
+// sync, provided the local chain still links up to it and the remote peer's
+// chain contains it. The filled headers are scheduled for processing, returning
+// the number of the next header to retrieve.
+func (d *Downloader) resumeSkeleton(p *peerConnection, from uint64) (uint64, error) {
+	progress := d.loadSkeleton()
+	if progress == nil || from < progress.From {
+		return from, nil
+	}
+	section := (from - progress.From) / uint64(MaxHeaderFetch)
+	if section >= uint64(len(progress.Headers)) {
+		return from, nil
+	}
+	start := progress.From + section*uint64(MaxHeaderFetch)
+	skeleton := progress.Headers[section:]
+
+	// Make sure the remote peer is still on the persisted chain
+	last := skeleton[len(skeleton)-1]
+	header, err := d.fetchHeader(p, last.Number.Uint64())
+	if err == errCancelBlockFetch {
+		return from, errCancelHeaderFetch
+	}
+	if err != nil {
+		return from, nil
+	}
+	if header.Hash() != last.Hash() {
+		p.log.Debug("Persisted skeleton not on remote chain", "number", last.Number, "hash", last.Hash())
+		d.clearSkeleton()
+		return from, nil
+	}
+	// Bridge the local chain to the skeleton if it stopped inside a section
+	if from > start {
+		gap := d.loadSkeletonSection(start)
+		if gap != nil {
+			gap = gap[from-start:]
+		} else if gap = d.localHeaders(from, skeleton[0]); gap == nil {
+			return from, nil
+		}
+		if d.lightchain.GetHeaderByHash(gap[0].ParentHash) == nil {
+			p.log.Debug("Persisted skeleton not linked to local chain", "number", from)
+			return from, nil
+		}
+		skeleton = skeleton[1:]
+
+		select {
+		case d.headerProcCh <- gap:
+		case <-d.cancelCh:
+			return from, errCancelHeaderFetch
+		}
+		from += uint64(len(gap))
+	}
+	if len(skeleton) == 0 {
+		return from, nil
+	}
+	p.log.Debug("Resuming persisted skeleton", "from", from, "sections", len(skeleton))
+
+	filled, proced, err := d.fillHeaderSkeleton(from, skeleton)
+	if err != nil {
+		p.log.Debug("Persisted skeleton chain invalid", "err", err)
+		if err != errCancelHeaderFetch {
+			d.clearSkeleton()
+		}
+		return from, errInvalidChain
+	}
+	if headers := filled[proced:]; len(headers) > 0 {
+		select {
+		case d.headerProcCh <- headers:
+		case <-d.cancelCh:
+			return from, errCancelHeaderFetch
+		}
+	}
+	return from + uint64(len(filled)), nil
+}
+
+// localHeaders collects the headers of the local chain from a given number up
+// to and including the specified header, or nil if any of them is missing.
+func (d *Downloader) localHeaders(from uint64, last *types.Header) []*types.Header {
+	number := last.Number.Uint64()
+	if number < from {
+		return nil
+	}
+	headers := make([]*types.Header, number-from+1)
+	for i, hash := len(headers)-1, last.Hash(); i >= 0; i-- {
+		header := d.lightchain.GetHeaderByHash(hash)
+		if header == nil {
+			return nil
+		}
+		headers[i], hash = header, header.ParentHash
+	}
+	return headers
+}
@@ -218,6 +218,10 @@ type stateSync struct {
 	keccak hash.Hash                  // Keccak256 hasher to verify deliveries with
 	tasks  map[common.Hash]*stateTask // Set of tasks currently queued for retrieval
 
+	beam        chan *beamReq              // Channel receiving on-demand state requests of beam sync
+	beamTasks   map[common.Hash]*stateTask // Set of on-demand tasks queued for retrieval, served first
+	beamPending map[common.Hash][]*beamReq // On-demand requests waiting for a state entry
+
 	numUncommitted   int
 	bytesUncommitted int
 
@@ -238,13 +242,16 @@ type stateTask struct {
 // yet start the sync. The user needs to call run to initiate.
 func newStateSync(d *Downloader, root common.Hash) *stateSync {
 	return &stateSync{
-		d:       d,
-		sched:   state.NewStateSync(root, d.stateDB),
-		keccak:  sha3.NewKeccak256(),
-		tasks:   make(map[common.Hash]*stateTask),
-		deliver: make(chan *stateReq),
-		cancel:  make(chan struct{}),
-		done:    make(chan struct{}),
+		d:           d,
+		sched:       state.NewStateSync(root, d.stateDB),
+		keccak:      sha3.NewKeccak256(),
+		tasks:       make(map[common.Hash]*stateTask),
+		beam:        make(chan *beamReq),
+		beamTasks:   make(map[common.Hash]*stateTask),
+		beamPending: make(map[common.Hash][]*beamReq),
+		deliver:     make(chan *stateReq),
+		cancel:      make(chan struct{}),
+		done:        make(chan struct{}),
 	}
 }
 
@@ -294,6 +301,10 @@ func (s *stateSync) loop() error {
 		case <-s.cancel:
 			return errCancelStateFetch
 
+		case req := <-s.beam:
+			// On-demand request from beam sync block processing, schedule it first
+			s.scheduleBeam(req)
+
 		case req := <-s.deliver:
 			// Response, disconnect or timeout triggered, drop the peer if stalling
 			log.Trace("Received node data response", "peer", req.peer.id, "count", len(req.response), "dropped", req.dropped, "timeout", !req.dropped && req.timedOut())
@@ -367,24 +378,60 @@ func (s *stateSync) fillTasks(n int, req *stateReq) {
 			s.tasks[hash] = &stateTask{make(map[string]struct{})}
 		}
 	}
-	// Find tasks that haven't been tried with the request's peer.
+	// Find tasks that haven't been tried with the request's peer, serving the
+	// on-demand ones blocking beam sync first.
 	req.items = make([]common.Hash, 0, n)
 	req.tasks = make(map[common.Hash]*stateTask, n)
-	for hash, t := range s.tasks {
-		// Stop when we've gathered enough requests
-		if len(req.items) == n {
-			break
+	for _, tasks := range []map[common.Hash]*stateTask{s.beamTasks, s.tasks} {
+		for hash, t := range tasks {
+			// Stop when we've gathered enough requests
+			if len(req.items) == n {
+				break
+			}
+			// Skip any requests we've already tried from this peer
+			if _, ok := t.attempts[req.peer.id]; ok {
+				continue
+			}
+			// Assign the request to this peer
+			t.attempts[req.peer.id] = struct{}{}
+			req.items = append(req.items, hash)
+			req.tasks[hash] = t
+			delete(tasks, hash)
 		}
-		// Skip any requests we've already tried from this peer
-		if _, ok := t.attempts[req.peer.id]; ok {
-			continue
+	}
+}
+
+// scheduleBeam queues an on-demand state request of beam sync for retrieval,
+// answering it right away if the entry is already in the database.
+func (s *stateSync) scheduleBeam(req *beamReq) {
+	if blob, err := s.d.stateDB.Get(req.hash[:]); err == nil {
+		req.result <- blob
+		return
+	}
+	if _, ok := s.beamPending[req.hash]; !ok {
+		task := s.tasks[req.hash]
+		if task == nil {
+			task = &stateTask{make(map[string]struct{})}
 		}
-		// Assign the request to this peer
-		t.attempts[req.peer.id] = struct{}{}
-		req.items = append(req.items, hash)
-		req.tasks[hash] = t
-		delete(s.tasks, hash)
+		delete(s.tasks, req.hash)
+		s.beamTasks[req.hash] = task
+	}
+	s.beamPending[req.hash] = append(s.beamPending[req.hash], req)
+}
+
+// deliverBeam hands a delivered state entry to the on-demand requests waiting
+// for it, returning whether there were any.
+func (s *stateSync) deliverBeam(hash common.Hash, blob []byte) bool {
+	reqs, ok := s.beamPending[hash]
+	if !ok {
+		return false
 	}
+	for _, req := range reqs {
+		req.result <- blob
+	}
+	delete(s.beamPending, hash)
+	delete(s.beamTasks, hash)
+	return true
 }
 
 // process iterates over a batch of delivered state data, injecting each item
@@ -405,13 +452,17 @@ func (s *stateSync) process(req *stateReq) (bool, error) {
 
 	for _, blob := range req.response {
 		prog, hash, err := s.processNodeData(blob)
+		beam := s.deliverBeam(hash, blob)
+
 		switch err {
 		case nil:
 			s.numUncommitted++
 			s.bytesUncommitted += len(blob)
 			progress = progress || prog
 		case trie.ErrNotRequested:
-			unexpected++
+			if !beam {
+				unexpected++
+			}
 		case trie.ErrAlreadyProcessed:
 			duplicate++
 		default:
@@ -444,7 +495,11 @@ func (s *stateSync) process(req *stateReq) (bool, error) {
 			return stale, fmt.Errorf("state node %s failed with all peers (%d tries, %d peers)", hash.TerminalString(), len(task.attempts), npeers)
 		}
 		// Missing item, place into the retry queue.
-		s.tasks[hash] = task
+		if _, ok := s.beamPending[hash]; ok {
+			s.beamTasks[hash] = task
+		} else {
+			s.tasks[hash] = task
+		}
 	}
 	return stale, nil
 }
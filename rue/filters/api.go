@@ -28,6 +28,7 @@ import (
 	"github.com/Rue-Foundation/go-rue/common"
 	"github.com/Rue-Foundation/go-rue/common/hexutil"
 	"github.com/Rue-Foundation/go-rue/core/types"
+	"github.com/Rue-Foundation/go-rue/internal/rueapi"
 	"github.com/Rue-Foundation/go-rue/ruedb"
 	"github.com/Rue-Foundation/go-rue/event"
 	"github.com/Rue-Foundation/go-rue/rpc"
@@ -134,12 +135,17 @@ func (api *PublicFilterAPI) NewPendingTransactionFilter() rpc.ID {
 
 // NewPendingTransactions creates a subscription that is triggered each time a transaction
 // enters the transaction pool and was signed from one of the transactions this nodes manages.
-func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+//
+// If criteria are given, only transactions matching the sender, recipient and method
+// selector restrictions are reported, either by hash or as full transaction objects.
+func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context, crit *PendingTxCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
-
+	if crit != nil {
+		return api.newFilteredPendingTransactions(notifier, *crit), nil
+	}
 	rpcSub := notifier.CreateSubscription()
 
 	go func() {
@@ -163,6 +169,37 @@ func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Su
 	return rpcSub, nil
 }
 
+// newFilteredPendingTransactions creates a subscription that reports the pending
+// transactions matching the given criteria, avoiding a follow up lookup by hash
+// when full transactions are requested.
+func (api *PublicFilterAPI) newFilteredPendingTransactions(notifier *rpc.Notifier, crit PendingTxCriteria) *rpc.Subscription {
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		txs := make(chan *types.Transaction)
+		pendingTxSub := api.events.SubscribePendingTxs(crit, txs)
+
+		for {
+			select {
+			case tx := <-txs:
+				if crit.FullTx {
+					notifier.Notify(rpcSub.ID, rueapi.NewRPCPendingTransaction(tx))
+				} else {
+					notifier.Notify(rpcSub.ID, tx.Hash())
+				}
+			case <-rpcSub.Err():
+				pendingTxSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				pendingTxSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub
+}
+
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
 // It is part of the filter package since polling goes with eth_getFilterChanges.
 //
@@ -274,6 +311,14 @@ type FilterCriteria struct {
 	Topics    [][]common.Hash
 }
 
+// PendingTxCriteria represents a request to stream pending transactions.
+type PendingTxCriteria struct {
+	FullTx  bool             // Report full transactions instead of hashes
+	From    []common.Address // Restricts matches to transactions signed by these accounts
+	To      []common.Address // Restricts matches to transactions sent to these accounts
+	Methods [][4]byte        // Restricts matches to calls starting with these method selectors
+}
+
 // NewFilter creates a new filter and returns the filter id. It can be
 // used to retrieve logs when the state changes. This method cannot be
 // used to fetch logs that are already stored in the state.
@@ -534,6 +579,31 @@ func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// UnmarshalJSON sets *args fields with given data.
+func (args *PendingTxCriteria) UnmarshalJSON(data []byte) error {
+	type input struct {
+		FullTx  bool             `json:"fullTx"`
+		From    []common.Address `json:"from"`
+		To      []common.Address `json:"to"`
+		Methods []hexutil.Bytes  `json:"methods"`
+	}
+
+	var raw input
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	args.FullTx, args.From, args.To = raw.FullTx, raw.From, raw.To
+
+	args.Methods = make([][4]byte, len(raw.Methods))
+	for i, method := range raw.Methods {
+		if len(method) != 4 {
+			return fmt.Errorf("invalid method selector at index %d: hex has invalid length %d after decoding", i, len(method))
+		}
+		copy(args.Methods[i][:], method)
+	}
+	return nil
+}
+
 func decodeAddress(s string) (common.Address, error) {
 	b, err := hexutil.Decode(s)
 	if err == nil && len(b) != common.AddressLength {
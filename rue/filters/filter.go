@@ -262,6 +262,45 @@ Logs:
 	return ret
 }
 
+// filterTx reports whether the given transaction matches the sender, recipient
+// and method selector criteria. Empty criteria match any transaction.
+func filterTx(tx *types.Transaction, from, to []common.Address, methods [][4]byte) bool {
+	if len(from) > 0 {
+		var signer types.Signer = types.FrontierSigner{}
+		if tx.Protected() {
+			signer = types.NewEIP155Signer(tx.ChainId())
+		}
+		sender, err := types.Sender(signer, tx)
+		if err != nil || !includes(from, sender) {
+			return false
+		}
+	}
+	if len(to) > 0 && (tx.To() == nil || !includes(to, *tx.To())) {
+		return false
+	}
+	if len(methods) > 0 {
+		data := tx.Data()
+		if len(data) < 4 {
+			return false
+		}
+		var (
+			selector [4]byte
+			included bool
+		)
+		copy(selector[:], data)
+		for _, method := range methods {
+			if method == selector {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	return true
+}
+
 func bloomFilter(bloom types.Bloom, addresses []common.Address, topics [][]common.Hash) bool {
 	if len(addresses) > 0 {
 		var included bool
@@ -50,6 +50,9 @@ const (
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// PendingTransactionBodiesSubscription queries full transactions entering
+	// the pending state that match a set of sender, recipient and method criteria
+	PendingTransactionBodiesSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -76,8 +79,10 @@ type subscription struct {
 	typ       Type
 	created   time.Time
 	logsCrit  FilterCriteria
+	txsCrit   PendingTxCriteria
 	logs      chan []*types.Log
 	hashes    chan common.Hash
+	txs       chan *types.Transaction
 	headers   chan *types.Header
 	installed chan struct{} // closed when the filter is installed
 	err       chan error    // closed when the filter is uninstalled
@@ -141,6 +146,7 @@ func (sub *Subscription) Unsubscribe() {
 				break uninstallLoop
 			case <-sub.f.logs:
 			case <-sub.f.hashes:
+			case <-sub.f.txs:
 			case <-sub.f.headers:
 			}
 		}
@@ -208,6 +214,7 @@ func (es *EventSystem) subscribeMinedPendingLogs(crit FilterCriteria, logs chan
 		created:   time.Now(),
 		logs:      logs,
 		hashes:    make(chan common.Hash),
+		txs:       make(chan *types.Transaction),
 		headers:   make(chan *types.Header),
 		installed: make(chan struct{}),
 		err:       make(chan error),
@@ -225,6 +232,7 @@ func (es *EventSystem) subscribeLogs(crit FilterCriteria, logs chan []*types.Log
 		created:   time.Now(),
 		logs:      logs,
 		hashes:    make(chan common.Hash),
+		txs:       make(chan *types.Transaction),
 		headers:   make(chan *types.Header),
 		installed: make(chan struct{}),
 		err:       make(chan error),
@@ -242,6 +250,7 @@ func (es *EventSystem) subscribePendingLogs(crit FilterCriteria, logs chan []*ty
 		created:   time.Now(),
 		logs:      logs,
 		hashes:    make(chan common.Hash),
+		txs:       make(chan *types.Transaction),
 		headers:   make(chan *types.Header),
 		installed: make(chan struct{}),
 		err:       make(chan error),
@@ -258,6 +267,7 @@ func (es *EventSystem) SubscribeNewHeads(headers chan *types.Header) *Subscripti
 		created:   time.Now(),
 		logs:      make(chan []*types.Log),
 		hashes:    make(chan common.Hash),
+		txs:       make(chan *types.Transaction),
 		headers:   headers,
 		installed: make(chan struct{}),
 		err:       make(chan error),
@@ -274,6 +284,25 @@ func (es *EventSystem) SubscribePendingTxEvents(hashes chan common.Hash) *Subscr
 		created:   time.Now(),
 		logs:      make(chan []*types.Log),
 		hashes:    hashes,
+		txs:       make(chan *types.Transaction),
+		headers:   make(chan *types.Header),
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
+// SubscribePendingTxs creates a subscription that writes transactions entering
+// the transaction pool that match the given criteria.
+func (es *EventSystem) SubscribePendingTxs(crit PendingTxCriteria, txs chan *types.Transaction) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       PendingTransactionBodiesSubscription,
+		txsCrit:   crit,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan common.Hash),
+		txs:       txs,
 		headers:   make(chan *types.Header),
 		installed: make(chan struct{}),
 		err:       make(chan error),
@@ -319,6 +348,11 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 		for _, f := range filters[PendingTransactionsSubscription] {
 			f.hashes <- e.Tx.Hash()
 		}
+		for _, f := range filters[PendingTransactionBodiesSubscription] {
+			if filterTx(e.Tx, f.txsCrit.From, f.txsCrit.To, f.txsCrit.Methods) {
+				f.txs <- e.Tx
+			}
+		}
 	case core.ChainEvent:
 		for _, f := range filters[BlocksSubscription] {
 			f.headers <- e.Block.Header()
@@ -18,6 +18,7 @@ package filters
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"fmt"
 	"math/big"
 	"math/rand"
@@ -30,6 +31,7 @@ import (
 	"github.com/Rue-Foundation/go-rue/core"
 	"github.com/Rue-Foundation/go-rue/core/bloombits"
 	"github.com/Rue-Foundation/go-rue/core/types"
+	"github.com/Rue-Foundation/go-rue/crypto"
 	"github.com/Rue-Foundation/go-rue/ruedb"
 	"github.com/Rue-Foundation/go-rue/event"
 	"github.com/Rue-Foundation/go-rue/params"
@@ -244,6 +246,101 @@ func TestPendingTxFilter(t *testing.T) {
 	}
 }
 
+// TestPendingTxSubscription tests whether pending transaction subscriptions
+// deliver full transactions matching the requested sender, recipient and method
+// selector criteria.
+func TestPendingTxSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mux        = new(event.TypeMux)
+		db, _      = ruedb.NewMemDatabase()
+		txFeed     = new(event.Feed)
+		rmLogsFeed = new(event.Feed)
+		logsFeed   = new(event.Feed)
+		chainFeed  = new(event.Feed)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		api        = NewPublicFilterAPI(backend, false)
+
+		key1, _  = crypto.GenerateKey()
+		key2, _  = crypto.GenerateKey()
+		addr1    = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2    = crypto.PubkeyToAddress(key2.PublicKey)
+		contract = common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268")
+		other    = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		selector = [4]byte{0xa9, 0x05, 0x9c, 0xbb}
+		signer   = types.HorizonSigner{}
+	)
+	sign := func(key *ecdsa.PrivateKey, nonce uint64, to common.Address, data []byte) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, to, new(big.Int), big.NewInt(100000), new(big.Int), data), signer, key)
+		return tx
+	}
+	transactions := []*types.Transaction{
+		sign(key1, 0, contract, append(selector[:], 0x01)), // match
+		sign(key2, 0, contract, append(selector[:], 0x02)), // wrong sender
+		sign(key1, 1, other, append(selector[:], 0x03)),    // wrong recipient
+		sign(key1, 2, contract, []byte{0x01, 0x02, 0x03}),  // selector too short
+		sign(key1, 3, contract, []byte{0, 0, 0, 0}),        // wrong selector
+		sign(key1, 4, contract, selector[:]),               // match
+	}
+	testCases := []struct {
+		crit     PendingTxCriteria
+		expected []*types.Transaction
+	}{
+		// match all
+		{PendingTxCriteria{}, transactions},
+		// match sender
+		{PendingTxCriteria{From: []common.Address{addr2}}, []*types.Transaction{transactions[1]}},
+		// match sender, recipient and method
+		{
+			PendingTxCriteria{From: []common.Address{addr1}, To: []common.Address{contract}, Methods: [][4]byte{selector}},
+			[]*types.Transaction{transactions[0], transactions[5]},
+		},
+		// match nothing
+		{PendingTxCriteria{To: []common.Address{addr1}}, nil},
+	}
+
+	var (
+		chans = make([]chan *types.Transaction, len(testCases))
+		subs  = make([]*Subscription, len(testCases))
+	)
+	for i, tc := range testCases {
+		chans[i] = make(chan *types.Transaction, len(transactions))
+		subs[i] = api.events.SubscribePendingTxs(tc.crit, chans[i])
+	}
+	go func() {
+		for _, tx := range transactions {
+			txFeed.Send(core.TxPreEvent{Tx: tx})
+		}
+	}()
+
+	for i, tc := range testCases {
+		var fetched []*types.Transaction
+		timeout := time.After(1 * time.Second)
+	fetch:
+		for len(fetched) < len(tc.expected) {
+			select {
+			case tx := <-chans[i]:
+				fetched = append(fetched, tx)
+			case <-timeout:
+				break fetch
+			}
+		}
+		if len(fetched) != len(tc.expected) {
+			t.Errorf("test %d: invalid number of transactions, have %d, want %d", i, len(fetched), len(tc.expected))
+			continue
+		}
+		for j := range fetched {
+			if fetched[j].Hash() != tc.expected[j].Hash() {
+				t.Errorf("test %d: transaction %d mismatch, have %x, want %x", i, j, fetched[j].Hash(), tc.expected[j].Hash())
+			}
+		}
+	}
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+}
+
 // TestLogFilterCreation test whether a given filter criteria makes sense.
 // If not it must return an error.
 func TestLogFilterCreation(t *testing.T) {
@@ -9,6 +9,9 @@ import (
 	"github.com/Rue-Foundation/go-rue/common/hexutil"
 	"github.com/Rue-Foundation/go-rue/consensus/ruehash"
 	"github.com/Rue-Foundation/go-rue/core"
+	"github.com/Rue-Foundation/go-rue/light"
+	"github.com/Rue-Foundation/go-rue/miner"
+	"github.com/Rue-Foundation/go-rue/params"
 	"github.com/Rue-Foundation/go-rue/rue/downloader"
 	"github.com/Rue-Foundation/go-rue/rue/gasprice"
 )
@@ -18,8 +21,12 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               uint64
 		SyncMode                downloader.SyncMode
+		SyncCheckpoint          *params.SyncCheckpoint `toml:",omitempty"`
 		LightServ               int  `toml:",omitempty"`
 		LightPeers              int  `toml:",omitempty"`
+		LightCheckpoint         *light.TrustedCheckpoint `toml:",omitempty"`
+		UltraLightServers       []string                 `toml:",omitempty"`
+		UltraLightThreshold     int                      `toml:",omitempty"`
 		MaxPeers                int  `toml:"-"`
 		SkipBcVersionCheck      bool `toml:"-"`
 		DatabaseHandles         int  `toml:"-"`
@@ -28,6 +35,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		MinerThreads            int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
+		MinerOrdering           string                `toml:",omitempty"`
+		MinerInclusion          miner.InclusionConfig `toml:",omitempty"`
+		Stratum                 miner.StratumConfig   `toml:",omitempty"`
 		RuehashCacheDir          string
 		RuehashCachesInMem       int
 		RuehashCachesOnDisk      int
@@ -44,8 +54,12 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.Genesis = c.Genesis
 	enc.NetworkId = c.NetworkId
 	enc.SyncMode = c.SyncMode
+	enc.SyncCheckpoint = c.SyncCheckpoint
 	enc.LightServ = c.LightServ
 	enc.LightPeers = c.LightPeers
+	enc.LightCheckpoint = c.LightCheckpoint
+	enc.UltraLightServers = c.UltraLightServers
+	enc.UltraLightThreshold = c.UltraLightThreshold
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
@@ -53,6 +67,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.MinerThreads = c.MinerThreads
 	enc.ExtraData = c.ExtraData
 	enc.GasPrice = c.GasPrice
+	enc.MinerOrdering = c.MinerOrdering
+	enc.MinerInclusion = c.MinerInclusion
+	enc.Stratum = c.Stratum
 	enc.RuehashCacheDir = c.Ruehash.CacheDir
 	enc.RuehashCachesInMem = c.Ruehash.CachesInMem
 	enc.RuehashCachesOnDisk = c.Ruehash.CachesOnDisk
@@ -72,8 +89,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               *uint64
 		SyncMode                *downloader.SyncMode
+		SyncCheckpoint          *params.SyncCheckpoint `toml:",omitempty"`
 		LightServ               *int  `toml:",omitempty"`
 		LightPeers              *int  `toml:",omitempty"`
+		LightCheckpoint         *light.TrustedCheckpoint `toml:",omitempty"`
+		UltraLightServers       []string                 `toml:",omitempty"`
+		UltraLightThreshold     *int                     `toml:",omitempty"`
 		MaxPeers                *int  `toml:"-"`
 		SkipBcVersionCheck      *bool `toml:"-"`
 		DatabaseHandles         *int  `toml:"-"`
@@ -82,6 +103,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		MinerThreads            *int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes   `toml:",omitempty"`
 		GasPrice                *big.Int
+		MinerOrdering           *string                `toml:",omitempty"`
+		MinerInclusion          *miner.InclusionConfig `toml:",omitempty"`
+		Stratum                 *miner.StratumConfig   `toml:",omitempty"`
 		RuehashCacheDir          *string
 		RuehashCachesInMem       *int
 		RuehashCachesOnDisk      *int
@@ -107,12 +131,24 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.SyncMode != nil {
 		c.SyncMode = *dec.SyncMode
 	}
+	if dec.SyncCheckpoint != nil {
+		c.SyncCheckpoint = dec.SyncCheckpoint
+	}
 	if dec.LightServ != nil {
 		c.LightServ = *dec.LightServ
 	}
 	if dec.LightPeers != nil {
 		c.LightPeers = *dec.LightPeers
 	}
+	if dec.LightCheckpoint != nil {
+		c.LightCheckpoint = dec.LightCheckpoint
+	}
+	if dec.UltraLightServers != nil {
+		c.UltraLightServers = dec.UltraLightServers
+	}
+	if dec.UltraLightThreshold != nil {
+		c.UltraLightThreshold = *dec.UltraLightThreshold
+	}
 	if dec.SkipBcVersionCheck != nil {
 		c.SkipBcVersionCheck = *dec.SkipBcVersionCheck
 	}
@@ -134,6 +170,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.GasPrice != nil {
 		c.GasPrice = dec.GasPrice
 	}
+	if dec.MinerOrdering != nil {
+		c.MinerOrdering = *dec.MinerOrdering
+	}
+	if dec.MinerInclusion != nil {
+		c.MinerInclusion = *dec.MinerInclusion
+	}
+	if dec.Stratum != nil {
+		c.Stratum = *dec.Stratum
+	}
 	if dec.RuehashCacheDir != nil {
 		c.Ruehash.CacheDir = *dec.RuehashCacheDir
 	}
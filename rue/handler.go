@@ -30,6 +30,7 @@ import (
 	"github.com/Rue-Foundation/go-rue/consensus"
 	"github.com/Rue-Foundation/go-rue/consensus/misc"
 	"github.com/Rue-Foundation/go-rue/core"
+	"github.com/Rue-Foundation/go-rue/core/forkid"
 	"github.com/Rue-Foundation/go-rue/core/types"
 	"github.com/Rue-Foundation/go-rue/rue/downloader"
 	"github.com/Rue-Foundation/go-rue/rue/fetcher"
@@ -38,6 +39,7 @@ import (
 	"github.com/Rue-Foundation/go-rue/log"
 	"github.com/Rue-Foundation/go-rue/p2p"
 	"github.com/Rue-Foundation/go-rue/p2p/discover"
+	"github.com/Rue-Foundation/go-rue/p2p/discv5"
 	"github.com/Rue-Foundation/go-rue/params"
 	"github.com/Rue-Foundation/go-rue/rlp"
 )
@@ -59,20 +61,33 @@ var (
 // not compatible (low protocol version restrictions and high requirements).
 var errIncompatibleConfig = errors.New("incompatible configuration")
 
+// codeError is an error carrying one of the protocol error codes. It is
+// returned if the remote peer failed the handshake or sent an invalid message.
+type codeError struct {
+	code errCode
+	msg  string
+}
+
+func (e *codeError) Error() string {
+	return fmt.Sprintf("%v - %v", e.code, e.msg)
+}
+
 func errResp(code errCode, format string, v ...interface{}) error {
-	return fmt.Errorf("%v - %v", code, fmt.Sprintf(format, v...))
+	return &codeError{code, fmt.Sprintf(format, v...)}
 }
 
 type ProtocolManager struct {
 	networkId uint64
 
 	fastSync  uint32 // Flag whether fast sync is enabled (gets disabled if we already have blocks)
+	beamSync  bool   // Flag whether fast sync retrieves the pivot state on demand (beam sync)
 	acceptTxs uint32 // Flag whether we're considered synchronised (enables transaction processing)
 
 	txpool      txPool
 	blockchain  *core.BlockChain
 	chaindb     ruedb.Database
 	chainconfig *params.ChainConfig
+	forkFilter  forkid.Filter // Fork ID filter, constant across the lifetime of the node
 	maxPeers    int
 
 	downloader *downloader.Downloader
@@ -80,10 +95,13 @@ type ProtocolManager struct {
 	peers      *peerSet
 
 	SubProtocols []p2p.Protocol
+	topic        discv5.Topic // discovery v5 topic of the network
 
 	eventMux      *event.TypeMux
 	txCh          chan core.TxPreEvent
 	txSub         event.Subscription
+	releaseCh     chan core.PrivateTxReleaseEvent
+	releaseSub    event.Subscription
 	minedBlockSub *event.TypeMuxSubscription
 
 	// channels for fetcher, syncer, txsyncLoop
@@ -99,7 +117,7 @@ type ProtocolManager struct {
 
 // NewProtocolManager returns a new ruereum sub protocol manager. The Ruereum sub protocol manages peers capable
 // with the ruereum network.
-func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, networkId uint64, mux *event.TypeMux, txpool txPool, engine consensus.Engine, blockchain *core.BlockChain, chaindb ruedb.Database) (*ProtocolManager, error) {
+func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, checkpoint *params.SyncCheckpoint, networkId uint64, mux *event.TypeMux, txpool txPool, engine consensus.Engine, blockchain *core.BlockChain, chaindb ruedb.Database) (*ProtocolManager, error) {
 	// Create the protocol manager with the base fields
 	manager := &ProtocolManager{
 		networkId:   networkId,
@@ -108,25 +126,31 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		blockchain:  blockchain,
 		chaindb:     chaindb,
 		chainconfig: config,
+		forkFilter: forkid.NewFilter(config, blockchain.Genesis().Hash(), func() uint64 {
+			return blockchain.CurrentHeader().Number.Uint64()
+		}),
 		peers:       newPeerSet(),
 		newPeerCh:   make(chan *peer),
 		noMorePeers: make(chan struct{}),
 		txsyncCh:    make(chan *txsync),
 		quitSync:    make(chan struct{}),
 	}
+	manager.topic = rueTopic(blockchain.Genesis().Hash(), networkId)
+
 	// Figure out whether to allow fast sync or not
-	if mode == downloader.FastSync && blockchain.CurrentBlock().NumberU64() > 0 {
+	manager.beamSync = mode == downloader.BeamSync
+	if (mode == downloader.FastSync || mode == downloader.BeamSync) && blockchain.CurrentBlock().NumberU64() > 0 {
 		log.Warn("Blockchain not empty, fast sync disabled")
 		mode = downloader.FullSync
 	}
-	if mode == downloader.FastSync {
+	if mode == downloader.FastSync || mode == downloader.BeamSync {
 		manager.fastSync = uint32(1)
 	}
 	// Initiate a sub-protocol for every implemented version we can handle
 	manager.SubProtocols = make([]p2p.Protocol, 0, len(ProtocolVersions))
 	for i, version := range ProtocolVersions {
 		// Skip protocol version if incompatible with the mode of operation
-		if mode == downloader.FastSync && version < eth63 {
+		if (mode == downloader.FastSync || mode == downloader.BeamSync) && version < eth63 {
 			continue
 		}
 		// Compatible; initialise the sub-protocol
@@ -135,6 +159,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 			Name:    ProtocolName,
 			Version: version,
 			Length:  ProtocolLengths[i],
+			Topic:   manager.topic,
 			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 				peer := manager.newPeer(int(version), p, rw)
 				select {
@@ -161,7 +186,12 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		return nil, errIncompatibleConfig
 	}
 	// Construct the different synchronisation mechanisms
-	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
+	manager.downloader = downloader.New(mode, checkpoint, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
+	if manager.beamSync {
+		// Keep retrieving missing state on demand even after a restart, while
+		// the beam sync state download resumes in the background
+		blockchain.SetStateFetcher(manager.downloader)
+	}
 
 	validator := func(header *types.Header) error {
 		return engine.VerifyHeader(blockchain, header, true)
@@ -178,7 +208,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		atomic.StoreUint32(&manager.acceptTxs, 1) // Mark initial sync done on any fetcher import
 		return manager.blockchain.InsertChain(blocks)
 	}
-	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.removePeer)
+	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.dropBadPeer)
 
 	return manager, nil
 }
@@ -202,12 +232,23 @@ func (pm *ProtocolManager) removePeer(id string) {
 	}
 }
 
+// dropBadPeer disconnects a peer which relayed an invalid block, also reporting
+// it to the reputation store of the p2p server.
+func (pm *ProtocolManager) dropBadPeer(id string) {
+	if peer := pm.peers.Peer(id); peer != nil {
+		peer.Penalize(p2p.OffenceSevere, "invalid block")
+	}
+	pm.removePeer(id)
+}
+
 func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.maxPeers = maxPeers
 
 	// broadcast transactions
 	pm.txCh = make(chan core.TxPreEvent, txChanSize)
 	pm.txSub = pm.txpool.SubscribeTxPreEvent(pm.txCh)
+	pm.releaseCh = make(chan core.PrivateTxReleaseEvent, txChanSize)
+	pm.releaseSub = pm.txpool.SubscribePrivateTxReleaseEvent(pm.releaseCh)
 	go pm.txBroadcastLoop()
 
 	// broadcast mined blocks
@@ -219,10 +260,23 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	go pm.txsyncLoop()
 }
 
+// advertise registers the topic of the network with discovery v5 until the
+// protocol manager is stopped.
+func (pm *ProtocolManager) advertise(net *discv5.Network) {
+	go func() {
+		logger := log.New("topic", pm.topic)
+		logger.Info("Starting topic registration")
+		defer logger.Info("Terminated topic registration")
+
+		net.RegisterTopic(pm.topic, pm.quitSync)
+	}()
+}
+
 func (pm *ProtocolManager) Stop() {
 	log.Info("Stopping Ruereum protocol")
 
 	pm.txSub.Unsubscribe()         // quits txBroadcastLoop
+	pm.releaseSub.Unsubscribe()
 	pm.minedBlockSub.Unsubscribe() // quits blockBroadcastLoop
 
 	// Quit the sync loop.
@@ -257,8 +311,12 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	p.Log().Debug("Ruereum peer connected", "name", p.Name())
 
 	// Execute the Ruereum handshake
-	td, head, genesis := pm.blockchain.Status()
-	if err := p.Handshake(pm.networkId, td, head, genesis); err != nil {
+	var (
+		number            = pm.blockchain.CurrentHeader().Number.Uint64()
+		td, head, genesis = pm.blockchain.Status()
+		forkID            = forkid.NewID(pm.chainconfig, genesis, number)
+	)
+	if err := p.Handshake(pm.networkId, td, head, genesis, forkID, pm.forkFilter); err != nil {
 		p.Log().Debug("Ruereum handshake failed", "err", err)
 		return err
 	}
@@ -303,6 +361,9 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	for {
 		if err := pm.handleMsg(p); err != nil {
 			p.Log().Debug("Ruereum message handling failed", "err", err)
+			if err, ok := err.(*codeError); ok {
+				p.Penalize(p2p.OffenceMajor, err.Error())
+			}
 			return err
 		}
 	}
@@ -666,7 +727,12 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 			p.MarkTransaction(tx.Hash())
 		}
-		pm.txpool.AddRemotes(txs)
+		for _, err := range pm.txpool.AddRemotes(txs) {
+			if err == core.ErrInvalidSender {
+				p.Penalize(p2p.OffenceMinor, "invalid transaction signature")
+				break
+			}
+		}
 
 	default:
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
@@ -735,6 +801,13 @@ func (self *ProtocolManager) txBroadcastLoop() {
 	for {
 		select {
 		case event := <-self.txCh:
+			// Private transactions are only broadcast after being released
+			if self.txpool.IsPrivate(event.Tx.Hash()) {
+				continue
+			}
+			self.BroadcastTx(event.Tx.Hash(), event.Tx)
+
+		case event := <-self.releaseCh:
 			self.BroadcastTx(event.Tx.Hash(), event.Tx)
 
 		// Err() channel will be closed when unsubscribing.
@@ -23,10 +23,13 @@ import (
 	"time"
 
 	"github.com/Rue-Foundation/go-rue/common"
+	"github.com/Rue-Foundation/go-rue/core"
+	"github.com/Rue-Foundation/go-rue/core/forkid"
 	"github.com/Rue-Foundation/go-rue/core/types"
 	"github.com/Rue-Foundation/go-rue/crypto"
 	"github.com/Rue-Foundation/go-rue/rue/downloader"
 	"github.com/Rue-Foundation/go-rue/p2p"
+	"github.com/Rue-Foundation/go-rue/p2p/discv5"
 	"github.com/Rue-Foundation/go-rue/rlp"
 )
 
@@ -55,15 +58,15 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 			wantError: errResp(ErrNoStatusMsg, "first msg has code 2 (!= 0)"),
 		},
 		{
-			code: StatusMsg, data: statusData{10, DefaultConfig.NetworkId, td, currentBlock, genesis},
+			code: StatusMsg, data: statusData63{10, DefaultConfig.NetworkId, td, currentBlock, genesis},
 			wantError: errResp(ErrProtocolVersionMismatch, "10 (!= %d)", protocol),
 		},
 		{
-			code: StatusMsg, data: statusData{uint32(protocol), 999, td, currentBlock, genesis},
+			code: StatusMsg, data: statusData63{uint32(protocol), 999, td, currentBlock, genesis},
 			wantError: errResp(ErrNetworkIdMismatch, "999 (!= 1)"),
 		},
 		{
-			code: StatusMsg, data: statusData{uint32(protocol), DefaultConfig.NetworkId, td, currentBlock, common.Hash{3}},
+			code: StatusMsg, data: statusData63{uint32(protocol), DefaultConfig.NetworkId, td, currentBlock, common.Hash{3}},
 			wantError: errResp(ErrGenesisBlockMismatch, "0300000000000000 (!= %x)", genesis[:8]),
 		},
 	}
@@ -88,9 +91,66 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 	}
 }
 
+func TestStatusMsgErrors64(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	var (
+		genesis = pm.blockchain.Genesis()
+		head    = pm.blockchain.CurrentHeader()
+		td      = pm.blockchain.GetTd(head.Hash(), head.Number.Uint64())
+		forkID  = forkid.NewID(pm.chainconfig, genesis.Hash(), head.Number.Uint64())
+	)
+	defer pm.Stop()
+
+	tests := []struct {
+		code      uint64
+		data      interface{}
+		wantError error
+	}{
+		{
+			code: TxMsg, data: []interface{}{},
+			wantError: errResp(ErrNoStatusMsg, "first msg has code 2 (!= 0)"),
+		},
+		{
+			code: StatusMsg, data: statusData{10, DefaultConfig.NetworkId, td, head.Hash(), genesis.Hash(), forkID},
+			wantError: errResp(ErrProtocolVersionMismatch, "10 (!= %d)", eth64),
+		},
+		{
+			code: StatusMsg, data: statusData{uint32(eth64), 999, td, head.Hash(), genesis.Hash(), forkID},
+			wantError: errResp(ErrNetworkIdMismatch, "999 (!= 1)"),
+		},
+		{
+			code: StatusMsg, data: statusData{uint32(eth64), DefaultConfig.NetworkId, td, head.Hash(), common.Hash{3}, forkID},
+			wantError: errResp(ErrGenesisBlockMismatch, "0300000000000000 (!= %x)", genesis.Hash().Bytes()[:8]),
+		},
+		{
+			code: StatusMsg, data: statusData{uint32(eth64), DefaultConfig.NetworkId, td, head.Hash(), genesis.Hash(), forkid.ID{Hash: [4]byte{0x00, 0x01, 0x02, 0x03}}},
+			wantError: errResp(ErrForkIDRejected, "%v", forkid.ErrLocalIncompatibleOrStale),
+		},
+	}
+	for i, test := range tests {
+		p, errc := newTestPeer("peer", eth64, pm, false)
+		// The send call might hang until reset because
+		// the protocol might not read the payload.
+		go p2p.Send(p.app, test.code, test.data)
+
+		select {
+		case err := <-errc:
+			if err == nil {
+				t.Errorf("test %d: protocol returned nil error, want %q", i, test.wantError)
+			} else if err.Error() != test.wantError.Error() {
+				t.Errorf("test %d: wrong error: got %q, want %q", i, err, test.wantError)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("protocol did not shut down within 2 seconds")
+		}
+		p.close()
+	}
+}
+
 // This test checks that received transactions are added to the local pool.
 func TestRecvTransactions62(t *testing.T) { testRecvTransactions(t, 62) }
 func TestRecvTransactions63(t *testing.T) { testRecvTransactions(t, 63) }
+func TestRecvTransactions64(t *testing.T) { testRecvTransactions(t, 64) }
 
 func testRecvTransactions(t *testing.T, protocol int) {
 	txAdded := make(chan []*types.Transaction)
@@ -119,6 +179,7 @@ func testRecvTransactions(t *testing.T, protocol int) {
 // This test checks that pending transactions are sent.
 func TestSendTransactions62(t *testing.T) { testSendTransactions(t, 62) }
 func TestSendTransactions63(t *testing.T) { testSendTransactions(t, 63) }
+func TestSendTransactions64(t *testing.T) { testSendTransactions(t, 64) }
 
 func testSendTransactions(t *testing.T, protocol int) {
 	pm := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
@@ -174,6 +235,59 @@ func testSendTransactions(t *testing.T, protocol int) {
 	wg.Wait()
 }
 
+// Tests that private transactions are neither synced to new peers nor broadcast
+// until they are released by the pool.
+func TestPrivateTransactions(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	pool := pm.txpool.(*testTxPool)
+	var (
+		public  = newTestTransaction(testAccount, 0, 0)
+		private = newTestTransaction(testAccount, 1, 0)
+		later   = newTestTransaction(testAccount, 2, 0)
+	)
+	pool.private = map[common.Hash]bool{private.Hash(): true}
+	pool.AddRemotes([]*types.Transaction{public, private})
+
+	p, _ := newTestPeer("peer", 63, pm, true)
+	defer p.close()
+
+	// expect reads the next message of the peer, checking that it announces the
+	// given transaction only
+	expect := func(tx *types.Transaction) {
+		msg, err := p.app.ReadMsg()
+		if err != nil {
+			t.Fatalf("read error: %v", err)
+		}
+		if msg.Code != TxMsg {
+			t.Fatalf("got code %d, want TxMsg", msg.Code)
+		}
+		var txs []*types.Transaction
+		if err := msg.Decode(&txs); err != nil {
+			t.Fatalf("failed to decode transactions: %v", err)
+		}
+		if len(txs) != 1 || txs[0].Hash() != tx.Hash() {
+			t.Fatalf("transaction mismatch: have %d txs, want %x", len(txs), tx.Hash())
+		}
+	}
+	// The initial sync must skip the private transaction
+	expect(public)
+
+	// Announcements of private transactions must be skipped too, the public one
+	// sent after it must be the next message
+	pool.txFeed.Send(core.TxPreEvent{Tx: private})
+	pool.txFeed.Send(core.TxPreEvent{Tx: later})
+	expect(later)
+
+	// Once released, the private transaction must be broadcast
+	pool.lock.Lock()
+	delete(pool.private, private.Hash())
+	pool.lock.Unlock()
+	pool.releaseFeed.Send(core.PrivateTxReleaseEvent{Tx: private})
+	expect(private)
+}
+
 // Tests that the custom union field encoder and decoder works correctly.
 func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 	// Create a "random" hash for testing
@@ -217,3 +331,20 @@ func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 		}
 	}
 }
+
+// Tests that all protocol versions advertise the discovery topic of the network.
+func TestProtocolTopic(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	genesis := pm.blockchain.Genesis().Hash()
+	want := discv5.Topic(fmt.Sprintf("RUE@%x/%d", genesis[:8], DefaultConfig.NetworkId))
+	for _, proto := range pm.SubProtocols {
+		if proto.Topic != want {
+			t.Errorf("%s/%d: wrong topic: got %q, want %q", proto.Name, proto.Version, proto.Topic, want)
+		}
+	}
+	if rueTopic(genesis, DefaultConfig.NetworkId+1) == want {
+		t.Error("topic doesn't depend on the network ID")
+	}
+}
@@ -360,6 +360,26 @@ func (ec *Client) SubscribeFilterLogs(ctx context.Context, q ruereum.FilterQuery
 	return ec.c.EthSubscribe(ctx, ch, "logs", toFilterArg(q))
 }
 
+// SubscribePendingTransactions subscribes to the full bodies of transactions entering
+// the remote node's transaction pool. Only transactions matching the given query are
+// delivered, the filtering being done by the remote node.
+func (ec *Client) SubscribePendingTransactions(ctx context.Context, q ruereum.PendingTransactionQuery, ch chan<- *types.Transaction) (ruereum.Subscription, error) {
+	return ec.c.EthSubscribe(ctx, ch, "newPendingTransactions", toPendingTxArg(q))
+}
+
+func toPendingTxArg(q ruereum.PendingTransactionQuery) interface{} {
+	methods := make([]hexutil.Bytes, len(q.Methods))
+	for i := range q.Methods {
+		methods[i] = q.Methods[i][:]
+	}
+	return map[string]interface{}{
+		"fullTx":  true,
+		"from":    q.From,
+		"to":      q.To,
+		"methods": methods,
+	}
+}
+
 func toFilterArg(q ruereum.FilterQuery) interface{} {
 	arg := map[string]interface{}{
 		"fromBlock": toBlockNumArg(q.FromBlock),
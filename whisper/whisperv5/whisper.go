@@ -504,6 +504,7 @@ func (wh *Whisper) runMessageLoop(p *Peer, rw p2p.MsgReadWriter) error {
 		}
 		if packet.Size > wh.MaxMessageSize() {
 			log.Warn("oversized message received", "peer", p.peer.ID())
+			p.peer.Penalize(p2p.OffenceMajor, "oversized message")
 			return errors.New("oversized message received")
 		}
 
@@ -516,11 +517,13 @@ func (wh *Whisper) runMessageLoop(p *Peer, rw p2p.MsgReadWriter) error {
 			var envelope Envelope
 			if err := packet.Decode(&envelope); err != nil {
 				log.Warn("failed to decode envelope, peer will be disconnected", "peer", p.peer.ID(), "err", err)
+				p.peer.Penalize(p2p.OffenceMajor, "undecodable envelope")
 				return errors.New("invalid envelope")
 			}
 			cached, err := wh.add(&envelope)
 			if err != nil {
 				log.Warn("bad envelope received, peer will be disconnected", "peer", p.peer.ID(), "err", err)
+				p.peer.Penalize(p2p.OffenceMinor, "invalid envelope")
 				return errors.New("invalid envelope")
 			}
 			if cached {
@@ -548,6 +548,7 @@ func (wh *Whisper) runMessageLoop(p *Peer, rw p2p.MsgReadWriter) error {
 		}
 		if packet.Size > wh.MaxMessageSize() {
 			log.Warn("oversized message received", "peer", p.peer.ID())
+			p.peer.Penalize(p2p.OffenceMajor, "oversized message")
 			return errors.New("oversized message received")
 		}
 
@@ -560,6 +561,7 @@ func (wh *Whisper) runMessageLoop(p *Peer, rw p2p.MsgReadWriter) error {
 			var envelopes []*Envelope
 			if err := packet.Decode(&envelopes); err != nil {
 				log.Warn("failed to decode envelopes, peer will be disconnected", "peer", p.peer.ID(), "err", err)
+				p.peer.Penalize(p2p.OffenceMajor, "undecodable envelopes")
 				return errors.New("invalid envelopes")
 			}
 
@@ -576,6 +578,7 @@ func (wh *Whisper) runMessageLoop(p *Peer, rw p2p.MsgReadWriter) error {
 			}
 
 			if trouble {
+				p.peer.Penalize(p2p.OffenceMinor, "invalid envelope")
 				return errors.New("invalid envelope")
 			}
 		case powRequirementCode:
@@ -583,11 +586,13 @@ func (wh *Whisper) runMessageLoop(p *Peer, rw p2p.MsgReadWriter) error {
 			i, err := s.Uint()
 			if err != nil {
 				log.Warn("failed to decode powRequirementCode message, peer will be disconnected", "peer", p.peer.ID(), "err", err)
+				p.peer.Penalize(p2p.OffenceMajor, "undecodable PoW requirement")
 				return errors.New("invalid powRequirementCode message")
 			}
 			f := math.Float64frombits(i)
 			if math.IsInf(f, 0) || math.IsNaN(f) || f < 0.0 {
 				log.Warn("invalid value in powRequirementCode message, peer will be disconnected", "peer", p.peer.ID(), "err", err)
+				p.peer.Penalize(p2p.OffenceMajor, "invalid PoW requirement")
 				return errors.New("invalid value in powRequirementCode message")
 			}
 			p.powRequirement = f